@@ -1,18 +1,24 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"investment/internal/data/repository"
 	"investment/internal/domain/service"
+	"investment/internal/infra/auth"
 	"investment/internal/infra/db"
+	"investment/internal/infra/marketdata"
 	"investment/internal/presentation/http"
 	"investment/pkg/config"
 	"investment/pkg/logger"
 	"investment/pkg/logger/sl"
+	"investment/pkg/ratelimit"
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -32,13 +38,125 @@ func main() {
 		log.Error("Error in migration", sl.Err(err))
 	}
 
+	if cfg.UserService.URL == "" {
+		log.Error("user_service.url is required to verify access tokens")
+		os.Exit(1)
+	}
+	// Verify access tokens against the user service's signing keys and
+	// reject those of sessions signed out there
+	useJWKS(cfg, log)
+	watchRevocations(cfg.UserService, log)
+
 	repo := repository.New(postgres)
+	var priceSync *service.PriceSyncService
+	provider := newPriceProvider(cfg.MarketData, log)
+	if provider != nil {
+		priceSync = service.NewPriceSyncService(repo, provider, cfg.MarketData.BackfillDays, log)
+	}
 	service := service.New(repo)
 
+	// Portfolios traded before tax lots existed get their lots built once
+	if count, err := service.BackfillTaxLots(); err != nil {
+		log.Error("Error backfilling tax lots", sl.Err(err))
+	} else if count > 0 {
+		log.Info("Backfilled tax lots", slog.Int("positions", count))
+	}
+
+	// Keep the price history of held securities in sync with the market data provider
+	if priceSync != nil {
+		go func() {
+			ticker := time.NewTicker(cfg.MarketData.Interval)
+			defer ticker.Stop()
+			for {
+				res, err := priceSync.SyncAll(context.Background())
+				if err != nil {
+					log.Error("Error syncing prices", sl.Err(err))
+				} else {
+					log.Info("Synced prices",
+						slog.String("provider", provider.Name()),
+						slog.Int("securities", res.Securities),
+						slog.Int("failed", res.Failed),
+						slog.Int("rows", res.Rows),
+					)
+				}
+				<-ticker.C
+			}
+		}()
+	}
+
 	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.RateLimit.TrustedProxies); err != nil {
+		log.Error("Invalid trusted proxies", sl.Err(err))
+		os.Exit(1)
+	}
+	r.Use(ratelimit.Middleware(newRateLimitStore(cfg.RateLimit, postgres, log), ratelimit.Rule{
+		Name:   "api",
+		Limit:  cfg.RateLimit.Limit,
+		Window: cfg.RateLimit.Window,
+		Key:    ratelimit.ByIP,
+	}, log))
 	http.New(r, service)
 
 	if err := r.Run(fmt.Sprintf(":%d", cfg.HTTPServer.Port)); err != nil {
 		log.Error("Unable to start the server: ", sl.Err(err))
 	}
 }
+
+func newPriceProvider(cfg config.MarketData, log *slog.Logger) service.PriceProvider {
+	switch cfg.Provider {
+	case "moex":
+		return marketdata.NewMOEXProvider(cfg.MOEXURL)
+	case "csv":
+		if cfg.CSVDir == "" {
+			log.Error("CSV price provider needs a csv_dir, price sync disabled")
+			return nil
+		}
+		return marketdata.NewCSVProvider(cfg.CSVDir)
+	case "":
+	default:
+		log.Error("Unknown price provider, price sync disabled", slog.String("provider", cfg.Provider))
+	}
+	return nil
+}
+
+// useJWKS installs the user service's token verification keys. A failed
+// first fetch is retried when the next token arrives.
+func useJWKS(cfg *config.Config, log *slog.Logger) {
+	jwks := auth.NewJWKS(cfg.UserService.URL)
+	if _, err := jwks.Refresh(context.Background()); err != nil {
+		log.Error("Error fetching token verification keys", sl.Err(err))
+	}
+	auth.UseJWKS(jwks, cfg.JWT.Issuer, cfg.JWT.Audience)
+}
+
+// watchRevocations installs the user service's revocation list and keeps it
+// current in the background.
+func watchRevocations(cfg config.UserService, log *slog.Logger) {
+	revocations := auth.NewRevocationList(cfg.URL)
+	auth.UseRevocationList(revocations)
+	go func() {
+		ticker := time.NewTicker(cfg.RevocationInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := revocations.Refresh(context.Background()); err != nil {
+				log.Error("Error refreshing session revocations", sl.Err(err))
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func newRateLimitStore(cfg config.RateLimit, db *gorm.DB, log *slog.Logger) ratelimit.Store {
+	switch cfg.Store {
+	case "postgres":
+		store, err := ratelimit.NewPostgresStore(db)
+		if err == nil {
+			return store
+		}
+		log.Error("Failed to init rate limit table, counting in memory instead", sl.Err(err))
+	case "memory", "":
+	default:
+		log.Error("Unknown rate limit store, counting in memory instead", slog.String("store", cfg.Store))
+	}
+	return ratelimit.NewMemoryStore()
+}
@@ -4,6 +4,7 @@ import (
 	"investment/internal/domain/model"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -16,6 +17,14 @@ func New(db *gorm.DB) *InvestmentRepository {
 	return &InvestmentRepository{db}
 }
 
+// InTransaction runs fn with a repository bound to one database transaction,
+// committing if fn returns nil.
+func (r *InvestmentRepository) InTransaction(fn func(repo *InvestmentRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(New(tx))
+	})
+}
+
 // Broker methods
 func (r *InvestmentRepository) CreateBroker(b *model.Broker) error {
 	return r.db.Create(b).Error
@@ -50,6 +59,18 @@ func (r *InvestmentRepository) GetPortfolioByID(id uint) (*model.Portfolio, erro
 	return &portfolio, err
 }
 
+// GetPortfolioByIDForUpdate loads the portfolio and locks its row until the
+// surrounding transaction ends, serialising trades in the portfolio.
+func (r *InvestmentRepository) GetPortfolioByIDForUpdate(id uint) (*model.Portfolio, error) {
+	var portfolio model.Portfolio
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&portfolio, id).Error
+	return &portfolio, err
+}
+
+func (r *InvestmentRepository) UpdatePortfolio(p *model.Portfolio) error {
+	return r.db.Omit("Broker").Save(p).Error
+}
+
 // Security methods
 func (r *InvestmentRepository) CreateSecurity(s *model.Security) error {
 	return r.db.Create(s).Error
@@ -67,6 +88,24 @@ func (r *InvestmentRepository) GetSecurityBySymbol(symbol string) (*model.Securi
 	return &security, err
 }
 
+// GetSecuritiesByIDsOrSymbols returns the securities matching any of the IDs
+// or, case-insensitively, any of the upper-case symbols.
+func (r *InvestmentRepository) GetSecuritiesByIDsOrSymbols(ids []uint, symbols []string) ([]model.Security, error) {
+	var securities []model.Security
+	if len(ids) == 0 && len(symbols) == 0 {
+		return securities, nil
+	}
+	q := r.db.Where("1 = 0")
+	if len(ids) > 0 {
+		q = q.Or("id IN ?", ids)
+	}
+	if len(symbols) > 0 {
+		q = q.Or("UPPER(symbol) IN ?", symbols)
+	}
+	err := q.Find(&securities).Error
+	return securities, err
+}
+
 func (r *InvestmentRepository) SearchSecurities(query string, securityType model.SecurityType) ([]model.Security, error) {
 	var securities []model.Security
 	q := r.db.Where("name ILIKE ? OR symbol ILIKE ?", "%"+query+"%", "%"+query+"%")
@@ -98,17 +137,265 @@ func (r *InvestmentRepository) GetTradesByPortfolioIDPaginated(portfolioID uint,
 	}
 
 	err := r.db.Preload("Security").Where("portfolio_id = ?", portfolioID).
-		Order("trade_date DESC").Limit(limit).Offset(offset).Find(&trades).Error
+		Order("trade_date DESC, id DESC").Limit(limit).Offset(offset).Find(&trades).Error
 	return trades, count, err
 }
 
+// GetTradesByPortfolioIDAfter returns up to limit trades ordered by
+// (trade_date, id) descending, starting after the given key when afterID is non-zero.
+func (r *InvestmentRepository) GetTradesByPortfolioIDAfter(portfolioID uint, afterDate time.Time, afterID uint, limit int) ([]model.Trade, error) {
+	var trades []model.Trade
+	q := r.db.Preload("Security").Where("portfolio_id = ?", portfolioID)
+	if afterID != 0 {
+		q = q.Where("(trade_date, id) < (?, ?)", afterDate, afterID)
+	}
+	err := q.Order("trade_date DESC, id DESC").Limit(limit).Find(&trades).Error
+	return trades, err
+}
+
 func (r *InvestmentRepository) GetTradesBySecurityID(portfolioID, securityID uint) ([]model.Trade, error) {
 	var trades []model.Trade
 	err := r.db.Where("portfolio_id = ? AND security_id = ?", portfolioID, securityID).
-		Order("trade_date ASC").Find(&trades).Error
+		Order("trade_date ASC, id ASC").Find(&trades).Error
+	return trades, err
+}
+
+// GetTradesBefore returns every trade dated before the given time, oldest
+// first.
+func (r *InvestmentRepository) GetTradesBefore(portfolioID uint, before time.Time) ([]model.Trade, error) {
+	var trades []model.Trade
+	err := r.db.Where("portfolio_id = ? AND trade_date < ?", portfolioID, before).
+		Order("trade_date ASC, id ASC").Find(&trades).Error
 	return trades, err
 }
 
+// Tax lot methods
+func (r *InvestmentRepository) GetTaxLotsBySecurityID(portfolioID, securityID uint) ([]model.TaxLot, error) {
+	var lots []model.TaxLot
+	err := r.db.Where("portfolio_id = ? AND security_id = ?", portfolioID, securityID).
+		Order("acquired_at ASC, id ASC").Find(&lots).Error
+	return lots, err
+}
+
+// GetTaxLotsByPortfolioID returns the portfolio's lots, only those with a
+// remaining quantity unless includeClosed is set. securityID 0 means all.
+func (r *InvestmentRepository) GetTaxLotsByPortfolioID(portfolioID, securityID uint, includeClosed bool) ([]model.TaxLot, error) {
+	var lots []model.TaxLot
+	q := r.db.Preload("Security").Where("portfolio_id = ?", portfolioID)
+	if securityID != 0 {
+		q = q.Where("security_id = ?", securityID)
+	}
+	if !includeClosed {
+		q = q.Where("remaining > 0")
+	}
+	err := q.Order("security_id ASC, acquired_at ASC, id ASC").Find(&lots).Error
+	return lots, err
+}
+
+// SaveTaxLots inserts new lots and updates existing ones, keyed by trade.
+func (r *InvestmentRepository) SaveTaxLots(lots []*model.TaxLot) error {
+	if len(lots) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "trade_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"acquired_at", "quantity", "remaining", "cost_per_unit", "updated_at"}),
+	}).Create(lots).Error
+}
+
+func (r *InvestmentRepository) CreateLotSelections(selections []model.LotSelection) error {
+	if len(selections) == 0 {
+		return nil
+	}
+	return r.db.Create(&selections).Error
+}
+
+func (r *InvestmentRepository) GetLotSelectionsBySellTradeIDs(tradeIDs []uint) ([]model.LotSelection, error) {
+	var selections []model.LotSelection
+	if len(tradeIDs) == 0 {
+		return selections, nil
+	}
+	err := r.db.Where("sell_trade_id IN ?", tradeIDs).Order("id ASC").Find(&selections).Error
+	return selections, err
+}
+
+// ReplaceRealizedGains swaps the stored gains of one position for gains.
+func (r *InvestmentRepository) ReplaceRealizedGains(portfolioID, securityID uint, gains []model.RealizedGain) error {
+	err := r.db.Where("portfolio_id = ? AND security_id = ?", portfolioID, securityID).
+		Delete(&model.RealizedGain{}).Error
+	if err != nil || len(gains) == 0 {
+		return err
+	}
+	return r.db.Create(&gains).Error
+}
+
+// GetRealizedGains returns gains from sells in [from, to]; zero times leave
+// that side open and securityID 0 means all securities.
+func (r *InvestmentRepository) GetRealizedGains(portfolioID, securityID uint, from, to time.Time) ([]model.RealizedGain, error) {
+	var gains []model.RealizedGain
+	q := r.db.Preload("Security").Where("portfolio_id = ?", portfolioID)
+	if securityID != 0 {
+		q = q.Where("security_id = ?", securityID)
+	}
+	if !from.IsZero() {
+		q = q.Where("sold_at >= ?", from)
+	}
+	if !to.IsZero() {
+		q = q.Where("sold_at <= ?", to)
+	}
+	err := q.Order("sold_at ASC, id ASC").Find(&gains).Error
+	return gains, err
+}
+
+// Corporate action methods
+func (r *InvestmentRepository) CreateCorporateAction(a *model.CorporateAction) error {
+	return r.db.Create(a).Error
+}
+
+func (r *InvestmentRepository) GetCorporateActionByID(id uint) (*model.CorporateAction, error) {
+	var a model.CorporateAction
+	if err := r.db.First(&a, id).Error; err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *InvestmentRepository) DeleteCorporateAction(id uint) error {
+	return r.db.Delete(&model.CorporateAction{}, id).Error
+}
+
+func (r *InvestmentRepository) GetCorporateActionsBySecurityID(securityID uint) ([]model.CorporateAction, error) {
+	var actions []model.CorporateAction
+	err := r.db.Where("security_id = ?", securityID).
+		Order("effective_date ASC, id ASC").Find(&actions).Error
+	return actions, err
+}
+
+func (r *InvestmentRepository) GetSplitsBySecurityID(securityID uint) ([]model.CorporateAction, error) {
+	var actions []model.CorporateAction
+	err := r.db.Where("security_id = ? AND type IN ?", securityID,
+		[]model.CorporateActionType{model.CorporateActionSplit, model.CorporateActionReverseSplit}).
+		Order("effective_date ASC, id ASC").Find(&actions).Error
+	return actions, err
+}
+
+// GetPortfolioIDsBySecurityID lists the portfolios that traded the security.
+func (r *InvestmentRepository) GetPortfolioIDsBySecurityID(securityID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&model.Trade{}).Where("security_id = ?", securityID).
+		Distinct().Order("portfolio_id ASC").Pluck("portfolio_id", &ids).Error
+	return ids, err
+}
+
+func (r *InvestmentRepository) UpdateSecuritySymbol(securityID uint, symbol string) error {
+	return r.db.Model(&model.Security{}).Where("id = ?", securityID).Update("symbol", symbol).Error
+}
+
+// Cash event methods
+func (r *InvestmentRepository) CreateCashEvent(e *model.CashEvent) error {
+	return r.db.Create(e).Error
+}
+
+func (r *InvestmentRepository) GetCashEventByID(id uint) (*model.CashEvent, error) {
+	var event model.CashEvent
+	err := r.db.Preload("Security").First(&event, id).Error
+	return &event, err
+}
+
+// GetCashEvents returns the portfolio's events paid in [from, to]; zero
+// times leave that side open.
+func (r *InvestmentRepository) GetCashEvents(portfolioID uint, from, to time.Time) ([]model.CashEvent, error) {
+	var events []model.CashEvent
+	q := r.db.Preload("Security").Where("portfolio_id = ?", portfolioID)
+	if !from.IsZero() {
+		q = q.Where("payment_date >= ?", from)
+	}
+	if !to.IsZero() {
+		q = q.Where("payment_date <= ?", to)
+	}
+	err := q.Order("payment_date ASC, id ASC").Find(&events).Error
+	return events, err
+}
+
+func (r *InvestmentRepository) DeleteCashEvent(id uint) error {
+	return r.db.Delete(&model.CashEvent{}, id).Error
+}
+
+// Cash ledger methods
+func (r *InvestmentRepository) CreateCashEntries(entries []model.CashEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return r.db.Create(&entries).Error
+}
+
+// CashBalance is the sum of a portfolio's cash entries in one currency.
+type CashBalance struct {
+	Currency string          `json:"currency"`
+	Amount   decimal.Decimal `json:"amount"`
+}
+
+func (r *InvestmentRepository) GetCashBalances(portfolioID uint) ([]CashBalance, error) {
+	var balances []CashBalance
+	err := r.db.Model(&model.CashEntry{}).
+		Select("currency, SUM(amount) AS amount").
+		Where("portfolio_id = ?", portfolioID).
+		Group("currency").Order("currency ASC").
+		Scan(&balances).Error
+	return balances, err
+}
+
+// GetCashEntries returns ledger entries dated in [from, to], oldest first;
+// zero times leave that side open and an empty currency means all.
+func (r *InvestmentRepository) GetCashEntries(portfolioID uint, currency string, from, to time.Time) ([]model.CashEntry, error) {
+	var entries []model.CashEntry
+	q := r.db.Where("portfolio_id = ?", portfolioID)
+	if currency != "" {
+		q = q.Where("currency = ?", currency)
+	}
+	if !from.IsZero() {
+		q = q.Where("date >= ?", from)
+	}
+	if !to.IsZero() {
+		q = q.Where("date <= ?", to)
+	}
+	err := q.Order("date ASC, id ASC").Find(&entries).Error
+	return entries, err
+}
+
+func (r *InvestmentRepository) DeleteCashEntriesByCashEventID(cashEventID uint) error {
+	return r.db.Where("cash_event_id = ?", cashEventID).Delete(&model.CashEntry{}).Error
+}
+
+// HeldSecurity is a held security and the date it was first traded.
+type HeldSecurity struct {
+	model.Security
+	FirstTraded *time.Time
+}
+
+// Position identifies the trades of one security in one portfolio.
+type Position struct {
+	PortfolioID uint
+	SecurityID  uint
+}
+
+// GetPositionsWithoutLots lists positions that have trades but no tax lots,
+// i.e. ones traded before lots were tracked.
+func (r *InvestmentRepository) GetPositionsWithoutLots() ([]Position, error) {
+	var positions []Position
+	err := r.db.Raw(`
+		SELECT DISTINCT t.portfolio_id, t.security_id
+		FROM trades t
+		WHERE t.deleted_at IS NULL
+		  AND NOT EXISTS (
+			SELECT 1 FROM tax_lots l
+			WHERE l.portfolio_id = t.portfolio_id AND l.security_id = t.security_id
+		  )
+		ORDER BY t.portfolio_id, t.security_id
+	`).Scan(&positions).Error
+	return positions, err
+}
+
 // Holding methods
 func (r *InvestmentRepository) UpsertHolding(h *model.Holding) error {
 	return r.db.Clauses(clause.OnConflict{
@@ -148,6 +435,13 @@ func (r *InvestmentRepository) GetLatestPrice(securityID uint) (*model.PriceHist
 	return &price, err
 }
 
+// GetPriceAsOf returns the last price dated on or before date.
+func (r *InvestmentRepository) GetPriceAsOf(securityID uint, date time.Time) (*model.PriceHistory, error) {
+	var price model.PriceHistory
+	err := r.db.Where("security_id = ? AND date <= ?", securityID, date).Order("date DESC").First(&price).Error
+	return &price, err
+}
+
 func (r *InvestmentRepository) GetPriceHistory(securityID uint, from, to time.Time) ([]model.PriceHistory, error) {
 	var prices []model.PriceHistory
 	err := r.db.Where("security_id = ? AND date >= ? AND date <= ?", securityID, from, to).
@@ -155,6 +449,99 @@ func (r *InvestmentRepository) GetPriceHistory(securityID uint, from, to time.Ti
 	return prices, err
 }
 
+// GetPriceDateRange returns the first and last price dates of a security,
+// nil when it has no prices.
+func (r *InvestmentRepository) GetPriceDateRange(securityID uint) (*time.Time, *time.Time, error) {
+	var first, last *time.Time
+	err := r.db.Model(&model.PriceHistory{}).
+		Select("MIN(date), MAX(date)").
+		Where("security_id = ?", securityID).
+		Row().Scan(&first, &last)
+	return first, last, err
+}
+
+// PriceGap is a run of days without prices between two stored ones.
+type PriceGap struct {
+	GapStart time.Time
+	GapEnd   time.Time
+}
+
+// GetPriceGaps returns up to limit gaps, newest first, between stored prices
+// of a security that are more than minDays apart.
+func (r *InvestmentRepository) GetPriceGaps(securityID uint, minDays, limit int) ([]PriceGap, error) {
+	var gaps []PriceGap
+	err := r.db.Raw(`
+		SELECT prev + 1 AS gap_start, date - 1 AS gap_end
+		FROM (
+			SELECT date, LAG(date) OVER (ORDER BY date) AS prev
+			FROM price_histories
+			WHERE security_id = ?
+		) d
+		WHERE date - prev > ?
+		ORDER BY date DESC
+		LIMIT ?`,
+		securityID, minDays, limit,
+	).Scan(&gaps).Error
+	return gaps, err
+}
+
+// CountExistingPrices counts how many of the prices already have a row for
+// their security and date.
+func (r *InvestmentRepository) CountExistingPrices(prices []model.PriceHistory) (int64, error) {
+	if len(prices) == 0 {
+		return 0, nil
+	}
+	keys := make([][]interface{}, len(prices))
+	for i, p := range prices {
+		keys[i] = []interface{}{p.SecurityID, p.Date}
+	}
+	var count int64
+	err := r.db.Model(&model.PriceHistory{}).Where("(security_id, date) IN ?", keys).Count(&count).Error
+	return count, err
+}
+
+// UpsertPriceHistories stores prices in one batch, overwriting those already
+// recorded for the same security and date.
+func (r *InvestmentRepository) UpsertPriceHistories(prices []model.PriceHistory) error {
+	if len(prices) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "security_id"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"open", "high", "low", "close", "volume"}),
+	}).CreateInBatches(&prices, 500).Error
+}
+
+// Price sync methods
+
+// GetHeldSecurities lists the securities any portfolio holds a quantity of,
+// with the date each was first traded.
+func (r *InvestmentRepository) GetHeldSecurities() ([]HeldSecurity, error) {
+	var held []HeldSecurity
+	err := r.db.Model(&model.Security{}).
+		Select("securities.*, (SELECT MIN(t.trade_date) FROM trades t WHERE t.security_id = securities.id AND t.deleted_at IS NULL) AS first_traded").
+		Where("EXISTS (SELECT 1 FROM holdings h WHERE h.security_id = securities.id AND h.quantity > 0)").
+		Order("securities.id ASC").
+		Find(&held).Error
+	return held, err
+}
+
+func (r *InvestmentRepository) GetPriceSyncStatus(securityID uint) (*model.PriceSyncStatus, error) {
+	var status model.PriceSyncStatus
+	err := r.db.Where("security_id = ?", securityID).First(&status).Error
+	return &status, err
+}
+
+func (r *InvestmentRepository) GetPriceSyncStatuses() ([]model.PriceSyncStatus, error) {
+	var statuses []model.PriceSyncStatus
+	err := r.db.Preload("Security").Order("security_id ASC").Find(&statuses).Error
+	return statuses, err
+}
+
+func (r *InvestmentRepository) SavePriceSyncStatus(status *model.PriceSyncStatus) error {
+	return r.db.Omit("Security").Clauses(clause.OnConflict{UpdateAll: true}).Create(status).Error
+}
+
 func (r *InvestmentRepository) GetLatestPricesForSecurities(securityIDs []uint) (map[uint]model.PriceHistory, error) {
 	if len(securityIDs) == 0 {
 		return make(map[uint]model.PriceHistory), nil
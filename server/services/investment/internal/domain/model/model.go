@@ -25,6 +25,44 @@ const (
 	TradeSideSell TradeSide = "sell"
 )
 
+// CostBasisMethod decides which lots a sell is matched against.
+type CostBasisMethod string
+
+const (
+	CostBasisFIFO     CostBasisMethod = "fifo"
+	CostBasisLIFO     CostBasisMethod = "lifo"
+	CostBasisAverage  CostBasisMethod = "average"
+	CostBasisSpecific CostBasisMethod = "specific"
+)
+
+type CorporateActionType string
+
+const (
+	CorporateActionSplit        CorporateActionType = "split"
+	CorporateActionReverseSplit CorporateActionType = "reverse_split"
+	CorporateActionSymbolChange CorporateActionType = "symbol_change"
+)
+
+type CashEventType string
+
+const (
+	CashEventDividend       CashEventType = "dividend"
+	CashEventCoupon         CashEventType = "coupon"
+	CashEventWithholdingTax CashEventType = "withholding_tax"
+)
+
+type CashEntryType string
+
+const (
+	CashEntryDeposit        CashEntryType = "deposit"
+	CashEntryWithdrawal     CashEntryType = "withdrawal"
+	CashEntryTrade          CashEntryType = "trade"
+	CashEntryFee            CashEntryType = "fee"
+	CashEntryDividend       CashEntryType = "dividend"
+	CashEntryCoupon         CashEntryType = "coupon"
+	CashEntryWithholdingTax CashEntryType = "withholding_tax"
+)
+
 type Broker struct {
 	ID        uint           `gorm:"primaryKey" json:"id"`
 	UserID    uint           `gorm:"index;not null" json:"user_id"`
@@ -34,14 +72,16 @@ type Broker struct {
 }
 
 type Portfolio struct {
-	ID           uint           `gorm:"primaryKey" json:"id"`
-	UserID       uint           `gorm:"index;not null" json:"user_id"`
-	BrokerID     uint           `gorm:"index;not null" json:"broker_id"`
-	Broker       *Broker        `gorm:"foreignKey:BrokerID" json:"broker,omitempty"`
-	Name         string         `gorm:"not null" json:"name"`
-	BaseCurrency string         `gorm:"size:3;default:RUB" json:"base_currency"`
-	CreatedAt    time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID              uint            `gorm:"primaryKey" json:"id"`
+	UserID          uint            `gorm:"index;not null" json:"user_id"`
+	BrokerID        uint            `gorm:"index;not null" json:"broker_id"`
+	Broker          *Broker         `gorm:"foreignKey:BrokerID" json:"broker,omitempty"`
+	Name            string          `gorm:"not null" json:"name"`
+	BaseCurrency    string          `gorm:"size:3;default:RUB" json:"base_currency"`
+	CostBasisMethod CostBasisMethod `gorm:"type:varchar(20);default:'fifo'" json:"cost_basis_method"`
+	EnforceCash     bool            `gorm:"default:false" json:"enforce_cash"`
+	CreatedAt       time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	DeletedAt       gorm.DeletedAt  `gorm:"index" json:"-"`
 }
 
 type Security struct {
@@ -82,6 +122,101 @@ type Holding struct {
 	UpdatedAt   time.Time       `json:"updated_at"`
 }
 
+// TaxLot is the open part of one buy trade. Quantity is what was bought,
+// Remaining what is not sold yet; CostPerUnit includes the buy fee.
+type TaxLot struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	PortfolioID uint            `gorm:"index:portfolio_security_lots;not null" json:"portfolio_id"`
+	SecurityID  uint            `gorm:"index:portfolio_security_lots;not null" json:"security_id"`
+	Security    *Security       `gorm:"foreignKey:SecurityID" json:"security,omitempty"`
+	TradeID     uint            `gorm:"uniqueIndex;not null" json:"trade_id"`
+	AcquiredAt  time.Time       `gorm:"not null" json:"acquired_at"`
+	Quantity    decimal.Decimal `gorm:"type:decimal(19,8);not null" json:"quantity"`
+	Remaining   decimal.Decimal `gorm:"type:decimal(19,8);not null" json:"remaining"`
+	CostPerUnit decimal.Decimal `gorm:"type:decimal(19,8);not null" json:"cost_per_unit"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// LotSelection records that a sell trade was matched against a specific lot.
+type LotSelection struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	SellTradeID uint            `gorm:"index;not null" json:"sell_trade_id"`
+	LotID       uint            `gorm:"index;not null" json:"lot_id"`
+	Quantity    decimal.Decimal `gorm:"type:decimal(19,8);not null" json:"quantity"`
+}
+
+// RealizedGain is the part of a sell matched against one lot. Proceeds are
+// net of the sell fee, shared across the sell's lots by quantity.
+type RealizedGain struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	PortfolioID uint            `gorm:"index:portfolio_security_gains;not null" json:"portfolio_id"`
+	SecurityID  uint            `gorm:"index:portfolio_security_gains;not null" json:"security_id"`
+	Security    *Security       `gorm:"foreignKey:SecurityID" json:"security,omitempty"`
+	SellTradeID uint            `gorm:"index;not null" json:"sell_trade_id"`
+	LotID       uint            `gorm:"index;not null" json:"lot_id"`
+	AcquiredAt  time.Time       `gorm:"not null" json:"acquired_at"`
+	SoldAt      time.Time       `gorm:"index;not null" json:"sold_at"`
+	Quantity    decimal.Decimal `gorm:"type:decimal(19,8);not null" json:"quantity"`
+	Proceeds    decimal.Decimal `gorm:"type:decimal(19,4);not null" json:"proceeds"`
+	CostBasis   decimal.Decimal `gorm:"type:decimal(19,4);not null" json:"cost_basis"`
+	Gain        decimal.Decimal `gorm:"type:decimal(19,4);not null" json:"gain"`
+}
+
+// CorporateAction is an event on a security that affects every holder.
+// Splits turn RatioFrom shares into RatioTo shares from EffectiveDate on;
+// a symbol change renames the security.
+type CorporateAction struct {
+	ID            uint                `gorm:"primaryKey" json:"id"`
+	SecurityID    uint                `gorm:"index;not null" json:"security_id"`
+	Type          CorporateActionType `gorm:"type:varchar(20);not null" json:"type"`
+	EffectiveDate time.Time           `gorm:"type:date;not null" json:"effective_date"`
+	RatioFrom     decimal.Decimal     `gorm:"type:decimal(19,8)" json:"ratio_from"`
+	RatioTo       decimal.Decimal     `gorm:"type:decimal(19,8)" json:"ratio_to"`
+	OldSymbol     string              `json:"old_symbol,omitempty"`
+	NewSymbol     string              `json:"new_symbol,omitempty"`
+	Note          string              `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt     time.Time           `gorm:"autoCreateTime" json:"created_at"`
+	DeletedAt     gorm.DeletedAt      `gorm:"index" json:"-"`
+}
+
+// IsSplit reports whether the action changes the number of shares held.
+func (a *CorporateAction) IsSplit() bool {
+	return a.Type == CorporateActionSplit || a.Type == CorporateActionReverseSplit
+}
+
+// CashEvent is income paid on a holding, or tax withheld from it. Amount is
+// always positive; withholding tax is subtracted in income reports.
+type CashEvent struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	PortfolioID uint            `gorm:"index;not null" json:"portfolio_id"`
+	SecurityID  uint            `gorm:"index;not null" json:"security_id"`
+	Security    *Security       `gorm:"foreignKey:SecurityID" json:"security,omitempty"`
+	Type        CashEventType   `gorm:"type:varchar(20);not null" json:"type"`
+	PaymentDate time.Time       `gorm:"type:date;index;not null" json:"payment_date"`
+	Amount      decimal.Decimal `gorm:"type:decimal(19,4);not null" json:"amount"`
+	Currency    string          `gorm:"size:3;not null" json:"currency"`
+	Note        string          `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt   time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	DeletedAt   gorm.DeletedAt  `gorm:"index" json:"-"`
+}
+
+// CashEntry is one movement on a portfolio's cash ledger. Amount is signed:
+// deposits, sell proceeds and income are positive, withdrawals, buys, fees
+// and withheld tax negative. Entries made by a trade or cash event point back
+// to it.
+type CashEntry struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	PortfolioID uint            `gorm:"index:portfolio_currency_cash;not null" json:"portfolio_id"`
+	Currency    string          `gorm:"index:portfolio_currency_cash;size:3;not null" json:"currency"`
+	Type        CashEntryType   `gorm:"type:varchar(20);not null" json:"type"`
+	Amount      decimal.Decimal `gorm:"type:decimal(19,4);not null" json:"amount"`
+	Date        time.Time       `gorm:"not null" json:"date"`
+	TradeID     *uint           `gorm:"index" json:"trade_id,omitempty"`
+	CashEventID *uint           `gorm:"index" json:"cash_event_id,omitempty"`
+	Note        string          `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt   time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
+
 type PriceHistory struct {
 	ID         uint            `gorm:"primaryKey" json:"id"`
 	SecurityID uint            `gorm:"uniqueIndex:security_date_unique;not null" json:"security_id"`
@@ -93,6 +228,29 @@ type PriceHistory struct {
 	Volume     int64           `json:"volume"`
 }
 
+type PriceSyncState string
+
+const (
+	PriceSyncOK     PriceSyncState = "ok"
+	PriceSyncFailed PriceSyncState = "failed"
+)
+
+// PriceSyncStatus records the last scheduled price sync of a security.
+// CoveredFrom is the earliest date the provider has been asked for, so a
+// history the provider doesn't have isn't requested again on every run.
+type PriceSyncStatus struct {
+	SecurityID    uint           `gorm:"primaryKey;autoIncrement:false" json:"security_id"`
+	Security      *Security      `gorm:"foreignKey:SecurityID" json:"security,omitempty"`
+	Provider      string         `gorm:"size:20;not null" json:"provider"`
+	Status        PriceSyncState `gorm:"type:varchar(10);not null" json:"status"`
+	LastSyncedAt  time.Time      `gorm:"not null" json:"last_synced_at"`
+	LastSuccessAt *time.Time     `json:"last_success_at,omitempty"`
+	CoveredFrom   *time.Time     `gorm:"type:date" json:"covered_from,omitempty"`
+	LastPriceDate *time.Time     `gorm:"type:date" json:"last_price_date,omitempty"`
+	RowsFetched   int            `json:"rows_fetched"`
+	LastError     string         `gorm:"type:text" json:"last_error,omitempty"`
+}
+
 func IsValidSecurityType(t SecurityType) bool {
 	switch t {
 	case SecurityTypeStock, SecurityTypeETF, SecurityTypeBond,
@@ -105,3 +263,27 @@ func IsValidSecurityType(t SecurityType) bool {
 func IsValidTradeSide(s TradeSide) bool {
 	return s == TradeSideBuy || s == TradeSideSell
 }
+
+func IsValidCostBasisMethod(m CostBasisMethod) bool {
+	switch m {
+	case CostBasisFIFO, CostBasisLIFO, CostBasisAverage, CostBasisSpecific:
+		return true
+	}
+	return false
+}
+
+func IsValidCorporateActionType(t CorporateActionType) bool {
+	switch t {
+	case CorporateActionSplit, CorporateActionReverseSplit, CorporateActionSymbolChange:
+		return true
+	}
+	return false
+}
+
+func IsValidCashEventType(t CashEventType) bool {
+	switch t {
+	case CashEventDividend, CashEventCoupon, CashEventWithholdingTax:
+		return true
+	}
+	return false
+}
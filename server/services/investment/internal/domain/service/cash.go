@@ -0,0 +1,189 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"investment/internal/data/repository"
+	"investment/internal/domain/model"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInsufficientCash = errors.New("not enough cash in the portfolio")
+	ErrInvalidCashEntry = errors.New("cash entry must be a deposit or withdrawal")
+	ErrInvalidCashInput = errors.New("cash amount must be positive and currency 3 letters")
+)
+
+// PortfolioChanges lists the portfolio settings to update; nil keeps the
+// current value.
+type PortfolioChanges struct {
+	Name        *string
+	EnforceCash *bool
+}
+
+func (s *InvestmentService) UpdatePortfolio(portfolioID, userID uint, changes PortfolioChanges) (*model.Portfolio, error) {
+	p, err := s.authorizePortfolio(portfolioID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if changes.Name != nil {
+		if *changes.Name == "" {
+			return nil, fmt.Errorf("update portfolio: name required")
+		}
+		p.Name = *changes.Name
+	}
+	if changes.EnforceCash != nil {
+		p.EnforceCash = *changes.EnforceCash
+	}
+	if err := s.repo.UpdatePortfolio(p); err != nil {
+		return nil, fmt.Errorf("update portfolio: %w", err)
+	}
+	return p, nil
+}
+
+// RecordCashMovement books a deposit or withdrawal. Portfolios enforcing cash
+// can't withdraw more than they hold in that currency on the date, nor so
+// much that the balance goes negative later on.
+func (s *InvestmentService) RecordCashMovement(portfolioID, userID uint, entryType model.CashEntryType, amount decimal.Decimal, currency string, date time.Time, note string) (*model.CashEntry, error) {
+	if entryType != model.CashEntryDeposit && entryType != model.CashEntryWithdrawal {
+		return nil, ErrInvalidCashEntry
+	}
+	currency = strings.ToUpper(currency)
+	if !amount.IsPositive() || len(currency) != 3 {
+		return nil, ErrInvalidCashInput
+	}
+	if _, err := s.authorizePortfolio(portfolioID, userID); err != nil {
+		return nil, err
+	}
+
+	entry := model.CashEntry{
+		PortfolioID: portfolioID,
+		Currency:    currency,
+		Type:        entryType,
+		Amount:      amount,
+		Date:        date,
+		Note:        note,
+	}
+	if entryType == model.CashEntryWithdrawal {
+		entry.Amount = amount.Neg()
+	}
+
+	err := s.repo.InTransaction(func(repo *repository.InvestmentRepository) error {
+		p, err := repo.GetPortfolioByIDForUpdate(portfolioID)
+		if err != nil {
+			return err
+		}
+		if entryType == model.CashEntryWithdrawal && p.EnforceCash {
+			if err := requireCash(repo, portfolioID, currency, amount, date); err != nil {
+				return err
+			}
+		}
+		entries := []model.CashEntry{entry}
+		if err := repo.CreateCashEntries(entries); err != nil {
+			return err
+		}
+		entry = entries[0]
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("record cash movement: %w", err)
+	}
+	return &entry, nil
+}
+
+func (s *InvestmentService) GetCashBalances(portfolioID, userID uint) ([]repository.CashBalance, error) {
+	if _, err := s.authorizePortfolio(portfolioID, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetCashBalances(portfolioID)
+}
+
+// GetCashEntries lists ledger entries dated between from and to; zero times
+// leave that side open and an empty currency means all.
+func (s *InvestmentService) GetCashEntries(portfolioID, userID uint, currency string, from, to time.Time) ([]model.CashEntry, error) {
+	if _, err := s.authorizePortfolio(portfolioID, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetCashEntries(portfolioID, strings.ToUpper(currency), from, to)
+}
+
+// requireCash fails with ErrInsufficientCash unless the portfolio can pay
+// amount in currency on date. The balance that day and after every later
+// entry must cover it, or a backdated debit paid for by later deposits would
+// leave the ledger negative in between.
+func requireCash(repo *repository.InvestmentRepository, portfolioID uint, currency string, amount decimal.Decimal, date time.Time) error {
+	entries, err := repo.GetCashEntries(portfolioID, currency, time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+	available := availableCash(entries, date)
+	if available.LessThan(amount) {
+		return fmt.Errorf("%w: %s %s needed on %s, %s available",
+			ErrInsufficientCash, amount, currency, date.Format("2006-01-02"), available)
+	}
+	return nil
+}
+
+// availableCash is the most that can be taken out of the ledger on date
+// without its balance going negative then or later: the lowest balance from
+// the end of date on. entries must be ordered by date.
+func availableCash(entries []model.CashEntry, date time.Time) decimal.Decimal {
+	balance := decimal.Zero
+	i := 0
+	for ; i < len(entries) && !entries[i].Date.After(date); i++ {
+		balance = balance.Add(entries[i].Amount)
+	}
+	lowest := balance
+	for ; i < len(entries); i++ {
+		balance = balance.Add(entries[i].Amount)
+		lowest = decimal.Min(lowest, balance)
+	}
+	return lowest
+}
+
+// tradeCashEntries settles a trade in the security's currency: the trade
+// value and its fee are booked as separate entries.
+func tradeCashEntries(t *model.Trade, currency string) []model.CashEntry {
+	value := t.Quantity.Mul(t.Price).Round(4)
+	if t.Side == model.TradeSideBuy {
+		value = value.Neg()
+	}
+	entries := []model.CashEntry{{
+		PortfolioID: t.PortfolioID,
+		Currency:    currency,
+		Type:        model.CashEntryTrade,
+		Amount:      value,
+		Date:        t.TradeDate,
+		TradeID:     &t.ID,
+	}}
+	if t.Fee.IsPositive() {
+		entries = append(entries, model.CashEntry{
+			PortfolioID: t.PortfolioID,
+			Currency:    currency,
+			Type:        model.CashEntryFee,
+			Amount:      t.Fee.Neg(),
+			Date:        t.TradeDate,
+			TradeID:     &t.ID,
+		})
+	}
+	return entries
+}
+
+// cashEventEntry books income as a credit and withheld tax as a debit.
+func cashEventEntry(e *model.CashEvent) model.CashEntry {
+	entry := model.CashEntry{
+		PortfolioID: e.PortfolioID,
+		Currency:    e.Currency,
+		Type:        model.CashEntryType(e.Type),
+		Amount:      e.Amount,
+		Date:        e.PaymentDate,
+		CashEventID: &e.ID,
+	}
+	if e.Type == model.CashEventWithholdingTax {
+		entry.Amount = e.Amount.Neg()
+	}
+	return entry
+}
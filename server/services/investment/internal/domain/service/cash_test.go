@@ -0,0 +1,51 @@
+package service
+
+import (
+	"investment/internal/domain/model"
+	"testing"
+	"time"
+)
+
+func TestAvailableCash(t *testing.T) {
+	// 1000 in on the 1st, 800 out on the 5th, 5000 in on the 10th
+	entries := []model.CashEntry{
+		{Date: day(1), Amount: dec("1000")},
+		{Date: day(5), Amount: dec("-800")},
+		{Date: day(10), Amount: dec("5000")},
+	}
+
+	tests := []struct {
+		name string
+		date time.Time
+		want string
+	}{
+		{"before any deposit", day(1).Add(-time.Hour), "0"},
+		{"on the deposit date", day(1), "200"},
+		// 1000 would be there on the 3rd, but the withdrawal on the 5th needs 800 of it
+		{"backdated before a later debit", day(3), "200"},
+		{"after the debit", day(7), "200"},
+		{"after the later deposit", day(10), "5200"},
+		{"past the end", day(20), "5200"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := availableCash(entries, tt.date); !got.Equal(dec(tt.want)) {
+				t.Errorf("available on %s = %s, want %s", tt.date.Format(time.DateOnly), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAvailableCashIgnoresLaterDeposits(t *testing.T) {
+	// A buy dated the 2nd can't be paid for by the deposit on the 10th
+	entries := []model.CashEntry{
+		{Date: day(1), Amount: dec("100")},
+		{Date: day(10), Amount: dec("10000")},
+	}
+	if got := availableCash(entries, day(2)); !got.Equal(dec("100")) {
+		t.Fatalf("available = %s, want 100", got)
+	}
+	if got := availableCash(nil, day(2)); !got.IsZero() {
+		t.Fatalf("available with an empty ledger = %s, want 0", got)
+	}
+}
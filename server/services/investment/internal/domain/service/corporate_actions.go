@@ -0,0 +1,379 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"investment/internal/data/repository"
+	"investment/internal/domain/model"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSecurityNotFound        = fmt.Errorf("security %w", ErrNotFound)
+	ErrCashEventNotFound       = fmt.Errorf("cash event %w", ErrNotFound)
+	ErrCorporateActionNotFound = fmt.Errorf("corporate action %w", ErrNotFound)
+	ErrInvalidCorporateAction  = errors.New("invalid corporate action type")
+	ErrInvalidSplitRatio       = errors.New("split needs ratio_to > ratio_from > 0, reverse split ratio_from > ratio_to > 0")
+	ErrSymbolRequired          = errors.New("symbol change needs a new symbol")
+	ErrSymbolTaken             = errors.New("symbol already belongs to another security")
+	ErrSymbolChangedSince      = errors.New("security has been renamed again since; undo the later symbol change first")
+	ErrInvalidCashEvent        = errors.New("invalid cash event type")
+	ErrCashEventAmount         = errors.New("cash event needs a positive amount or amount per share")
+	ErrNoHolding               = errors.New("no holding of the security on the record date")
+)
+
+// CashEventInput describes a cash event to record. With AmountPerShare the
+// amount is computed from the quantity held at the end of RecordDate, which
+// defaults to PaymentDate.
+type CashEventInput struct {
+	SecurityID     uint
+	Type           model.CashEventType
+	PaymentDate    time.Time
+	RecordDate     time.Time
+	Amount         decimal.Decimal
+	AmountPerShare decimal.Decimal
+	Currency       string
+	Note           string
+}
+
+// IncomeTotals sums cash events of one currency. Net is dividends plus
+// coupons less withholding tax.
+type IncomeTotals struct {
+	Currency       string          `json:"currency"`
+	Dividends      decimal.Decimal `json:"dividends"`
+	Coupons        decimal.Decimal `json:"coupons"`
+	WithholdingTax decimal.Decimal `json:"withholding_tax"`
+	Net            decimal.Decimal `json:"net"`
+}
+
+func (t *IncomeTotals) add(e *model.CashEvent) {
+	switch e.Type {
+	case model.CashEventDividend:
+		t.Dividends = t.Dividends.Add(e.Amount)
+		t.Net = t.Net.Add(e.Amount)
+	case model.CashEventCoupon:
+		t.Coupons = t.Coupons.Add(e.Amount)
+		t.Net = t.Net.Add(e.Amount)
+	case model.CashEventWithholdingTax:
+		t.WithholdingTax = t.WithholdingTax.Add(e.Amount)
+		t.Net = t.Net.Sub(e.Amount)
+	}
+}
+
+type SecurityIncome struct {
+	SecurityID uint   `json:"security_id"`
+	Symbol     string `json:"symbol"`
+	IncomeTotals
+}
+
+type IncomeReport struct {
+	PortfolioID uint              `json:"portfolio_id"`
+	Totals      []IncomeTotals    `json:"totals"`
+	BySecurity  []SecurityIncome  `json:"by_security"`
+	Events      []model.CashEvent `json:"events"`
+}
+
+// CreateCorporateAction records an action on a security. A symbol change
+// renames the security; a split rescales the lots and holdings of every
+// portfolio that traded it. Splits that would make a later sell oversell are
+// rejected.
+func (s *InvestmentService) CreateCorporateAction(a *model.CorporateAction) (*model.CorporateAction, error) {
+	if !model.IsValidCorporateActionType(a.Type) {
+		return nil, ErrInvalidCorporateAction
+	}
+	switch a.Type {
+	case model.CorporateActionSplit:
+		if !a.RatioFrom.IsPositive() || !a.RatioTo.GreaterThan(a.RatioFrom) {
+			return nil, ErrInvalidSplitRatio
+		}
+	case model.CorporateActionReverseSplit:
+		if !a.RatioTo.IsPositive() || !a.RatioFrom.GreaterThan(a.RatioTo) {
+			return nil, ErrInvalidSplitRatio
+		}
+	case model.CorporateActionSymbolChange:
+		a.NewSymbol = strings.TrimSpace(a.NewSymbol)
+		if a.NewSymbol == "" {
+			return nil, ErrSymbolRequired
+		}
+		a.RatioFrom, a.RatioTo = decimal.Zero, decimal.Zero
+	}
+
+	err := s.repo.InTransaction(func(repo *repository.InvestmentRepository) error {
+		sec, err := repo.GetSecurityByID(a.SecurityID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSecurityNotFound
+			}
+			return err
+		}
+
+		if a.Type == model.CorporateActionSymbolChange {
+			if other, err := repo.GetSecurityBySymbol(a.NewSymbol); err == nil && other.ID != sec.ID {
+				return ErrSymbolTaken
+			} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			a.OldSymbol = sec.Symbol
+			if err := repo.UpdateSecuritySymbol(sec.ID, a.NewSymbol); err != nil {
+				return err
+			}
+		}
+
+		if err := repo.CreateCorporateAction(a); err != nil {
+			return err
+		}
+		if !a.IsSplit() {
+			return nil
+		}
+		return rebuildHolders(repo, sec.ID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create corporate action: %w", err)
+	}
+	return a, nil
+}
+
+// DeleteCorporateAction removes an action recorded by mistake and undoes it:
+// a symbol change gives the security its old symbol back, a split rescales
+// the holders' lots and holdings back. Only the latest symbol change of a
+// security can be undone, and removing a split that later sells depend on is
+// rejected.
+func (s *InvestmentService) DeleteCorporateAction(id uint) error {
+	err := s.repo.InTransaction(func(repo *repository.InvestmentRepository) error {
+		a, err := repo.GetCorporateActionByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCorporateActionNotFound
+			}
+			return err
+		}
+
+		if a.Type == model.CorporateActionSymbolChange {
+			sec, err := repo.GetSecurityByID(a.SecurityID)
+			if err != nil {
+				return err
+			}
+			if sec.Symbol != a.NewSymbol {
+				return ErrSymbolChangedSince
+			}
+			if other, err := repo.GetSecurityBySymbol(a.OldSymbol); err == nil && other.ID != sec.ID {
+				return ErrSymbolTaken
+			} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if err := repo.UpdateSecuritySymbol(sec.ID, a.OldSymbol); err != nil {
+				return err
+			}
+		}
+
+		if err := repo.DeleteCorporateAction(a.ID); err != nil {
+			return err
+		}
+		if !a.IsSplit() {
+			return nil
+		}
+		return rebuildHolders(repo, a.SecurityID)
+	})
+	if err != nil {
+		return fmt.Errorf("delete corporate action: %w", err)
+	}
+	return nil
+}
+
+// rebuildHolders rebuilds the position in the security of every portfolio
+// that traded it.
+func rebuildHolders(repo *repository.InvestmentRepository, securityID uint) error {
+	portfolioIDs, err := repo.GetPortfolioIDsBySecurityID(securityID)
+	if err != nil {
+		return err
+	}
+	for _, id := range portfolioIDs {
+		p, err := repo.GetPortfolioByIDForUpdate(id)
+		if err != nil {
+			return err
+		}
+		if err := rebuildPosition(repo, p, securityID); err != nil {
+			return fmt.Errorf("portfolio %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func (s *InvestmentService) GetCorporateActions(securityID uint) ([]model.CorporateAction, error) {
+	return s.repo.GetCorporateActionsBySecurityID(securityID)
+}
+
+// CreateCashEvent records a dividend, coupon or withholding tax on one of the
+// portfolio's securities and books it on the cash ledger.
+func (s *InvestmentService) CreateCashEvent(portfolioID, userID uint, in CashEventInput) (*model.CashEvent, error) {
+	if !model.IsValidCashEventType(in.Type) {
+		return nil, ErrInvalidCashEvent
+	}
+	if in.Amount.IsNegative() || in.AmountPerShare.IsNegative() ||
+		in.Amount.IsZero() == in.AmountPerShare.IsZero() {
+		return nil, ErrCashEventAmount
+	}
+	p, err := s.authorizePortfolio(portfolioID, userID)
+	if err != nil {
+		return nil, err
+	}
+	sec, err := s.repo.GetSecurityByID(in.SecurityID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSecurityNotFound
+		}
+		return nil, fmt.Errorf("create cash event: %w", err)
+	}
+
+	amount := in.Amount
+	if amount.IsZero() {
+		recordDate := in.RecordDate
+		if recordDate.IsZero() {
+			recordDate = in.PaymentDate
+		}
+		held, err := s.quantityHeld(p, sec.ID, recordDate)
+		if err != nil {
+			return nil, fmt.Errorf("create cash event: %w", err)
+		}
+		if !held.IsPositive() {
+			return nil, ErrNoHolding
+		}
+		amount = held.Mul(in.AmountPerShare).Round(4)
+	}
+
+	currency := strings.ToUpper(in.Currency)
+	if currency == "" {
+		currency = sec.Currency
+	}
+
+	e := &model.CashEvent{
+		PortfolioID: portfolioID,
+		SecurityID:  sec.ID,
+		Type:        in.Type,
+		PaymentDate: in.PaymentDate,
+		Amount:      amount,
+		Currency:    currency,
+		Note:        in.Note,
+	}
+	err = s.repo.InTransaction(func(repo *repository.InvestmentRepository) error {
+		if err := repo.CreateCashEvent(e); err != nil {
+			return err
+		}
+		return repo.CreateCashEntries([]model.CashEntry{cashEventEntry(e)})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create cash event: %w", err)
+	}
+	e.Security = sec
+	return e, nil
+}
+
+// quantityHeld replays the position up to the end of date, splits included.
+func (s *InvestmentService) quantityHeld(p *model.Portfolio, securityID uint, date time.Time) (decimal.Decimal, error) {
+	end := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location()).AddDate(0, 0, 1)
+
+	trades, err := s.repo.GetTradesBySecurityID(p.ID, securityID)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	splits, err := s.repo.GetSplitsBySecurityID(securityID)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	trades = filterBefore(trades, end, func(t model.Trade) time.Time { return t.TradeDate })
+	splits = filterBefore(splits, end, func(a model.CorporateAction) time.Time { return a.EffectiveDate })
+
+	// Which lots sold doesn't change the quantity, so FIFO will do
+	lots, _, err := matchLots(trades, splits, nil, model.CostBasisFIFO)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	held := decimal.Zero
+	for _, l := range lots {
+		held = held.Add(l.Remaining)
+	}
+	return held, nil
+}
+
+// filterBefore keeps the items of a date-sorted slice dated before end.
+func filterBefore[T any](items []T, end time.Time, date func(T) time.Time) []T {
+	n := sort.Search(len(items), func(i int) bool { return !date(items[i]).Before(end) })
+	return items[:n]
+}
+
+func (s *InvestmentService) GetCashEvents(portfolioID, userID uint, from, to time.Time) ([]model.CashEvent, error) {
+	if _, err := s.authorizePortfolio(portfolioID, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetCashEvents(portfolioID, from, to)
+}
+
+func (s *InvestmentService) DeleteCashEvent(portfolioID, eventID, userID uint) error {
+	if _, err := s.authorizePortfolio(portfolioID, userID); err != nil {
+		return err
+	}
+	e, err := s.repo.GetCashEventByID(eventID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCashEventNotFound
+		}
+		return fmt.Errorf("delete cash event: %w", err)
+	}
+	if e.PortfolioID != portfolioID {
+		return ErrCashEventNotFound
+	}
+	return s.repo.InTransaction(func(repo *repository.InvestmentRepository) error {
+		if err := repo.DeleteCashEntriesByCashEventID(eventID); err != nil {
+			return err
+		}
+		return repo.DeleteCashEvent(eventID)
+	})
+}
+
+// GetIncomeReport totals the portfolio's cash events paid between from and
+// to, per currency and per security; zero times leave that side open.
+func (s *InvestmentService) GetIncomeReport(portfolioID, userID uint, from, to time.Time) (*IncomeReport, error) {
+	events, err := s.GetCashEvents(portfolioID, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &IncomeReport{
+		PortfolioID: portfolioID,
+		Totals:      []IncomeTotals{},
+		BySecurity:  []SecurityIncome{},
+		Events:      events,
+	}
+	totals := map[string]int{}
+	bySecurity := map[string]int{}
+	for i := range events {
+		e := &events[i]
+
+		t, ok := totals[e.Currency]
+		if !ok {
+			t = len(report.Totals)
+			totals[e.Currency] = t
+			report.Totals = append(report.Totals, IncomeTotals{Currency: e.Currency})
+		}
+		report.Totals[t].add(e)
+
+		key := fmt.Sprintf("%d/%s", e.SecurityID, e.Currency)
+		si, ok := bySecurity[key]
+		if !ok {
+			si = len(report.BySecurity)
+			bySecurity[key] = si
+			row := SecurityIncome{SecurityID: e.SecurityID, IncomeTotals: IncomeTotals{Currency: e.Currency}}
+			if e.Security != nil {
+				row.Symbol = e.Security.Symbol
+			}
+			report.BySecurity = append(report.BySecurity, row)
+		}
+		report.BySecurity[si].add(e)
+	}
+	return report, nil
+}
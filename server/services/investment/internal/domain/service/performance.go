@@ -0,0 +1,369 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"investment/internal/domain/model"
+	"math"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoPerformanceData = errors.New("portfolio has no trades up to the end of the period")
+	ErrInvalidPeriod     = errors.New("performance period must start on or before its end")
+	ErrNoBenchmarkPrices = errors.New("benchmark has no prices up to the end of the period")
+)
+
+// PerformancePoint is the portfolio at the end of one day. NetFlow is money
+// put in by buys less money taken out by sells; Index is the time-weighted
+// growth of 1 since the start of the period.
+type PerformancePoint struct {
+	Date    time.Time       `json:"date"`
+	Value   decimal.Decimal `json:"value"`
+	NetFlow decimal.Decimal `json:"net_flow"`
+	Income  decimal.Decimal `json:"income"`
+	Index   float64         `json:"index"`
+}
+
+type BenchmarkPoint struct {
+	Date  time.Time `json:"date"`
+	Index float64   `json:"index"`
+}
+
+// BenchmarkPerformance is the price return of a security over the same
+// period; Excess is the portfolio's TWR less that return.
+type BenchmarkPerformance struct {
+	SecurityID  uint             `json:"security_id"`
+	Symbol      string           `json:"symbol"`
+	Return      float64          `json:"return"`
+	Excess      float64          `json:"excess_return"`
+	MaxDrawdown float64          `json:"max_drawdown"`
+	Series      []BenchmarkPoint `json:"series"`
+}
+
+// Performance reports returns as fractions, 0.05 being 5%. AnnualizedTWR is
+// only given for periods of a year or more and XIRR when it converges.
+type Performance struct {
+	PortfolioID   uint                  `json:"portfolio_id"`
+	From          time.Time             `json:"from"`
+	To            time.Time             `json:"to"`
+	StartValue    decimal.Decimal       `json:"start_value"`
+	EndValue      decimal.Decimal       `json:"end_value"`
+	NetFlows      decimal.Decimal       `json:"net_flows"`
+	Income        decimal.Decimal       `json:"income"`
+	TWR           float64               `json:"twr"`
+	AnnualizedTWR *float64              `json:"annualized_twr"`
+	XIRR          *float64              `json:"xirr"`
+	MaxDrawdown   float64               `json:"max_drawdown"`
+	Series        []PerformancePoint    `json:"series"`
+	Benchmark     *BenchmarkPerformance `json:"benchmark,omitempty"`
+}
+
+// GetPerformance values the portfolio's holdings at the end of every day
+// between from and to and derives its time-weighted return, money-weighted
+// return (XIRR) and maximum drawdown. Trades are the external flows and cash
+// events count as income, so deposits sitting on the cash ledger don't dilute
+// the result. Holdings are valued at the last close known on each day, or the
+// last trade price before the first close, and summed in their own currencies
+// like the portfolio summary. A zero from, or one before the first trade,
+// starts at the first trade and a zero to ends today; a non-zero benchmarkID
+// adds that security's price return.
+func (s *InvestmentService) GetPerformance(portfolioID, userID uint, from, to time.Time, benchmarkID uint) (*Performance, error) {
+	if _, err := s.authorizePortfolio(portfolioID, userID); err != nil {
+		return nil, err
+	}
+
+	if to.IsZero() {
+		to = time.Now()
+	}
+	to = truncateDay(to)
+	trades, err := s.repo.GetTradesBefore(portfolioID, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, fmt.Errorf("get performance: %w", err)
+	}
+	if len(trades) == 0 {
+		return nil, ErrNoPerformanceData
+	}
+	// Nothing is held before the first trade, so an earlier from would only
+	// replay empty days
+	from = truncateDay(from)
+	if start := truncateDay(trades[0].TradeDate); from.Before(start) {
+		from = start
+	}
+	if from.After(to) {
+		return nil, ErrInvalidPeriod
+	}
+
+	positions := map[uint]*pricedPosition{}
+	for _, t := range trades {
+		if _, ok := positions[t.SecurityID]; ok {
+			continue
+		}
+		splits, err := s.repo.GetSplitsBySecurityID(t.SecurityID)
+		if err != nil {
+			return nil, fmt.Errorf("get performance: %w", err)
+		}
+		prices, err := s.repo.GetPriceHistory(t.SecurityID, truncateDay(t.TradeDate), to)
+		if err != nil {
+			return nil, fmt.Errorf("get performance: %w", err)
+		}
+		positions[t.SecurityID] = &pricedPosition{splits: splits, prices: prices}
+	}
+
+	events, err := s.repo.GetCashEvents(portfolioID, from, to.AddDate(0, 0, 1).Add(-time.Nanosecond))
+	if err != nil {
+		return nil, fmt.Errorf("get performance: %w", err)
+	}
+
+	perf := replayPerformance(trades, positions, events, from, to)
+	perf.PortfolioID = portfolioID
+
+	if benchmarkID != 0 {
+		b, err := s.benchmarkPerformance(benchmarkID, from, to)
+		if err != nil {
+			return nil, err
+		}
+		b.Excess = perf.TWR - b.Return
+		perf.Benchmark = b
+	}
+	return perf, nil
+}
+
+// replayPerformance values the positions at the end of every day from the
+// first trade to to and measures the days from from on. trades and events
+// must be ordered by date; positions holds the splits and closes of every
+// traded security and is consumed by the replay.
+func replayPerformance(trades []model.Trade, positions map[uint]*pricedPosition, events []model.CashEvent, from, to time.Time) *Performance {
+	perf := &Performance{
+		From:   from,
+		To:     to,
+		Series: make([]PerformancePoint, 0, int(to.Sub(from).Hours()/24)+1),
+	}
+	var flows []cashFlow
+	index, peak := 1.0, 1.0
+	prev := decimal.Zero
+	nextTrade, nextEvent := 0, 0
+
+	for day := truncateDay(trades[0].TradeDate); !day.After(to); day = day.AddDate(0, 0, 1) {
+		for _, p := range positions {
+			p.applySplits(day)
+		}
+
+		// Buys count as money in at the start of the day, sells as money out
+		// at its end, so a day that opens or closes a position still has a
+		// base to measure against
+		in, out := decimal.Zero, decimal.Zero
+		for ; nextTrade < len(trades) && truncateDay(trades[nextTrade].TradeDate).Equal(day); nextTrade++ {
+			t := &trades[nextTrade]
+			p := positions[t.SecurityID]
+			value := t.Quantity.Mul(t.Price)
+			if t.Side == model.TradeSideBuy {
+				p.quantity = p.quantity.Add(t.Quantity)
+				in = in.Add(value).Add(t.Fee)
+			} else {
+				p.quantity = p.quantity.Sub(t.Quantity)
+				out = out.Add(value).Sub(t.Fee)
+			}
+			p.price = t.Price
+		}
+
+		value := decimal.Zero
+		for _, p := range positions {
+			p.applyClose(day)
+			value = value.Add(p.quantity.Mul(p.price))
+		}
+
+		if day.Before(from) {
+			prev = value
+			continue
+		}
+
+		income := decimal.Zero
+		for ; nextEvent < len(events) && !truncateDay(events[nextEvent].PaymentDate).After(day); nextEvent++ {
+			e := &events[nextEvent]
+			if e.Type == model.CashEventWithholdingTax {
+				income = income.Sub(e.Amount)
+			} else {
+				income = income.Add(e.Amount)
+			}
+		}
+
+		if day.Equal(from) {
+			perf.StartValue = prev
+			if prev.IsPositive() {
+				flows = append(flows, cashFlow{date: day, amount: prev.Neg().InexactFloat64()})
+			}
+		}
+		if base := prev.Add(in); base.IsPositive() {
+			growth := value.Add(out).Add(income).Div(base).InexactFloat64()
+			index *= growth
+		}
+		peak = math.Max(peak, index)
+		perf.MaxDrawdown = math.Max(perf.MaxDrawdown, 1-index/peak)
+
+		if net := out.Add(income).Sub(in); !net.IsZero() {
+			flows = append(flows, cashFlow{date: day, amount: net.InexactFloat64()})
+		}
+		perf.NetFlows = perf.NetFlows.Add(in).Sub(out)
+		perf.Income = perf.Income.Add(income)
+		perf.Series = append(perf.Series, PerformancePoint{
+			Date:    day,
+			Value:   value,
+			NetFlow: in.Sub(out),
+			Income:  income,
+			Index:   index,
+		})
+		prev = value
+	}
+
+	perf.EndValue = prev
+	perf.TWR = index - 1
+	if days := to.Sub(from).Hours()/24 + 1; days >= 365 {
+		annualized := math.Pow(index, 365/days) - 1
+		perf.AnnualizedTWR = &annualized
+	}
+	if prev.IsPositive() {
+		flows = append(flows, cashFlow{date: to, amount: prev.InexactFloat64()})
+	}
+	if rate, ok := xirr(flows); ok {
+		perf.XIRR = &rate
+	}
+	return perf
+}
+
+// benchmarkPerformance follows the split-adjusted close of a security from
+// the last close on or before from.
+func (s *InvestmentService) benchmarkPerformance(securityID uint, from, to time.Time) (*BenchmarkPerformance, error) {
+	sec, err := s.repo.GetSecurityByID(securityID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSecurityNotFound
+		}
+		return nil, fmt.Errorf("get benchmark: %w", err)
+	}
+	splits, err := s.repo.GetSplitsBySecurityID(securityID)
+	if err != nil {
+		return nil, fmt.Errorf("get benchmark: %w", err)
+	}
+	prices, err := s.repo.GetPriceHistory(securityID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("get benchmark: %w", err)
+	}
+	if seed, err := s.repo.GetPriceAsOf(securityID, from); err == nil {
+		if len(prices) == 0 || seed.Date.Before(prices[0].Date) {
+			prices = append([]model.PriceHistory{*seed}, prices...)
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("get benchmark: %w", err)
+	}
+	if len(prices) == 0 {
+		return nil, ErrNoBenchmarkPrices
+	}
+
+	// Prices before the first close are unknown, so the index starts there.
+	// Splits only scale the quantity held, which the index divides out
+	p := &pricedPosition{quantity: decimal.NewFromInt(1), splits: splits, prices: prices}
+
+	b := &BenchmarkPerformance{
+		SecurityID: sec.ID,
+		Symbol:     sec.Symbol,
+		Series:     make([]BenchmarkPoint, 0, int(to.Sub(from).Hours()/24)+1),
+	}
+	var base decimal.Decimal
+	index, peak := 1.0, 1.0
+	for day := truncateDay(prices[0].Date); !day.After(to); day = day.AddDate(0, 0, 1) {
+		p.applySplits(day)
+		p.applyClose(day)
+		value := p.quantity.Mul(p.price)
+		if base.IsZero() {
+			base = value
+		}
+		if day.Before(from) {
+			continue
+		}
+		index = value.Div(base).InexactFloat64()
+		peak = math.Max(peak, index)
+		b.MaxDrawdown = math.Max(b.MaxDrawdown, 1-index/peak)
+		b.Series = append(b.Series, BenchmarkPoint{Date: day, Index: index})
+	}
+	b.Return = index - 1
+	return b, nil
+}
+
+// pricedPosition follows the quantity and last known price of one security
+// through a day-by-day replay. Splits and closes are consumed in date order.
+type pricedPosition struct {
+	quantity decimal.Decimal
+	price    decimal.Decimal
+	splits   []model.CorporateAction
+	prices   []model.PriceHistory
+}
+
+// applySplits rescales for the splits effective by the start of day.
+func (p *pricedPosition) applySplits(day time.Time) {
+	for len(p.splits) > 0 && !day.Before(truncateDay(p.splits[0].EffectiveDate)) {
+		factor := p.splits[0].RatioTo.Div(p.splits[0].RatioFrom)
+		p.quantity = p.quantity.Mul(factor).Round(8)
+		p.price = p.price.Div(factor)
+		p.splits = p.splits[1:]
+	}
+}
+
+// applyClose takes the day's close, if there is one, as the price.
+func (p *pricedPosition) applyClose(day time.Time) {
+	for len(p.prices) > 0 && !day.Before(truncateDay(p.prices[0].Date)) {
+		p.price = p.prices[0].Close
+		p.prices = p.prices[1:]
+	}
+}
+
+type cashFlow struct {
+	date   time.Time
+	amount float64
+}
+
+// xirr finds the annual rate at which the flows' present value is zero by
+// bisection. It needs money both going in and coming out.
+func xirr(flows []cashFlow) (float64, bool) {
+	var in, out bool
+	for _, f := range flows {
+		in = in || f.amount < 0
+		out = out || f.amount > 0
+	}
+	if !in || !out {
+		return 0, false
+	}
+
+	npv := func(rate float64) float64 {
+		sum := 0.0
+		for _, f := range flows {
+			years := f.date.Sub(flows[0].date).Hours() / 24 / 365
+			sum += f.amount / math.Pow(1+rate, years)
+		}
+		return sum
+	}
+
+	lo, hi := -0.999999, 1.0
+	for npv(lo)*npv(hi) > 0 {
+		if hi >= 1e6 {
+			return 0, false
+		}
+		hi *= 10
+	}
+	for i := 0; i < 200 && hi-lo > 1e-10; i++ {
+		mid := (lo + hi) / 2
+		if npv(lo)*npv(mid) <= 0 {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return (lo + hi) / 2, true
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
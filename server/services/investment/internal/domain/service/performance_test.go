@@ -0,0 +1,211 @@
+package service
+
+import (
+	"investment/internal/domain/model"
+	"math"
+	"testing"
+	"time"
+)
+
+const perfTolerance = 1e-9
+
+func closeTo(got, want float64) bool {
+	return math.Abs(got-want) < perfTolerance
+}
+
+func closes(securityID uint, prices map[int]string) []model.PriceHistory {
+	var history []model.PriceHistory
+	for n := 1; n <= 31; n++ {
+		if c, ok := prices[n]; ok {
+			history = append(history, model.PriceHistory{SecurityID: securityID, Date: day(n), Close: dec(c)})
+		}
+	}
+	return history
+}
+
+func tradeOf(securityID uint, t model.Trade) model.Trade {
+	t.SecurityID = securityID
+	return t
+}
+
+func checkIndexes(t *testing.T, perf *Performance, want ...float64) {
+	t.Helper()
+	if len(perf.Series) != len(want) {
+		t.Fatalf("got %d points, want %d", len(perf.Series), len(want))
+	}
+	for i, w := range want {
+		if !closeTo(perf.Series[i].Index, w) {
+			t.Errorf("index on %s = %v, want %v", perf.Series[i].Date.Format(time.DateOnly), perf.Series[i].Index, w)
+		}
+	}
+}
+
+// Two deposits: 1000 in at 100, up 10%, another 1100 in at 110, then down
+// 10%. Each day's growth is measured on what was invested at its start.
+func twoBuys() ([]model.Trade, map[uint]*pricedPosition) {
+	trades := []model.Trade{
+		tradeOf(1, buy(1, day(1), "10", "100", "0")),
+		tradeOf(1, buy(2, day(3), "10", "110", "0")),
+	}
+	positions := map[uint]*pricedPosition{
+		1: {prices: closes(1, map[int]string{1: "100", 2: "110", 3: "110", 4: "99"})},
+	}
+	return trades, positions
+}
+
+func TestReplayPerformanceChainsDailyReturns(t *testing.T) {
+	trades, positions := twoBuys()
+	perf := replayPerformance(trades, positions, nil, day(1), day(4))
+
+	checkIndexes(t, perf, 1, 1.1, 1.1, 0.99)
+	if !closeTo(perf.TWR, -0.01) {
+		t.Errorf("TWR = %v, want -0.01", perf.TWR)
+	}
+	// From the peak of 1.1 down to 0.99
+	if !closeTo(perf.MaxDrawdown, 0.1) {
+		t.Errorf("max drawdown = %v, want 0.1", perf.MaxDrawdown)
+	}
+	if !perf.StartValue.IsZero() || !perf.EndValue.Equal(dec("1980")) || !perf.NetFlows.Equal(dec("2100")) {
+		t.Errorf("start %s, end %s, net flows %s; want 0, 1980 and 2100", perf.StartValue, perf.EndValue, perf.NetFlows)
+	}
+	if perf.AnnualizedTWR != nil {
+		t.Errorf("annualized TWR = %v for four days, want none", *perf.AnnualizedTWR)
+	}
+
+	// More money was in for the fall than for the rise, so the money-weighted
+	// return is below the time-weighted one
+	if perf.XIRR == nil || *perf.XIRR >= perf.TWR {
+		t.Fatalf("XIRR = %v, want below the TWR of %v", perf.XIRR, perf.TWR)
+	}
+	flows := []cashFlow{{day(1), -1000}, {day(3), -1100}, {day(4), 1980}}
+	if npv := presentValue(flows, *perf.XIRR); math.Abs(npv) > 1e-4 {
+		t.Errorf("flows at the XIRR of %v are worth %v, want 0", *perf.XIRR, npv)
+	}
+}
+
+func TestReplayPerformanceFromMidPeriod(t *testing.T) {
+	trades, positions := twoBuys()
+	perf := replayPerformance(trades, positions, nil, day(3), day(4))
+
+	// The value held before from is the opening flow
+	checkIndexes(t, perf, 1, 0.9)
+	if !perf.StartValue.Equal(dec("1100")) || !perf.NetFlows.Equal(dec("1100")) {
+		t.Errorf("start %s, net flows %s; want 1100 and 1100", perf.StartValue, perf.NetFlows)
+	}
+	if !closeTo(perf.TWR, -0.1) || !closeTo(perf.MaxDrawdown, 0.1) {
+		t.Errorf("TWR = %v, max drawdown = %v; want -0.1 and 0.1", perf.TWR, perf.MaxDrawdown)
+	}
+}
+
+func TestReplayPerformanceIncomeAndSell(t *testing.T) {
+	trades := []model.Trade{
+		tradeOf(1, buy(1, day(1), "10", "100", "0")),
+		tradeOf(1, sell(2, day(3), "10", "100", "1")),
+	}
+	positions := map[uint]*pricedPosition{1: {prices: closes(1, map[int]string{1: "100", 2: "100"})}}
+	events := []model.CashEvent{
+		{Type: model.CashEventDividend, PaymentDate: day(2), Amount: dec("50")},
+		{Type: model.CashEventWithholdingTax, PaymentDate: day(2), Amount: dec("5")},
+	}
+	perf := replayPerformance(trades, positions, events, day(1), day(3))
+
+	// 45 of net income on 1000, then the sale's fee
+	checkIndexes(t, perf, 1, 1.045, 1.045*0.999)
+	if !perf.Income.Equal(dec("45")) || !perf.EndValue.IsZero() || !perf.NetFlows.Equal(dec("1")) {
+		t.Errorf("income %s, end %s, net flows %s; want 45, 0 and 1", perf.Income, perf.EndValue, perf.NetFlows)
+	}
+	if p := perf.Series[2]; !p.NetFlow.Equal(dec("-999")) {
+		t.Errorf("net flow on the sale = %s, want -999", p.NetFlow)
+	}
+}
+
+func TestReplayPerformanceSplit(t *testing.T) {
+	trades := []model.Trade{tradeOf(1, buy(1, day(1), "10", "100", "0"))}
+	positions := map[uint]*pricedPosition{1: {
+		splits: []model.CorporateAction{{Type: model.CorporateActionSplit, EffectiveDate: day(2), RatioFrom: dec("1"), RatioTo: dec("2")}},
+		prices: closes(1, map[int]string{1: "100", 3: "55"}),
+	}}
+	perf := replayPerformance(trades, positions, nil, day(1), day(3))
+
+	// Without a close on the split day the old price is split too
+	checkIndexes(t, perf, 1, 1, 1.1)
+	if !perf.Series[1].Value.Equal(dec("1000")) || !perf.EndValue.Equal(dec("1100")) {
+		t.Errorf("value on the split day %s, at the end %s; want 1000 and 1100", perf.Series[1].Value, perf.EndValue)
+	}
+}
+
+func TestReplayPerformanceAnnualizes(t *testing.T) {
+	first := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)
+	trades := []model.Trade{tradeOf(1, buy(1, first, "10", "100", "0"))}
+	positions := map[uint]*pricedPosition{1: {prices: []model.PriceHistory{{Date: last, Close: dec("110")}}}}
+	perf := replayPerformance(trades, positions, nil, first, last)
+
+	// 365 days counting both ends is exactly a year for the TWR; the XIRR
+	// spans the 364 days between the flows
+	if perf.AnnualizedTWR == nil || !closeTo(*perf.AnnualizedTWR, 0.1) {
+		t.Errorf("annualized TWR = %v, want 0.1", perf.AnnualizedTWR)
+	}
+	if want := math.Pow(1.1, 365.0/364) - 1; perf.XIRR == nil || math.Abs(*perf.XIRR-want) > 1e-8 {
+		t.Errorf("XIRR = %v, want %v", perf.XIRR, want)
+	}
+}
+
+func TestXIRR(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name  string
+		flows []cashFlow
+		want  float64
+	}{
+		{"ten percent over a year", []cashFlow{
+			{date(2023, 1, 1), -1000},
+			{date(2024, 1, 1), 1100},
+		}, 0.1},
+		{"loss", []cashFlow{
+			{date(2023, 1, 1), -1000},
+			{date(2024, 1, 1), 500},
+		}, -0.5},
+		// The example of the XIRR function in Excel's documentation
+		{"excel reference", []cashFlow{
+			{date(2008, 1, 1), -10000},
+			{date(2008, 3, 1), 2750},
+			{date(2008, 10, 30), 4250},
+			{date(2009, 2, 15), 3250},
+			{date(2009, 4, 1), 2750},
+		}, 0.373362535},
+		// Past the first guess of 100% the upper bound is raised
+		{"above one hundred percent", []cashFlow{
+			{date(2023, 1, 1), -1000},
+			{date(2024, 1, 1), 5000},
+		}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := xirr(tt.flows)
+			if !ok || math.Abs(got-tt.want) > 1e-8 {
+				t.Fatalf("xirr = %v, %v; want %v", got, ok, tt.want)
+			}
+		})
+	}
+
+	for name, flows := range map[string][]cashFlow{
+		"only money in":    {{date(2023, 1, 1), -1000}, {date(2023, 6, 1), -500}},
+		"only money out":   {{date(2023, 1, 1), 1000}},
+		"no flows":         nil,
+		"beyond the bound": {{date(2023, 1, 1), -1}, {date(2023, 1, 2), 1000}},
+	} {
+		if got, ok := xirr(flows); ok {
+			t.Errorf("%s: xirr = %v, want no rate", name, got)
+		}
+	}
+}
+
+// presentValue discounts flows to the first one at an annual rate.
+func presentValue(flows []cashFlow, rate float64) float64 {
+	sum := 0.0
+	for _, f := range flows {
+		sum += f.amount / math.Pow(1+rate, f.date.Sub(flows[0].date).Hours()/24/365)
+	}
+	return sum
+}
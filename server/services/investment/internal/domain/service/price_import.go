@@ -0,0 +1,225 @@
+package service
+
+import (
+	"fmt"
+	"investment/internal/data/repository"
+	"investment/internal/domain/model"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// MaxPriceImportRows caps one bulk price import.
+const MaxPriceImportRows = 100000
+
+const priceImportBatch = 1000
+
+var ErrTooManyPriceRows = fmt.Errorf("a price import takes at most %d rows", MaxPriceImportRows)
+
+// PriceRow is one OHLCV row of a bulk import, as text. The security is named
+// by SecurityID or Symbol; Row numbers the row in the upload for the report.
+type PriceRow struct {
+	Row        int
+	SecurityID uint
+	Symbol     string
+	Date       string
+	Open       string
+	High       string
+	Low        string
+	Close      string
+	Volume     string
+}
+
+type RejectedPriceRow struct {
+	Row    int    `json:"row"`
+	Reason string `json:"reason"`
+}
+
+type PriceImportResult struct {
+	Total    int                `json:"total"`
+	Inserted int                `json:"inserted"`
+	Updated  int                `json:"updated"`
+	Rejected []RejectedPriceRow `json:"rejected"`
+}
+
+// ImportPrices validates every row and upserts the valid ones in batches,
+// overwriting prices already stored for the same security and date. Invalid
+// rows, and repeats of a security and date earlier in the upload, are
+// reported as rejected rather than failing the import.
+func (s *InvestmentService) ImportPrices(rows []PriceRow) (*PriceImportResult, error) {
+	if len(rows) > MaxPriceImportRows {
+		return nil, ErrTooManyPriceRows
+	}
+	result := &PriceImportResult{Total: len(rows), Rejected: []RejectedPriceRow{}}
+
+	byID, bySymbol, err := s.resolvePriceRowSecurities(rows)
+	if err != nil {
+		return nil, fmt.Errorf("import prices: %w", err)
+	}
+
+	prices, rejected := checkPriceRows(rows, byID, bySymbol)
+	result.Rejected = append(result.Rejected, rejected...)
+
+	err = s.repo.InTransaction(func(repo *repository.InvestmentRepository) error {
+		return savePrices(repo, prices, result)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("import prices: %w", err)
+	}
+	return result, nil
+}
+
+// priceWriter is the part of the repository a price import writes through.
+type priceWriter interface {
+	CountExistingPrices(prices []model.PriceHistory) (int64, error)
+	UpsertPriceHistories(prices []model.PriceHistory) error
+}
+
+// checkPriceRows parses the rows against the resolved securities, keeping
+// the first row of each security and date.
+func checkPriceRows(rows []PriceRow, byID map[uint]*model.Security, bySymbol map[string]*model.Security) ([]model.PriceHistory, []RejectedPriceRow) {
+	type priceKey struct {
+		securityID uint
+		date       string
+	}
+	seen := map[priceKey]int{}
+	prices := make([]model.PriceHistory, 0, len(rows))
+	var rejected []RejectedPriceRow
+	for _, row := range rows {
+		sec, reason := lookupPriceRowSecurity(row, byID, bySymbol)
+		var p model.PriceHistory
+		if reason == "" {
+			p, reason = parsePriceRow(row)
+		}
+		if reason == "" {
+			key := priceKey{sec.ID, row.Date}
+			if first, ok := seen[key]; ok {
+				reason = fmt.Sprintf("duplicate of row %d", first)
+			} else {
+				seen[key] = row.Row
+			}
+		}
+		if reason != "" {
+			rejected = append(rejected, RejectedPriceRow{Row: row.Row, Reason: reason})
+			continue
+		}
+		p.SecurityID = sec.ID
+		prices = append(prices, p)
+	}
+	return prices, rejected
+}
+
+// savePrices upserts prices in batches, counting those that replace a
+// stored price as updated and the rest as inserted.
+func savePrices(repo priceWriter, prices []model.PriceHistory, result *PriceImportResult) error {
+	for start := 0; start < len(prices); start += priceImportBatch {
+		batch := prices[start:min(start+priceImportBatch, len(prices))]
+		existing, err := repo.CountExistingPrices(batch)
+		if err != nil {
+			return err
+		}
+		if err := repo.UpsertPriceHistories(batch); err != nil {
+			return err
+		}
+		result.Updated += int(existing)
+		result.Inserted += len(batch) - int(existing)
+	}
+	return nil
+}
+
+// resolvePriceRowSecurities loads every security the rows name, keyed by ID
+// and by upper-case symbol.
+func (s *InvestmentService) resolvePriceRowSecurities(rows []PriceRow) (map[uint]*model.Security, map[string]*model.Security, error) {
+	idSet, symbolSet := map[uint]bool{}, map[string]bool{}
+	for _, row := range rows {
+		if row.SecurityID != 0 {
+			idSet[row.SecurityID] = true
+		} else if row.Symbol != "" {
+			symbolSet[strings.ToUpper(row.Symbol)] = true
+		}
+	}
+	ids := make([]uint, 0, len(idSet))
+	for id := range idSet {
+		ids = append(ids, id)
+	}
+	symbols := make([]string, 0, len(symbolSet))
+	for sym := range symbolSet {
+		symbols = append(symbols, sym)
+	}
+
+	found, err := s.repo.GetSecuritiesByIDsOrSymbols(ids, symbols)
+	if err != nil {
+		return nil, nil, err
+	}
+	byID := make(map[uint]*model.Security, len(found))
+	bySymbol := make(map[string]*model.Security, len(found))
+	for i := range found {
+		byID[found[i].ID] = &found[i]
+		bySymbol[strings.ToUpper(found[i].Symbol)] = &found[i]
+	}
+	return byID, bySymbol, nil
+}
+
+func lookupPriceRowSecurity(row PriceRow, byID map[uint]*model.Security, bySymbol map[string]*model.Security) (*model.Security, string) {
+	switch {
+	case row.SecurityID != 0:
+		if sec, ok := byID[row.SecurityID]; ok {
+			return sec, ""
+		}
+		return nil, fmt.Sprintf("unknown security_id %d", row.SecurityID)
+	case row.Symbol != "":
+		if sec, ok := bySymbol[strings.ToUpper(row.Symbol)]; ok {
+			return sec, ""
+		}
+		return nil, fmt.Sprintf("unknown symbol %q", row.Symbol)
+	default:
+		return nil, "security_id or symbol required"
+	}
+}
+
+// parsePriceRow checks one row, returning why it is rejected if it is.
+func parsePriceRow(row PriceRow) (model.PriceHistory, string) {
+	var p model.PriceHistory
+	date, err := time.Parse(time.DateOnly, row.Date)
+	if err != nil {
+		return p, fmt.Sprintf("invalid date %q, use YYYY-MM-DD", row.Date)
+	}
+	p.Date = date
+	if row.Close == "" {
+		return p, "close required"
+	}
+
+	for _, f := range []struct {
+		name string
+		val  string
+		dst  *decimal.Decimal
+	}{{"open", row.Open, &p.Open}, {"high", row.High, &p.High}, {"low", row.Low, &p.Low}, {"close", row.Close, &p.Close}} {
+		if f.val == "" {
+			continue
+		}
+		v, err := decimal.NewFromString(f.val)
+		if err != nil {
+			return p, fmt.Sprintf("invalid %s %q", f.name, f.val)
+		}
+		if v.IsNegative() {
+			return p, fmt.Sprintf("%s must not be negative", f.name)
+		}
+		*f.dst = v
+	}
+	if !p.Close.IsPositive() {
+		return p, "close must be positive"
+	}
+	if !p.High.IsZero() && !p.Low.IsZero() && p.High.LessThan(p.Low) {
+		return p, "high is below low"
+	}
+
+	if row.Volume != "" {
+		v, err := decimal.NewFromString(row.Volume)
+		if err != nil || !v.IsInteger() || v.IsNegative() {
+			return p, fmt.Sprintf("invalid volume %q", row.Volume)
+		}
+		p.Volume = v.IntPart()
+	}
+	return p, ""
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"investment/internal/domain/model"
+	"testing"
+	"time"
+)
+
+// memoryPrices keeps prices by security and date.
+type memoryPrices struct {
+	prices  map[string]model.PriceHistory
+	batches int
+	failAt  int
+}
+
+func newMemoryPrices() *memoryPrices {
+	return &memoryPrices{prices: map[string]model.PriceHistory{}}
+}
+
+func priceKeyOf(p model.PriceHistory) string {
+	return fmt.Sprintf("%d/%s", p.SecurityID, p.Date.Format(time.DateOnly))
+}
+
+func (m *memoryPrices) CountExistingPrices(prices []model.PriceHistory) (int64, error) {
+	var n int64
+	for _, p := range prices {
+		if _, ok := m.prices[priceKeyOf(p)]; ok {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *memoryPrices) UpsertPriceHistories(prices []model.PriceHistory) error {
+	m.batches++
+	if m.batches == m.failAt {
+		return errors.New("upsert failed")
+	}
+	for _, p := range prices {
+		m.prices[priceKeyOf(p)] = p
+	}
+	return nil
+}
+
+var (
+	sber = &model.Security{ID: 1, Symbol: "SBER"}
+	gazp = &model.Security{ID: 2, Symbol: "GAZP"}
+
+	priceSecuritiesByID     = map[uint]*model.Security{1: sber, 2: gazp}
+	priceSecuritiesBySymbol = map[string]*model.Security{"SBER": sber, "GAZP": gazp}
+)
+
+func TestCheckPriceRowsRejections(t *testing.T) {
+	tests := []struct {
+		name   string
+		row    PriceRow
+		reason string
+	}{
+		{"unknown id", PriceRow{SecurityID: 9, Date: "2026-01-05", Close: "1"}, "unknown security_id 9"},
+		{"unknown symbol", PriceRow{Symbol: "XXX", Date: "2026-01-05", Close: "1"}, `unknown symbol "XXX"`},
+		{"no security", PriceRow{Date: "2026-01-05", Close: "1"}, "security_id or symbol required"},
+		{"bad date", PriceRow{SecurityID: 1, Date: "05.01.2026", Close: "1"}, `invalid date "05.01.2026", use YYYY-MM-DD`},
+		{"no close", PriceRow{SecurityID: 1, Date: "2026-01-05"}, "close required"},
+		{"zero close", PriceRow{SecurityID: 1, Date: "2026-01-05", Close: "0"}, "close must be positive"},
+		{"bad open", PriceRow{SecurityID: 1, Date: "2026-01-05", Open: "1,5", Close: "1"}, `invalid open "1,5"`},
+		{"negative low", PriceRow{SecurityID: 1, Date: "2026-01-05", Low: "-1", Close: "1"}, "low must not be negative"},
+		{"high below low", PriceRow{SecurityID: 1, Date: "2026-01-05", High: "1", Low: "2", Close: "1.5"}, "high is below low"},
+		{"fractional volume", PriceRow{SecurityID: 1, Date: "2026-01-05", Close: "1", Volume: "10.5"}, `invalid volume "10.5"`},
+		{"negative volume", PriceRow{SecurityID: 1, Date: "2026-01-05", Close: "1", Volume: "-3"}, `invalid volume "-3"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.row.Row = 7
+			prices, rejected := checkPriceRows([]PriceRow{tt.row}, priceSecuritiesByID, priceSecuritiesBySymbol)
+			if len(prices) != 0 || len(rejected) != 1 {
+				t.Fatalf("got %d prices and %d rejections, want one rejection", len(prices), len(rejected))
+			}
+			if r := rejected[0]; r.Row != 7 || r.Reason != tt.reason {
+				t.Errorf("rejected row %d: %q, want row 7: %q", r.Row, r.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestCheckPriceRowsDuplicates(t *testing.T) {
+	rows := []PriceRow{
+		{Row: 1, SecurityID: 1, Date: "2026-01-05", Open: "300", High: "310", Low: "295", Close: "305", Volume: "1000"},
+		{Row: 2, SecurityID: 2, Date: "2026-01-05", Close: "160"},
+		// The same security by symbol, in any case, on the same date
+		{Row: 3, Symbol: "sber", Date: "2026-01-05", Close: "306"},
+		{Row: 4, SecurityID: 1, Date: "2026-01-06", Close: "307"},
+		// An invalid row doesn't claim its date
+		{Row: 5, SecurityID: 2, Date: "2026-01-06", Close: "-1"},
+		{Row: 6, Symbol: "GAZP", Date: "2026-01-06", Close: "161"},
+		{Row: 7, SecurityID: 2, Date: "2026-01-06", Close: "162"},
+	}
+
+	prices, rejected := checkPriceRows(rows, priceSecuritiesByID, priceSecuritiesBySymbol)
+	want := []RejectedPriceRow{
+		{Row: 3, Reason: "duplicate of row 1"},
+		{Row: 5, Reason: "close must not be negative"},
+		{Row: 7, Reason: "duplicate of row 6"},
+	}
+	if len(rejected) != len(want) {
+		t.Fatalf("rejected = %+v, want %+v", rejected, want)
+	}
+	for i := range want {
+		if rejected[i] != want[i] {
+			t.Errorf("rejected[%d] = %+v, want %+v", i, rejected[i], want[i])
+		}
+	}
+
+	if len(prices) != 4 {
+		t.Fatalf("got %d prices, want 4", len(prices))
+	}
+	first := prices[0]
+	if first.SecurityID != 1 || !first.Date.Equal(day(5)) || !first.Open.Equal(dec("300")) ||
+		!first.High.Equal(dec("310")) || !first.Low.Equal(dec("295")) || !first.Close.Equal(dec("305")) || first.Volume != 1000 {
+		t.Errorf("first price = %+v", first)
+	}
+	if last := prices[3]; last.SecurityID != 2 || !last.Close.Equal(dec("161")) {
+		t.Errorf("last price = security %d closing at %s, want GAZP at 161", last.SecurityID, last.Close)
+	}
+}
+
+func TestSavePricesCounts(t *testing.T) {
+	store := newMemoryPrices()
+	store.prices[priceKeyOf(model.PriceHistory{SecurityID: 1, Date: day(5)})] = model.PriceHistory{SecurityID: 1, Date: day(5), Close: dec("300")}
+
+	prices := []model.PriceHistory{
+		{SecurityID: 1, Date: day(5), Close: dec("305")},
+		{SecurityID: 1, Date: day(6), Close: dec("307")},
+		{SecurityID: 2, Date: day(5), Close: dec("160")},
+	}
+	result := &PriceImportResult{}
+	if err := savePrices(store, prices, result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Inserted != 2 || result.Updated != 1 {
+		t.Fatalf("inserted %d, updated %d; want 2 and 1", result.Inserted, result.Updated)
+	}
+	if p := store.prices[priceKeyOf(prices[0])]; !p.Close.Equal(dec("305")) {
+		t.Fatalf("stored close = %s, want the imported 305", p.Close)
+	}
+}
+
+func TestSavePricesBatches(t *testing.T) {
+	store := newMemoryPrices()
+	prices := make([]model.PriceHistory, 2*priceImportBatch+1)
+	for i := range prices {
+		prices[i] = model.PriceHistory{SecurityID: 1, Date: day(1).AddDate(0, 0, i), Close: dec("1")}
+	}
+
+	result := &PriceImportResult{}
+	if err := savePrices(store, prices, result); err != nil {
+		t.Fatal(err)
+	}
+	if store.batches != 3 || result.Inserted != len(prices) || result.Updated != 0 {
+		t.Fatalf("%d batches, inserted %d, updated %d; want 3, %d and 0", store.batches, result.Inserted, result.Updated, len(prices))
+	}
+
+	// Importing the same file again replaces every price
+	result = &PriceImportResult{}
+	if err := savePrices(store, prices, result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Inserted != 0 || result.Updated != len(prices) {
+		t.Fatalf("re-import inserted %d, updated %d; want 0 and %d", result.Inserted, result.Updated, len(prices))
+	}
+
+	store.failAt = store.batches + 2
+	if err := savePrices(store, prices, &PriceImportResult{}); err == nil {
+		t.Fatal("a failed batch was not reported")
+	}
+}
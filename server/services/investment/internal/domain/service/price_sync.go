@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"investment/internal/data/repository"
+	"investment/internal/domain/model"
+	"investment/pkg/logger/sl"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PriceProvider fetches daily OHLCV bars from a market data source.
+type PriceProvider interface {
+	// Name identifies the provider in sync statuses.
+	Name() string
+	// FetchDaily returns the bars of sec dated in [from, to], oldest first.
+	// Days the source has no bar for are left out.
+	FetchDaily(ctx context.Context, sec *model.Security, from, to time.Time) ([]model.PriceHistory, error)
+}
+
+const (
+	// maxPriceGap is how many days apart stored prices may be before the days
+	// between are fetched; weekends and exchange holidays are shorter.
+	maxPriceGap = 7
+	// maxGapFetches bounds the gaps fetched per security and sync. A gap the
+	// provider has no bars for either, e.g. a trading halt, stays and is
+	// tried again on the next sync.
+	maxGapFetches = 3
+)
+
+type PriceSyncResult struct {
+	Securities int `json:"securities"`
+	Synced     int `json:"synced"`
+	Failed     int `json:"failed"`
+	Rows       int `json:"rows"`
+}
+
+// PriceSyncService keeps the price history of held securities up to date
+// from a PriceProvider.
+type PriceSyncService struct {
+	repo     *repository.InvestmentRepository
+	provider PriceProvider
+	backfill int
+	log      *slog.Logger
+}
+
+// NewPriceSyncService syncs through provider. Securities without trades are
+// backfilled backfillDays into the past; traded ones from their first trade.
+func NewPriceSyncService(repo *repository.InvestmentRepository, provider PriceProvider, backfillDays int, log *slog.Logger) *PriceSyncService {
+	return &PriceSyncService{repo: repo, provider: provider, backfill: backfillDays, log: log}
+}
+
+// SyncAll syncs every held security up to today. A security that fails is
+// recorded as failed and doesn't stop the others.
+func (s *PriceSyncService) SyncAll(ctx context.Context) (PriceSyncResult, error) {
+	var res PriceSyncResult
+	held, err := s.repo.GetHeldSecurities()
+	if err != nil {
+		return res, fmt.Errorf("sync prices: %w", err)
+	}
+
+	today := truncateDay(time.Now())
+	for i := range held {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		res.Securities++
+		rows, err := s.SyncSecurity(ctx, &held[i], today)
+		res.Rows += rows
+		if err != nil {
+			res.Failed++
+			s.log.Error("Price sync failed", slog.String("symbol", held[i].Symbol), sl.Err(err))
+			continue
+		}
+		res.Synced++
+	}
+	return res, nil
+}
+
+// SyncSecurity fetches the history missing before the first and after the
+// last stored price, up to today, and in the latest gaps between stored
+// prices, and records the outcome.
+func (s *PriceSyncService) SyncSecurity(ctx context.Context, sec *repository.HeldSecurity, today time.Time) (int, error) {
+	status, err := s.repo.GetPriceSyncStatus(sec.ID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, err
+		}
+		status = &model.PriceSyncStatus{SecurityID: sec.ID}
+	}
+
+	rows, syncErr := s.fetchMissing(ctx, sec, status, today)
+
+	status.Provider = s.provider.Name()
+	status.LastSyncedAt = time.Now()
+	status.RowsFetched = rows
+	if syncErr != nil {
+		status.Status = model.PriceSyncFailed
+		status.LastError = syncErr.Error()
+	} else {
+		status.Status = model.PriceSyncOK
+		status.LastError = ""
+		status.LastSuccessAt = &status.LastSyncedAt
+	}
+	if err := s.repo.SavePriceSyncStatus(status); err != nil {
+		return rows, errors.Join(syncErr, err)
+	}
+	return rows, syncErr
+}
+
+func (s *PriceSyncService) fetchMissing(ctx context.Context, sec *repository.HeldSecurity, status *model.PriceSyncStatus, today time.Time) (int, error) {
+	start := today.AddDate(0, 0, -s.backfill)
+	if sec.FirstTraded != nil {
+		start = truncateDay(*sec.FirstTraded)
+	}
+
+	first, last, err := s.repo.GetPriceDateRange(sec.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	type dateRange struct {
+		from, to time.Time
+		gap      bool
+	}
+	var ranges []dateRange
+	covered := status.CoveredFrom
+	if covered == nil || (first != nil && first.Before(*covered)) {
+		covered = first
+	}
+	switch {
+	case last == nil:
+		ranges = append(ranges, dateRange{start, today, false})
+	default:
+		if start.Before(truncateDay(*covered)) {
+			ranges = append(ranges, dateRange{start, truncateDay(*covered).AddDate(0, 0, -1), false})
+		}
+		if next := truncateDay(*last).AddDate(0, 0, 1); !next.After(today) {
+			ranges = append(ranges, dateRange{next, today, false})
+		}
+
+		// Gaps come from prices entered by hand or imported in bulk
+		gaps, err := s.repo.GetPriceGaps(sec.ID, maxPriceGap, maxGapFetches)
+		if err != nil {
+			return 0, err
+		}
+		for _, g := range gaps {
+			ranges = append(ranges, dateRange{truncateDay(g.GapStart), truncateDay(g.GapEnd), true})
+		}
+	}
+
+	rows := 0
+	for _, r := range ranges {
+		bars, err := s.provider.FetchDaily(ctx, &sec.Security, r.from, r.to)
+		if err != nil {
+			return rows, fmt.Errorf("fetch %s to %s: %w", r.from.Format(time.DateOnly), r.to.Format(time.DateOnly), err)
+		}
+		for i := range bars {
+			bars[i].SecurityID = sec.ID
+		}
+		if err := s.repo.UpsertPriceHistories(bars); err != nil {
+			return rows, err
+		}
+		rows += len(bars)
+		if len(bars) > 0 {
+			d := bars[len(bars)-1].Date
+			if status.LastPriceDate == nil || d.After(*status.LastPriceDate) {
+				status.LastPriceDate = &d
+			}
+		}
+		if !r.gap && (status.CoveredFrom == nil || r.from.Before(*status.CoveredFrom)) {
+			from := r.from
+			status.CoveredFrom = &from
+		}
+	}
+	if status.LastPriceDate == nil && last != nil {
+		status.LastPriceDate = last
+	}
+	return rows, nil
+}
+
+func (s *InvestmentService) GetPriceSyncStatuses() ([]model.PriceSyncStatus, error) {
+	return s.repo.GetPriceSyncStatuses()
+}
@@ -5,12 +5,24 @@ import (
 	"fmt"
 	"investment/internal/data/repository"
 	"investment/internal/domain/model"
+	"investment/pkg/cursor"
 	"time"
 
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrNotFound and ErrForbidden classify the errors below; match them with errors.Is
+	ErrNotFound  = errors.New("not found")
+	ErrForbidden = errors.New("forbidden")
+
+	ErrBrokerNotFound        = fmt.Errorf("broker %w", ErrNotFound)
+	ErrBrokerAccessDenied    = fmt.Errorf("%w: access denied to this broker", ErrForbidden)
+	ErrPortfolioNotFound     = fmt.Errorf("portfolio %w", ErrNotFound)
+	ErrPortfolioAccessDenied = fmt.Errorf("%w: access denied to this portfolio", ErrForbidden)
+)
+
 type InvestmentService struct {
 	repo *repository.InvestmentRepository
 }
@@ -40,19 +52,30 @@ func (s *InvestmentService) GetBrokers(userID uint) ([]model.Broker, error) {
 }
 
 // Portfolio methods
-func (s *InvestmentService) CreatePortfolio(userID uint, brokerID uint, name, baseCurrency string) (*model.Portfolio, error) {
+func (s *InvestmentService) CreatePortfolio(userID uint, brokerID uint, name, baseCurrency string, method model.CostBasisMethod, enforceCash bool) (*model.Portfolio, error) {
 	if name == "" {
 		return nil, fmt.Errorf("create portfolio: name required")
 	}
+	if method == "" {
+		method = model.CostBasisFIFO
+	}
+	if !model.IsValidCostBasisMethod(method) {
+		return nil, ErrInvalidCostBasisMethod
+	}
+	if _, err := s.authorizeBroker(brokerID, userID); err != nil {
+		return nil, err
+	}
 	if baseCurrency == "" {
 		baseCurrency = "RUB"
 	}
 	p := &model.Portfolio{
-		UserID:       userID,
-		BrokerID:     brokerID,
-		Name:         name,
-		BaseCurrency: baseCurrency,
-		CreatedAt:    time.Now(),
+		UserID:          userID,
+		BrokerID:        brokerID,
+		Name:            name,
+		BaseCurrency:    baseCurrency,
+		CostBasisMethod: method,
+		EnforceCash:     enforceCash,
+		CreatedAt:       time.Now(),
 	}
 	if err := s.repo.CreatePortfolio(p); err != nil {
 		return nil, fmt.Errorf("create portfolio: %w", err)
@@ -64,8 +87,37 @@ func (s *InvestmentService) GetPortfolios(userID uint) ([]model.Portfolio, error
 	return s.repo.GetPortfoliosByUserID(userID)
 }
 
-func (s *InvestmentService) GetPortfolio(portfolioID uint) (*model.Portfolio, error) {
-	return s.repo.GetPortfolioByID(portfolioID)
+func (s *InvestmentService) GetPortfolio(portfolioID, userID uint) (*model.Portfolio, error) {
+	return s.authorizePortfolio(portfolioID, userID)
+}
+
+// authorizePortfolio loads the portfolio and checks that userID owns it.
+func (s *InvestmentService) authorizePortfolio(portfolioID, userID uint) (*model.Portfolio, error) {
+	p, err := s.repo.GetPortfolioByID(portfolioID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPortfolioNotFound
+		}
+		return nil, fmt.Errorf("get portfolio: %w", err)
+	}
+	if p.UserID != userID {
+		return nil, ErrPortfolioAccessDenied
+	}
+	return p, nil
+}
+
+func (s *InvestmentService) authorizeBroker(brokerID, userID uint) (*model.Broker, error) {
+	b, err := s.repo.GetBrokerByID(brokerID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBrokerNotFound
+		}
+		return nil, fmt.Errorf("get broker: %w", err)
+	}
+	if b.UserID != userID {
+		return nil, ErrBrokerAccessDenied
+	}
+	return b, nil
 }
 
 // Security methods
@@ -101,8 +153,12 @@ func (s *InvestmentService) SearchSecurities(query string, securityType model.Se
 	return s.repo.SearchSecurities(query, securityType)
 }
 
-// Trade methods with automatic holding update
-func (s *InvestmentService) ExecuteTrade(portfolioID, securityID uint, side model.TradeSide, qty, price, fee decimal.Decimal, tradeDate time.Time, note string) (*model.Trade, error) {
+// ExecuteTrade books a trade, settles it on the cash ledger and rebuilds the
+// security's tax lots, realized gains and holding. A sell may name the lots it
+// closes in picks; otherwise the portfolio's cost basis method chooses. Sells
+// beyond the quantity held at the trade date, and buys beyond the cash held
+// when the portfolio enforces cash, are rejected and nothing is stored.
+func (s *InvestmentService) ExecuteTrade(userID, portfolioID, securityID uint, side model.TradeSide, qty, price, fee decimal.Decimal, tradeDate time.Time, note string, picks []LotPick) (*model.Trade, error) {
 	if !model.IsValidTradeSide(side) {
 		return nil, fmt.Errorf("execute trade: invalid side '%s'", side)
 	}
@@ -115,8 +171,17 @@ func (s *InvestmentService) ExecuteTrade(portfolioID, securityID uint, side mode
 	if fee.LessThan(decimal.Zero) {
 		return nil, fmt.Errorf("execute trade: fee cannot be negative")
 	}
+	if side == model.TradeSideBuy && len(picks) > 0 {
+		return nil, ErrInvalidLotSelection
+	}
+	p, err := s.authorizePortfolio(portfolioID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if side == model.TradeSideSell && p.CostBasisMethod == model.CostBasisSpecific && len(picks) == 0 {
+		return nil, ErrLotSelectionRequired
+	}
 
-	// Create trade
 	t := &model.Trade{
 		PortfolioID: portfolioID,
 		SecurityID:  securityID,
@@ -128,81 +193,98 @@ func (s *InvestmentService) ExecuteTrade(portfolioID, securityID uint, side mode
 		Note:        note,
 	}
 
-	if err := s.repo.CreateTrade(t); err != nil {
-		return nil, fmt.Errorf("execute trade: %w", err)
-	}
+	err = s.repo.InTransaction(func(repo *repository.InvestmentRepository) error {
+		p, err := repo.GetPortfolioByIDForUpdate(portfolioID)
+		if err != nil {
+			return err
+		}
+		sec, err := repo.GetSecurityByID(securityID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSecurityNotFound
+			}
+			return err
+		}
+		if side == model.TradeSideBuy && p.EnforceCash {
+			cost := qty.Mul(price).Add(fee).Round(4)
+			if err := requireCash(repo, portfolioID, sec.Currency, cost, tradeDate); err != nil {
+				return err
+			}
+		}
 
-	// Update holding
-	if err := s.recalculateHolding(portfolioID, securityID); err != nil {
-		return nil, fmt.Errorf("execute trade: failed to update holding: %w", err)
+		if err := repo.CreateTrade(t); err != nil {
+			return err
+		}
+		if err := repo.CreateCashEntries(tradeCashEntries(t, sec.Currency)); err != nil {
+			return err
+		}
+
+		selections := make([]model.LotSelection, len(picks))
+		for i, pick := range picks {
+			selections[i] = model.LotSelection{SellTradeID: t.ID, LotID: pick.LotID, Quantity: pick.Quantity}
+		}
+		if err := repo.CreateLotSelections(selections); err != nil {
+			return err
+		}
+
+		return rebuildPosition(repo, p, securityID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("execute trade: %w", err)
 	}
 
 	return t, nil
 }
 
-func (s *InvestmentService) GetTrades(portfolioID uint) ([]model.Trade, error) {
+func (s *InvestmentService) GetTrades(portfolioID, userID uint) ([]model.Trade, error) {
+	if _, err := s.authorizePortfolio(portfolioID, userID); err != nil {
+		return nil, err
+	}
 	return s.repo.GetTradesByPortfolioID(portfolioID)
 }
 
-func (s *InvestmentService) GetTradesPaginated(portfolioID uint, limit, offset int) ([]model.Trade, int64, error) {
+func (s *InvestmentService) GetTradesPaginated(portfolioID, userID uint, limit, offset int) ([]model.Trade, int64, error) {
+	if _, err := s.authorizePortfolio(portfolioID, userID); err != nil {
+		return nil, 0, err
+	}
 	return s.repo.GetTradesByPortfolioIDPaginated(portfolioID, limit, offset)
 }
 
-// Holding methods
-func (s *InvestmentService) recalculateHolding(portfolioID, securityID uint) error {
-	trades, err := s.repo.GetTradesBySecurityID(portfolioID, securityID)
-	if err != nil {
-		return err
+// GetTradesPage returns one keyset page of trades, newest first, starting
+// after the trade encoded in token. The returned token is empty on the last page.
+func (s *InvestmentService) GetTradesPage(portfolioID, userID uint, token string, limit int) ([]model.Trade, string, error) {
+	if _, err := s.authorizePortfolio(portfolioID, userID); err != nil {
+		return nil, "", err
 	}
 
-	if len(trades) == 0 {
-		return s.repo.DeleteHolding(portfolioID, securityID)
-	}
-
-	// Calculate using FIFO for average cost
-	quantity := decimal.Zero
-	totalCost := decimal.Zero
-
-	for _, t := range trades {
-		tradeCost := t.Quantity.Mul(t.Price).Add(t.Fee)
-
-		if t.Side == model.TradeSideBuy {
-			quantity = quantity.Add(t.Quantity)
-			totalCost = totalCost.Add(tradeCost)
-		} else {
-			// For sells, reduce quantity proportionally
-			if quantity.IsPositive() {
-				avgCost := totalCost.Div(quantity)
-				quantity = quantity.Sub(t.Quantity)
-				if quantity.IsPositive() {
-					totalCost = quantity.Mul(avgCost)
-				} else {
-					totalCost = decimal.Zero
-					quantity = decimal.Zero
-				}
-			}
+	var key cursor.Key
+	if token != "" {
+		var err error
+		if key, err = cursor.Decode(token); err != nil {
+			return nil, "", err
 		}
 	}
 
-	if quantity.IsZero() || quantity.IsNegative() {
-		return s.repo.DeleteHolding(portfolioID, securityID)
+	// One extra row tells whether another page exists
+	trades, err := s.repo.GetTradesByPortfolioIDAfter(portfolioID, key.Time, key.ID, limit+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("get trades page: %w", err)
 	}
 
-	avgCost := totalCost.Div(quantity)
-
-	holding := &model.Holding{
-		PortfolioID: portfolioID,
-		SecurityID:  securityID,
-		Quantity:    quantity,
-		AverageCost: avgCost,
-		TotalCost:   totalCost,
-		UpdatedAt:   time.Now(),
+	next := ""
+	if len(trades) > limit {
+		trades = trades[:limit]
+		last := trades[limit-1]
+		next = cursor.Encode(cursor.Key{Time: last.TradeDate, ID: last.ID})
 	}
-
-	return s.repo.UpsertHolding(holding)
+	return trades, next, nil
 }
 
-func (s *InvestmentService) GetHoldings(portfolioID uint) ([]model.Holding, error) {
+// Holding methods
+func (s *InvestmentService) GetHoldings(portfolioID, userID uint) ([]model.Holding, error) {
+	if _, err := s.authorizePortfolio(portfolioID, userID); err != nil {
+		return nil, err
+	}
 	return s.repo.GetHoldingsByPortfolioID(portfolioID)
 }
 
@@ -222,9 +304,39 @@ type PortfolioSummary struct {
 	TotalUnrealPnL   decimal.Decimal  `json:"total_unrealized_pnl"`
 	TotalUnrealPct   decimal.Decimal  `json:"total_unrealized_pct"`
 	Holdings         []HoldingWithPnL `json:"holdings"`
+	// Cash lists every currency; TotalCash and TotalValue only count cash
+	// in the portfolio's base currency
+	Cash       []repository.CashBalance `json:"cash"`
+	TotalCash  decimal.Decimal          `json:"total_cash"`
+	TotalValue decimal.Decimal          `json:"total_value"`
+}
+
+func (s *InvestmentService) CalculatePortfolioValue(portfolioID, userID uint) (*PortfolioSummary, error) {
+	p, err := s.authorizePortfolio(portfolioID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := s.valueHoldings(portfolioID)
+	if err != nil {
+		return nil, err
+	}
+
+	cash, err := s.repo.GetCashBalances(portfolioID)
+	if err != nil {
+		return nil, fmt.Errorf("calculate portfolio: failed to get cash: %w", err)
+	}
+	summary.Cash = cash
+	for _, c := range cash {
+		if c.Currency == p.BaseCurrency {
+			summary.TotalCash = summary.TotalCash.Add(c.Amount)
+		}
+	}
+	summary.TotalValue = summary.TotalMarketValue.Add(summary.TotalCash)
+	return summary, nil
 }
 
-func (s *InvestmentService) CalculatePortfolioValue(portfolioID uint) (*PortfolioSummary, error) {
+func (s *InvestmentService) valueHoldings(portfolioID uint) (*PortfolioSummary, error) {
 	holdings, err := s.repo.GetHoldingsByPortfolioID(portfolioID)
 	if err != nil {
 		return nil, fmt.Errorf("calculate portfolio: %w", err)
@@ -321,6 +433,6 @@ func (s *InvestmentService) GetPriceHistory(securityID uint, from, to time.Time)
 }
 
 // Legacy method for backward compatibility
-func (s *InvestmentService) CreateTrade(portfolioID, securityID uint, side string, qty, price, fee decimal.Decimal, date time.Time) (*model.Trade, error) {
-	return s.ExecuteTrade(portfolioID, securityID, model.TradeSide(side), qty, price, fee, date, "")
+func (s *InvestmentService) CreateTrade(userID, portfolioID, securityID uint, side string, qty, price, fee decimal.Decimal, date time.Time) (*model.Trade, error) {
+	return s.ExecuteTrade(userID, portfolioID, securityID, model.TradeSide(side), qty, price, fee, date, "", nil)
 }
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"investment/internal/data/repository"
+	"investment/internal/domain/model"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrOversell               = errors.New("sell quantity exceeds held quantity")
+	ErrInvalidCostBasisMethod = errors.New("invalid cost basis method")
+	ErrLotSelectionRequired   = errors.New("portfolio uses specific lot identification: sells must name their lots")
+	ErrInvalidLotSelection    = errors.New("lot selection must name open lots of the security and add up to the sell quantity")
+)
+
+// LotPick asks for quantity to be sold out of one lot.
+type LotPick struct {
+	LotID    uint
+	Quantity decimal.Decimal
+}
+
+type RealizedSummary struct {
+	PortfolioID   uint                 `json:"portfolio_id"`
+	TotalProceeds decimal.Decimal      `json:"total_proceeds"`
+	TotalCost     decimal.Decimal      `json:"total_cost_basis"`
+	TotalGain     decimal.Decimal      `json:"total_gain"`
+	Gains         []model.RealizedGain `json:"gains"`
+}
+
+func (s *InvestmentService) GetTaxLots(portfolioID, userID, securityID uint, includeClosed bool) ([]model.TaxLot, error) {
+	if _, err := s.authorizePortfolio(portfolioID, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetTaxLotsByPortfolioID(portfolioID, securityID, includeClosed)
+}
+
+// GetRealizedGains reports gains from sells between from and to; zero times
+// leave that side open.
+func (s *InvestmentService) GetRealizedGains(portfolioID, userID, securityID uint, from, to time.Time) (*RealizedSummary, error) {
+	if _, err := s.authorizePortfolio(portfolioID, userID); err != nil {
+		return nil, err
+	}
+	gains, err := s.repo.GetRealizedGains(portfolioID, securityID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("get realized gains: %w", err)
+	}
+
+	summary := &RealizedSummary{PortfolioID: portfolioID, Gains: gains}
+	for _, g := range gains {
+		summary.TotalProceeds = summary.TotalProceeds.Add(g.Proceeds)
+		summary.TotalCost = summary.TotalCost.Add(g.CostBasis)
+		summary.TotalGain = summary.TotalGain.Add(g.Gain)
+	}
+	return summary, nil
+}
+
+// BackfillTaxLots builds lots, realized gains and holdings for positions
+// traded before lots were tracked. Positions whose history can't be replayed,
+// e.g. because it oversells, are skipped and returned in the error.
+func (s *InvestmentService) BackfillTaxLots() (int, error) {
+	positions, err := s.repo.GetPositionsWithoutLots()
+	if err != nil {
+		return 0, fmt.Errorf("backfill tax lots: %w", err)
+	}
+
+	count := 0
+	var errs []error
+	for _, pos := range positions {
+		err := s.repo.InTransaction(func(repo *repository.InvestmentRepository) error {
+			p, err := repo.GetPortfolioByIDForUpdate(pos.PortfolioID)
+			if err != nil {
+				return err
+			}
+			return rebuildPosition(repo, p, pos.SecurityID)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("portfolio %d security %d: %w", pos.PortfolioID, pos.SecurityID, err))
+			continue
+		}
+		count++
+	}
+	return count, errors.Join(errs...)
+}
+
+// rebuildPosition replays every trade of the security in trade date order,
+// rebuilding its lots, realized gains and holding. Replaying the whole history
+// keeps backdated trades consistent with the sells after them.
+func rebuildPosition(repo *repository.InvestmentRepository, p *model.Portfolio, securityID uint) error {
+	trades, err := repo.GetTradesBySecurityID(p.ID, securityID)
+	if err != nil {
+		return err
+	}
+	existing, err := repo.GetTaxLotsBySecurityID(p.ID, securityID)
+	if err != nil {
+		return err
+	}
+	var sellIDs []uint
+	for _, t := range trades {
+		if t.Side == model.TradeSideSell {
+			sellIDs = append(sellIDs, t.ID)
+		}
+	}
+	selections, err := repo.GetLotSelectionsBySellTradeIDs(sellIDs)
+	if err != nil {
+		return err
+	}
+	splits, err := repo.GetSplitsBySecurityID(securityID)
+	if err != nil {
+		return err
+	}
+
+	// Selections name lots by id; the replay knows lots by their buy trade
+	lotTrades := make(map[uint]uint, len(existing))
+	for _, l := range existing {
+		lotTrades[l.ID] = l.TradeID
+	}
+	picks := make(map[uint][]tradePick)
+	for _, sel := range selections {
+		picks[sel.SellTradeID] = append(picks[sel.SellTradeID], tradePick{
+			buyTradeID: lotTrades[sel.LotID],
+			quantity:   sel.Quantity,
+		})
+	}
+
+	lots, matches, err := matchLots(trades, splits, picks, p.CostBasisMethod)
+	if err != nil {
+		return err
+	}
+
+	if err := repo.SaveTaxLots(lots); err != nil {
+		return fmt.Errorf("save tax lots: %w", err)
+	}
+	gains := make([]model.RealizedGain, len(matches))
+	for i, m := range matches {
+		m.gain.LotID = m.lot.ID
+		gains[i] = m.gain
+	}
+	if err := repo.ReplaceRealizedGains(p.ID, securityID, gains); err != nil {
+		return fmt.Errorf("save realized gains: %w", err)
+	}
+
+	quantity, totalCost := decimal.Zero, decimal.Zero
+	for _, l := range lots {
+		quantity = quantity.Add(l.Remaining)
+		totalCost = totalCost.Add(l.Remaining.Mul(l.CostPerUnit))
+	}
+	if !quantity.IsPositive() {
+		return repo.DeleteHolding(p.ID, securityID)
+	}
+	return repo.UpsertHolding(&model.Holding{
+		PortfolioID: p.ID,
+		SecurityID:  securityID,
+		Quantity:    quantity,
+		AverageCost: totalCost.Div(quantity),
+		TotalCost:   totalCost,
+		UpdatedAt:   time.Now(),
+	})
+}
+
+type tradePick struct {
+	buyTradeID uint
+	quantity   decimal.Decimal
+}
+
+type lotMatch struct {
+	lot  *model.TaxLot
+	gain model.RealizedGain
+}
+
+type lotTake struct {
+	lot      *model.TaxLot
+	quantity decimal.Decimal
+}
+
+// matchLots turns buys into lots and matches each sell against them, using
+// the sell's own picks when it has any and method otherwise. Specific-ID
+// sells without picks fall back to FIFO. Splits rescale the lots open on
+// their effective date; trades from that day on are in post-split units.
+func matchLots(trades []model.Trade, splits []model.CorporateAction, picks map[uint][]tradePick, method model.CostBasisMethod) ([]*model.TaxLot, []lotMatch, error) {
+	var lots []*model.TaxLot
+	var matches []lotMatch
+
+	next := 0
+	for _, t := range trades {
+		for ; next < len(splits) && !t.TradeDate.Before(splits[next].EffectiveDate); next++ {
+			applySplit(lots, &splits[next])
+		}
+
+		if t.Side == model.TradeSideBuy {
+			lots = append(lots, &model.TaxLot{
+				PortfolioID: t.PortfolioID,
+				SecurityID:  t.SecurityID,
+				TradeID:     t.ID,
+				AcquiredAt:  t.TradeDate,
+				Quantity:    t.Quantity,
+				Remaining:   t.Quantity,
+				CostPerUnit: t.Quantity.Mul(t.Price).Add(t.Fee).Div(t.Quantity),
+			})
+			continue
+		}
+
+		held := decimal.Zero
+		for _, l := range lots {
+			held = held.Add(l.Remaining)
+		}
+		if t.Quantity.GreaterThan(held) {
+			return nil, nil, fmt.Errorf("%w: selling %s on %s with %s held",
+				ErrOversell, t.Quantity, t.TradeDate.Format("2006-01-02"), held)
+		}
+
+		var takes []lotTake
+		var err error
+		average := false
+		switch {
+		case len(picks[t.ID]) > 0:
+			takes, err = takePicked(lots, picks[t.ID], t.Quantity)
+		case method == model.CostBasisLIFO:
+			takes = takeInOrder(lots, t.Quantity, true)
+		case method == model.CostBasisAverage:
+			takes = takeProportionally(lots, t.Quantity, held)
+			average = true
+		default:
+			takes = takeInOrder(lots, t.Quantity, false)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Under average cost every lot sells at the pool's average
+		poolCost := decimal.Zero
+		if average {
+			for _, l := range lots {
+				poolCost = poolCost.Add(l.Remaining.Mul(l.CostPerUnit))
+			}
+			poolCost = poolCost.Div(held)
+		}
+
+		proceeds := t.Quantity.Mul(t.Price).Sub(t.Fee).Round(4)
+		left := proceeds
+		for i, take := range takes {
+			share := proceeds.Mul(take.quantity).Div(t.Quantity).Round(4)
+			if i == len(takes)-1 {
+				share = left
+			}
+			left = left.Sub(share)
+
+			unitCost := take.lot.CostPerUnit
+			if average {
+				unitCost = poolCost
+			}
+			cost := take.quantity.Mul(unitCost).Round(4)
+			take.lot.Remaining = take.lot.Remaining.Sub(take.quantity)
+
+			matches = append(matches, lotMatch{
+				lot: take.lot,
+				gain: model.RealizedGain{
+					PortfolioID: t.PortfolioID,
+					SecurityID:  t.SecurityID,
+					SellTradeID: t.ID,
+					AcquiredAt:  take.lot.AcquiredAt,
+					SoldAt:      t.TradeDate,
+					Quantity:    take.quantity,
+					Proceeds:    share,
+					CostBasis:   cost,
+					Gain:        share.Sub(cost),
+				},
+			})
+		}
+	}
+	for ; next < len(splits); next++ {
+		applySplit(lots, &splits[next])
+	}
+	return lots, matches, nil
+}
+
+// applySplit turns every RatioFrom units of each lot into RatioTo units.
+// The lot's total cost stays the same.
+func applySplit(lots []*model.TaxLot, split *model.CorporateAction) {
+	factor := split.RatioTo.Div(split.RatioFrom)
+	for _, l := range lots {
+		l.Quantity = l.Quantity.Mul(factor).Round(8)
+		l.Remaining = l.Remaining.Mul(factor).Round(8)
+		l.CostPerUnit = l.CostPerUnit.Div(factor)
+	}
+}
+
+// takeInOrder sells from the oldest lots first, or the newest with reverse.
+func takeInOrder(lots []*model.TaxLot, qty decimal.Decimal, reverse bool) []lotTake {
+	var takes []lotTake
+	for i := range lots {
+		l := lots[i]
+		if reverse {
+			l = lots[len(lots)-1-i]
+		}
+		if !qty.IsPositive() {
+			break
+		}
+		if !l.Remaining.IsPositive() {
+			continue
+		}
+		q := decimal.Min(qty, l.Remaining)
+		takes = append(takes, lotTake{lot: l, quantity: q})
+		qty = qty.Sub(q)
+	}
+	return takes
+}
+
+// takeProportionally sells the same fraction of every open lot, which keeps
+// the average cost of what is left unchanged. The last open lot absorbs
+// rounding.
+func takeProportionally(lots []*model.TaxLot, qty, held decimal.Decimal) []lotTake {
+	var open []*model.TaxLot
+	for _, l := range lots {
+		if l.Remaining.IsPositive() {
+			open = append(open, l)
+		}
+	}
+
+	takes := make([]lotTake, 0, len(open))
+	left := qty
+	for i, l := range open {
+		q := l.Remaining.Mul(qty).Div(held).Round(8)
+		if i == len(open)-1 || q.GreaterThan(left) {
+			q = decimal.Min(left, l.Remaining)
+		}
+		if !q.IsPositive() {
+			continue
+		}
+		takes = append(takes, lotTake{lot: l, quantity: q})
+		left = left.Sub(q)
+	}
+	return takes
+}
+
+// takePicked sells exactly the picked quantities, which must come from lots
+// open at the time of the sell and add up to qty.
+func takePicked(lots []*model.TaxLot, picks []tradePick, qty decimal.Decimal) ([]lotTake, error) {
+	byTrade := make(map[uint]*model.TaxLot, len(lots))
+	for _, l := range lots {
+		byTrade[l.TradeID] = l
+	}
+
+	takes := make([]lotTake, 0, len(picks))
+	taken := make(map[*model.TaxLot]decimal.Decimal)
+	total := decimal.Zero
+	for _, p := range picks {
+		l, ok := byTrade[p.buyTradeID]
+		if !ok || !p.quantity.IsPositive() {
+			return nil, ErrInvalidLotSelection
+		}
+		taken[l] = taken[l].Add(p.quantity)
+		if taken[l].GreaterThan(l.Remaining) {
+			return nil, ErrInvalidLotSelection
+		}
+		takes = append(takes, lotTake{lot: l, quantity: p.quantity})
+		total = total.Add(p.quantity)
+	}
+	if !total.Equal(qty) {
+		return nil, ErrInvalidLotSelection
+	}
+	return takes, nil
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"errors"
+	"investment/internal/domain/model"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func day(n int) time.Time {
+	return time.Date(2026, 1, n, 0, 0, 0, 0, time.UTC)
+}
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func buy(id uint, date time.Time, qty, price, fee string) model.Trade {
+	return model.Trade{ID: id, TradeDate: date, Side: model.TradeSideBuy, Quantity: dec(qty), Price: dec(price), Fee: dec(fee)}
+}
+
+func sell(id uint, date time.Time, qty, price, fee string) model.Trade {
+	return model.Trade{ID: id, TradeDate: date, Side: model.TradeSideSell, Quantity: dec(qty), Price: dec(price), Fee: dec(fee)}
+}
+
+// gainWant is a realized gain by the buy trade of its lot.
+type gainWant struct {
+	buyTradeID               uint
+	quantity, proceeds, cost string
+}
+
+func checkGains(t *testing.T, matches []lotMatch, want []gainWant) {
+	t.Helper()
+	if len(matches) != len(want) {
+		t.Fatalf("got %d gains, want %d", len(matches), len(want))
+	}
+	for i, w := range want {
+		m := matches[i]
+		g := m.gain
+		if m.lot.TradeID != w.buyTradeID || !g.Quantity.Equal(dec(w.quantity)) ||
+			!g.Proceeds.Equal(dec(w.proceeds)) || !g.CostBasis.Equal(dec(w.cost)) ||
+			!g.Gain.Equal(dec(w.proceeds).Sub(dec(w.cost))) {
+			t.Errorf("gain %d = lot of trade %d: %s sold for %s costing %s (gain %s), want %+v",
+				i, m.lot.TradeID, g.Quantity, g.Proceeds, g.CostBasis, g.Gain, w)
+		}
+	}
+}
+
+func checkRemaining(t *testing.T, lots []*model.TaxLot, want ...string) {
+	t.Helper()
+	if len(lots) != len(want) {
+		t.Fatalf("got %d lots, want %d", len(lots), len(want))
+	}
+	for i, w := range want {
+		if !lots[i].Remaining.Equal(dec(w)) {
+			t.Errorf("lot %d remaining = %s, want %s", i, lots[i].Remaining, w)
+		}
+	}
+}
+
+func TestMatchLotsMethods(t *testing.T) {
+	// Two lots at 100 and 120, then 15 of the 20 sold at 130 for 1950
+	trades := []model.Trade{
+		buy(1, day(1), "10", "100", "0"),
+		buy(2, day(2), "10", "120", "0"),
+		sell(3, day(3), "15", "130", "0"),
+	}
+
+	tests := []struct {
+		name      string
+		method    model.CostBasisMethod
+		picks     map[uint][]tradePick
+		gains     []gainWant
+		remaining []string
+	}{
+		{
+			name:      "fifo",
+			method:    model.CostBasisFIFO,
+			gains:     []gainWant{{1, "10", "1300", "1000"}, {2, "5", "650", "600"}},
+			remaining: []string{"0", "5"},
+		},
+		{
+			name:      "lifo",
+			method:    model.CostBasisLIFO,
+			gains:     []gainWant{{2, "10", "1300", "1200"}, {1, "5", "650", "500"}},
+			remaining: []string{"5", "0"},
+		},
+		{
+			// Both lots give up the same share and sell at the pool's 110
+			name:      "average",
+			method:    model.CostBasisAverage,
+			gains:     []gainWant{{1, "7.5", "975", "825"}, {2, "7.5", "975", "825"}},
+			remaining: []string{"2.5", "2.5"},
+		},
+		{
+			name:   "specific",
+			method: model.CostBasisSpecific,
+			picks: map[uint][]tradePick{3: {
+				{buyTradeID: 1, quantity: dec("5")},
+				{buyTradeID: 2, quantity: dec("10")},
+			}},
+			gains:     []gainWant{{1, "5", "650", "500"}, {2, "10", "1300", "1200"}},
+			remaining: []string{"5", "0"},
+		},
+		{
+			name:      "specific without picks falls back to fifo",
+			method:    model.CostBasisSpecific,
+			gains:     []gainWant{{1, "10", "1300", "1000"}, {2, "5", "650", "600"}},
+			remaining: []string{"0", "5"},
+		},
+		{
+			// Picks win over the portfolio's method
+			name:   "picks under fifo",
+			method: model.CostBasisFIFO,
+			picks: map[uint][]tradePick{3: {
+				{buyTradeID: 2, quantity: dec("10")},
+				{buyTradeID: 1, quantity: dec("5")},
+			}},
+			gains:     []gainWant{{2, "10", "1300", "1200"}, {1, "5", "650", "500"}},
+			remaining: []string{"5", "0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lots, matches, err := matchLots(trades, nil, tt.picks, tt.method)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGains(t, matches, tt.gains)
+			checkRemaining(t, lots, tt.remaining...)
+		})
+	}
+}
+
+func TestMatchLotsPartialWithFees(t *testing.T) {
+	// The buy fee raises the unit cost to 100.5; sell fees lower proceeds
+	trades := []model.Trade{
+		buy(1, day(1), "10", "100", "5"),
+		sell(2, day(2), "4", "110", "2"),
+		sell(3, day(3), "6", "90", "0"),
+	}
+
+	lots, matches, err := matchLots(trades, nil, nil, model.CostBasisFIFO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGains(t, matches, []gainWant{{1, "4", "438", "402"}, {1, "6", "540", "603"}})
+	checkRemaining(t, lots, "0")
+	if !lots[0].CostPerUnit.Equal(dec("100.5")) {
+		t.Errorf("cost per unit = %s, want 100.5", lots[0].CostPerUnit)
+	}
+}
+
+func TestMatchLotsOversell(t *testing.T) {
+	tests := []struct {
+		name   string
+		trades []model.Trade
+	}{
+		{"more than held", []model.Trade{
+			buy(1, day(1), "10", "100", "0"),
+			sell(2, day(2), "11", "100", "0"),
+		}},
+		{"sold before bought", []model.Trade{
+			sell(1, day(1), "1", "100", "0"),
+			buy(2, day(2), "10", "100", "0"),
+		}},
+		{"sold twice", []model.Trade{
+			buy(1, day(1), "10", "100", "0"),
+			sell(2, day(2), "6", "100", "0"),
+			sell(3, day(3), "6", "100", "0"),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, method := range []model.CostBasisMethod{model.CostBasisFIFO, model.CostBasisLIFO, model.CostBasisAverage} {
+				if _, _, err := matchLots(tt.trades, nil, nil, method); !errors.Is(err, ErrOversell) {
+					t.Errorf("%s: err = %v, want ErrOversell", method, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchLotsInvalidPicks(t *testing.T) {
+	trades := []model.Trade{
+		buy(1, day(1), "10", "100", "0"),
+		buy(2, day(2), "10", "120", "0"),
+		sell(3, day(3), "5", "130", "0"),
+	}
+	tests := []struct {
+		name  string
+		picks []tradePick
+	}{
+		{"unknown lot", []tradePick{{buyTradeID: 9, quantity: dec("5")}}},
+		{"short of the sell", []tradePick{{buyTradeID: 1, quantity: dec("4")}}},
+		{"over the sell", []tradePick{{buyTradeID: 1, quantity: dec("6")}}},
+		{"negative quantity", []tradePick{
+			{buyTradeID: 1, quantity: dec("-5")},
+			{buyTradeID: 2, quantity: dec("10")},
+		}},
+		{"more than the lot holds", []tradePick{
+			{buyTradeID: 2, quantity: dec("11")},
+			{buyTradeID: 1, quantity: dec("-6")},
+		}},
+		{"same lot twice past its size", []tradePick{
+			{buyTradeID: 1, quantity: dec("3")},
+			{buyTradeID: 1, quantity: dec("8")},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			picks := map[uint][]tradePick{3: tt.picks}
+			if _, _, err := matchLots(trades, nil, picks, model.CostBasisSpecific); !errors.Is(err, ErrInvalidLotSelection) {
+				t.Fatalf("err = %v, want ErrInvalidLotSelection", err)
+			}
+		})
+	}
+}
+
+func TestMatchLotsSplit(t *testing.T) {
+	// A 1:2 split between the buy and the sell; the sell is in new shares
+	splits := []model.CorporateAction{
+		{Type: model.CorporateActionSplit, EffectiveDate: day(2), RatioFrom: dec("1"), RatioTo: dec("2")},
+		{Type: model.CorporateActionReverseSplit, EffectiveDate: day(5), RatioFrom: dec("2"), RatioTo: dec("1")},
+	}
+	trades := []model.Trade{
+		buy(1, day(1), "10", "100", "0"),
+		// Trades on the effective date are already in post-split units
+		sell(2, day(2), "5", "60", "0"),
+		sell(3, day(3), "10", "60", "0"),
+		buy(4, day(4), "4", "55", "0"),
+	}
+
+	lots, matches, err := matchLots(trades, splits, nil, model.CostBasisFIFO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGains(t, matches, []gainWant{{1, "5", "300", "250"}, {1, "10", "600", "500"}})
+
+	// The reverse split after the last trade still applies to what is open
+	checkRemaining(t, lots, "2.5", "2")
+	if l := lots[0]; !l.Quantity.Equal(dec("10")) || !l.CostPerUnit.Equal(dec("100")) {
+		t.Errorf("first lot = %s at %s, want 10 at 100", l.Quantity, l.CostPerUnit)
+	}
+	if l := lots[1]; !l.Quantity.Equal(dec("2")) || !l.CostPerUnit.Equal(dec("110")) {
+		t.Errorf("second lot = %s at %s, want 2 at 110", l.Quantity, l.CostPerUnit)
+	}
+
+	// Before the split the old share count is the limit
+	oversell := []model.Trade{buy(1, day(1), "10", "100", "0"), sell(2, day(1), "11", "50", "0")}
+	if _, _, err := matchLots(oversell, splits, nil, model.CostBasisFIFO); !errors.Is(err, ErrOversell) {
+		t.Fatalf("err = %v, want ErrOversell", err)
+	}
+	afterSplit := []model.Trade{buy(1, day(1), "10", "100", "0"), sell(2, day(3), "20", "50", "0")}
+	if _, _, err := matchLots(afterSplit, splits, nil, model.CostBasisFIFO); err != nil {
+		t.Fatalf("selling every post-split share: %v", err)
+	}
+}
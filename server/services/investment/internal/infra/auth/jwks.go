@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	jwksTimeout = 5 * time.Second
+	// jwksMaxAge is how long fetched keys are trusted before a refetch.
+	jwksMaxAge = 10 * time.Minute
+	// jwksMinRefetch limits refetches triggered by unknown kids, so tokens
+	// with made-up kids can't hammer the user service.
+	jwksMinRefetch = 30 * time.Second
+	// jwksMinRSABits is the smallest RSA modulus trusted for signatures.
+	jwksMinRSABits = 2048
+)
+
+type publicKey struct {
+	alg string
+	key any
+}
+
+// JWKS caches the user service's token verification keys by kid. An
+// unknown kid triggers a refetch, so keys added by a rotation are picked up
+// before the cache expires. If a fetch fails the cached keys are kept.
+// Verifications only share a read lock; a fetch runs outside the lock and
+// concurrent callers wait for the one in flight rather than start their own.
+type JWKS struct {
+	url    string
+	client *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]publicKey
+	fetchedAt time.Time
+	inflight  *jwksFetch
+}
+
+// jwksFetch is a fetch in progress; n and err are set before done closes.
+type jwksFetch struct {
+	done chan struct{}
+	n    int
+	err  error
+}
+
+// NewJWKS reads keys from the user service at baseURL.
+func NewJWKS(baseURL string) *JWKS {
+	return &JWKS{
+		url:    strings.TrimRight(baseURL, "/") + "/.well-known/jwks.json",
+		client: &http.Client{Timeout: jwksTimeout},
+		keys:   map[string]publicKey{},
+	}
+}
+
+// Refresh replaces the cached keys with the user service's current set and
+// returns how many it holds.
+func (j *JWKS) Refresh(ctx context.Context) (int, error) {
+	j.mu.Lock()
+	f := j.inflight
+	if f == nil {
+		f = &jwksFetch{done: make(chan struct{})}
+		j.inflight = f
+		j.mu.Unlock()
+
+		keys, err := j.fetch(ctx)
+		f.n, f.err = len(keys), err
+
+		j.mu.Lock()
+		// Failed fetches count too, so an unreachable user service isn't
+		// asked again on every request
+		j.fetchedAt = time.Now()
+		if err == nil {
+			j.keys = keys
+		}
+		j.inflight = nil
+		j.mu.Unlock()
+		close(f.done)
+		return f.n, f.err
+	}
+	j.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.n, f.err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// fetch downloads and decodes the user service's key set.
+func (j *JWKS) fetch(ctx context.Context) (map[string]publicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build jwks request: %w", err)
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks: user service responded %s", resp.Status)
+	}
+
+	var body struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			Alg string `json:"alg"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+
+	keys := make(map[string]publicKey, len(body.Keys))
+	for _, k := range body.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch {
+		case k.Kty == "RSA" && k.Alg == jwt.SigningMethodRS256.Alg():
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+				return nil, fmt.Errorf("decode jwks: malformed RSA key %q", k.Kid)
+			}
+			pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+			if pub.N.BitLen() < jwksMinRSABits {
+				return nil, fmt.Errorf("decode jwks: RSA key %q is %d bits, want at least %d", k.Kid, pub.N.BitLen(), jwksMinRSABits)
+			}
+			keys[k.Kid] = publicKey{alg: k.Alg, key: pub}
+		case k.Kty == "OKP" && k.Crv == "Ed25519" && k.Alg == jwt.SigningMethodEdDSA.Alg():
+			x, err := base64.RawURLEncoding.DecodeString(k.X)
+			if err != nil || len(x) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("decode jwks: malformed Ed25519 key %q", k.Kid)
+			}
+			keys[k.Kid] = publicKey{alg: k.Alg, key: ed25519.PublicKey(x)}
+		}
+	}
+	return keys, nil
+}
+
+// verificationKey is the jwt.Keyfunc for tokens signed by the user service.
+func (j *JWKS) verificationKey(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no kid")
+	}
+
+	j.mu.RLock()
+	key, ok := j.keys[kid]
+	age := time.Since(j.fetchedAt)
+	j.mu.RUnlock()
+
+	if (!ok && age > jwksMinRefetch) || age > jwksMaxAge {
+		ctx, cancel := context.WithTimeout(context.Background(), jwksTimeout)
+		if _, err := j.Refresh(ctx); err == nil {
+			j.mu.RLock()
+			key, ok = j.keys[kid]
+			j.mu.RUnlock()
+		}
+		cancel()
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.alg {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.key, nil
+}
+
+var (
+	jwks     *JWKS
+	issuer   string
+	audience string
+)
+
+// UseJWKS makes ParseToken verify tokens against keys, requiring the given
+// issuer and audience.
+func UseJWKS(keys *JWKS, iss, aud string) {
+	jwks, issuer, audience = keys, iss, aud
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// serveJWKS publishes key under kid and returns a JWKS reading it.
+func serveJWKS(t *testing.T, kid string, key *rsa.PublicKey) *JWKS {
+	t.Helper()
+	set := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(srv.Close)
+	return NewJWKS(srv.URL)
+}
+
+func generateRSAKey(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestJWKSRejectsShortRSAKeys(t *testing.T) {
+	j := serveJWKS(t, "weak", &generateRSAKey(t, 1024).PublicKey)
+	if n, err := j.Refresh(context.Background()); err == nil {
+		t.Fatalf("accepted a set of %d with a 1024-bit key", n)
+	}
+
+	j = serveJWKS(t, "strong", &generateRSAKey(t, jwksMinRSABits).PublicKey)
+	if n, err := j.Refresh(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Refresh = %d, %v; want the 2048-bit key", n, err)
+	}
+}
+
+func TestParseTokenClaims(t *testing.T) {
+	key := generateRSAKey(t, jwksMinRSABits)
+	UseJWKS(serveJWKS(t, "k1", &key.PublicKey), "bux-test", "bux-test")
+	t.Cleanup(func() { UseJWKS(nil, "", "") })
+
+	sign := func(claims jwt.MapClaims) string {
+		claims["iss"], claims["aud"] = "bux-test", "bux-test"
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "k1"
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	if userID, err := ParseToken(sign(jwt.MapClaims{"sub": 42, "exp": exp})); err != nil || userID != 42 {
+		t.Fatalf("ParseToken = %d, %v; want user 42", userID, err)
+	}
+	for name, claims := range map[string]jwt.MapClaims{
+		"no sub":     {"exp": exp},
+		"string sub": {"sub": "42", "exp": exp},
+		"no exp":     {"sub": 42},
+		"expired":    {"sub": 42, "exp": time.Now().Add(-time.Hour).Unix()},
+	} {
+		if _, err := ParseToken(sign(claims)); err == nil {
+			t.Errorf("%s: token accepted", name)
+		}
+	}
+}
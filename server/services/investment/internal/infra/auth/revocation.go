@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+const revocationTimeout = 5 * time.Second
+
+// RevocationList mirrors the sessions the user service has revoked recently
+// enough that their access tokens may still be unexpired. ParseToken rejects
+// tokens of those sessions once the list is installed with
+// UseRevocationList. If a refresh fails the previous list is kept.
+type RevocationList struct {
+	url    string
+	client *http.Client
+
+	mu      sync.RWMutex
+	revoked map[string]struct{}
+}
+
+// NewRevocationList reads revocations from the user service at baseURL.
+func NewRevocationList(baseURL string) *RevocationList {
+	return &RevocationList{
+		url:     strings.TrimRight(baseURL, "/") + "/auth/revocations",
+		client:  &http.Client{Timeout: revocationTimeout},
+		revoked: map[string]struct{}{},
+	}
+}
+
+// Refresh replaces the list with the user service's current one and returns
+// how many sessions it holds.
+func (l *RevocationList) Refresh(ctx context.Context) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("build revocations request: %w", err)
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("fetch revocations: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("fetch revocations: user service responded %s", resp.Status)
+	}
+
+	var body struct {
+		Revoked []struct {
+			SessionID string `json:"session_id"`
+		} `json:"revoked"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("decode revocations: %w", err)
+	}
+
+	revoked := make(map[string]struct{}, len(body.Revoked))
+	for _, r := range body.Revoked {
+		revoked[r.SessionID] = struct{}{}
+	}
+	l.mu.Lock()
+	l.revoked = revoked
+	l.mu.Unlock()
+	return len(revoked), nil
+}
+
+func (l *RevocationList) IsSessionRevoked(sessionID string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, ok := l.revoked[sessionID]
+	return ok
+}
+
+var revocations *RevocationList
+
+// UseRevocationList makes ParseToken reject tokens of sessions on the list.
+func UseRevocationList(l *RevocationList) {
+	revocations = l
+}
@@ -24,11 +24,62 @@ func Migrate(db *gorm.DB) error {
 		&model.Trade{},
 		&model.Holding{},
 		&model.PriceHistory{},
+		&model.TaxLot{},
+		&model.LotSelection{},
+		&model.RealizedGain{},
+		&model.CorporateAction{},
+		&model.CashEvent{},
+		&model.CashEntry{},
+		&model.PriceSyncStatus{},
 	)
 
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return backfillCashLedger(db)
+}
+
+// backfillCashLedger books the trades and cash events recorded before the
+// cash ledger existed. It only inserts entries that are missing, so it runs
+// on every start and a failed run is retried by the next one. Without
+// deposits on record the resulting balances may be negative.
+func backfillCashLedger(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`
+			INSERT INTO cash_entries (portfolio_id, currency, type, amount, date, trade_id, created_at)
+			SELECT t.portfolio_id, s.currency, 'trade',
+				CASE WHEN t.side = 'buy' THEN -t.quantity * t.price ELSE t.quantity * t.price END,
+				t.trade_date, t.id, NOW()
+			FROM trades t
+			JOIN securities s ON s.id = t.security_id
+			WHERE t.deleted_at IS NULL
+				AND NOT EXISTS (SELECT 1 FROM cash_entries c WHERE c.trade_id = t.id AND c.type = 'trade')
+		`).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Exec(`
+			INSERT INTO cash_entries (portfolio_id, currency, type, amount, date, trade_id, created_at)
+			SELECT t.portfolio_id, s.currency, 'fee', -t.fee, t.trade_date, t.id, NOW()
+			FROM trades t
+			JOIN securities s ON s.id = t.security_id
+			WHERE t.deleted_at IS NULL AND t.fee > 0
+				AND NOT EXISTS (SELECT 1 FROM cash_entries c WHERE c.trade_id = t.id AND c.type = 'fee')
+		`).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Exec(`
+			INSERT INTO cash_entries (portfolio_id, currency, type, amount, date, cash_event_id, created_at)
+			SELECT e.portfolio_id, e.currency, e.type,
+				CASE WHEN e.type = 'withholding_tax' THEN -e.amount ELSE e.amount END,
+				e.payment_date, e.id, NOW()
+			FROM cash_events e
+			WHERE e.deleted_at IS NULL
+				AND NOT EXISTS (SELECT 1 FROM cash_entries c WHERE c.cash_event_id = e.id)
+		`).Error
+	})
 }
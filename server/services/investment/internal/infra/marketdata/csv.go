@@ -0,0 +1,169 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"investment/internal/domain/model"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// CSVProvider reads bars from <dir>/<SYMBOL>.csv files with a header row
+// naming date, open, high, low, close and volume columns in any order; only
+// date and close are required. A security without a file has no bars.
+type CSVProvider struct {
+	dir string
+}
+
+func NewCSVProvider(dir string) *CSVProvider {
+	return &CSVProvider{dir: dir}
+}
+
+func (p *CSVProvider) Name() string {
+	return "csv"
+}
+
+func (p *CSVProvider) FetchDaily(ctx context.Context, sec *model.Security, from, to time.Time) ([]model.PriceHistory, error) {
+	name := filepath.Join(p.dir, filepath.Base(sec.Symbol)+".csv")
+	f, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	bars, err := ReadOHLCV(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	n := 0
+	for _, b := range bars {
+		if !b.Date.Before(from) && !b.Date.After(to) {
+			bars[n] = b
+			n++
+		}
+	}
+	return bars[:n], ctx.Err()
+}
+
+// OHLCVRecord is one CSV row as text, for callers that validate rows
+// themselves. Line is its line number in the file.
+type OHLCVRecord struct {
+	Line       int
+	SecurityID string
+	Symbol     string
+	Date       string
+	Open       string
+	High       string
+	Low        string
+	Close      string
+	Volume     string
+}
+
+// ReadOHLCVRecords reads CSV with a header row naming security_id, symbol,
+// date, open, high, low, close and volume columns in any order. Only date
+// and close are required; absent columns read as empty.
+func ReadOHLCVRecords(r io.Reader) ([]OHLCVRecord, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	for _, c := range []string{"date", "close"} {
+		if _, ok := col[c]; !ok {
+			return nil, fmt.Errorf("header has no %s column", c)
+		}
+	}
+
+	var records []OHLCVRecord
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := col[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		line, _ := cr.FieldPos(0)
+		records = append(records, OHLCVRecord{
+			Line:       line,
+			SecurityID: field("security_id"),
+			Symbol:     field("symbol"),
+			Date:       field("date"),
+			Open:       field("open"),
+			High:       field("high"),
+			Low:        field("low"),
+			Close:      field("close"),
+			Volume:     field("volume"),
+		})
+	}
+}
+
+// ReadOHLCV parses OHLCV rows from CSV with a header row, as read by
+// ReadOHLCVRecords. Dates are YYYY-MM-DD; rows are returned in file order.
+func ReadOHLCV(r io.Reader) ([]model.PriceHistory, error) {
+	records, err := ReadOHLCVRecords(r)
+	if err != nil {
+		return nil, err
+	}
+
+	bars := make([]model.PriceHistory, 0, len(records))
+	for _, rec := range records {
+		date, err := time.Parse(time.DateOnly, rec.Date)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid date %q", rec.Line, rec.Date)
+		}
+		if rec.Close == "" {
+			return nil, fmt.Errorf("line %d: close required", rec.Line)
+		}
+		bar := model.PriceHistory{Date: date}
+		for _, c := range []struct {
+			name string
+			val  string
+			dst  *decimal.Decimal
+		}{{"open", rec.Open, &bar.Open}, {"high", rec.High, &bar.High}, {"low", rec.Low, &bar.Low}, {"close", rec.Close, &bar.Close}} {
+			if c.val == "" {
+				continue
+			}
+			if *c.dst, err = decimal.NewFromString(c.val); err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s %q", rec.Line, c.name, c.val)
+			}
+		}
+		if rec.Volume != "" {
+			volume, err := decimal.NewFromString(rec.Volume)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid volume %q", rec.Line, rec.Volume)
+			}
+			bar.Volume = volume.IntPart()
+		}
+		bars = append(bars, bar)
+	}
+	return bars, nil
+}
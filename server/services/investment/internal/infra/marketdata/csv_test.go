@@ -0,0 +1,92 @@
+package marketdata
+
+import (
+	"context"
+	"investment/internal/domain/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeCSV(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCSVProviderFetchDaily(t *testing.T) {
+	dir := t.TempDir()
+	// Columns in any order, a byte order mark and spaces after commas
+	writeCSV(t, dir, "GAZP.csv", "\ufeffClose, Date, Volume, Open\n"+
+		"160.1, 2026-02-27, 500, 159\n"+
+		"161.5, 2026-03-02, 700, 160.2\n"+
+		"162, 2026-03-03, , \n"+
+		"158.3, 2026-03-10, 300, 161\n")
+	p := NewCSVProvider(dir)
+
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+	bars, err := p.FetchDaily(context.Background(), &model.Security{Symbol: "GAZP"}, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bars) != 2 {
+		t.Fatalf("got %d bars, want the 2 in range: %+v", len(bars), bars)
+	}
+	if b := bars[0]; b.Date.Format(time.DateOnly) != "2026-03-02" || b.Close.String() != "161.5" ||
+		b.Open.String() != "160.2" || b.Volume != 700 {
+		t.Errorf("first bar = %+v", b)
+	}
+	if b := bars[1]; b.Date.Format(time.DateOnly) != "2026-03-03" || !b.Open.IsZero() || b.Volume != 0 {
+		t.Errorf("bar with empty cells = %+v", b)
+	}
+}
+
+func TestCSVProviderMissingFile(t *testing.T) {
+	p := NewCSVProvider(t.TempDir())
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	bars, err := p.FetchDaily(context.Background(), &model.Security{Symbol: "NONE"}, day, day)
+	if err != nil || bars != nil {
+		t.Fatalf("got %v, %v; want no bars and no error", bars, err)
+	}
+}
+
+func TestCSVProviderStaysInDir(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "prices")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeCSV(t, root, "SECRET.csv", "date,close\n2026-03-02,1\n")
+
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	bars, err := NewCSVProvider(dir).FetchDaily(context.Background(), &model.Security{Symbol: "../SECRET"}, day, day)
+	if err != nil || len(bars) != 0 {
+		t.Fatalf("read outside the directory: %v, %v", bars, err)
+	}
+}
+
+func TestCSVProviderInvalidRows(t *testing.T) {
+	tests := []struct {
+		name, content, wantErr string
+	}{
+		{"no close column", "date,open\n2026-03-02,1\n", "no close column"},
+		{"bad date", "date,close\n02.03.2026,1\n", "line 2: invalid date"},
+		{"empty close", "date,close\n2026-03-02,\n", "line 2: close required"},
+		{"bad number", "date,close,high\n2026-03-02,1,abc\n", "line 2: invalid high"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeCSV(t, dir, "X.csv", tt.content)
+			day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+			_, err := NewCSVProvider(dir).FetchDaily(context.Background(), &model.Security{Symbol: "X"}, day, day)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+// Package marketdata fetches daily prices for the scheduled price sync. Every
+// provider implements service.PriceProvider.
+package marketdata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"investment/internal/domain/model"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const moexTimeout = 30 * time.Second
+
+// MOEXProvider reads daily history from a Moscow Exchange ISS compatible
+// API. Only the main board of each security is used; bond prices come as a
+// percentage of face value, the way ISS reports them.
+type MOEXProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewMOEXProvider talks to the ISS API at baseURL, e.g. https://iss.moex.com
+// or a local stand-in serving the same format.
+func NewMOEXProvider(baseURL string) *MOEXProvider {
+	return &MOEXProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: moexTimeout},
+	}
+}
+
+func (m *MOEXProvider) Name() string {
+	return "moex"
+}
+
+type issTable struct {
+	Columns []string `json:"columns"`
+	Data    [][]any  `json:"data"`
+}
+
+type issHistory struct {
+	History issTable `json:"history"`
+	Cursor  issTable `json:"history.cursor"`
+}
+
+func (m *MOEXProvider) FetchDaily(ctx context.Context, sec *model.Security, from, to time.Time) ([]model.PriceHistory, error) {
+	market := "shares"
+	if sec.Type == model.SecurityTypeBond {
+		market = "bonds"
+	}
+	endpoint := fmt.Sprintf("%s/iss/history/engines/stock/markets/%s/securities/%s.json",
+		m.baseURL, market, url.PathEscape(sec.Symbol))
+
+	var bars []model.PriceHistory
+	for start := 0; ; {
+		q := url.Values{}
+		q.Set("from", from.Format(time.DateOnly))
+		q.Set("till", to.Format(time.DateOnly))
+		q.Set("start", fmt.Sprint(start))
+		q.Set("marketprice_board", "1")
+		q.Set("iss.meta", "off")
+		q.Set("history.columns", "TRADEDATE,OPEN,HIGH,LOW,CLOSE,VOLUME")
+
+		page, err := m.get(ctx, endpoint+"?"+q.Encode())
+		if err != nil {
+			return nil, err
+		}
+		rows, err := parseISSHistory(&page.History)
+		if err != nil {
+			return nil, fmt.Errorf("moex %s: %w", sec.Symbol, err)
+		}
+		bars = append(bars, rows...)
+
+		next, ok := nextISSPage(&page.Cursor)
+		if !ok || len(page.History.Data) == 0 {
+			break
+		}
+		start = next
+	}
+	return bars, nil
+}
+
+func (m *MOEXProvider) get(ctx context.Context, u string) (*issHistory, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build moex request: %w", err)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("moex request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read moex response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("moex responded %s", resp.Status)
+	}
+
+	var page issHistory
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&page); err != nil {
+		return nil, fmt.Errorf("decode moex response: %w", err)
+	}
+	return &page, nil
+}
+
+// parseISSHistory turns history rows into bars, skipping days without a
+// close.
+func parseISSHistory(t *issTable) ([]model.PriceHistory, error) {
+	col := map[string]int{}
+	for i, c := range t.Columns {
+		col[strings.ToUpper(c)] = i
+	}
+	for _, c := range []string{"TRADEDATE", "CLOSE"} {
+		if _, ok := col[c]; !ok {
+			return nil, fmt.Errorf("history has no %s column", c)
+		}
+	}
+	cell := func(row []any, name string) any {
+		i, ok := col[name]
+		if !ok || i >= len(row) {
+			return nil
+		}
+		return row[i]
+	}
+
+	var bars []model.PriceHistory
+	for _, row := range t.Data {
+		closePrice, ok, err := issDecimal(cell(row, "CLOSE"))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		dateStr, _ := cell(row, "TRADEDATE").(string)
+		date, err := time.Parse(time.DateOnly, dateStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRADEDATE %q", dateStr)
+		}
+
+		bar := model.PriceHistory{Date: date, Close: closePrice}
+		if bar.Open, _, err = issDecimal(cell(row, "OPEN")); err != nil {
+			return nil, err
+		}
+		if bar.High, _, err = issDecimal(cell(row, "HIGH")); err != nil {
+			return nil, err
+		}
+		if bar.Low, _, err = issDecimal(cell(row, "LOW")); err != nil {
+			return nil, err
+		}
+		volume, _, err := issDecimal(cell(row, "VOLUME"))
+		if err != nil {
+			return nil, err
+		}
+		bar.Volume = volume.IntPart()
+		bars = append(bars, bar)
+	}
+	return bars, nil
+}
+
+// issDecimal reads a numeric cell; ok is false for nulls.
+func issDecimal(v any) (d decimal.Decimal, ok bool, err error) {
+	switch n := v.(type) {
+	case nil:
+		return decimal.Zero, false, nil
+	case json.Number:
+		d, err = decimal.NewFromString(n.String())
+		if err != nil {
+			return decimal.Zero, false, fmt.Errorf("invalid number %q", n)
+		}
+		return d, true, nil
+	default:
+		return decimal.Zero, false, fmt.Errorf("unexpected value %v", v)
+	}
+}
+
+// nextISSPage reads the INDEX, TOTAL and PAGESIZE cursor and returns where
+// the next page starts, if there is one.
+func nextISSPage(t *issTable) (int, bool) {
+	if len(t.Data) == 0 {
+		return 0, false
+	}
+	vals := map[string]int64{}
+	for i, c := range t.Columns {
+		if i >= len(t.Data[0]) {
+			break
+		}
+		if n, ok := t.Data[0][i].(json.Number); ok {
+			vals[strings.ToUpper(c)], _ = n.Int64()
+		}
+	}
+	next := vals["INDEX"] + vals["PAGESIZE"]
+	if vals["PAGESIZE"] <= 0 || next >= vals["TOTAL"] {
+		return 0, false
+	}
+	return int(next), true
+}
@@ -0,0 +1,133 @@
+package marketdata
+
+import (
+	"context"
+	"fmt"
+	"investment/internal/domain/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// issPages serves history pages of two rows each from rows, with the cursor
+// ISS sends, and records the requests.
+func issPages(t *testing.T, rows []string) (*httptest.Server, *[]*http.Request) {
+	t.Helper()
+	var requests []*http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		var start int
+		fmt.Sscan(r.URL.Query().Get("start"), &start)
+		end := min(start+2, len(rows))
+		page := rows[min(start, len(rows)):end]
+		fmt.Fprintf(w, `{
+			"history": {"columns": ["TRADEDATE","OPEN","HIGH","LOW","CLOSE","VOLUME"], "data": [%s]},
+			"history.cursor": {"columns": ["INDEX","TOTAL","PAGESIZE"], "data": [[%d, %d, 2]]}
+		}`, strings.Join(page, ","), start, len(rows))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestMOEXProviderFetchDaily(t *testing.T) {
+	srv, requests := issPages(t, []string{
+		`["2026-03-02", 300.5, 305, 299.1, 304.25, 1200]`,
+		`["2026-03-03", 304, 306.7, 301, 302, 900]`,
+		`["2026-03-04", null, null, null, null, 0]`,
+		`["2026-03-05", 303, 310, 302.5, 309.9, 1500]`,
+	})
+	p := NewMOEXProvider(srv.URL + "/")
+
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)
+	bars, err := p.FetchDaily(context.Background(), &model.Security{Symbol: "SBER", Type: model.SecurityTypeStock}, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The day without a close is skipped
+	want := []struct {
+		date, open, close string
+		volume            int64
+	}{
+		{"2026-03-02", "300.5", "304.25", 1200},
+		{"2026-03-03", "304", "302", 900},
+		{"2026-03-05", "303", "309.9", 1500},
+	}
+	if len(bars) != len(want) {
+		t.Fatalf("got %d bars, want %d: %+v", len(bars), len(want), bars)
+	}
+	for i, w := range want {
+		b := bars[i]
+		if b.Date.Format(time.DateOnly) != w.date || b.Open.String() != w.open ||
+			b.Close.String() != w.close || b.Volume != w.volume {
+			t.Errorf("bar %d = %s open %s close %s volume %d, want %+v",
+				i, b.Date.Format(time.DateOnly), b.Open, b.Close, b.Volume, w)
+		}
+	}
+
+	if len(*requests) != 2 {
+		t.Fatalf("made %d requests, want 2 pages", len(*requests))
+	}
+	first := (*requests)[0]
+	if first.URL.Path != "/iss/history/engines/stock/markets/shares/securities/SBER.json" {
+		t.Errorf("path = %s", first.URL.Path)
+	}
+	q := first.URL.Query()
+	if q.Get("from") != "2026-03-01" || q.Get("till") != "2026-03-06" || q.Get("start") != "0" {
+		t.Errorf("query = %s", first.URL.RawQuery)
+	}
+	if got := (*requests)[1].URL.Query().Get("start"); got != "2" {
+		t.Errorf("second page start = %s, want 2", got)
+	}
+}
+
+func TestMOEXProviderBondsMarket(t *testing.T) {
+	srv, requests := issPages(t, []string{`["2026-03-02", 99.5, 99.9, 99.1, 99.75, 40]`})
+	p := NewMOEXProvider(srv.URL)
+
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	bars, err := p.FetchDaily(context.Background(), &model.Security{Symbol: "SU26238RMFS4", Type: model.SecurityTypeBond}, day, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bars) != 1 || bars[0].Close.String() != "99.75" {
+		t.Fatalf("bars = %+v", bars)
+	}
+	if path := (*requests)[0].URL.Path; !strings.Contains(path, "/markets/bonds/") {
+		t.Errorf("path = %s, want the bonds market", path)
+	}
+}
+
+func TestMOEXProviderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"server error", http.StatusBadGateway, "", "502"},
+		{"bad json", http.StatusOK, "{", "decode moex response"},
+		{"no close column", http.StatusOK,
+			`{"history": {"columns": ["TRADEDATE"], "data": [["2026-03-02"]]}}`, "no CLOSE column"},
+		{"bad date", http.StatusOK,
+			`{"history": {"columns": ["TRADEDATE","CLOSE"], "data": [["02.03.2026", 1]]}}`, "invalid TRADEDATE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+
+			day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+			_, err := NewMOEXProvider(srv.URL).FetchDaily(context.Background(), &model.Security{Symbol: "SBER"}, day, day)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
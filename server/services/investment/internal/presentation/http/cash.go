@@ -0,0 +1,127 @@
+package http
+
+import (
+	"investment/internal/domain/model"
+	"investment/internal/domain/service"
+	"investment/internal/presentation/http/dto"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *InvestmentHandler) UpdatePortfolio(c *gin.Context) {
+	var uri PortfolioURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req dto.UpdatePortfolioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	p, err := h.service.UpdatePortfolio(uri.ID, userID.(uint), service.PortfolioChanges{
+		Name:        req.Name,
+		EnforceCash: req.EnforceCash,
+	})
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// Cash ledger handlers
+func (h *InvestmentHandler) GetCashBalances(c *gin.Context) {
+	var uri PortfolioURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	userID, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	balances, err := h.service.GetCashBalances(uri.ID, userID.(uint))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, balances)
+}
+
+func (h *InvestmentHandler) RecordCashMovement(c *gin.Context) {
+	var uri PortfolioURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req dto.CashMovementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+		return
+	}
+	amount, err := req.ParseAmount()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid amount format"})
+		return
+	}
+
+	entry, err := h.service.RecordCashMovement(uri.ID, userID.(uint), model.CashEntryType(req.Type), amount, req.Currency, date, req.Note)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, entry)
+}
+
+func (h *InvestmentHandler) GetCashEntries(c *gin.Context) {
+	var uri PortfolioURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var query dto.CashEntriesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	from, to, err := parseDateRange(query.From, query.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries, err := h.service.GetCashEntries(uri.ID, userID.(uint), query.Currency, from, to)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
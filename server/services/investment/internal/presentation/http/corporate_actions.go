@@ -0,0 +1,221 @@
+package http
+
+import (
+	"investment/internal/domain/model"
+	"investment/internal/domain/service"
+	"investment/internal/presentation/http/dto"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Corporate action handlers
+func (h *InvestmentHandler) CreateCorporateAction(c *gin.Context) {
+	var req dto.CreateCorporateActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", req.EffectiveDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid effective_date format, use YYYY-MM-DD"})
+		return
+	}
+
+	ratioFrom, ratioTo, err := req.ParseRatio()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ratio format"})
+		return
+	}
+
+	action, err := h.service.CreateCorporateAction(&model.CorporateAction{
+		SecurityID:    req.SecurityID,
+		Type:          model.CorporateActionType(req.Type),
+		EffectiveDate: date,
+		RatioFrom:     ratioFrom,
+		RatioTo:       ratioTo,
+		NewSymbol:     req.NewSymbol,
+		Note:          req.Note,
+	})
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, action)
+}
+
+// DeleteCorporateAction removes a wrongly recorded action and undoes it.
+func (h *InvestmentHandler) DeleteCorporateAction(c *gin.Context) {
+	var uri struct {
+		ID uint `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := h.service.DeleteCorporateAction(uri.ID); err != nil {
+		c.JSON(errorStatus(err, http.StatusUnprocessableEntity), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *InvestmentHandler) GetCorporateActions(c *gin.Context) {
+	var uri SecurityURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	actions, err := h.service.GetCorporateActions(uri.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, actions)
+}
+
+// Cash event handlers
+func (h *InvestmentHandler) CreateCashEvent(c *gin.Context) {
+	var uri PortfolioURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req dto.CreateCashEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	paymentDate, err := time.Parse("2006-01-02", req.PaymentDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payment_date format, use YYYY-MM-DD"})
+		return
+	}
+	var recordDate time.Time
+	if req.RecordDate != "" {
+		if recordDate, err = time.Parse("2006-01-02", req.RecordDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid record_date format, use YYYY-MM-DD"})
+			return
+		}
+	}
+
+	amount, err := req.ParseAmount()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid amount format"})
+		return
+	}
+	perShare, err := req.ParseAmountPerShare()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid amount_per_share format"})
+		return
+	}
+
+	event, err := h.service.CreateCashEvent(uri.ID, userID.(uint), service.CashEventInput{
+		SecurityID:     req.SecurityID,
+		Type:           model.CashEventType(req.Type),
+		PaymentDate:    paymentDate,
+		RecordDate:     recordDate,
+		Amount:         amount,
+		AmountPerShare: perShare,
+		Currency:       req.Currency,
+		Note:           req.Note,
+	})
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, event)
+}
+
+func (h *InvestmentHandler) GetCashEvents(c *gin.Context) {
+	var uri PortfolioURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var query dto.DateRangeQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	from, to, err := parseDateRange(query.From, query.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	events, err := h.service.GetCashEvents(uri.ID, userID.(uint), from, to)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+func (h *InvestmentHandler) DeleteCashEvent(c *gin.Context) {
+	var uri struct {
+		ID      uint `uri:"id" binding:"required"`
+		EventID uint `uri:"event_id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	userID, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := h.service.DeleteCashEvent(uri.ID, uri.EventID, userID.(uint)); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *InvestmentHandler) GetIncomeReport(c *gin.Context) {
+	var uri PortfolioURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var query dto.DateRangeQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	from, to, err := parseDateRange(query.From, query.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.service.GetIncomeReport(uri.ID, userID.(uint), from, to)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -1,15 +1,27 @@
 package dto
 
-import "github.com/shopspring/decimal"
+import (
+	"encoding/json"
+
+	"github.com/shopspring/decimal"
+)
 
 type CreateBrokerRequest struct {
 	Name string `json:"name" binding:"required"`
 }
 
 type CreatePortfolioRequest struct {
-	BrokerID     uint   `json:"broker_id" binding:"required"`
-	Name         string `json:"name" binding:"required"`
-	BaseCurrency string `json:"base_currency"`
+	BrokerID        uint   `json:"broker_id" binding:"required"`
+	Name            string `json:"name" binding:"required"`
+	BaseCurrency    string `json:"base_currency"`
+	CostBasisMethod string `json:"cost_basis_method" binding:"omitempty,oneof=fifo lifo average specific"`
+	// EnforceCash rejects buys and withdrawals beyond the cash held
+	EnforceCash bool `json:"enforce_cash"`
+}
+
+type UpdatePortfolioRequest struct {
+	Name        *string `json:"name"`
+	EnforceCash *bool   `json:"enforce_cash"`
 }
 
 type CreateSecurityRequest struct {
@@ -30,6 +42,17 @@ type CreateTradeRequest struct {
 	Fee         string `json:"fee"`
 	TradeDate   string `json:"trade_date" binding:"required"`
 	Note        string `json:"note"`
+	// Lots names the lots a sell closes; required for specific-ID portfolios
+	Lots []LotPickRequest `json:"lots" binding:"omitempty,dive"`
+}
+
+type LotPickRequest struct {
+	LotID    uint   `json:"lot_id" binding:"required"`
+	Quantity string `json:"quantity" binding:"required"`
+}
+
+func (r *LotPickRequest) ParseQuantity() (decimal.Decimal, error) {
+	return decimal.NewFromString(r.Quantity)
 }
 
 func (r *CreateTradeRequest) ParseQuantity() (decimal.Decimal, error) {
@@ -86,3 +109,113 @@ type SearchSecuritiesQuery struct {
 	Query string `form:"q"`
 	Type  string `form:"type"`
 }
+
+type TaxLotsQuery struct {
+	SecurityID    uint `form:"security_id"`
+	IncludeClosed bool `form:"include_closed"`
+}
+
+type RealizedGainsQuery struct {
+	SecurityID uint   `form:"security_id"`
+	From       string `form:"from"`
+	To         string `form:"to"`
+}
+
+type CreateCorporateActionRequest struct {
+	SecurityID    uint   `json:"security_id" binding:"required"`
+	Type          string `json:"type" binding:"required,oneof=split reverse_split symbol_change"`
+	EffectiveDate string `json:"effective_date" binding:"required"`
+	// RatioFrom old shares become RatioTo new ones, e.g. 1 -> 10 for a 10:1 split
+	RatioFrom string `json:"ratio_from"`
+	RatioTo   string `json:"ratio_to"`
+	NewSymbol string `json:"new_symbol"`
+	Note      string `json:"note"`
+}
+
+func (r *CreateCorporateActionRequest) ParseRatio() (decimal.Decimal, decimal.Decimal, error) {
+	if r.RatioFrom == "" && r.RatioTo == "" {
+		return decimal.Zero, decimal.Zero, nil
+	}
+	from, err := decimal.NewFromString(r.RatioFrom)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	to, err := decimal.NewFromString(r.RatioTo)
+	return from, to, err
+}
+
+// CreateCashEventRequest takes either amount or amount_per_share; the latter
+// is multiplied by the quantity held at the end of record_date, which
+// defaults to payment_date.
+type CreateCashEventRequest struct {
+	SecurityID     uint   `json:"security_id" binding:"required"`
+	Type           string `json:"type" binding:"required,oneof=dividend coupon withholding_tax"`
+	PaymentDate    string `json:"payment_date" binding:"required"`
+	RecordDate     string `json:"record_date"`
+	Amount         string `json:"amount"`
+	AmountPerShare string `json:"amount_per_share"`
+	Currency       string `json:"currency"`
+	Note           string `json:"note"`
+}
+
+func (r *CreateCashEventRequest) ParseAmount() (decimal.Decimal, error) {
+	if r.Amount == "" {
+		return decimal.Zero, nil
+	}
+	return decimal.NewFromString(r.Amount)
+}
+
+func (r *CreateCashEventRequest) ParseAmountPerShare() (decimal.Decimal, error) {
+	if r.AmountPerShare == "" {
+		return decimal.Zero, nil
+	}
+	return decimal.NewFromString(r.AmountPerShare)
+}
+
+type DateRangeQuery struct {
+	From string `form:"from"`
+	To   string `form:"to"`
+}
+
+type CashMovementRequest struct {
+	Type     string `json:"type" binding:"required,oneof=deposit withdrawal"`
+	Amount   string `json:"amount" binding:"required"`
+	Currency string `json:"currency" binding:"required,len=3"`
+	Date     string `json:"date" binding:"required"`
+	Note     string `json:"note"`
+}
+
+func (r *CashMovementRequest) ParseAmount() (decimal.Decimal, error) {
+	return decimal.NewFromString(r.Amount)
+}
+
+type CashEntriesQuery struct {
+	Currency string `form:"currency"`
+	From     string `form:"from"`
+	To       string `form:"to"`
+}
+
+type PerformanceQuery struct {
+	From        string `form:"from"`
+	To          string `form:"to"`
+	BenchmarkID uint   `form:"benchmark_id"`
+}
+
+// PriceImportQuery names the security for bulk price rows that don't.
+type PriceImportQuery struct {
+	SecurityID uint   `form:"security_id"`
+	Symbol     string `form:"symbol"`
+}
+
+// PriceRowRequest is one row of a JSON bulk price import. Prices and volume
+// may be JSON numbers or numeric strings.
+type PriceRowRequest struct {
+	SecurityID uint        `json:"security_id"`
+	Symbol     string      `json:"symbol"`
+	Date       string      `json:"date"`
+	Open       json.Number `json:"open"`
+	High       json.Number `json:"high"`
+	Low        json.Number `json:"low"`
+	Close      json.Number `json:"close"`
+	Volume     json.Number `json:"volume"`
+}
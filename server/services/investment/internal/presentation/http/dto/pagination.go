@@ -67,3 +67,35 @@ func NewPaginatedResponse[T any](data []T, page, pageSize, totalCount int) Pagin
 		TotalPages: totalPages,
 	}
 }
+
+// CursorParams selects keyset pagination. It is used when the request carries
+// a cursor parameter; an empty cursor asks for the first page.
+type CursorParams struct {
+	Cursor   string
+	PageSize int
+}
+
+// ParseCursorPagination reports whether cursor mode was requested.
+func ParseCursorPagination(ctx *gin.Context) (CursorParams, bool) {
+	token, ok := ctx.GetQuery("cursor")
+	if !ok {
+		return CursorParams{}, false
+	}
+	return CursorParams{Cursor: token, PageSize: ParsePagination(ctx).PageSize}, true
+}
+
+type CursorResponse[T any] struct {
+	Data       []T    `json:"data"`
+	PageSize   int    `json:"page_size"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+func NewCursorResponse[T any](data []T, pageSize int, nextCursor string) CursorResponse[T] {
+	return CursorResponse[T]{
+		Data:       data,
+		PageSize:   pageSize,
+		NextCursor: nextCursor,
+		HasMore:    nextCursor != "",
+	}
+}
@@ -1,10 +1,12 @@
 package http
 
 import (
+	"errors"
 	"investment/internal/domain/model"
 	"investment/internal/domain/service"
 	"investment/internal/presentation/http/dto"
 	"investment/internal/presentation/http/middleware"
+	"investment/pkg/cursor"
 	"net/http"
 	"time"
 
@@ -29,10 +31,21 @@ func New(r *gin.Engine, s *service.InvestmentService) {
 		api.POST("/portfolios", h.CreatePortfolio)
 		api.GET("/portfolios", h.GetPortfolios)
 		api.GET("/portfolios/:id", h.GetPortfolio)
+		api.PATCH("/portfolios/:id", h.UpdatePortfolio)
 		api.GET("/portfolios/:id/holdings", h.GetHoldings)
 		api.GET("/portfolios/:id/summary", h.GetPortfolioSummary)
 		api.GET("/portfolios/:id/value", h.GetPortfolioSummary)
 		api.GET("/portfolios/:id/trades", h.GetTrades)
+		api.GET("/portfolios/:id/lots", h.GetTaxLots)
+		api.GET("/portfolios/:id/realized", h.GetRealizedGains)
+		api.GET("/portfolios/:id/income", h.GetIncomeReport)
+		api.GET("/portfolios/:id/performance", h.GetPerformance)
+		api.GET("/portfolios/:id/cash-events", h.GetCashEvents)
+		api.POST("/portfolios/:id/cash-events", h.CreateCashEvent)
+		api.DELETE("/portfolios/:id/cash-events/:event_id", h.DeleteCashEvent)
+		api.GET("/portfolios/:id/cash", h.GetCashBalances)
+		api.POST("/portfolios/:id/cash", h.RecordCashMovement)
+		api.GET("/portfolios/:id/cash/entries", h.GetCashEntries)
 
 		// Securities
 		api.POST("/securities", h.CreateSecurity)
@@ -40,12 +53,30 @@ func New(r *gin.Engine, s *service.InvestmentService) {
 		api.GET("/securities/:id", h.GetSecurity)
 		api.GET("/securities/:id/price", h.GetLatestPrice)
 		api.GET("/securities/:id/history", h.GetPriceHistory)
+		api.GET("/securities/:id/corporate-actions", h.GetCorporateActions)
 
 		// Trades
 		api.POST("/trades", h.ExecuteTrade)
 
 		// Prices
 		api.POST("/prices", h.UpdatePrice)
+		api.GET("/prices/sync-status", h.GetPriceSyncStatuses)
+	}
+
+	// Corporate actions change shared securities and every holder's
+	// positions, so only operators record them
+	actions := r.Group("/api/corporate-actions")
+	actions.Use(middleware.AdminMiddleware())
+	{
+		actions.POST("", h.CreateCorporateAction)
+		actions.DELETE("/:id", h.DeleteCorporateAction)
+	}
+
+	// A bulk import overwrites the shared price history of any security
+	prices := r.Group("/api/prices/bulk")
+	prices.Use(middleware.AdminMiddleware())
+	{
+		prices.POST("", h.ImportPrices)
 	}
 }
 
@@ -95,9 +126,9 @@ func (h *InvestmentHandler) CreatePortfolio(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
-	p, err := h.service.CreatePortfolio(userID.(uint), req.BrokerID, req.Name, req.BaseCurrency)
+	p, err := h.service.CreatePortfolio(userID.(uint), req.BrokerID, req.Name, req.BaseCurrency, model.CostBasisMethod(req.CostBasisMethod), req.EnforceCash)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusCreated, p)
@@ -123,9 +154,14 @@ func (h *InvestmentHandler) GetPortfolio(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	portfolio, err := h.service.GetPortfolio(uri.ID)
+	userID, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	portfolio, err := h.service.GetPortfolio(uri.ID, userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "portfolio not found"})
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, portfolio)
@@ -137,9 +173,14 @@ func (h *InvestmentHandler) GetHoldings(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	holdings, err := h.service.GetHoldings(uri.ID)
+	userID, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	holdings, err := h.service.GetHoldings(uri.ID, userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, holdings)
@@ -151,9 +192,14 @@ func (h *InvestmentHandler) GetPortfolioSummary(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	summary, err := h.service.CalculatePortfolioValue(uri.ID)
+	userID, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	summary, err := h.service.CalculatePortfolioValue(uri.ID, userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, summary)
@@ -165,26 +211,138 @@ func (h *InvestmentHandler) GetTrades(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
+	userID, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if cp, ok := dto.ParseCursorPagination(c); ok {
+		trades, next, err := h.service.GetTradesPage(uri.ID, userID.(uint), cp.Cursor, cp.PageSize)
+		if err != nil {
+			status := errorStatus(err, http.StatusInternalServerError)
+			if errors.Is(err, cursor.ErrInvalidCursor) {
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, dto.NewCursorResponse(trades, cp.PageSize, next))
+		return
+	}
 
 	if c.Query("page") != "" {
 		pg := dto.ParsePagination(c)
-		trades, total, err := h.service.GetTradesPaginated(uri.ID, pg.Limit(), pg.Offset())
+		trades, total, err := h.service.GetTradesPaginated(uri.ID, userID.(uint), pg.Limit(), pg.Offset())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 			return
 		}
 		c.JSON(http.StatusOK, dto.NewPaginatedResponse(trades, pg.Page, pg.PageSize, int(total)))
 		return
 	}
 
-	trades, err := h.service.GetTrades(uri.ID)
+	trades, err := h.service.GetTrades(uri.ID, userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, trades)
 }
 
+func (h *InvestmentHandler) GetTaxLots(c *gin.Context) {
+	var uri PortfolioURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var query dto.TaxLotsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	lots, err := h.service.GetTaxLots(uri.ID, userID.(uint), query.SecurityID, query.IncludeClosed)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, lots)
+}
+
+func (h *InvestmentHandler) GetRealizedGains(c *gin.Context) {
+	var uri PortfolioURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var query dto.RealizedGainsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	from, to, err := parseDateRange(query.From, query.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary, err := h.service.GetRealizedGains(uri.ID, userID.(uint), query.SecurityID, from, to)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// GetPerformance reports TWR, XIRR and drawdown over ?from=&to=, compared
+// with ?benchmark_id= when given.
+func (h *InvestmentHandler) GetPerformance(c *gin.Context) {
+	var uri PortfolioURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var query dto.PerformanceQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	from, to, err := parseDateRange(query.From, query.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	perf, err := h.service.GetPerformance(uri.ID, userID.(uint), from, to, query.BenchmarkID)
+	if err != nil {
+		status := errorStatus(err, http.StatusInternalServerError)
+		if errors.Is(err, service.ErrNoPerformanceData) || errors.Is(err, service.ErrInvalidPeriod) ||
+			errors.Is(err, service.ErrNoBenchmarkPrices) {
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, perf)
+}
+
 // Security handlers
 func (h *InvestmentHandler) CreateSecurity(c *gin.Context) {
 	var req dto.CreateSecurityRequest
@@ -302,6 +460,11 @@ func (h *InvestmentHandler) ExecuteTrade(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	userID, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
 
 	date, err := time.Parse(time.RFC3339, req.TradeDate)
 	if err != nil {
@@ -327,9 +490,19 @@ func (h *InvestmentHandler) ExecuteTrade(c *gin.Context) {
 		return
 	}
 
-	t, err := h.service.ExecuteTrade(req.PortfolioID, req.SecurityID, model.TradeSide(req.Side), qty, price, fee, date, req.Note)
+	picks := make([]service.LotPick, len(req.Lots))
+	for i, l := range req.Lots {
+		q, err := l.ParseQuantity()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid lot quantity format"})
+			return
+		}
+		picks[i] = service.LotPick{LotID: l.LotID, Quantity: q}
+	}
+
+	t, err := h.service.ExecuteTrade(userID.(uint), req.PortfolioID, req.SecurityID, model.TradeSide(req.Side), qty, price, fee, date, req.Note, picks)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusCreated, t)
@@ -365,6 +538,50 @@ func (h *InvestmentHandler) UpdatePrice(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
+// GetPriceSyncStatuses shows the outcome of the last scheduled price sync of
+// every security.
+func (h *InvestmentHandler) GetPriceSyncStatuses(c *gin.Context) {
+	statuses, err := h.service.GetPriceSyncStatuses()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, statuses)
+}
+
+// parseDateRange parses optional YYYY-MM-DD bounds; to covers its whole day.
+func parseDateRange(fromStr, toStr string) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+	if fromStr != "" {
+		if from, err = time.Parse("2006-01-02", fromStr); err != nil {
+			return from, to, errors.New("invalid 'from' date format, use YYYY-MM-DD")
+		}
+	}
+	if toStr != "" {
+		if to, err = time.Parse("2006-01-02", toStr); err != nil {
+			return from, to, errors.New("invalid 'to' date format, use YYYY-MM-DD")
+		}
+		// Include entire day
+		to = to.Add(24*time.Hour - time.Nanosecond)
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return from, to, errors.New("'to' must be after 'from'")
+	}
+	return from, to, nil
+}
+
+// errorStatus maps ownership errors to 404/403 and anything else to fallback.
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrForbidden):
+		return http.StatusForbidden
+	}
+	return fallback
+}
+
 type SecurityURI struct {
 	ID uint `uri:"id" binding:"required"`
 }
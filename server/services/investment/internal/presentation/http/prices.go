@@ -0,0 +1,130 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"investment/internal/domain/service"
+	"investment/internal/infra/marketdata"
+	"investment/internal/presentation/http/dto"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxPriceImportSize = 32 << 20
+
+// ImportPrices upserts OHLCV rows sent as a JSON array, a text/csv body or a
+// multipart upload with the CSV in "file". CSV columns are named by the
+// header: security_id or symbol, date, open, high, low, close and volume.
+// ?security_id= or ?symbol= names the security for rows that don't.
+func (h *InvestmentHandler) ImportPrices(c *gin.Context) {
+	var query dto.PriceImportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxPriceImportSize)
+
+	var rows []service.PriceRow
+	var err error
+	switch contentType := c.ContentType(); {
+	case contentType == "multipart/form-data":
+		header, ferr := c.FormFile("file")
+		if ferr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "price file is required"})
+			return
+		}
+		file, ferr := header.Open()
+		if ferr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": ferr.Error()})
+			return
+		}
+		defer file.Close()
+		rows, err = readPriceCSV(file)
+	case contentType == "text/csv":
+		rows, err = readPriceCSV(c.Request.Body)
+	default:
+		rows, err = readPriceJSON(c.Request.Body)
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "price import is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range rows {
+		if rows[i].SecurityID == 0 && rows[i].Symbol == "" {
+			rows[i].SecurityID, rows[i].Symbol = query.SecurityID, query.Symbol
+		}
+	}
+
+	result, err := h.service.ImportPrices(rows)
+	if err != nil {
+		if errors.Is(err, service.ErrTooManyPriceRows) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func readPriceCSV(r io.Reader) ([]service.PriceRow, error) {
+	records, err := marketdata.ReadOHLCVRecords(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv: %w", err)
+	}
+	rows := make([]service.PriceRow, len(records))
+	for i, rec := range records {
+		rows[i] = service.PriceRow{
+			Row:    rec.Line,
+			Symbol: rec.Symbol,
+			Date:   rec.Date,
+			Open:   rec.Open,
+			High:   rec.High,
+			Low:    rec.Low,
+			Close:  rec.Close,
+			Volume: rec.Volume,
+		}
+		if rec.SecurityID != "" {
+			id, err := strconv.ParseUint(rec.SecurityID, 10, 0)
+			if err != nil || id == 0 {
+				return nil, fmt.Errorf("line %d: invalid security_id %q", rec.Line, rec.SecurityID)
+			}
+			rows[i].SecurityID = uint(id)
+		}
+	}
+	return rows, nil
+}
+
+func readPriceJSON(r io.Reader) ([]service.PriceRow, error) {
+	var req []dto.PriceRowRequest
+	if err := json.NewDecoder(r).Decode(&req); err != nil {
+		return nil, fmt.Errorf("invalid json: expected an array of price rows: %w", err)
+	}
+	rows := make([]service.PriceRow, len(req))
+	for i, p := range req {
+		rows[i] = service.PriceRow{
+			Row:        i + 1,
+			SecurityID: p.SecurityID,
+			Symbol:     strings.TrimSpace(p.Symbol),
+			Date:       p.Date,
+			Open:       p.Open.String(),
+			High:       p.High.String(),
+			Low:        p.Low.String(),
+			Close:      p.Close.String(),
+			Volume:     p.Volume.String(),
+		}
+	}
+	return rows, nil
+}
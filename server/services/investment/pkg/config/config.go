@@ -9,10 +9,14 @@ import (
 )
 
 type Config struct {
-	Env        string `yaml:"env" env-default:"local"`
-	HTTPServer `yaml:"http_server"`
-	Postgres   `yaml:"postgres"`
-	RMQ        `yaml:"rmq"`
+	Env         string `yaml:"env" env-default:"local"`
+	HTTPServer  `yaml:"http_server"`
+	Postgres    `yaml:"postgres"`
+	RMQ         `yaml:"rmq"`
+	UserService `yaml:"user_service"`
+	JWT         `yaml:"jwt"`
+	RateLimit   `yaml:"rate_limit"`
+	MarketData  `yaml:"market_data"`
 }
 
 type HTTPServer struct {
@@ -34,6 +38,41 @@ type RMQ struct {
 	URL string `yaml:"url"`
 }
 
+// MarketData selects where scheduled price syncs fetch bars from: "moex",
+// "csv", or "" to leave syncing off.
+type MarketData struct {
+	Provider     string        `yaml:"provider" env:"MARKET_DATA_PROVIDER"`
+	MOEXURL      string        `yaml:"moex_url" env:"MARKET_DATA_MOEX_URL" env-default:"https://iss.moex.com"`
+	CSVDir       string        `yaml:"csv_dir" env:"MARKET_DATA_CSV_DIR"`
+	Interval     time.Duration `yaml:"interval" env-default:"6h"`
+	BackfillDays int           `yaml:"backfill_days" env-default:"365"`
+}
+
+// UserService locates the user service, whose published keys access tokens
+// are verified with. Tokens of sessions it has revoked are rejected, the
+// list refreshed every RevocationInterval.
+type UserService struct {
+	URL                string        `yaml:"url" env:"USER_SERVICE_URL"`
+	RevocationInterval time.Duration `yaml:"revocation_interval" env-default:"30s"`
+}
+
+// JWT is the issuer and audience access tokens must carry.
+type JWT struct {
+	Issuer   string `yaml:"issuer" env:"JWT_ISSUER" env-default:"bux-user"`
+	Audience string `yaml:"audience" env:"JWT_AUDIENCE" env-default:"bux"`
+}
+
+// RateLimit caps requests per client address at Limit per Window. Store is
+// "memory", counted per instance, or "postgres", shared by all of them.
+// Client addresses are taken from X-Forwarded-For only for requests from
+// TrustedProxies.
+type RateLimit struct {
+	Store          string        `yaml:"store" env:"RATE_LIMIT_STORE" env-default:"memory"`
+	Limit          int           `yaml:"limit" env:"RATE_LIMIT_LIMIT" env-default:"300"`
+	Window         time.Duration `yaml:"window" env:"RATE_LIMIT_WINDOW" env-default:"1m"`
+	TrustedProxies []string      `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+}
+
 type DBConfig struct {
 	User     string
 	Password string
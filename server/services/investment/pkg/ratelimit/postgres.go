@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// postgresSweepEvery is how many hits pass between deletions of
+	// buckets whose window ended long ago.
+	postgresSweepEvery = 1000
+	postgresSweepAge   = 24 * time.Hour
+)
+
+// Bucket is one key's count in its current window.
+type Bucket struct {
+	Key         string    `gorm:"primaryKey;size:255"`
+	WindowStart time.Time `gorm:"not null;index"`
+	Count       int       `gorm:"not null"`
+}
+
+func (Bucket) TableName() string {
+	return "rate_limit_buckets"
+}
+
+// PostgresStore counts requests in a table, so every instance of a service
+// shares the same limits. Windows follow the database clock.
+type PostgresStore struct {
+	db   *gorm.DB
+	hits atomic.Uint64
+}
+
+// NewPostgresStore creates the bucket table if needed.
+func NewPostgresStore(db *gorm.DB) (*PostgresStore, error) {
+	if err := db.AutoMigrate(&Bucket{}); err != nil {
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+func (s *PostgresStore) Hit(ctx context.Context, key string, window time.Duration) (int, error) {
+	if s.hits.Add(1)%postgresSweepEvery == 0 {
+		s.db.WithContext(ctx).Where("window_start < ?", time.Now().Add(-postgresSweepAge)).Delete(&Bucket{})
+	}
+
+	// One statement both starts a new window and counts within the current
+	// one, so concurrent hits can't lose counts
+	var count int
+	err := s.db.WithContext(ctx).Raw(`
+		INSERT INTO rate_limit_buckets (key, window_start, count)
+		VALUES (?, to_timestamp(floor(extract(epoch FROM now())::float8 / ?::float8) * ?::float8), 1)
+		ON CONFLICT (key) DO UPDATE SET
+			count = CASE WHEN rate_limit_buckets.window_start = EXCLUDED.window_start
+				THEN rate_limit_buckets.count + 1 ELSE 1 END,
+			window_start = EXCLUDED.window_start
+		RETURNING count`,
+		key, window.Seconds(), window.Seconds(),
+	).Scan(&count).Error
+	return count, err
+}
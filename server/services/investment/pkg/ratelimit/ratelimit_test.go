@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type failingStore struct{}
+
+func (failingStore) Hit(context.Context, string, time.Duration) (int, error) {
+	return 0, errors.New("store down")
+}
+
+func newLimitedRouter(store Store, limit int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	r.Use(Middleware(store, Rule{Name: "test", Limit: limit, Window: time.Hour, Key: ByIP}, log))
+	r.GET("/", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	return r
+}
+
+func get(r *gin.Engine, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddlewareLimits(t *testing.T) {
+	r := newLimitedRouter(NewMemoryStore(), 2)
+
+	for i, remaining := range []string{"1", "0"} {
+		w := get(r, "10.0.0.1:1234")
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d, want 200", i+1, w.Code)
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != remaining {
+			t.Errorf("request %d: remaining %s, want %s", i+1, got, remaining)
+		}
+		if w.Header().Get("Retry-After") != "" {
+			t.Errorf("request %d: Retry-After set under the limit", i+1)
+		}
+	}
+
+	w := get(r, "10.0.0.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("over the limit: status %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("over the limit: remaining %s, want 0", got)
+	}
+	retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retry < 1 || retry > 3600 {
+		t.Fatalf("Retry-After = %q, want 1 to 3600 seconds", w.Header().Get("Retry-After"))
+	}
+	reset, err := strconv.ParseInt(w.Header().Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil || reset%3600 != 0 || reset <= time.Now().Unix() {
+		t.Errorf("X-RateLimit-Reset = %q, want the next full hour", w.Header().Get("X-RateLimit-Reset"))
+	}
+
+	// Another client has its own allowance
+	if w := get(r, "10.0.0.2:1234"); w.Code != http.StatusOK {
+		t.Fatalf("another client: status %d, want 200", w.Code)
+	}
+}
+
+func TestMiddlewareLetsThroughOnStoreFailure(t *testing.T) {
+	r := newLimitedRouter(failingStore{}, 0)
+
+	w := get(r, "10.0.0.1:1234")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", w.Code)
+	}
+	if w.Header().Get("X-RateLimit-Limit") != "" {
+		t.Error("limit headers set without a count")
+	}
+}
@@ -1,19 +1,24 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
 	"time"
 	"transaction/internal/data/repository"
 	"transaction/internal/domain/service"
+	"transaction/internal/infra/auth"
 	"transaction/internal/infra/db"
+	"transaction/internal/infra/notify"
 	"transaction/internal/presentation/http"
 	"transaction/pkg/config"
 	"transaction/pkg/logger"
 	"transaction/pkg/logger/sl"
+	"transaction/pkg/ratelimit"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -33,30 +38,76 @@ func main() {
 		log.Error("Error in migration", sl.Err(err))
 	}
 
+	if cfg.UserService.URL == "" {
+		log.Error("user_service.url is required to verify access tokens")
+		os.Exit(1)
+	}
+	// Verify access tokens against the user service's signing keys and
+	// reject those of sessions signed out there
+	useJWKS(cfg, log)
+	watchRevocations(cfg.UserService, log)
+
+	// Exchange rates (pairs missing in the table are crossed through RUB)
+	rateRepo := repository.NewExchangeRateRepository(postgres)
+	rateProvider := service.NewStoredRateProvider(rateRepo, service.DefaultReportingCurrency)
+	converter := service.NewCurrencyConverter(rateProvider)
+	rateService := service.NewExchangeRateService(rateRepo, rateProvider)
+
 	// Account
 	accountRepo := repository.NewAccountRepository(postgres)
-	accountService := service.NewAccountService(accountRepo)
+	accountService := service.NewAccountService(accountRepo, converter)
 
 	// Category
 	categoryRepo := repository.NewCategoryRepository(postgres)
 	categoryService := service.NewCategoryService(categoryRepo)
 
-	// Transaction (with account repo for balance updates)
+	// Transaction (balance updates run in a single DB transaction)
+	uow := repository.NewUnitOfWork(postgres)
 	txRepo := repository.New(postgres)
-	txService := service.NewWithAccountRepo(txRepo, accountRepo)
+	txService := service.NewWithUnitOfWork(txRepo, accountRepo, uow)
+
+	// Reconciliation
+	reconcileService := service.NewReconciliationService(accountRepo, uow)
+
+	// Category rules (applied to new and imported transactions)
+	ruleRepo := repository.NewCategoryRuleRepository(postgres)
+	ruleService := service.NewCategoryRuleService(ruleRepo, categoryRepo, accountRepo, uow)
+	txService.UseCategorizer(ruleService)
+
+	// Statement import
+	importService := service.NewImportService(txRepo, accountRepo, uow, ruleService)
 
 	// Analytics
-	analyticsService := service.NewAnalyticsService(txRepo)
+	analyticsService := service.NewAnalyticsService(txRepo, categoryRepo, converter)
 
 	// Budget
 	budgetRepo := repository.NewBudgetRepository(postgres)
-	budgetService := service.NewBudgetService(budgetRepo)
+	budgetService := service.NewBudgetService(budgetRepo, converter)
 
 	// Recurring Transactions
 	recurringTxRepo := repository.NewRecurringTransactionRepository(postgres)
-	recurringTxService := service.NewRecurringTransactionService(recurringTxRepo, txRepo, accountRepo)
+	recurringTxService := service.NewRecurringTransactionService(recurringTxRepo, txRepo, accountRepo, uow)
+
+	// Budget alerts (queued in the notification outbox on every new or changed transaction)
+	notificationRepo := repository.NewNotificationRepository(postgres)
+	notificationService := service.NewNotificationService(notificationRepo, newNotifier(cfg.Notifier, log))
+	budgetAlertService := service.NewBudgetAlertService(budgetService)
+	txService.UseChangeListener(budgetAlertService)
+	importService.UseChangeListener(budgetAlertService)
+	recurringTxService.UseChangeListener(budgetAlertService)
+	ruleService.UseChangeListener(budgetAlertService)
 
 	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.RateLimit.TrustedProxies); err != nil {
+		log.Error("Invalid trusted proxies", sl.Err(err))
+		os.Exit(1)
+	}
+	r.Use(ratelimit.Middleware(newRateLimitStore(cfg.RateLimit, postgres, log), ratelimit.Rule{
+		Name:   "api",
+		Limit:  cfg.RateLimit.Limit,
+		Window: cfg.RateLimit.Window,
+		Key:    ratelimit.ByIP,
+	}, log))
 	http.New(r, txService)
 	http.NewAccountHTTP(r, accountService)
 	http.NewCategoryHTTP(r, categoryService)
@@ -64,6 +115,11 @@ func main() {
 	http.NewBudgetHTTP(r, budgetService)
 	http.NewExportHTTP(r, txService)
 	http.NewRecurringTransactionHTTP(r, recurringTxService)
+	http.NewReconcileHTTP(r, reconcileService)
+	http.NewExchangeRateHTTP(r, rateService)
+	http.NewImportHTTP(r, importService)
+	http.NewCategoryRuleHTTP(r, ruleService)
+	http.NewNotificationHTTP(r, notificationService)
 
 	// Start recurring transaction scheduler
 	go func() {
@@ -78,7 +134,82 @@ func main() {
 		}
 	}()
 
+	// Deliver queued notifications
+	go func() {
+		ticker := time.NewTicker(cfg.Notifier.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			res, err := notificationService.Dispatch(context.Background(), cfg.Notifier.BatchSize)
+			if err != nil {
+				log.Error("Error dispatching notifications", sl.Err(err))
+			} else if res.Sent+res.Retry+res.Failed > 0 {
+				log.Info("Dispatched notifications",
+					slog.Int("sent", res.Sent),
+					slog.Int("retry", res.Retry),
+					slog.Int("failed", res.Failed),
+				)
+			}
+		}
+	}()
+
 	if err := r.Run(fmt.Sprintf(":%d", cfg.HTTPServer.Port)); err != nil {
 		log.Error("Unable to start the server: ", sl.Err(err))
 	}
 }
+
+func newNotifier(cfg config.Notifier, log *slog.Logger) service.Notifier {
+	switch cfg.Type {
+	case "webhook":
+		if cfg.WebhookURL == "" {
+			log.Error("Webhook notifier needs a webhook_url, logging notifications instead")
+			break
+		}
+		return notify.NewWebhookNotifier(cfg.WebhookURL, cfg.WebhookSecret)
+	case "log", "":
+	default:
+		log.Error("Unknown notifier type, logging notifications instead", slog.String("type", cfg.Type))
+	}
+	return notify.NewLogNotifier(log)
+}
+
+// useJWKS installs the user service's token verification keys. A failed
+// first fetch is retried when the next token arrives.
+func useJWKS(cfg *config.Config, log *slog.Logger) {
+	jwks := auth.NewJWKS(cfg.UserService.URL)
+	if _, err := jwks.Refresh(context.Background()); err != nil {
+		log.Error("Error fetching token verification keys", sl.Err(err))
+	}
+	auth.UseJWKS(jwks, cfg.JWT.Issuer, cfg.JWT.Audience)
+}
+
+// watchRevocations installs the user service's revocation list and keeps it
+// current in the background.
+func watchRevocations(cfg config.UserService, log *slog.Logger) {
+	revocations := auth.NewRevocationList(cfg.URL)
+	auth.UseRevocationList(revocations)
+	go func() {
+		ticker := time.NewTicker(cfg.RevocationInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := revocations.Refresh(context.Background()); err != nil {
+				log.Error("Error refreshing session revocations", sl.Err(err))
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func newRateLimitStore(cfg config.RateLimit, db *gorm.DB, log *slog.Logger) ratelimit.Store {
+	switch cfg.Store {
+	case "postgres":
+		store, err := ratelimit.NewPostgresStore(db)
+		if err == nil {
+			return store
+		}
+		log.Error("Failed to init rate limit table, counting in memory instead", sl.Err(err))
+	case "memory", "":
+	default:
+		log.Error("Unknown rate limit store, counting in memory instead", slog.String("store", cfg.Store))
+	}
+	return ratelimit.NewMemoryStore()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+	"transaction/internal/data/repository"
+	"transaction/internal/domain/service"
+	"transaction/internal/infra/db"
+	"transaction/pkg/config"
+	"transaction/pkg/logger"
+	"transaction/pkg/logger/sl"
+)
+
+// reconcile compares cached account balances with the transaction ledger and,
+// with -fix, rewrites drifted balances in bulk.
+func main() {
+	fix := flag.Bool("fix", false, "rewrite cached balances that differ from the ledger")
+	userID := flag.Uint("user", 0, "only reconcile accounts of this user")
+	flag.Parse()
+
+	cfg := config.MustLoad()
+	log := logger.SetupLogger(cfg.Env)
+
+	postgres, err := db.New(cfg.Postgres)
+	if err != nil {
+		log.Error("Failed to init storage", sl.Err(err))
+		os.Exit(1)
+	}
+
+	accountRepo := repository.NewAccountRepository(postgres)
+	uow := repository.NewUnitOfWork(postgres)
+	reconcileService := service.NewReconciliationService(accountRepo, uow)
+
+	results, err := reconcileService.ReconcileAll(*userID, *fix)
+	if err != nil {
+		log.Error("Reconciliation failed", sl.Err(err))
+	}
+
+	drifted := 0
+	for _, r := range results {
+		if r.IsBalanced() {
+			continue
+		}
+		drifted++
+		log.Warn("Balance drift",
+			slog.Uint64("account_id", uint64(r.AccountID)),
+			slog.Uint64("user_id", uint64(r.UserID)),
+			slog.String("currency", r.Currency),
+			slog.String("cached", r.CachedBalance.String()),
+			slog.String("expected", r.ExpectedBalance.String()),
+			slog.String("difference", r.Difference.String()),
+			slog.Bool("fixed", r.Fixed),
+		)
+	}
+
+	log.Info("Reconciliation finished",
+		slog.Int("accounts", len(results)),
+		slog.Int("drifted", drifted),
+		slog.Bool("fix", *fix),
+	)
+
+	if err != nil || (drifted > 0 && !*fix) {
+		os.Exit(1)
+	}
+}
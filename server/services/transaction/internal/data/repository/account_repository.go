@@ -5,6 +5,7 @@ import (
 
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AccountRepository struct {
@@ -28,6 +29,18 @@ func (r *AccountRepository) GetByID(id uint) (*model.Account, error) {
 	return &account, nil
 }
 
+// GetByIDsForUpdate loads the accounts and holds row locks on them until the
+// surrounding transaction ends. Rows are locked in id order to avoid deadlocks
+// between concurrent transfers.
+func (r *AccountRepository) GetByIDsForUpdate(ids []uint) ([]model.Account, error) {
+	var accounts []model.Account
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).
+		Order("id ASC").
+		Find(&accounts).Error
+	return accounts, err
+}
+
 func (r *AccountRepository) GetByUserID(userID uint) ([]model.Account, error) {
 	var accounts []model.Account
 	err := r.db.Where("user_id = ?", userID).
@@ -44,8 +57,12 @@ func (r *AccountRepository) GetActiveByUserID(userID uint) ([]model.Account, err
 	return accounts, err
 }
 
+// Update writes the fields users edit. Balances only change under the row
+// lock taken by GetByIDsForUpdate, so a stale copy mustn't overwrite them.
 func (r *AccountRepository) Update(account *model.Account) error {
-	return r.db.Save(account).Error
+	return r.db.Model(account).
+		Select("name", "icon", "color", "sort_order", "is_active").
+		Updates(account).Error
 }
 
 func (r *AccountRepository) Delete(id uint) error {
@@ -58,13 +75,71 @@ func (r *AccountRepository) UpdateBalance(id uint, newBalance decimal.Decimal) e
 		Update("balance", newBalance).Error
 }
 
-func (r *AccountRepository) GetTotalBalance(userID uint, currency string) (decimal.Decimal, error) {
-	var result struct {
-		Total decimal.Decimal
-	}
+type CurrencyTotalRow struct {
+	Currency string
+	Total    decimal.Decimal
+}
+
+// GetTotalsByCurrency sums active account balances of a user per account currency.
+func (r *AccountRepository) GetTotalsByCurrency(userID uint) ([]CurrencyTotalRow, error) {
+	var rows []CurrencyTotalRow
 	err := r.db.Model(&model.Account{}).
-		Select("COALESCE(SUM(balance), 0) as total").
-		Where("user_id = ? AND currency = ? AND is_active = ?", userID, currency, true).
-		Scan(&result).Error
-	return result.Total, err
+		Select("currency, COALESCE(SUM(balance), 0) as total").
+		Where("user_id = ? AND is_active = ?", userID, true).
+		Group("currency").
+		Order("currency").
+		Scan(&rows).Error
+	return rows, err
+}
+
+type LedgerNetRow struct {
+	AccountID uint
+	Net       decimal.Decimal
+}
+
+// GetLedgerNet sums the effect of completed, non-deleted transactions on each
+// of the given accounts. Accounts without transactions are absent from the result.
+func (r *AccountRepository) GetLedgerNet(accountIDs []uint) (map[uint]decimal.Decimal, error) {
+	result := make(map[uint]decimal.Decimal, len(accountIDs))
+	if len(accountIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []LedgerNetRow
+	err := r.db.Raw(`
+		SELECT account_id, SUM(net) as net
+		FROM (
+			SELECT
+				t.account_id,
+				CASE WHEN t.type = 'income' THEN t.amount ELSE -t.amount END as net
+			FROM transactions t
+			WHERE t.account_id IN ?
+			  AND t.status = 'completed'
+			  AND t.deleted_at IS NULL
+			UNION ALL
+			SELECT
+				t.destination_account_id as account_id,
+				COALESCE(t.destination_amount, t.amount) as net
+			FROM transactions t
+			WHERE t.destination_account_id IN ?
+			  AND t.type = 'transfer'
+			  AND t.status = 'completed'
+			  AND t.deleted_at IS NULL
+		) ledger
+		GROUP BY account_id
+	`, accountIDs, accountIDs).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		result[row.AccountID] = row.Net
+	}
+	return result, nil
+}
+
+func (r *AccountRepository) GetAll() ([]model.Account, error) {
+	var accounts []model.Account
+	err := r.db.Order("id ASC").Find(&accounts).Error
+	return accounts, err
 }
@@ -30,6 +30,7 @@ func (r *BudgetRepository) GetByUserID(userID uint) ([]model.Budget, error) {
 	var budgets []model.Budget
 	err := r.db.Preload("Category").
 		Where("user_id = ?", userID).
+		Order("id ASC").
 		Find(&budgets).Error
 	return budgets, err
 }
@@ -42,43 +43,42 @@ func (r *BudgetRepository) Delete(id uint) error {
 	return r.db.Delete(&model.Budget{}, id).Error
 }
 
-type BudgetStatusRow struct {
-	BudgetID      uint
-	CategoryID    uint
-	CategoryName  string
-	CategoryIcon  string
-	CategoryColor string
-	BudgetAmount  decimal.Decimal
-	SpentAmount   decimal.Decimal
-	Period        string
-	Currency      string
+type DailySpendingRow struct {
+	// CategoryID is the requested category the spending rolls up to
+	CategoryID uint
+	Day        time.Time
+	Currency   string
+	Amount     decimal.Decimal
 }
 
-func (r *BudgetRepository) GetBudgetStatus(userID uint, from, to time.Time) ([]BudgetStatusRow, error) {
-	var rows []BudgetStatusRow
+// GetDailySpending sums completed expense lines in each of the categories and
+// their subcategories per UTC day and currency over [from, to), in one query.
+// A line counts for every requested category it falls under. Split
+// transactions count by their lines. Rows are ordered by category and day.
+func (r *BudgetRepository) GetDailySpending(userID uint, categoryIDs []uint, from, to time.Time) ([]DailySpendingRow, error) {
+	var rows []DailySpendingRow
 	err := r.db.Raw(`
+		WITH RECURSIVE tree AS (
+			SELECT id AS root_id, id FROM categories WHERE id IN ? AND deleted_at IS NULL
+			UNION
+			SELECT tree.root_id, c.id FROM categories c JOIN tree ON c.parent_id = tree.id
+			WHERE c.deleted_at IS NULL
+		)
 		SELECT
-			b.id as budget_id,
-			b.category_id,
-			c.name as category_name,
-			c.icon as category_icon,
-			c.color as category_color,
-			b.amount as budget_amount,
-			COALESCE(SUM(t.amount), 0) as spent_amount,
-			b.period,
-			b.currency
-		FROM budgets b
-		JOIN categories c ON c.id = b.category_id
-		LEFT JOIN transactions t ON t.category_id = b.category_id
-			AND t.user_id = b.user_id
-			AND t.type = 'expense'
-			AND t.status = 'completed'
-			AND t.transaction_date >= ?
-			AND t.transaction_date <= ?
-			AND t.deleted_at IS NULL
-		WHERE b.user_id = ?
-		  AND b.deleted_at IS NULL
-		GROUP BY b.id, b.category_id, c.name, c.icon, c.color, b.amount, b.period, b.currency
-	`, from, to, userID).Scan(&rows).Error
+			tree.root_id as category_id,
+			date_trunc('day', t.transaction_date AT TIME ZONE 'UTC') as day,
+			t.currency,
+			SUM(t.amount) as amount
+		FROM `+transactionLines+` t
+		JOIN tree ON tree.id = t.category_id
+		WHERE t.user_id = ?
+		  AND t.type = 'expense'
+		  AND t.status = 'completed'
+		  AND t.transaction_date >= ?
+		  AND t.transaction_date < ?
+		  AND t.deleted_at IS NULL
+		GROUP BY tree.root_id, day, t.currency
+		ORDER BY tree.root_id, day
+	`, categoryIDs, userID, from, to).Scan(&rows).Error
 	return rows, err
 }
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"transaction/internal/domain/model"
+
+	"gorm.io/gorm"
+)
+
+type CategoryRuleRepository struct {
+	db *gorm.DB
+}
+
+func NewCategoryRuleRepository(db *gorm.DB) *CategoryRuleRepository {
+	return &CategoryRuleRepository{db: db}
+}
+
+func (r *CategoryRuleRepository) Create(rule *model.CategoryRule) (*model.CategoryRule, error) {
+	if err := r.db.Create(rule).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Preload("Category").First(rule, rule.ID).Error; err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *CategoryRuleRepository) GetByID(id uint) (*model.CategoryRule, error) {
+	var rule model.CategoryRule
+	if err := r.db.Preload("Category").First(&rule, id).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// GetByUserID returns rules in the order they are evaluated.
+func (r *CategoryRuleRepository) GetByUserID(userID uint) ([]model.CategoryRule, error) {
+	var rules []model.CategoryRule
+	err := r.db.Preload("Category").
+		Where("user_id = ?", userID).
+		Order("priority ASC, id ASC").
+		Find(&rules).Error
+	return rules, err
+}
+
+func (r *CategoryRuleRepository) GetActiveByUserID(userID uint) ([]model.CategoryRule, error) {
+	var rules []model.CategoryRule
+	err := r.db.Where("user_id = ? AND is_active = ?", userID, true).
+		Order("priority ASC, id ASC").
+		Find(&rules).Error
+	return rules, err
+}
+
+func (r *CategoryRuleRepository) Update(rule *model.CategoryRule) (*model.CategoryRule, error) {
+	rule.Category = nil
+	if err := r.db.Save(rule).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Preload("Category").First(rule, rule.ID).Error; err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *CategoryRuleRepository) Delete(id uint) error {
+	return r.db.Delete(&model.CategoryRule{}, id).Error
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"time"
+	"transaction/internal/domain/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ExchangeRateRepository struct {
+	db *gorm.DB
+}
+
+func NewExchangeRateRepository(db *gorm.DB) *ExchangeRateRepository {
+	return &ExchangeRateRepository{db: db}
+}
+
+func (r *ExchangeRateRepository) UpsertBatch(rates []model.ExchangeRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "base"}, {Name: "quote"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "updated_at"}),
+	}).CreateInBatches(&rates, 500).Error
+}
+
+// GetLatest returns the most recent base→quote rate dated on or before the given day.
+func (r *ExchangeRateRepository) GetLatest(base, quote string, on time.Time) (*model.ExchangeRate, error) {
+	var rate model.ExchangeRate
+	err := r.db.Where("base = ? AND quote = ? AND date <= ?", base, quote, on).
+		Order("date DESC").
+		First(&rate).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+func (r *ExchangeRateRepository) GetRange(base, quote string, from, to time.Time) ([]model.ExchangeRate, error) {
+	var rates []model.ExchangeRate
+	err := r.db.Where("base = ? AND quote = ? AND date >= ? AND date <= ?", base, quote, from, to).
+		Order("date ASC").
+		Find(&rates).Error
+	return rates, err
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"time"
+	"transaction/internal/domain/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// Enqueue stores n unless a notification with the same dedupe key exists.
+// It reports whether n was stored.
+func (r *NotificationRepository) Enqueue(n *model.Notification) (bool, error) {
+	res := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "dedupe_key"}},
+		DoNothing: true,
+	}).Create(n)
+	return res.RowsAffected > 0, res.Error
+}
+
+// ClaimDue leases up to limit pending notifications whose next attempt is
+// due, oldest first, by moving their next attempt to until. Claimed rows are
+// not due again for anyone else until the lease runs out, so the claim is
+// its own short transaction and delivery needs no lock held.
+func (r *NotificationRepository) ClaimDue(now, until time.Time, limit int) ([]model.Notification, error) {
+	var notifications []model.Notification
+	err := r.db.Raw(`
+		WITH claimed AS (
+			UPDATE notifications SET next_attempt_at = ?, updated_at = ?
+			WHERE id IN (
+				SELECT id FROM notifications
+				WHERE status = ? AND next_attempt_at <= ?
+				ORDER BY id
+				LIMIT ?
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *
+		)
+		SELECT * FROM claimed ORDER BY id
+	`, until, now, model.NotificationPending, now, limit).Scan(&notifications).Error
+	return notifications, err
+}
+
+func (r *NotificationRepository) GetByUserID(userID uint, status model.NotificationStatus, limit int) ([]model.Notification, error) {
+	var notifications []model.Notification
+	query := r.db.Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("id DESC").Limit(limit).Find(&notifications).Error
+	return notifications, err
+}
+
+func (r *NotificationRepository) Update(n *model.Notification) error {
+	return r.db.Save(n).Error
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TransactionRepository struct {
@@ -16,12 +17,20 @@ func New(db *gorm.DB) *TransactionRepository {
 	return &TransactionRepository{db}
 }
 
+// withRelations preloads what responses show: the category and the split
+// lines with their categories.
+func (r *TransactionRepository) withRelations() *gorm.DB {
+	return r.db.Preload("Category").
+		Preload("Splits", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Splits.Category")
+}
+
 func (r *TransactionRepository) Create(transaction *model.Transaction) (*model.Transaction, error) {
 	if err := r.db.Create(transaction).Error; err != nil {
 		return nil, err
 	}
 
-	if err := r.db.Preload("Category").First(transaction, transaction.ID).Error; err != nil {
+	if err := r.withRelations().First(transaction, transaction.ID).Error; err != nil {
 		return nil, err
 	}
 
@@ -30,7 +39,17 @@ func (r *TransactionRepository) Create(transaction *model.Transaction) (*model.T
 
 func (r *TransactionRepository) GetByID(id uint) (*model.Transaction, error) {
 	var tx model.Transaction
-	if err := r.db.Preload("Category").First(&tx, id).Error; err != nil {
+	if err := r.withRelations().First(&tx, id).Error; err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// GetByIDForUpdate loads a transaction and locks its row until the
+// surrounding transaction ends.
+func (r *TransactionRepository) GetByIDForUpdate(id uint) (*model.Transaction, error) {
+	var tx model.Transaction
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&tx, id).Error; err != nil {
 		return nil, err
 	}
 	return &tx, nil
@@ -38,7 +57,7 @@ func (r *TransactionRepository) GetByID(id uint) (*model.Transaction, error) {
 
 func (r *TransactionRepository) GetAll() ([]model.Transaction, error) {
 	var txs []model.Transaction
-	if err := r.db.Preload("Category").Order("transaction_date DESC").Find(&txs).Error; err != nil {
+	if err := r.withRelations().Order("transaction_date DESC").Find(&txs).Error; err != nil {
 		return nil, err
 	}
 	return txs, nil
@@ -46,7 +65,7 @@ func (r *TransactionRepository) GetAll() ([]model.Transaction, error) {
 
 func (r *TransactionRepository) GetByUserID(userID uint) ([]model.Transaction, error) {
 	var txs []model.Transaction
-	if err := r.db.Preload("Category").
+	if err := r.withRelations().
 		Where("user_id = ?", userID).
 		Order("transaction_date DESC").
 		Find(&txs).Error; err != nil {
@@ -64,7 +83,7 @@ func (r *TransactionRepository) GetByUserIDPaginated(userID uint, limit, offset
 		return nil, 0, err
 	}
 
-	if err := r.db.Preload("Category").
+	if err := r.withRelations().
 		Where("user_id = ?", userID).
 		Order("transaction_date DESC").
 		Limit(limit).Offset(offset).
@@ -76,7 +95,7 @@ func (r *TransactionRepository) GetByUserIDPaginated(userID uint, limit, offset
 
 func (r *TransactionRepository) GetByAccountID(accountID, userID uint) ([]model.Transaction, error) {
 	var txs []model.Transaction
-	if err := r.db.Preload("Category").
+	if err := r.withRelations().
 		Where("(account_id = ? OR destination_account_id = ?) AND user_id = ?", accountID, accountID, userID).
 		Order("transaction_date DESC").
 		Find(&txs).Error; err != nil {
@@ -95,7 +114,7 @@ func (r *TransactionRepository) GetByAccountIDPaginated(accountID, userID uint,
 		return nil, 0, err
 	}
 
-	if err := r.db.Preload("Category").
+	if err := r.withRelations().
 		Where("(account_id = ? OR destination_account_id = ?) AND user_id = ?", accountID, accountID, userID).
 		Order("transaction_date DESC").
 		Limit(limit).Offset(offset).
@@ -105,58 +124,155 @@ func (r *TransactionRepository) GetByAccountIDPaginated(accountID, userID uint,
 	return txs, count, nil
 }
 
+// Search returns the transactions matching every criterion, in the order set
+// by an OrderedBy criterion.
+func (r *TransactionRepository) Search(criteria ...TransactionCriterion) ([]model.Transaction, error) {
+	var txs []model.Transaction
+	if err := r.withRelations().
+		Scopes(scopes(criteria)...).
+		Find(&txs).Error; err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+func (r *TransactionRepository) SearchPaginated(limit, offset int, criteria ...TransactionCriterion) ([]model.Transaction, int64, error) {
+	var txs []model.Transaction
+	var count int64
+
+	// Count only drops an ORDER BY already on the statement, and scopes are
+	// applied later, so the criteria are applied up front here
+	q := r.db.Model(&model.Transaction{})
+	for _, c := range criteria {
+		q = c(q)
+	}
+	if err := q.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := r.withRelations().
+		Scopes(scopes(criteria)...).
+		Limit(limit).Offset(offset).
+		Find(&txs).Error; err != nil {
+		return nil, 0, err
+	}
+	return txs, count, nil
+}
+
+func scopes(criteria []TransactionCriterion) []func(*gorm.DB) *gorm.DB {
+	res := make([]func(*gorm.DB) *gorm.DB, len(criteria))
+	for i, c := range criteria {
+		res[i] = c
+	}
+	return res
+}
+
 func (r *TransactionRepository) Update(tx *model.Transaction) (*model.Transaction, error) {
-	if err := r.db.Save(tx).Error; err != nil {
+	// Associations are omitted so a stale preloaded Category can't override CategoryID
+	if err := r.db.Omit(clause.Associations).Save(tx).Error; err != nil {
 		return nil, err
 	}
+
+	if err := r.replaceSplits(tx); err != nil {
+		return nil, err
+	}
+
+	tx.Category = nil
+	tx.Splits = nil
+	if err := r.withRelations().First(tx, tx.ID).Error; err != nil {
+		return nil, err
+	}
+
 	return tx, nil
 }
 
+// replaceSplits swaps the stored split lines of tx for tx.Splits.
+func (r *TransactionRepository) replaceSplits(tx *model.Transaction) error {
+	if err := r.db.Where("transaction_id = ?", tx.ID).Delete(&model.TransactionSplit{}).Error; err != nil {
+		return err
+	}
+	if len(tx.Splits) == 0 {
+		return nil
+	}
+	for i := range tx.Splits {
+		tx.Splits[i].ID = 0
+		tx.Splits[i].TransactionID = tx.ID
+		tx.Splits[i].Category = nil
+	}
+	return r.db.Create(&tx.Splits).Error
+}
+
 func (r *TransactionRepository) Delete(id uint) error {
 	return r.db.Delete(&model.Transaction{}, id).Error
 }
 
 // Analytics aggregation queries
 
+// transactionLines is a subquery with one row per categorised line: split
+// transactions contribute their split lines, others the transaction itself.
+const transactionLines = `(
+	SELECT t.id AS transaction_id, t.user_id, t.category_id, t.amount, t.type, t.currency,
+		t.status, t.transaction_date, t.deleted_at
+	FROM transactions t
+	WHERE NOT EXISTS (SELECT 1 FROM transaction_splits s WHERE s.transaction_id = t.id)
+	UNION ALL
+	SELECT t.id, t.user_id, s.category_id, s.amount, t.type, t.currency,
+		t.status, t.transaction_date, t.deleted_at
+	FROM transaction_splits s
+	JOIN transactions t ON t.id = s.transaction_id
+)`
+
 type CategoryTotalRow struct {
 	CategoryID    *uint
 	CategoryName  string
 	CategoryIcon  string
 	CategoryColor string
 	Type          string
+	Currency      string
 	Total         decimal.Decimal
 	Count         int64
 }
 
 type MonthlyTotalRow struct {
-	Year    int
-	Month   int
-	Income  decimal.Decimal
-	Expense decimal.Decimal
+	Year     int
+	Month    int
+	Currency string
+	Income   decimal.Decimal
+	Expense  decimal.Decimal
 }
 
-func (r *TransactionRepository) GetSummaryByCategory(userID uint, from, to time.Time) ([]CategoryTotalRow, error) {
+// GetSummaryByCategory totals completed income and expense lines per category
+// and currency. With rollup, subcategory lines count towards their top-level
+// category and only top-level categories are returned.
+func (r *TransactionRepository) GetSummaryByCategory(userID uint, from, to time.Time, rollup bool) ([]CategoryTotalRow, error) {
 	var rows []CategoryTotalRow
 	err := r.db.Raw(`
+		WITH RECURSIVE category_roots AS (
+			SELECT id, id AS root_id FROM categories WHERE parent_id IS NULL
+			UNION
+			SELECT c.id, cr.root_id FROM categories c JOIN category_roots cr ON c.parent_id = cr.id
+		)
 		SELECT
-			t.category_id,
+			c.id as category_id,
 			COALESCE(c.name, 'Без категории') as category_name,
 			COALESCE(c.icon, '') as category_icon,
 			COALESCE(c.color, '') as category_color,
 			t.type,
+			t.currency,
 			SUM(t.amount) as total,
 			COUNT(*) as count
-		FROM transactions t
-		LEFT JOIN categories c ON c.id = t.category_id
+		FROM `+transactionLines+` t
+		LEFT JOIN category_roots cr ON cr.id = t.category_id
+		LEFT JOIN categories c ON c.id = CASE WHEN ? THEN COALESCE(cr.root_id, t.category_id) ELSE t.category_id END
 		WHERE t.user_id = ?
 		  AND t.type IN ('income', 'expense')
 		  AND t.status = 'completed'
 		  AND t.transaction_date >= ?
 		  AND t.transaction_date <= ?
 		  AND t.deleted_at IS NULL
-		GROUP BY t.category_id, c.name, c.icon, c.color, t.type
+		GROUP BY c.id, c.name, c.icon, c.color, t.type, t.currency
 		ORDER BY total DESC
-	`, userID, from, to).Scan(&rows).Error
+	`, rollup, userID, from, to).Scan(&rows).Error
 	return rows, err
 }
 
@@ -166,6 +282,7 @@ func (r *TransactionRepository) GetSummaryByMonth(userID uint, from, to time.Tim
 		SELECT
 			EXTRACT(YEAR FROM transaction_date)::int as year,
 			EXTRACT(MONTH FROM transaction_date)::int as month,
+			currency,
 			COALESCE(SUM(CASE WHEN type = 'income' THEN amount ELSE 0 END), 0) as income,
 			COALESCE(SUM(CASE WHEN type = 'expense' THEN amount ELSE 0 END), 0) as expense
 		FROM transactions
@@ -175,8 +292,40 @@ func (r *TransactionRepository) GetSummaryByMonth(userID uint, from, to time.Tim
 		  AND transaction_date >= ?
 		  AND transaction_date <= ?
 		  AND deleted_at IS NULL
-		GROUP BY year, month
+		GROUP BY year, month, currency
 		ORDER BY year, month
 	`, userID, from, to).Scan(&rows).Error
 	return rows, err
 }
+
+// SetAccount moves the given transactions to accountID in batches, like
+// SetCategory. Balances are left to the caller.
+func (r *TransactionRepository) SetAccount(ids []uint, accountID uint) error {
+	const batch = 1000
+	for start := 0; start < len(ids); start += batch {
+		end := min(start+batch, len(ids))
+		err := r.db.Model(&model.Transaction{}).
+			Where("id IN ?", ids[start:end]).
+			Update("account_id", accountID).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetCategory moves the given transactions to categoryID in batches that stay
+// under the Postgres bind parameter limit.
+func (r *TransactionRepository) SetCategory(ids []uint, categoryID uint) error {
+	const batch = 1000
+	for start := 0; start < len(ids); start += batch {
+		end := min(start+batch, len(ids))
+		err := r.db.Model(&model.Transaction{}).
+			Where("id IN ?", ids[start:end]).
+			Update("category_id", categoryID).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"strings"
+	"time"
+	"transaction/internal/domain/model"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TransactionCriterion narrows or orders a transactions query. Criteria are
+// plain gorm scopes, so any combination can be passed to Search.
+type TransactionCriterion func(db *gorm.DB) *gorm.DB
+
+type TransactionSort string
+
+const (
+	SortDateDesc   TransactionSort = "date_desc"
+	SortDateAsc    TransactionSort = "date_asc"
+	SortAmountDesc TransactionSort = "amount_desc"
+	SortAmountAsc  TransactionSort = "amount_asc"
+)
+
+func IsValidTransactionSort(s TransactionSort) bool {
+	switch s {
+	case SortDateDesc, SortDateAsc, SortAmountDesc, SortAmountAsc:
+		return true
+	}
+	return false
+}
+
+func ByUser(userID uint) TransactionCriterion {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("transactions.user_id = ?", userID)
+	}
+}
+
+// ByAccount matches transactions where the account is either side of the movement.
+func ByAccount(accountID uint) TransactionCriterion {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("(transactions.account_id = ? OR transactions.destination_account_id = ?)", accountID, accountID)
+	}
+}
+
+func DateFrom(from time.Time) TransactionCriterion {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("transactions.transaction_date >= ?", from)
+	}
+}
+
+func DateTo(to time.Time) TransactionCriterion {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("transactions.transaction_date <= ?", to)
+	}
+}
+
+func OfTypes(types ...model.TransactionType) TransactionCriterion {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("transactions.type IN ?", types)
+	}
+}
+
+func WithStatuses(statuses ...model.TransactionStatus) TransactionCriterion {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("transactions.status IN ?", statuses)
+	}
+}
+
+// InCategories matches transactions in the categories, or with a split line in them.
+func InCategories(categoryIDs ...uint) TransactionCriterion {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`(transactions.category_id IN ? OR EXISTS (
+			SELECT 1 FROM transaction_splits s
+			WHERE s.transaction_id = transactions.id AND s.category_id IN ?
+		))`, categoryIDs, categoryIDs)
+	}
+}
+
+// Uncategorized matches transactions with no category that are not split;
+// split lines carry their own categories.
+func Uncategorized() TransactionCriterion {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("transactions.category_id IS NULL").
+			Where("NOT EXISTS (SELECT 1 FROM transaction_splits s WHERE s.transaction_id = transactions.id)")
+	}
+}
+
+// categoryTree selects the given categories and all of their descendants.
+const categoryTree = `
+	WITH RECURSIVE tree AS (
+		SELECT id FROM categories WHERE id IN ? AND deleted_at IS NULL
+		UNION
+		SELECT c.id FROM categories c JOIN tree ON c.parent_id = tree.id
+		WHERE c.deleted_at IS NULL
+	)
+	SELECT id FROM tree`
+
+// InCategoryTrees matches the given categories and all of their descendants
+// via Category.ParentID, on the transaction or any of its split lines.
+func InCategoryTrees(categoryIDs ...uint) TransactionCriterion {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`(transactions.category_id IN (`+categoryTree+`) OR EXISTS (
+			SELECT 1 FROM transaction_splits s
+			WHERE s.transaction_id = transactions.id AND s.category_id IN (`+categoryTree+`)
+		))`, categoryIDs, categoryIDs)
+	}
+}
+
+func MinAmount(min decimal.Decimal) TransactionCriterion {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("transactions.amount >= ?", min)
+	}
+}
+
+func MaxAmount(max decimal.Decimal) TransactionCriterion {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("transactions.amount <= ?", max)
+	}
+}
+
+func WithCurrency(currency string) TransactionCriterion {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("transactions.currency = ?", strings.ToUpper(currency))
+	}
+}
+
+// DescriptionContains does a case-insensitive substring match. LIKE wildcards
+// in text are matched literally.
+func DescriptionContains(text string) TransactionCriterion {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text)
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("transactions.description ILIKE ?", "%"+escaped+"%")
+	}
+}
+
+// OrderedBy sorts the result; id breaks ties so the order is stable.
+func OrderedBy(sort TransactionSort) TransactionCriterion {
+	return func(db *gorm.DB) *gorm.DB {
+		switch sort {
+		case SortDateAsc:
+			return db.Order("transactions.transaction_date ASC, transactions.id ASC")
+		case SortAmountDesc:
+			return db.Order("transactions.amount DESC, transactions.id DESC")
+		case SortAmountAsc:
+			return db.Order("transactions.amount ASC, transactions.id ASC")
+		}
+		return db.Order("transactions.transaction_date DESC, transactions.id DESC")
+	}
+}
+
+// AfterKey continues a date-ordered listing after the row at (date, id).
+func AfterKey(date time.Time, id uint, sort TransactionSort) TransactionCriterion {
+	return func(db *gorm.DB) *gorm.DB {
+		if sort == SortDateAsc {
+			return db.Where("(transactions.transaction_date, transactions.id) > (?, ?)", date, id)
+		}
+		return db.Where("(transactions.transaction_date, transactions.id) < (?, ?)", date, id)
+	}
+}
+
+// ForUpdate locks the matched transactions until the surrounding database
+// transaction ends. Preloaded relations are not locked.
+func ForUpdate() TransactionCriterion {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+}
+
+func Limited(n int) TransactionCriterion {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Limit(n)
+	}
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+)
+
+// Repositories is the set of repositories bound to one database transaction.
+type Repositories struct {
+	Transactions  *TransactionRepository
+	Accounts      *AccountRepository
+	Recurring     *RecurringTransactionRepository
+	Budgets       *BudgetRepository
+	Notifications *NotificationRepository
+}
+
+// UnitOfWork runs a group of repository calls inside a single Postgres transaction.
+type UnitOfWork struct {
+	db *gorm.DB
+}
+
+func NewUnitOfWork(db *gorm.DB) *UnitOfWork {
+	return &UnitOfWork{db: db}
+}
+
+// Do commits if fn returns nil and rolls everything back otherwise.
+func (u *UnitOfWork) Do(fn func(repos *Repositories) error) error {
+	return u.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&Repositories{
+			Transactions:  New(tx),
+			Accounts:      NewAccountRepository(tx),
+			Recurring:     NewRecurringTransactionRepository(tx),
+			Budgets:       NewBudgetRepository(tx),
+			Notifications: NewNotificationRepository(tx),
+		})
+	})
+}
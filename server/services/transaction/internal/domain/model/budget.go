@@ -10,10 +10,17 @@ import (
 type BudgetPeriod string
 
 const (
-	BudgetPeriodMonthly BudgetPeriod = "monthly"
-	BudgetPeriodYearly  BudgetPeriod = "yearly"
+	BudgetPeriodWeekly    BudgetPeriod = "weekly"
+	BudgetPeriodMonthly   BudgetPeriod = "monthly"
+	BudgetPeriodQuarterly BudgetPeriod = "quarterly"
+	BudgetPeriodYearly    BudgetPeriod = "yearly"
+	BudgetPeriodCustom    BudgetPeriod = "custom"
 )
 
+// MaxBudgetAnchorDay keeps month-based periods from starting on a day that
+// some months don't have.
+const MaxBudgetAnchorDay = 28
+
 type Budget struct {
 	ID         uint            `gorm:"primaryKey" json:"id"`
 	UserID     uint            `gorm:"index;not null" json:"user_id"`
@@ -22,15 +29,86 @@ type Budget struct {
 	Amount     decimal.Decimal `gorm:"type:decimal(19,4);not null" json:"amount"`
 	Currency   string          `gorm:"type:char(3);default:'RUB'" json:"currency"`
 	Period     BudgetPeriod    `gorm:"type:varchar(20);default:'monthly'" json:"period"`
-	CreatedAt  time.Time       `json:"created_at"`
-	UpdatedAt  time.Time       `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt  `gorm:"index" json:"-"`
+	// Anchor is the first day of any one period, e.g. a payday for a month
+	// running from the 25th. Without it periods follow the calendar.
+	Anchor     *time.Time `gorm:"type:date" json:"anchor,omitempty"`
+	PeriodDays int        `gorm:"default:0" json:"period_days,omitempty"`
+	Rollover   bool       `gorm:"default:false" json:"rollover"`
+	// AlertThresholds are percentages of the available amount; crossing one
+	// in a period queues a notification.
+	AlertThresholds []int          `gorm:"serializer:json;type:text" json:"alert_thresholds,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func IsValidBudgetPeriod(p BudgetPeriod) bool {
 	switch p {
-	case BudgetPeriodMonthly, BudgetPeriodYearly:
+	case BudgetPeriodWeekly, BudgetPeriodMonthly, BudgetPeriodQuarterly, BudgetPeriodYearly, BudgetPeriodCustom:
 		return true
 	}
 	return false
 }
+
+// PeriodContaining returns the budget period around t as [start, end) in UTC.
+// Periods repeat from the anchor every week, month, quarter or year, or every
+// PeriodDays days for custom budgets.
+func (b *Budget) PeriodContaining(t time.Time) (time.Time, time.Time) {
+	anchor := b.anchor()
+	day := truncateDay(t)
+
+	switch b.Period {
+	case BudgetPeriodWeekly:
+		return stepDays(anchor, day, 7)
+	case BudgetPeriodCustom:
+		return stepDays(anchor, day, max(b.PeriodDays, 1))
+	case BudgetPeriodQuarterly:
+		return stepMonths(anchor, day, 3)
+	case BudgetPeriodYearly:
+		return stepMonths(anchor, day, 12)
+	}
+	return stepMonths(anchor, day, 1)
+}
+
+// anchor falls back to a calendar-aligned start: Monday for weekly budgets,
+// the first of January otherwise.
+func (b *Budget) anchor() time.Time {
+	if b.Anchor != nil {
+		return truncateDay(*b.Anchor)
+	}
+	if b.Period == BudgetPeriodWeekly {
+		return time.Date(2000, 1, 3, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func stepDays(anchor, day time.Time, n int) (time.Time, time.Time) {
+	diff := int(day.Sub(anchor).Hours() / 24)
+	k := floorDiv(diff, n)
+	start := anchor.AddDate(0, 0, k*n)
+	return start, start.AddDate(0, 0, n)
+}
+
+func stepMonths(anchor, day time.Time, n int) (time.Time, time.Time) {
+	months := (day.Year()-anchor.Year())*12 + int(day.Month()-anchor.Month())
+	k := floorDiv(months, n)
+	start := anchor.AddDate(0, k*n, 0)
+	if start.After(day) {
+		k--
+		start = anchor.AddDate(0, k*n, 0)
+	}
+	return start, anchor.AddDate(0, (k+1)*n, 0)
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
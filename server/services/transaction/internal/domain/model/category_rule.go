@@ -0,0 +1,62 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// CategoryRule assigns CategoryID to transactions matching every condition
+// that is set and, with SetAccountID, moves them to that account. A user's
+// rules are tried by ascending Priority and the first match wins.
+type CategoryRule struct {
+	ID                  uint             `gorm:"primaryKey" json:"id"`
+	UserID              uint             `gorm:"index;not null" json:"user_id"`
+	Name                string           `gorm:"type:varchar(100)" json:"name"`
+	Priority            int              `gorm:"not null;default:0" json:"priority"`
+	IsActive            bool             `gorm:"default:true" json:"is_active"`
+	DescriptionContains string           `gorm:"type:varchar(255)" json:"description_contains,omitempty"`
+	MinAmount           *decimal.Decimal `gorm:"type:decimal(19,4)" json:"min_amount,omitempty"`
+	MaxAmount           *decimal.Decimal `gorm:"type:decimal(19,4)" json:"max_amount,omitempty"`
+	AccountID           *uint            `gorm:"index" json:"account_id,omitempty"`
+	Type                TransactionType  `gorm:"type:varchar(20)" json:"type,omitempty"`
+	CategoryID          uint             `gorm:"index;not null" json:"category_id"`
+	Category            *Category        `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	SetAccountID        *uint            `gorm:"index" json:"set_account_id,omitempty"`
+	CreatedAt           time.Time        `json:"created_at"`
+	UpdatedAt           time.Time        `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt   `gorm:"index" json:"-"`
+}
+
+func (r *CategoryRule) HasConditions() bool {
+	return r.DescriptionContains != "" || r.MinAmount != nil || r.MaxAmount != nil ||
+		r.AccountID != nil || r.Type != ""
+}
+
+// Matches reports whether tx satisfies the rule. Transfers only match rules
+// that ask for them explicitly, so they don't end up in spending categories.
+func (r *CategoryRule) Matches(tx *Transaction) bool {
+	if r.Type != "" {
+		if tx.Type != r.Type {
+			return false
+		}
+	} else if tx.Type == TransactionTypeTransfer {
+		return false
+	}
+	if r.AccountID != nil && tx.AccountID != *r.AccountID {
+		return false
+	}
+	if r.MinAmount != nil && tx.Amount.LessThan(*r.MinAmount) {
+		return false
+	}
+	if r.MaxAmount != nil && tx.Amount.GreaterThan(*r.MaxAmount) {
+		return false
+	}
+	if r.DescriptionContains != "" &&
+		!strings.Contains(strings.ToLower(tx.Description), strings.ToLower(r.DescriptionContains)) {
+		return false
+	}
+	return true
+}
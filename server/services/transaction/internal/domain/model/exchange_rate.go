@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeRate is the daily rate of one unit of Base expressed in Quote.
+type ExchangeRate struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	Base      string          `gorm:"type:char(3);not null;uniqueIndex:rate_pair_date_unique" json:"base"`
+	Quote     string          `gorm:"type:char(3);not null;uniqueIndex:rate_pair_date_unique" json:"quote"`
+	Date      time.Time       `gorm:"type:date;not null;uniqueIndex:rate_pair_date_unique" json:"date"`
+	Rate      decimal.Decimal `gorm:"type:decimal(19,8);not null" json:"rate"`
+	Source    string          `gorm:"type:varchar(50)" json:"source,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+)
+
+type NotificationKind string
+
+const (
+	NotificationBudgetThreshold NotificationKind = "budget_threshold"
+)
+
+type NotificationStatus string
+
+const (
+	NotificationPending NotificationStatus = "pending"
+	NotificationSent    NotificationStatus = "sent"
+	NotificationFailed  NotificationStatus = "failed"
+)
+
+func IsValidNotificationStatus(s NotificationStatus) bool {
+	switch s {
+	case NotificationPending, NotificationSent, NotificationFailed:
+		return true
+	}
+	return false
+}
+
+// Notification is an outbox entry. It is written in the same database
+// transaction as the change that caused it and delivered later; DedupeKey
+// keeps the same event from being queued twice.
+type Notification struct {
+	ID            uint               `gorm:"primaryKey" json:"id"`
+	UserID        uint               `gorm:"index;not null" json:"user_id"`
+	Kind          NotificationKind   `gorm:"type:varchar(50);not null" json:"kind"`
+	Subject       string             `gorm:"type:varchar(255)" json:"subject"`
+	Body          string             `gorm:"type:text" json:"body"`
+	Payload       string             `gorm:"type:jsonb" json:"payload"`
+	DedupeKey     string             `gorm:"type:varchar(255);uniqueIndex;not null" json:"-"`
+	Status        NotificationStatus `gorm:"type:varchar(20);index;default:'pending'" json:"status"`
+	Attempts      int                `gorm:"default:0" json:"attempts"`
+	LastError     string             `gorm:"type:text" json:"last_error,omitempty"`
+	NextAttemptAt time.Time          `gorm:"index" json:"next_attempt_at"`
+	SentAt        *time.Time         `json:"sent_at,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
@@ -24,23 +24,28 @@ const (
 )
 
 type Transaction struct {
-	ID                   uint              `gorm:"primaryKey" json:"id"`
-	UserID               uint              `gorm:"index;not null" json:"user_id"`
-	AccountID            uint              `gorm:"index;not null" json:"account_id"`
-	Account              *Account          `gorm:"foreignKey:AccountID" json:"-"`
-	DestinationAccountID *uint             `gorm:"index" json:"destination_account_id,omitempty"`
-	DestinationAccount   *Account          `gorm:"foreignKey:DestinationAccountID" json:"-"`
-	Type                 TransactionType   `gorm:"type:varchar(20);not null;default:'expense'" json:"type"`
-	Status               TransactionStatus `gorm:"type:varchar(20);not null;default:'completed'" json:"status"`
-	Amount               decimal.Decimal   `gorm:"type:decimal(19,4);not null" json:"amount"`
-	Currency             string            `gorm:"type:char(3);default:'RUB'" json:"currency"`
-	CategoryID           *uint             `gorm:"index" json:"category_id,omitempty"`
-	Category             *Category         `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
-	Description          string            `gorm:"type:text" json:"description"`
-	TransactionDate      time.Time         `gorm:"not null" json:"transaction_date"`
-	CreatedAt            time.Time         `json:"created_at"`
-	UpdatedAt            time.Time         `json:"updated_at"`
-	DeletedAt            gorm.DeletedAt    `gorm:"index" json:"-"`
+	ID                   uint               `gorm:"primaryKey" json:"id"`
+	UserID               uint               `gorm:"index;not null" json:"user_id"`
+	AccountID            uint               `gorm:"index;not null" json:"account_id"`
+	Account              *Account           `gorm:"foreignKey:AccountID" json:"-"`
+	DestinationAccountID *uint              `gorm:"index" json:"destination_account_id,omitempty"`
+	DestinationAccount   *Account           `gorm:"foreignKey:DestinationAccountID" json:"-"`
+	DestinationAmount    *decimal.Decimal   `gorm:"type:decimal(19,4)" json:"destination_amount,omitempty"`
+	DestinationCurrency  *string            `gorm:"type:char(3)" json:"destination_currency,omitempty"`
+	ExchangeRate         *decimal.Decimal   `gorm:"type:decimal(19,8)" json:"exchange_rate,omitempty"`
+	Type                 TransactionType    `gorm:"type:varchar(20);not null;default:'expense'" json:"type"`
+	Status               TransactionStatus  `gorm:"type:varchar(20);not null;default:'completed'" json:"status"`
+	Amount               decimal.Decimal    `gorm:"type:decimal(19,4);not null" json:"amount"`
+	Currency             string             `gorm:"type:char(3);default:'RUB'" json:"currency"`
+	CategoryID           *uint              `gorm:"index" json:"category_id,omitempty"`
+	Category             *Category          `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	Splits               []TransactionSplit `gorm:"foreignKey:TransactionID" json:"splits,omitempty"`
+	Description          string             `gorm:"type:text" json:"description"`
+	TransactionDate      time.Time          `gorm:"not null" json:"transaction_date"`
+	ImportFingerprint    *string            `gorm:"type:char(64);index" json:"-"`
+	CreatedAt            time.Time          `json:"created_at"`
+	UpdatedAt            time.Time          `json:"updated_at"`
+	DeletedAt            gorm.DeletedAt     `gorm:"index" json:"-"`
 }
 
 func IsValidTransactionType(t TransactionType) bool {
@@ -58,3 +63,12 @@ func IsValidTransactionStatus(s TransactionStatus) bool {
 	}
 	return false
 }
+
+// CreditedAmount is what a transfer adds to the destination account. Transfers
+// stored before destination amounts existed credit the source amount.
+func (t *Transaction) CreditedAmount() decimal.Decimal {
+	if t.DestinationAmount != nil {
+		return *t.DestinationAmount
+	}
+	return t.Amount
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// TransactionSplit is one line of a transaction spread over several
+// categories. The lines of a transaction sum to its Amount.
+type TransactionSplit struct {
+	ID            uint            `gorm:"primaryKey" json:"id"`
+	TransactionID uint            `gorm:"index;not null" json:"transaction_id"`
+	CategoryID    *uint           `gorm:"index" json:"category_id,omitempty"`
+	Category      *Category       `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	Amount        decimal.Decimal `gorm:"type:decimal(19,4);not null" json:"amount"`
+	Note          string          `gorm:"type:text" json:"note"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 	"transaction/internal/data/repository"
 	"transaction/internal/domain/model"
 
@@ -17,11 +19,12 @@ var (
 )
 
 type AccountService struct {
-	repo *repository.AccountRepository
+	repo      *repository.AccountRepository
+	converter *CurrencyConverter
 }
 
-func NewAccountService(repo *repository.AccountRepository) *AccountService {
-	return &AccountService{repo: repo}
+func NewAccountService(repo *repository.AccountRepository, converter *CurrencyConverter) *AccountService {
+	return &AccountService{repo: repo, converter: converter}
 }
 
 func (s *AccountService) CreateAccount(account *model.Account) (*model.Account, error) {
@@ -40,6 +43,7 @@ func (s *AccountService) CreateAccount(account *model.Account) (*model.Account,
 	if account.Balance.IsZero() {
 		account.Balance = decimal.Zero
 	}
+	account.OpeningBalance = account.Balance
 
 	account.IsActive = true
 
@@ -120,6 +124,38 @@ func (s *AccountService) UpdateBalance(id uint, newBalance decimal.Decimal) erro
 	return s.repo.UpdateBalance(id, newBalance)
 }
 
-func (s *AccountService) GetTotalBalance(userID uint, currency string) (decimal.Decimal, error) {
-	return s.repo.GetTotalBalance(userID, currency)
+type TotalBalance struct {
+	Currency   string
+	Total      decimal.Decimal
+	ByCurrency map[string]decimal.Decimal
+}
+
+// GetTotalBalance sums the user's active accounts in currency, converting
+// each account currency at today's rate.
+func (s *AccountService) GetTotalBalance(userID uint, currency string) (*TotalBalance, error) {
+	currency = strings.ToUpper(currency)
+	if currency == "" {
+		currency = DefaultReportingCurrency
+	}
+
+	rows, err := s.repo.GetTotalsByCurrency(userID)
+	if err != nil {
+		return nil, fmt.Errorf("get total balance: %w", err)
+	}
+
+	result := &TotalBalance{
+		Currency:   currency,
+		Total:      decimal.Zero,
+		ByCurrency: make(map[string]decimal.Decimal, len(rows)),
+	}
+	now := time.Now()
+	for _, r := range rows {
+		converted, err := s.converter.Convert(r.Total, r.Currency, currency, now)
+		if err != nil {
+			return nil, err
+		}
+		result.ByCurrency[r.Currency] = r.Total
+		result.Total = result.Total.Add(converted)
+	}
+	return result, nil
 }
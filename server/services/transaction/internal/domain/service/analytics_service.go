@@ -2,8 +2,11 @@ package service
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 	"transaction/internal/data/repository"
+	"transaction/internal/domain/model"
 
 	"github.com/shopspring/decimal"
 )
@@ -26,22 +29,44 @@ type MonthlyTotal struct {
 }
 
 type TransactionSummary struct {
+	Currency     string
 	TotalIncome  decimal.Decimal
 	TotalExpense decimal.Decimal
 	ByCategory   []CategoryTotal
 	ByMonth      []MonthlyTotal
 }
 
+// CategoryNode is a category with its spending and its subcategories.
+// Total and Count cover the whole subtree; OwnTotal only the category itself.
+type CategoryNode struct {
+	Category model.Category
+	OwnTotal decimal.Decimal
+	Total    decimal.Decimal
+	Count    int64
+	Children []*CategoryNode
+}
+
 type AnalyticsService struct {
-	repo *repository.TransactionRepository
+	repo         *repository.TransactionRepository
+	categoryRepo *repository.CategoryRepository
+	converter    *CurrencyConverter
 }
 
-func NewAnalyticsService(repo *repository.TransactionRepository) *AnalyticsService {
-	return &AnalyticsService{repo: repo}
+func NewAnalyticsService(repo *repository.TransactionRepository, categoryRepo *repository.CategoryRepository, converter *CurrencyConverter) *AnalyticsService {
+	return &AnalyticsService{repo: repo, categoryRepo: categoryRepo, converter: converter}
 }
 
-func (s *AnalyticsService) GetSummary(userID uint, from, to time.Time) (*TransactionSummary, error) {
-	catRows, err := s.repo.GetSummaryByCategory(userID, from, to)
+// GetSummary totals income and expense in the reporting currency. Category
+// totals are converted at the rate for the end of the range, monthly totals at
+// the rate for the end of each month. With rollup, category totals are given
+// for top-level categories and include their subcategories.
+func (s *AnalyticsService) GetSummary(userID uint, from, to time.Time, currency string, rollup bool) (*TransactionSummary, error) {
+	currency = strings.ToUpper(currency)
+	if currency == "" {
+		currency = DefaultReportingCurrency
+	}
+
+	catRows, err := s.repo.GetSummaryByCategory(userID, from, to, rollup)
 	if err != nil {
 		return nil, fmt.Errorf("get summary by category: %w", err)
 	}
@@ -52,21 +77,43 @@ func (s *AnalyticsService) GetSummary(userID uint, from, to time.Time) (*Transac
 	}
 
 	summary := &TransactionSummary{
+		Currency:     currency,
 		TotalIncome:  decimal.Zero,
 		TotalExpense: decimal.Zero,
 	}
 
+	type categoryKey struct {
+		id  uint
+		typ string
+	}
+	catIndex := make(map[categoryKey]int)
+
 	for _, r := range catRows {
-		total := r.Total
-		summary.ByCategory = append(summary.ByCategory, CategoryTotal{
-			CategoryID:    r.CategoryID,
-			CategoryName:  r.CategoryName,
-			CategoryIcon:  r.CategoryIcon,
-			CategoryColor: r.CategoryColor,
-			Type:          r.Type,
-			Total:         total,
-			Count:         r.Count,
-		})
+		total, err := s.converter.Convert(r.Total, r.Currency, currency, to)
+		if err != nil {
+			return nil, err
+		}
+
+		key := categoryKey{typ: r.Type}
+		if r.CategoryID != nil {
+			key.id = *r.CategoryID
+		}
+		if i, ok := catIndex[key]; ok {
+			summary.ByCategory[i].Total = summary.ByCategory[i].Total.Add(total)
+			summary.ByCategory[i].Count += r.Count
+		} else {
+			catIndex[key] = len(summary.ByCategory)
+			summary.ByCategory = append(summary.ByCategory, CategoryTotal{
+				CategoryID:    r.CategoryID,
+				CategoryName:  r.CategoryName,
+				CategoryIcon:  r.CategoryIcon,
+				CategoryColor: r.CategoryColor,
+				Type:          r.Type,
+				Total:         total,
+				Count:         r.Count,
+			})
+		}
+
 		switch r.Type {
 		case "income":
 			summary.TotalIncome = summary.TotalIncome.Add(total)
@@ -75,14 +122,116 @@ func (s *AnalyticsService) GetSummary(userID uint, from, to time.Time) (*Transac
 		}
 	}
 
+	sort.SliceStable(summary.ByCategory, func(i, j int) bool {
+		return summary.ByCategory[i].Total.GreaterThan(summary.ByCategory[j].Total)
+	})
+
+	monthIndex := make(map[[2]int]int)
 	for _, r := range monthRows {
+		rateDate := time.Date(r.Year, time.Month(r.Month)+1, 1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond)
+		if rateDate.After(to) {
+			rateDate = to
+		}
+		income, err := s.converter.Convert(r.Income, r.Currency, currency, rateDate)
+		if err != nil {
+			return nil, err
+		}
+		expense, err := s.converter.Convert(r.Expense, r.Currency, currency, rateDate)
+		if err != nil {
+			return nil, err
+		}
+
+		key := [2]int{r.Year, r.Month}
+		if i, ok := monthIndex[key]; ok {
+			summary.ByMonth[i].Income = summary.ByMonth[i].Income.Add(income)
+			summary.ByMonth[i].Expense = summary.ByMonth[i].Expense.Add(expense)
+			continue
+		}
+		monthIndex[key] = len(summary.ByMonth)
 		summary.ByMonth = append(summary.ByMonth, MonthlyTotal{
 			Year:    r.Year,
 			Month:   r.Month,
-			Income:  r.Income,
-			Expense: r.Expense,
+			Income:  income,
+			Expense: expense,
 		})
 	}
 
 	return summary, nil
 }
+
+// GetCategoryTree returns the user's categories as a forest with totals for
+// the range in the reporting currency, rolled up from subcategories.
+func (s *AnalyticsService) GetCategoryTree(userID uint, from, to time.Time, currency string) ([]*CategoryNode, error) {
+	currency = strings.ToUpper(currency)
+	if currency == "" {
+		currency = DefaultReportingCurrency
+	}
+
+	categories, err := s.categoryRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("get categories: %w", err)
+	}
+	rows, err := s.repo.GetSummaryByCategory(userID, from, to, false)
+	if err != nil {
+		return nil, fmt.Errorf("get summary by category: %w", err)
+	}
+
+	nodes := make(map[uint]*CategoryNode, len(categories))
+	for _, c := range categories {
+		nodes[c.ID] = &CategoryNode{Category: c}
+	}
+	for _, r := range rows {
+		if r.CategoryID == nil {
+			continue
+		}
+		node, ok := nodes[*r.CategoryID]
+		if !ok {
+			continue
+		}
+		total, err := s.converter.Convert(r.Total, r.Currency, currency, to)
+		if err != nil {
+			return nil, err
+		}
+		node.OwnTotal = node.OwnTotal.Add(total)
+		node.Count += r.Count
+	}
+
+	// Categories keep the repository order; orphans whose parent is gone become roots
+	var roots []*CategoryNode
+	for _, c := range categories {
+		node := nodes[c.ID]
+		if parent, ok := parentNode(nodes, c.ParentID); ok && parent != node {
+			parent.Children = append(parent.Children, node)
+			continue
+		}
+		roots = append(roots, node)
+	}
+
+	visited := make(map[uint]bool, len(nodes))
+	for _, root := range roots {
+		rollUp(root, visited)
+	}
+	return roots, nil
+}
+
+func parentNode(nodes map[uint]*CategoryNode, parentID *uint) (*CategoryNode, bool) {
+	if parentID == nil {
+		return nil, false
+	}
+	node, ok := nodes[*parentID]
+	return node, ok
+}
+
+// rollUp fills Total and Count for a subtree and returns the node's total.
+func rollUp(node *CategoryNode, visited map[uint]bool) decimal.Decimal {
+	visited[node.Category.ID] = true
+	node.Total = node.OwnTotal
+	for _, child := range node.Children {
+		if visited[child.Category.ID] {
+			continue
+		}
+		node.Total = node.Total.Add(rollUp(child, visited))
+		node.Count += child.Count
+	}
+	return node.Total
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"transaction/internal/data/repository"
+	"transaction/internal/domain/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// ChangeListener is told about transactions created or changed, inside the
+// unit of work that changes them, so what it writes through repos commits or
+// rolls back with the change. An error rolls the change back.
+type ChangeListener interface {
+	TransactionsChanged(repos *repository.Repositories, txs []model.Transaction) error
+}
+
+// NotificationOutbox holds notifications until they are delivered.
+// *repository.NotificationRepository stores them in Postgres.
+type NotificationOutbox interface {
+	// Enqueue stores n unless one with the same dedupe key exists and
+	// reports whether n was stored.
+	Enqueue(n *model.Notification) (bool, error)
+	// ClaimDue leases up to limit pending notifications due at now until
+	// until and returns them.
+	ClaimDue(now, until time.Time, limit int) ([]model.Notification, error)
+	Update(n *model.Notification) error
+}
+
+// BudgetAlertService queues a notification whenever spending in a budget
+// period crosses one of the budget's alert thresholds. Each threshold fires
+// at most once per period.
+type BudgetAlertService struct {
+	budgets *BudgetService
+}
+
+func NewBudgetAlertService(budgets *BudgetService) *BudgetAlertService {
+	return &BudgetAlertService{budgets: budgets}
+}
+
+// TransactionsChanged checks the budget periods the expenses in txs fall in.
+// Spending is read and alerts are queued through repos, so the check sees the
+// change and its alerts are stored only if the change is.
+func (s *BudgetAlertService) TransactionsChanged(repos *repository.Repositories, txs []model.Transaction) error {
+	dates := map[uint][]time.Time{}
+	for _, tx := range txs {
+		if tx.Type != model.TransactionTypeExpense || tx.Status != model.TransactionStatusCompleted {
+			continue
+		}
+		dates[tx.UserID] = append(dates[tx.UserID], tx.TransactionDate)
+	}
+
+	budgets := s.budgets.withRepo(repos.Budgets)
+	for userID, refs := range dates {
+		if err := checkBudgets(budgets, repos.Notifications, userID, refs...); err != nil {
+			return fmt.Errorf("check budget alerts: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkBudgets evaluates the user's budgets in the periods containing refs
+// and queues an alert for every threshold reached.
+func checkBudgets(budgets *BudgetService, outbox NotificationOutbox, userID uint, refs ...time.Time) error {
+	list, err := budgets.repo.GetByUserID(userID)
+	if err != nil {
+		return fmt.Errorf("get budgets: %w", err)
+	}
+
+	for i := range list {
+		b := &list[i]
+		if len(b.AlertThresholds) == 0 {
+			continue
+		}
+
+		checked := map[time.Time]bool{}
+		for _, ref := range refs {
+			start, _ := b.PeriodContaining(ref)
+			if checked[start] {
+				continue
+			}
+			checked[start] = true
+
+			status, err := budgets.PeriodStatus(b, ref)
+			if err != nil {
+				return err
+			}
+			if err := queueBudgetAlerts(outbox, b, status); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// queueBudgetAlerts queues an alert for every threshold status has reached.
+// Thresholds already alerted in the period are skipped by their dedupe key.
+func queueBudgetAlerts(outbox NotificationOutbox, b *model.Budget, status *BudgetStatus) error {
+	for _, threshold := range b.AlertThresholds {
+		if !thresholdReached(status, threshold) {
+			continue
+		}
+		n, err := budgetAlert(b, status, threshold)
+		if err != nil {
+			return err
+		}
+		if _, err := outbox.Enqueue(n); err != nil {
+			return fmt.Errorf("enqueue budget alert: %w", err)
+		}
+	}
+	return nil
+}
+
+// thresholdReached treats any spending against nothing available, e.g. after
+// a negative rollover, as past every threshold.
+func thresholdReached(status *BudgetStatus, threshold int) bool {
+	if !status.Available.IsPositive() {
+		return status.SpentAmount.IsPositive()
+	}
+	return status.SpentPercent.GreaterThanOrEqual(decimal.NewFromInt(int64(threshold)))
+}
+
+type budgetAlertPayload struct {
+	BudgetID     uint      `json:"budget_id"`
+	CategoryID   uint      `json:"category_id"`
+	CategoryName string    `json:"category_name"`
+	Threshold    int       `json:"threshold"`
+	Available    string    `json:"available"`
+	Spent        string    `json:"spent"`
+	SpentPercent string    `json:"spent_percent"`
+	Currency     string    `json:"currency"`
+	PeriodStart  time.Time `json:"period_start"`
+	PeriodEnd    time.Time `json:"period_end"`
+}
+
+func budgetAlert(b *model.Budget, status *BudgetStatus, threshold int) (*model.Notification, error) {
+	payload, err := json.Marshal(budgetAlertPayload{
+		BudgetID:     b.ID,
+		CategoryID:   b.CategoryID,
+		CategoryName: status.CategoryName,
+		Threshold:    threshold,
+		Available:    status.Available.StringFixed(2),
+		Spent:        status.SpentAmount.StringFixed(2),
+		SpentPercent: status.SpentPercent.StringFixed(1),
+		Currency:     status.Currency,
+		PeriodStart:  status.PeriodStart,
+		PeriodEnd:    status.PeriodEnd,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encode budget alert: %w", err)
+	}
+
+	name := status.CategoryName
+	if name == "" {
+		name = fmt.Sprintf("category %d", b.CategoryID)
+	}
+	return &model.Notification{
+		UserID:  b.UserID,
+		Kind:    model.NotificationBudgetThreshold,
+		Subject: fmt.Sprintf("Budget %q reached %d%%", name, threshold),
+		Body: fmt.Sprintf("Spent %s of %s %s in the period %s to %s.",
+			status.SpentAmount.StringFixed(2), status.Available.StringFixed(2), status.Currency,
+			status.PeriodStart.Format("2006-01-02"), status.PeriodEnd.Format("2006-01-02")),
+		Payload:       string(payload),
+		DedupeKey:     fmt.Sprintf("budget:%d:%s:%d", b.ID, status.PeriodStart.Format("2006-01-02"), threshold),
+		Status:        model.NotificationPending,
+		NextAttemptAt: time.Now(),
+	}, nil
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+	"transaction/internal/domain/model"
+	"transaction/internal/infra/notify"
+
+	"github.com/shopspring/decimal"
+)
+
+// memoryOutbox is a NotificationOutbox with the dedupe semantics of the
+// Postgres one.
+type memoryOutbox struct {
+	byKey map[string]*model.Notification
+	order []string
+}
+
+func newMemoryOutbox() *memoryOutbox {
+	return &memoryOutbox{byKey: map[string]*model.Notification{}}
+}
+
+func (o *memoryOutbox) Enqueue(n *model.Notification) (bool, error) {
+	if _, ok := o.byKey[n.DedupeKey]; ok {
+		return false, nil
+	}
+	n.ID = uint(len(o.order) + 1)
+	stored := *n
+	o.byKey[n.DedupeKey] = &stored
+	o.order = append(o.order, n.DedupeKey)
+	return true, nil
+}
+
+func (o *memoryOutbox) ClaimDue(now, until time.Time, limit int) ([]model.Notification, error) {
+	var due []model.Notification
+	for _, key := range o.order {
+		n := o.byKey[key]
+		if n.Status == model.NotificationPending && !n.NextAttemptAt.After(now) && len(due) < limit {
+			n.NextAttemptAt = until
+			due = append(due, *n)
+		}
+	}
+	return due, nil
+}
+
+func (o *memoryOutbox) Update(n *model.Notification) error {
+	stored := *n
+	o.byKey[n.DedupeKey] = &stored
+	return nil
+}
+
+func (o *memoryOutbox) keys() []string {
+	keys := append([]string(nil), o.order...)
+	sort.Strings(keys)
+	return keys
+}
+
+func alertBudget() *model.Budget {
+	return &model.Budget{
+		ID:              7,
+		UserID:          1,
+		CategoryID:      3,
+		Amount:          decimal.NewFromInt(100),
+		Currency:        "EUR",
+		Period:          model.BudgetPeriodMonthly,
+		AlertThresholds: []int{50, 80, 100},
+	}
+}
+
+func spentStatus(b *model.Budget, periodStart time.Time, spent int64) *BudgetStatus {
+	st := &BudgetStatus{
+		BudgetID:    b.ID,
+		CategoryID:  b.CategoryID,
+		Available:   b.Amount,
+		SpentAmount: decimal.NewFromInt(spent),
+		Currency:    b.Currency,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodStart.AddDate(0, 1, 0).Add(-time.Nanosecond),
+	}
+	st.SpentPercent = st.SpentAmount.Div(st.Available).Mul(decimal.NewFromInt(100))
+	return st
+}
+
+func TestQueueBudgetAlertsOnThresholdCrossing(t *testing.T) {
+	b := alertBudget()
+	may := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	outbox := newMemoryOutbox()
+
+	steps := []struct {
+		name  string
+		start time.Time
+		spent int64
+		want  []string
+	}{
+		{"below every threshold", may, 40, nil},
+		{"crosses 50 and 80", may, 85, []string{
+			"budget:7:2026-05-01:50",
+			"budget:7:2026-05-01:80",
+		}},
+		{"same thresholds again are suppressed", may, 90, []string{
+			"budget:7:2026-05-01:50",
+			"budget:7:2026-05-01:80",
+		}},
+		{"crosses 100", may, 120, []string{
+			"budget:7:2026-05-01:100",
+			"budget:7:2026-05-01:50",
+			"budget:7:2026-05-01:80",
+		}},
+		{"next period alerts anew", may.AddDate(0, 1, 0), 60, []string{
+			"budget:7:2026-05-01:100",
+			"budget:7:2026-05-01:50",
+			"budget:7:2026-05-01:80",
+			"budget:7:2026-06-01:50",
+		}},
+	}
+	for _, step := range steps {
+		if err := queueBudgetAlerts(outbox, b, spentStatus(b, step.start, step.spent)); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := outbox.keys(); !slices.Equal(got, step.want) {
+			t.Fatalf("%s: queued %v, want %v", step.name, got, step.want)
+		}
+	}
+
+	n := outbox.byKey["budget:7:2026-05-01:80"]
+	if n.UserID != b.UserID || n.Kind != model.NotificationBudgetThreshold || n.Status != model.NotificationPending {
+		t.Errorf("unexpected notification %+v", n)
+	}
+}
+
+func TestQueueBudgetAlertsWithNothingAvailable(t *testing.T) {
+	b := alertBudget()
+	start := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	outbox := newMemoryOutbox()
+
+	// A negative rollover leaves nothing to spend, so any spending is past
+	// every threshold
+	status := spentStatus(b, start, 5)
+	status.Available = decimal.NewFromInt(-20)
+	status.SpentPercent = decimal.Zero
+	if err := queueBudgetAlerts(outbox, b, status); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(outbox.keys()); got != 3 {
+		t.Errorf("queued %d alerts, want 3", got)
+	}
+}
+
+func TestDeliverDueRetriesWithBackoff(t *testing.T) {
+	notifier := notify.NewMemoryNotifier()
+	notifier.Err = errors.New("webhook down")
+	s := &NotificationService{notifier: notifier}
+	ctx := context.Background()
+
+	t0 := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+	outbox := newMemoryOutbox()
+	if _, err := outbox.Enqueue(&model.Notification{
+		UserID:        1,
+		Kind:          model.NotificationBudgetThreshold,
+		DedupeKey:     "budget:7:2026-05-01:80",
+		Status:        model.NotificationPending,
+		NextAttemptAt: t0,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	stored := func() *model.Notification { return outbox.byKey["budget:7:2026-05-01:80"] }
+
+	res, err := s.deliverDue(ctx, outbox, t0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != (DispatchResult{Retry: 1}) {
+		t.Fatalf("first run: %+v", res)
+	}
+	if n := stored(); n.Attempts != 1 || !n.NextAttemptAt.Equal(t0.Add(time.Minute)) || n.LastError != "webhook down" {
+		t.Fatalf("after first failure: %+v", n)
+	}
+
+	// Not due again before the backoff has passed
+	if res, _ := s.deliverDue(ctx, outbox, t0.Add(30*time.Second), 10); res != (DispatchResult{}) {
+		t.Fatalf("run during backoff: %+v", res)
+	}
+
+	t1 := t0.Add(time.Minute)
+	if res, _ := s.deliverDue(ctx, outbox, t1, 10); res != (DispatchResult{Retry: 1}) {
+		t.Fatalf("second run: %+v", res)
+	}
+	if n := stored(); n.Attempts != 2 || !n.NextAttemptAt.Equal(t1.Add(2*time.Minute)) {
+		t.Fatalf("backoff did not double: %+v", n)
+	}
+
+	notifier.Err = nil
+	t2 := t1.Add(2 * time.Minute)
+	if res, _ := s.deliverDue(ctx, outbox, t2, 10); res != (DispatchResult{Sent: 1}) {
+		t.Fatalf("third run: %+v", res)
+	}
+	n := stored()
+	if n.Status != model.NotificationSent || n.SentAt == nil || n.LastError != "" {
+		t.Fatalf("not marked sent: %+v", n)
+	}
+	if sent := notifier.Sent(); len(sent) != 1 || sent[0].DedupeKey != n.DedupeKey {
+		t.Fatalf("notifier got %+v", sent)
+	}
+
+	if res, _ := s.deliverDue(ctx, outbox, t2.Add(time.Hour), 10); res != (DispatchResult{}) {
+		t.Fatalf("sent notification delivered again: %+v", res)
+	}
+}
+
+func TestDeliverDueGivesUp(t *testing.T) {
+	notifier := notify.NewMemoryNotifier()
+	notifier.Err = errors.New("webhook down")
+	s := &NotificationService{notifier: notifier}
+
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+	outbox := newMemoryOutbox()
+	outbox.Enqueue(&model.Notification{DedupeKey: "k", Status: model.NotificationPending, NextAttemptAt: now})
+
+	for i := 1; i <= maxNotificationAttempts; i++ {
+		res, err := s.deliverDue(context.Background(), outbox, now, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := DispatchResult{Retry: 1}
+		if i == maxNotificationAttempts {
+			want = DispatchResult{Failed: 1}
+		}
+		if res != want {
+			t.Fatalf("attempt %d: %+v, want %+v", i, res, want)
+		}
+		now = outbox.byKey["k"].NextAttemptAt
+	}
+	if n := outbox.byKey["k"]; n.Status != model.NotificationFailed || n.Attempts != maxNotificationAttempts {
+		t.Fatalf("not marked failed: %+v", n)
+	}
+}
+
+func TestDeliverDueLeasesClaimedBatch(t *testing.T) {
+	notifier := notify.NewMemoryNotifier()
+	s := &NotificationService{notifier: notifier}
+
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+	outbox := newMemoryOutbox()
+	outbox.Enqueue(&model.Notification{DedupeKey: "k", Status: model.NotificationPending, NextAttemptAt: now})
+
+	// A dispatcher that claims the batch and runs out of time delivers nothing
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if res, err := s.deliverDue(ctx, outbox, now, 10); err != nil || res != (DispatchResult{}) {
+		t.Fatalf("cancelled run: %+v, %v", res, err)
+	}
+	if n := outbox.byKey["k"]; n.Status != model.NotificationPending || n.Attempts != 0 ||
+		!n.NextAttemptAt.Equal(now.Add(notificationLease)) {
+		t.Fatalf("claimed notification: %+v", n)
+	}
+
+	// Others don't get it while the lease lasts, and do once it runs out
+	if res, _ := s.deliverDue(context.Background(), outbox, now.Add(time.Minute), 10); res != (DispatchResult{}) {
+		t.Fatalf("run during the lease: %+v", res)
+	}
+	if res, _ := s.deliverDue(context.Background(), outbox, now.Add(notificationLease), 10); res != (DispatchResult{Sent: 1}) {
+		t.Fatalf("run after the lease: %+v", res)
+	}
+	if sent := notifier.Sent(); len(sent) != 1 {
+		t.Fatalf("delivered %d times, want once", len(sent))
+	}
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 	"transaction/internal/data/repository"
 	"transaction/internal/domain/model"
@@ -14,8 +16,19 @@ var (
 	ErrBudgetNotFound      = errors.New("budget not found")
 	ErrBudgetAccessDenied  = errors.New("access denied to this budget")
 	ErrInvalidBudgetPeriod = errors.New("invalid budget period")
+	ErrInvalidBudgetAnchor = errors.New("budget anchor must be on day 1-28 for month-based periods")
+	ErrInvalidPeriodDays   = errors.New("custom budget period needs an anchor and period_days of at least 1")
+	ErrInvalidThreshold    = errors.New("alert thresholds must be percentages between 1 and 1000")
 )
 
+// maxBudgetPeriods bounds how far back history and rollover walk.
+const maxBudgetPeriods = 520
+
+// maxAlertThreshold allows alerts well past overspending, e.g. at 150%.
+const maxAlertThreshold = 1000
+
+// BudgetStatus is a budget's position in one period. Carried is the rollover
+// from earlier periods; Available is BudgetAmount plus Carried.
 type BudgetStatus struct {
 	BudgetID      uint
 	CategoryID    uint
@@ -23,28 +36,56 @@ type BudgetStatus struct {
 	CategoryIcon  string
 	CategoryColor string
 	BudgetAmount  decimal.Decimal
+	Carried       decimal.Decimal
+	Available     decimal.Decimal
 	SpentAmount   decimal.Decimal
 	Remaining     decimal.Decimal
 	SpentPercent  decimal.Decimal
 	Period        string
+	PeriodStart   time.Time
+	PeriodEnd     time.Time
 	Currency      string
 }
 
+// BudgetChanges lists the fields to update; nil and zero values keep the
+// current value.
+type BudgetChanges struct {
+	Amount     decimal.Decimal
+	Period     model.BudgetPeriod
+	Anchor     *time.Time
+	PeriodDays *int
+	Rollover   *bool
+	// AlertThresholds replaces the thresholds when not nil; empty clears them
+	AlertThresholds *[]int
+}
+
 type BudgetService struct {
-	repo *repository.BudgetRepository
+	repo      *repository.BudgetRepository
+	converter *CurrencyConverter
+}
+
+func NewBudgetService(repo *repository.BudgetRepository, converter *CurrencyConverter) *BudgetService {
+	return &BudgetService{repo: repo, converter: converter}
 }
 
-func NewBudgetService(repo *repository.BudgetRepository) *BudgetService {
-	return &BudgetService{repo: repo}
+// withRepo returns a copy of the service reading through repo, e.g. one bound
+// to a unit of work.
+func (s *BudgetService) withRepo(repo *repository.BudgetRepository) *BudgetService {
+	return &BudgetService{repo: repo, converter: s.converter}
 }
 
 func (s *BudgetService) CreateBudget(budget *model.Budget) (*model.Budget, error) {
 	if budget.Amount.LessThanOrEqual(decimal.Zero) {
 		return nil, ErrInvalidAmount
 	}
-	if !model.IsValidBudgetPeriod(budget.Period) {
-		return nil, ErrInvalidBudgetPeriod
+	if err := validateBudgetPeriod(budget); err != nil {
+		return nil, err
 	}
+	thresholds, err := normalizeThresholds(budget.AlertThresholds)
+	if err != nil {
+		return nil, err
+	}
+	budget.AlertThresholds = thresholds
 	if budget.Currency == "" {
 		budget.Currency = "RUB"
 	}
@@ -58,7 +99,7 @@ func (s *BudgetService) GetBudgets(userID uint) ([]model.Budget, error) {
 	return s.repo.GetByUserID(userID)
 }
 
-func (s *BudgetService) UpdateBudget(id, userID uint, amount decimal.Decimal, period model.BudgetPeriod) (*model.Budget, error) {
+func (s *BudgetService) GetBudget(id, userID uint) (*model.Budget, error) {
 	budget, err := s.repo.GetByID(id)
 	if err != nil {
 		return nil, ErrBudgetNotFound
@@ -66,11 +107,41 @@ func (s *BudgetService) UpdateBudget(id, userID uint, amount decimal.Decimal, pe
 	if budget.UserID != userID {
 		return nil, ErrBudgetAccessDenied
 	}
-	if !amount.IsZero() {
-		budget.Amount = amount
+	return budget, nil
+}
+
+func (s *BudgetService) UpdateBudget(id, userID uint, changes BudgetChanges) (*model.Budget, error) {
+	budget, err := s.GetBudget(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !changes.Amount.IsZero() {
+		if changes.Amount.IsNegative() {
+			return nil, ErrInvalidAmount
+		}
+		budget.Amount = changes.Amount
+	}
+	if changes.Period != "" {
+		budget.Period = changes.Period
+	}
+	if changes.Anchor != nil {
+		budget.Anchor = changes.Anchor
+	}
+	if changes.PeriodDays != nil {
+		budget.PeriodDays = *changes.PeriodDays
 	}
-	if period != "" && model.IsValidBudgetPeriod(period) {
-		budget.Period = period
+	if changes.Rollover != nil {
+		budget.Rollover = *changes.Rollover
+	}
+	if changes.AlertThresholds != nil {
+		thresholds, err := normalizeThresholds(*changes.AlertThresholds)
+		if err != nil {
+			return nil, err
+		}
+		budget.AlertThresholds = thresholds
+	}
+	if err := validateBudgetPeriod(budget); err != nil {
+		return nil, err
 	}
 	if err := s.repo.Update(budget); err != nil {
 		return nil, fmt.Errorf("update budget: %w", err)
@@ -79,48 +150,239 @@ func (s *BudgetService) UpdateBudget(id, userID uint, amount decimal.Decimal, pe
 }
 
 func (s *BudgetService) DeleteBudget(id, userID uint) error {
-	budget, err := s.repo.GetByID(id)
+	if _, err := s.GetBudget(id, userID); err != nil {
+		return err
+	}
+	return s.repo.Delete(id)
+}
+
+func validateBudgetPeriod(b *model.Budget) error {
+	if !model.IsValidBudgetPeriod(b.Period) {
+		return ErrInvalidBudgetPeriod
+	}
+	switch b.Period {
+	case model.BudgetPeriodCustom:
+		if b.Anchor == nil || b.PeriodDays < 1 {
+			return ErrInvalidPeriodDays
+		}
+	case model.BudgetPeriodMonthly, model.BudgetPeriodQuarterly, model.BudgetPeriodYearly:
+		if b.Anchor != nil && b.Anchor.Day() > model.MaxBudgetAnchorDay {
+			return ErrInvalidBudgetAnchor
+		}
+		b.PeriodDays = 0
+	default:
+		b.PeriodDays = 0
+	}
+	return nil
+}
+
+// normalizeThresholds sorts thresholds and drops repeats.
+func normalizeThresholds(thresholds []int) ([]int, error) {
+	for _, t := range thresholds {
+		if t < 1 || t > maxAlertThreshold {
+			return nil, ErrInvalidThreshold
+		}
+	}
+	sorted := slices.Clone(thresholds)
+	slices.Sort(sorted)
+	return slices.Compact(sorted), nil
+}
+
+// GetBudgetStatus reports each budget in the period that contains ref.
+// Spending in other currencies is converted at the period-end rate. Amounts are
+// reported in currency when given, otherwise in each budget's own currency.
+// The spending of all budgets, rollover periods included, is read with one
+// query, and each rate is looked up once.
+func (s *BudgetService) GetBudgetStatus(userID uint, currency string, ref time.Time) ([]BudgetStatus, error) {
+	budgets, err := s.repo.GetByUserID(userID)
 	if err != nil {
-		return ErrBudgetNotFound
+		return nil, fmt.Errorf("get budgets: %w", err)
 	}
-	if budget.UserID != userID {
-		return ErrBudgetAccessDenied
+	if len(budgets) == 0 {
+		return []BudgetStatus{}, nil
 	}
-	return s.repo.Delete(id)
+
+	walks := make([][]budgetPeriod, len(budgets))
+	categoryIDs := make([]uint, len(budgets))
+	var from, to time.Time
+	for i := range budgets {
+		walks[i], _ = budgetPeriods(&budgets[i], ref, 1)
+		categoryIDs[i] = budgets[i].CategoryID
+		first, last := walks[i][0].start, walks[i][len(walks[i])-1].end
+		if i == 0 || first.Before(from) {
+			from = first
+		}
+		if i == 0 || last.After(to) {
+			to = last
+		}
+	}
+
+	rows, err := s.repo.GetDailySpending(userID, categoryIDs, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("get budget spending: %w", err)
+	}
+	byCategory := map[uint][]repository.DailySpendingRow{}
+	for _, r := range rows {
+		byCategory[r.CategoryID] = append(byCategory[r.CategoryID], r)
+	}
+
+	converter := s.converter.withRateCache()
+	statuses := make([]BudgetStatus, 0, len(budgets))
+	for i := range budgets {
+		periods := walks[i]
+		addSpending(periods, byCategory[budgets[i].CategoryID])
+		st, err := budgetStatuses(converter, &budgets[i], periods, len(periods)-1, currency)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, st[len(st)-1])
+	}
+	return statuses, nil
 }
 
-func (s *BudgetService) GetBudgetStatus(userID uint) ([]BudgetStatus, error) {
-	now := time.Now()
-	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
-	to := from.AddDate(0, 1, 0).Add(-time.Nanosecond)
+// GetBudgetHistory returns the last count periods of a budget up to the one
+// containing ref, oldest first.
+func (s *BudgetService) GetBudgetHistory(id, userID uint, currency string, ref time.Time, count int) ([]BudgetStatus, error) {
+	budget, err := s.GetBudget(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.periodStatuses(budget, ref, min(max(count, 1), maxBudgetPeriods), currency)
+}
+
+// PeriodStatus reports b in its own currency for the period containing ref.
+func (s *BudgetService) PeriodStatus(b *model.Budget, ref time.Time) (*BudgetStatus, error) {
+	periods, err := s.periodStatuses(b, ref, 1, "")
+	if err != nil {
+		return nil, err
+	}
+	return &periods[len(periods)-1], nil
+}
 
-	rows, err := s.repo.GetBudgetStatus(userID, from, to)
+type budgetPeriod struct {
+	start, end time.Time
+	spent      map[string]decimal.Decimal
+}
+
+// periodStatuses computes the last count periods of b ending with the one
+// containing ref.
+func (s *BudgetService) periodStatuses(b *model.Budget, ref time.Time, count int, currency string) ([]BudgetStatus, error) {
+	periods, historyFrom := budgetPeriods(b, ref, count)
+	rows, err := s.repo.GetDailySpending(b.UserID, []uint{b.CategoryID}, periods[0].start, periods[len(periods)-1].end)
 	if err != nil {
-		return nil, fmt.Errorf("get budget status: %w", err)
-	}
-
-	statuses := make([]BudgetStatus, len(rows))
-	for i, r := range rows {
-		budgetAmt := r.BudgetAmount
-		spentAmt := r.SpentAmount
-		remaining := budgetAmt.Sub(spentAmt)
-		var pct decimal.Decimal
-		if !budgetAmt.IsZero() {
-			pct = spentAmt.Div(budgetAmt).Mul(decimal.NewFromInt(100))
-		}
-		statuses[i] = BudgetStatus{
-			BudgetID:      r.BudgetID,
-			CategoryID:    r.CategoryID,
-			CategoryName:  r.CategoryName,
-			CategoryIcon:  r.CategoryIcon,
-			CategoryColor: r.CategoryColor,
-			BudgetAmount:  budgetAmt,
-			SpentAmount:   spentAmt,
-			Remaining:     remaining,
-			SpentPercent:  pct,
-			Period:        r.Period,
-			Currency:      r.Currency,
+		return nil, fmt.Errorf("get budget spending: %w", err)
+	}
+	addSpending(periods, rows)
+	return budgetStatuses(s.converter.withRateCache(), b, periods, historyFrom, currency)
+}
+
+// budgetPeriods lists the periods of b up to the one containing ref, oldest
+// first, and the index of the first of the last count. With rollover the
+// walk starts at the period the budget was created in, so carried amounts
+// include every earlier period.
+func budgetPeriods(b *model.Budget, ref time.Time, count int) ([]budgetPeriod, int) {
+	refStart, refEnd := b.PeriodContaining(ref)
+	createdStart, _ := b.PeriodContaining(b.CreatedAt)
+
+	// Walk back from the requested period, newest first
+	periods := []budgetPeriod{{start: refStart, end: refEnd, spent: map[string]decimal.Decimal{}}}
+	for len(periods) < maxBudgetPeriods {
+		first := periods[len(periods)-1].start
+		if len(periods) >= count && !(b.Rollover && first.After(createdStart)) {
+			break
+		}
+		start, end := b.PeriodContaining(first.AddDate(0, 0, -1))
+		periods = append(periods, budgetPeriod{start: start, end: end, spent: map[string]decimal.Decimal{}})
+	}
+	slices.Reverse(periods)
+	return periods, max(len(periods)-count, 0)
+}
+
+// addSpending adds day-ordered spending rows to the periods they fall in.
+func addSpending(periods []budgetPeriod, rows []repository.DailySpendingRow) {
+	p := 0
+	for _, r := range rows {
+		for p < len(periods)-1 && !r.Day.Before(periods[p].end) {
+			p++
+		}
+		if r.Day.Before(periods[p].start) || !r.Day.Before(periods[p].end) {
+			continue
+		}
+		periods[p].spent[r.Currency] = periods[p].spent[r.Currency].Add(r.Amount)
+	}
+}
+
+// budgetStatuses reports the periods of b from historyFrom on, carrying
+// rollover through all of them.
+func budgetStatuses(converter *CurrencyConverter, b *model.Budget, periods []budgetPeriod, historyFrom int, currency string) ([]BudgetStatus, error) {
+	createdStart, _ := b.PeriodContaining(b.CreatedAt)
+	reportCurrency := b.Currency
+	if currency != "" {
+		reportCurrency = strings.ToUpper(currency)
+	}
+
+	now := time.Now()
+	carried := decimal.Zero
+	statuses := make([]BudgetStatus, 0, len(periods)-historyFrom)
+	for i, period := range periods {
+		rateDate := period.end.Add(-time.Nanosecond)
+		if now.Before(rateDate) {
+			rateDate = now
+		}
+
+		// Carry is tracked in the budget's own currency
+		spent := decimal.Zero
+		for cur, amount := range period.spent {
+			converted, err := converter.Convert(amount, cur, b.Currency, rateDate)
+			if err != nil {
+				return nil, err
+			}
+			spent = spent.Add(converted)
+		}
+		// Periods before the budget existed carry nothing forward
+		inCarry := carried
+		if b.Rollover && !period.start.Before(createdStart) {
+			carried = b.Amount.Add(inCarry).Sub(spent)
+		}
+
+		if i < historyFrom {
+			continue
+		}
+
+		st := BudgetStatus{
+			BudgetID:    b.ID,
+			CategoryID:  b.CategoryID,
+			Period:      string(b.Period),
+			PeriodStart: period.start,
+			PeriodEnd:   period.end.Add(-time.Nanosecond),
+			Currency:    reportCurrency,
+		}
+		if b.Category != nil {
+			st.CategoryName = b.Category.Name
+			st.CategoryIcon = b.Category.Icon
+			st.CategoryColor = b.Category.Color
+		}
+		for _, v := range []struct {
+			src *decimal.Decimal
+			dst *decimal.Decimal
+		}{
+			{&b.Amount, &st.BudgetAmount},
+			{&inCarry, &st.Carried},
+			{&spent, &st.SpentAmount},
+		} {
+			converted, err := converter.Convert(*v.src, b.Currency, reportCurrency, rateDate)
+			if err != nil {
+				return nil, err
+			}
+			*v.dst = converted
+		}
+
+		st.Available = st.BudgetAmount.Add(st.Carried)
+		st.Remaining = st.Available.Sub(st.SpentAmount)
+		if st.Available.IsPositive() {
+			st.SpentPercent = st.SpentAmount.Div(st.Available).Mul(decimal.NewFromInt(100))
 		}
+		statuses = append(statuses, st)
 	}
 	return statuses, nil
 }
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"transaction/internal/data/repository"
+	"transaction/internal/domain/model"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRuleNotFound     = errors.New("category rule not found")
+	ErrRuleAccessDenied = errors.New("access denied to this category rule")
+	ErrRuleNoConditions = errors.New("category rule needs at least one condition")
+)
+
+// Categorizer fills in the category of a transaction created without one.
+type Categorizer interface {
+	Categorize(tx *model.Transaction) error
+}
+
+// Recategorization is a category change proposed or made by a rule. The
+// account IDs differ when the rule also moves the transaction.
+type Recategorization struct {
+	TransactionID   uint
+	Description     string
+	Amount          decimal.Decimal
+	TransactionDate time.Time
+	OldCategoryID   *uint
+	NewCategoryID   uint
+	OldAccountID    uint
+	NewAccountID    uint
+	RuleID          uint
+}
+
+type RecategorizeOptions struct {
+	// RuleID limits the run to one rule; otherwise all active rules apply
+	RuleID *uint
+	// Overwrite also re-categorises transactions that already have a category
+	Overwrite bool
+	DryRun    bool
+}
+
+type CategoryRuleService struct {
+	repo         *repository.CategoryRuleRepository
+	categoryRepo *repository.CategoryRepository
+	accountRepo  *repository.AccountRepository
+	uow          *repository.UnitOfWork
+	listener     ChangeListener
+}
+
+func NewCategoryRuleService(
+	repo *repository.CategoryRuleRepository,
+	categoryRepo *repository.CategoryRepository,
+	accountRepo *repository.AccountRepository,
+	uow *repository.UnitOfWork,
+) *CategoryRuleService {
+	return &CategoryRuleService{
+		repo:         repo,
+		categoryRepo: categoryRepo,
+		accountRepo:  accountRepo,
+		uow:          uow,
+	}
+}
+
+// UseChangeListener reports transactions changed by Recategorize to l inside
+// the unit of work that changes them.
+func (s *CategoryRuleService) UseChangeListener(l ChangeListener) {
+	s.listener = l
+}
+
+func (s *CategoryRuleService) Create(rule *model.CategoryRule) (*model.CategoryRule, error) {
+	if err := s.validate(rule); err != nil {
+		return nil, err
+	}
+	rule.IsActive = true
+
+	created, err := s.repo.Create(rule)
+	if err != nil {
+		return nil, fmt.Errorf("create category rule: %w", err)
+	}
+	return created, nil
+}
+
+func (s *CategoryRuleService) GetByUser(userID uint) ([]model.CategoryRule, error) {
+	rules, err := s.repo.GetByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("get category rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (s *CategoryRuleService) GetByID(id, userID uint) (*model.CategoryRule, error) {
+	rule, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, ErrRuleNotFound
+	}
+	if rule.UserID != userID {
+		return nil, ErrRuleAccessDenied
+	}
+	return rule, nil
+}
+
+func (s *CategoryRuleService) Update(rule *model.CategoryRule) (*model.CategoryRule, error) {
+	if err := s.validate(rule); err != nil {
+		return nil, err
+	}
+	updated, err := s.repo.Update(rule)
+	if err != nil {
+		return nil, fmt.Errorf("update category rule: %w", err)
+	}
+	return updated, nil
+}
+
+func (s *CategoryRuleService) Delete(id, userID uint) error {
+	if _, err := s.GetByID(id, userID); err != nil {
+		return err
+	}
+	return s.repo.Delete(id)
+}
+
+func (s *CategoryRuleService) validate(rule *model.CategoryRule) error {
+	if !rule.HasConditions() {
+		return ErrRuleNoConditions
+	}
+	rule.DescriptionContains = strings.TrimSpace(rule.DescriptionContains)
+	if rule.Type != "" && !model.IsValidTransactionType(rule.Type) {
+		return ErrInvalidTransactionType
+	}
+	if (rule.MinAmount != nil && rule.MinAmount.IsNegative()) ||
+		(rule.MaxAmount != nil && rule.MaxAmount.IsNegative()) {
+		return ErrInvalidAmount
+	}
+	if rule.MinAmount != nil && rule.MaxAmount != nil && rule.MinAmount.GreaterThan(*rule.MaxAmount) {
+		return ErrInvalidAmountRange
+	}
+
+	category, err := s.categoryRepo.GetByID(rule.CategoryID)
+	if err != nil {
+		return ErrCategoryNotFound
+	}
+	if category.UserID != rule.UserID {
+		return ErrCategoryAccessDenied
+	}
+
+	for _, id := range []*uint{rule.AccountID, rule.SetAccountID} {
+		if id == nil {
+			continue
+		}
+		account, err := s.accountRepo.GetByID(*id)
+		if err != nil {
+			return ErrAccountNotFound
+		}
+		if account.UserID != rule.UserID {
+			return ErrAccountAccessDenied
+		}
+	}
+	return nil
+}
+
+// targetAccounts loads the accounts the rules move transactions to. Deleted
+// and inactive accounts are left out, so rules pointing at them still set
+// the category but move nothing.
+func targetAccounts(repo *repository.AccountRepository, rules []model.CategoryRule) (map[uint]*model.Account, error) {
+	accounts := map[uint]*model.Account{}
+	for _, r := range rules {
+		if r.SetAccountID == nil {
+			continue
+		}
+		if _, seen := accounts[*r.SetAccountID]; seen {
+			continue
+		}
+		account, err := repo.GetByID(*r.SetAccountID)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			account = nil
+		case err != nil:
+			return nil, fmt.Errorf("get rule account: %w", err)
+		case !account.IsActive:
+			account = nil
+		}
+		accounts[*r.SetAccountID] = account
+	}
+	return accounts, nil
+}
+
+// Categorize sets the category, and the account if the rule sets one, of an
+// uncategorised transaction from the first matching active rule of its owner.
+func (s *CategoryRuleService) Categorize(tx *model.Transaction) error {
+	if tx.CategoryID != nil || len(tx.Splits) > 0 {
+		return nil
+	}
+	rules, err := s.repo.GetActiveByUserID(tx.UserID)
+	if err != nil {
+		return fmt.Errorf("get category rules: %w", err)
+	}
+	accounts, err := targetAccounts(s.accountRepo, rules)
+	if err != nil {
+		return err
+	}
+	applyRules(rules, tx, accounts)
+	return nil
+}
+
+// CategorizeAll is Categorize for a batch of one user's transactions. Only
+// categories are set: statement lines stay on the account they are imported
+// to, or a re-import would no longer recognise them.
+func (s *CategoryRuleService) CategorizeAll(userID uint, txs []model.Transaction) error {
+	rules, err := s.repo.GetActiveByUserID(userID)
+	if err != nil {
+		return fmt.Errorf("get category rules: %w", err)
+	}
+	for i := range txs {
+		if txs[i].CategoryID == nil && len(txs[i].Splits) == 0 {
+			applyRules(rules, &txs[i], nil)
+		}
+	}
+	return nil
+}
+
+// Recategorize runs rules over the user's existing transactions. With
+// DryRun it only reports the changes it would make. Moving a transaction to
+// another account moves its effect on the balances with it. The matching
+// transactions are locked while the changes are worked out and made, so
+// concurrent edits are neither overwritten nor lost.
+func (s *CategoryRuleService) Recategorize(userID uint, opts RecategorizeOptions) ([]Recategorization, error) {
+	var rules []model.CategoryRule
+	if opts.RuleID != nil {
+		rule, err := s.GetByID(*opts.RuleID, userID)
+		if err != nil {
+			return nil, err
+		}
+		rules = []model.CategoryRule{*rule}
+	} else {
+		var err error
+		rules, err = s.repo.GetActiveByUserID(userID)
+		if err != nil {
+			return nil, fmt.Errorf("get category rules: %w", err)
+		}
+	}
+
+	criteria := []repository.TransactionCriterion{
+		repository.ByUser(userID),
+		repository.OrderedBy(repository.SortDateDesc),
+	}
+	if !opts.Overwrite {
+		criteria = append(criteria, repository.Uncategorized())
+	}
+	if !opts.DryRun {
+		criteria = append(criteria, repository.ForUpdate())
+	}
+
+	var changes []Recategorization
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		txs, err := repos.Transactions.Search(criteria...)
+		if err != nil {
+			return fmt.Errorf("get transactions: %w", err)
+		}
+		accounts, err := targetAccounts(repos.Accounts, rules)
+		if err != nil {
+			return err
+		}
+
+		var changed []model.Transaction
+		byCategory := make(map[uint][]uint)
+		byAccount := make(map[uint][]uint)
+		for i := range txs {
+			tx := &txs[i]
+			if len(tx.Splits) > 0 {
+				continue
+			}
+			oldCategory, oldAccount := tx.CategoryID, tx.AccountID
+			rule := applyRules(rules, tx, accounts)
+			if rule == nil || (oldCategory != nil && *oldCategory == rule.CategoryID && oldAccount == tx.AccountID) {
+				continue
+			}
+			changes = append(changes, Recategorization{
+				TransactionID:   tx.ID,
+				Description:     tx.Description,
+				Amount:          tx.Amount,
+				TransactionDate: tx.TransactionDate,
+				OldCategoryID:   oldCategory,
+				NewCategoryID:   rule.CategoryID,
+				OldAccountID:    oldAccount,
+				NewAccountID:    tx.AccountID,
+				RuleID:          rule.ID,
+			})
+			changed = append(changed, *tx)
+			byCategory[rule.CategoryID] = append(byCategory[rule.CategoryID], tx.ID)
+			if tx.AccountID != oldAccount {
+				byAccount[tx.AccountID] = append(byAccount[tx.AccountID], tx.ID)
+			}
+		}
+		if opts.DryRun || len(changed) == 0 {
+			return nil
+		}
+
+		ts := boundTransactionService(repos, s.listener)
+		for accountID, ids := range byAccount {
+			for _, id := range ids {
+				if err := ts.moveTransaction(id, userID, accountID); err != nil {
+					return err
+				}
+			}
+			if err := repos.Transactions.SetAccount(ids, accountID); err != nil {
+				return fmt.Errorf("set account: %w", err)
+			}
+		}
+		for categoryID, ids := range byCategory {
+			if err := repos.Transactions.SetCategory(ids, categoryID); err != nil {
+				return fmt.Errorf("set category: %w", err)
+			}
+		}
+		return ts.notifyChanged(changed...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// applyRules applies the first matching rule to tx and returns it. The rule
+// always sets the category; it moves tx only to an account found in
+// accounts, and only if that account is in the transaction's currency.
+// Transfers keep their accounts.
+func applyRules(rules []model.CategoryRule, tx *model.Transaction, accounts map[uint]*model.Account) *model.CategoryRule {
+	for i := range rules {
+		if !rules[i].Matches(tx) {
+			continue
+		}
+		categoryID := rules[i].CategoryID
+		tx.CategoryID = &categoryID
+		tx.Category = nil
+
+		if id := rules[i].SetAccountID; id != nil && tx.Type != model.TransactionTypeTransfer {
+			if a := accounts[*id]; a != nil && a.UserID == tx.UserID && strings.EqualFold(a.Currency, tx.Currency) {
+				tx.AccountID = a.ID
+				tx.Account = nil
+			}
+		}
+		return &rules[i]
+	}
+	return nil
+}
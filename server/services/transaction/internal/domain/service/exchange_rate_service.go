@@ -0,0 +1,178 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"transaction/internal/data/repository"
+	"transaction/internal/domain/model"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRateNotFound = errors.New("exchange rate not found")
+	ErrInvalidRate  = errors.New("rate must be greater than zero")
+	ErrInvalidDate  = errors.New("date is required")
+)
+
+// DefaultReportingCurrency is used when a report doesn't ask for a currency.
+const DefaultReportingCurrency = "RUB"
+
+// RateProvider returns how many units of quote one unit of base buys on a given day.
+type RateProvider interface {
+	Rate(base, quote string, on time.Time) (decimal.Decimal, error)
+}
+
+// StoredRateProvider serves rates loaded into the exchange_rates table, using
+// the latest rate dated on or before the requested day. Pairs that aren't
+// stored directly are derived from the inverse pair or crossed through pivot.
+type StoredRateProvider struct {
+	repo  *repository.ExchangeRateRepository
+	pivot string
+}
+
+func NewStoredRateProvider(repo *repository.ExchangeRateRepository, pivot string) *StoredRateProvider {
+	return &StoredRateProvider{repo: repo, pivot: strings.ToUpper(pivot)}
+}
+
+func (p *StoredRateProvider) Rate(base, quote string, on time.Time) (decimal.Decimal, error) {
+	if base == quote {
+		return decimal.NewFromInt(1), nil
+	}
+
+	rate, err := p.pairRate(base, quote, on)
+	if err == nil || !errors.Is(err, ErrRateNotFound) {
+		return rate, err
+	}
+
+	if p.pivot == "" || base == p.pivot || quote == p.pivot {
+		return decimal.Zero, ErrRateNotFound
+	}
+	toPivot, err := p.pairRate(base, p.pivot, on)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	fromPivot, err := p.pairRate(p.pivot, quote, on)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return toPivot.Mul(fromPivot), nil
+}
+
+func (p *StoredRateProvider) pairRate(base, quote string, on time.Time) (decimal.Decimal, error) {
+	direct, err := p.repo.GetLatest(base, quote, on)
+	if err == nil {
+		return direct.Rate, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return decimal.Zero, fmt.Errorf("get rate %s/%s: %w", base, quote, err)
+	}
+
+	inverse, err := p.repo.GetLatest(quote, base, on)
+	if err == nil {
+		return decimal.NewFromInt(1).Div(inverse.Rate), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return decimal.Zero, fmt.Errorf("get rate %s/%s: %w", quote, base, err)
+	}
+	return decimal.Zero, ErrRateNotFound
+}
+
+// CurrencyConverter converts amounts between currencies with rates from a RateProvider.
+type CurrencyConverter struct {
+	provider RateProvider
+}
+
+func NewCurrencyConverter(provider RateProvider) *CurrencyConverter {
+	return &CurrencyConverter{provider: provider}
+}
+
+// Convert returns amount in currency to, rounded to the scale of stored amounts.
+func (c *CurrencyConverter) Convert(amount decimal.Decimal, from, to string, on time.Time) (decimal.Decimal, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to || amount.IsZero() {
+		return amount, nil
+	}
+	rate, err := c.provider.Rate(from, to, on)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("convert %s to %s: %w", from, to, err)
+	}
+	return amount.Mul(rate).Round(4), nil
+}
+
+// withRateCache returns a converter that looks each rate up only once. It is
+// meant for one report, as it never sees rates loaded later.
+func (c *CurrencyConverter) withRateCache() *CurrencyConverter {
+	return &CurrencyConverter{provider: &cachedRates{provider: c.provider, rates: map[rateKey]decimal.Decimal{}}}
+}
+
+type rateKey struct {
+	base, quote string
+	day         time.Time
+}
+
+// cachedRates remembers the rates of a provider per pair and UTC day. It is
+// not safe for concurrent use.
+type cachedRates struct {
+	provider RateProvider
+	rates    map[rateKey]decimal.Decimal
+}
+
+func (c *cachedRates) Rate(base, quote string, on time.Time) (decimal.Decimal, error) {
+	on = on.UTC()
+	key := rateKey{base: base, quote: quote, day: time.Date(on.Year(), on.Month(), on.Day(), 0, 0, 0, 0, time.UTC)}
+	if rate, ok := c.rates[key]; ok {
+		return rate, nil
+	}
+	rate, err := c.provider.Rate(base, quote, on)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	c.rates[key] = rate
+	return rate, nil
+}
+
+type ExchangeRateService struct {
+	repo     *repository.ExchangeRateRepository
+	provider RateProvider
+}
+
+func NewExchangeRateService(repo *repository.ExchangeRateRepository, provider RateProvider) *ExchangeRateService {
+	return &ExchangeRateService{repo: repo, provider: provider}
+}
+
+// LoadRates validates and upserts daily rates, replacing any rate already
+// stored for the same pair and day.
+func (s *ExchangeRateService) LoadRates(rates []model.ExchangeRate) (int, error) {
+	for i := range rates {
+		r := &rates[i]
+		r.Base = strings.ToUpper(r.Base)
+		r.Quote = strings.ToUpper(r.Quote)
+		if len(r.Base) != 3 || len(r.Quote) != 3 {
+			return 0, ErrInvalidCurrency
+		}
+		if !r.Rate.IsPositive() {
+			return 0, ErrInvalidRate
+		}
+		if r.Date.IsZero() {
+			return 0, ErrInvalidDate
+		}
+		r.Date = time.Date(r.Date.Year(), r.Date.Month(), r.Date.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	if err := s.repo.UpsertBatch(rates); err != nil {
+		return 0, fmt.Errorf("load rates: %w", err)
+	}
+	return len(rates), nil
+}
+
+func (s *ExchangeRateService) GetRate(base, quote string, on time.Time) (decimal.Decimal, error) {
+	return s.provider.Rate(strings.ToUpper(base), strings.ToUpper(quote), on)
+}
+
+func (s *ExchangeRateService) GetHistory(base, quote string, from, to time.Time) ([]model.ExchangeRate, error) {
+	return s.repo.GetRange(strings.ToUpper(base), strings.ToUpper(quote), from, to)
+}
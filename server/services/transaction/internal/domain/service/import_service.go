@@ -0,0 +1,207 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"transaction/internal/data/repository"
+	"transaction/internal/domain/model"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrEmptyStatement            = errors.New("statement contains no transactions")
+	ErrStatementCurrencyMismatch = errors.New("statement currency does not match account currency")
+)
+
+// ImportRow is one parsed statement line and whether it is already on the account.
+type ImportRow struct {
+	Transaction model.Transaction
+	Duplicate   bool
+}
+
+type ImportResult struct {
+	DryRun     bool
+	Rows       []ImportRow
+	Imported   int
+	Duplicates int
+}
+
+type ImportService struct {
+	repo        *repository.TransactionRepository
+	accountRepo *repository.AccountRepository
+	uow         *repository.UnitOfWork
+	rules       *CategoryRuleService
+	listener    ChangeListener
+}
+
+func NewImportService(
+	repo *repository.TransactionRepository,
+	accountRepo *repository.AccountRepository,
+	uow *repository.UnitOfWork,
+	rules *CategoryRuleService,
+) *ImportService {
+	return &ImportService{
+		repo:        repo,
+		accountRepo: accountRepo,
+		uow:         uow,
+		rules:       rules,
+	}
+}
+
+// UseChangeListener reports imported transactions to l inside the unit of
+// work that books them.
+func (s *ImportService) UseChangeListener(l ChangeListener) {
+	s.listener = l
+}
+
+// Import books parsed statement transactions on an account, skipping those
+// already present. A line counts as present when an existing transaction has
+// the same date, signed amount and description; repeated identical lines are
+// matched one for one, so re-importing a statement adds nothing. With dryRun
+// the result is computed but nothing is written. Category rules are applied
+// to every line, so the preview shows where each one will land.
+func (s *ImportService) Import(accountID, userID uint, txs []model.Transaction, dryRun bool) (*ImportResult, error) {
+	if len(txs) == 0 {
+		return nil, ErrEmptyStatement
+	}
+
+	var result *ImportResult
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		// Locking the account serialises concurrent imports of the same statement
+		accounts, err := repos.Accounts.GetByIDsForUpdate([]uint{accountID})
+		if err != nil {
+			return fmt.Errorf("lock account: %w", err)
+		}
+		if len(accounts) == 0 {
+			return ErrAccountNotFound
+		}
+		account := accounts[0]
+		if account.UserID != userID {
+			return ErrAccountAccessDenied
+		}
+
+		from, to := txs[0].TransactionDate, txs[0].TransactionDate
+		for i := range txs {
+			tx := &txs[i]
+			if tx.Currency == "" {
+				tx.Currency = account.Currency
+			}
+			if !strings.EqualFold(tx.Currency, account.Currency) {
+				return ErrStatementCurrencyMismatch
+			}
+			tx.AccountID = account.ID
+			tx.UserID = userID
+			if tx.TransactionDate.Before(from) {
+				from = tx.TransactionDate
+			}
+			if tx.TransactionDate.After(to) {
+				to = tx.TransactionDate
+			}
+		}
+
+		if s.rules != nil {
+			if err := s.rules.CategorizeAll(userID, txs); err != nil {
+				return err
+			}
+		}
+
+		existing, err := repos.Transactions.Search(
+			repository.ByAccount(account.ID),
+			repository.DateFrom(from),
+			repository.DateTo(to.Add(24*time.Hour-time.Nanosecond)),
+		)
+		if err != nil {
+			return fmt.Errorf("get existing transactions: %w", err)
+		}
+
+		result = &ImportResult{DryRun: dryRun, Rows: matchExisting(account.ID, existing, txs)}
+		// The listener hears about the import as a whole below
+		ts := boundTransactionService(repos, nil)
+		for i := range txs {
+			if result.Rows[i].Duplicate {
+				result.Duplicates++
+				continue
+			}
+			if !dryRun {
+				if _, err := ts.CreateTransaction(&txs[i]); err != nil {
+					return fmt.Errorf("import line %d: %w", i+1, err)
+				}
+			}
+			result.Rows[i].Transaction = txs[i]
+			result.Imported++
+		}
+
+		if s.listener == nil || dryRun || result.Imported == 0 {
+			return nil
+		}
+		imported := make([]model.Transaction, 0, result.Imported)
+		for _, row := range result.Rows {
+			if !row.Duplicate {
+				imported = append(imported, row.Transaction)
+			}
+		}
+		return s.listener.TransactionsChanged(repos, imported)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// matchExisting fingerprints each statement line and marks those already
+// among the account's existing transactions, matching repeated identical
+// lines one for one.
+func matchExisting(accountID uint, existing, txs []model.Transaction) []ImportRow {
+	known := make(map[string]int, len(existing))
+	for i := range existing {
+		known[fingerprintOf(&existing[i], accountID)]++
+	}
+
+	rows := make([]ImportRow, len(txs))
+	for i := range txs {
+		tx := &txs[i]
+		fp := fingerprint(accountID, tx.TransactionDate, signedAmount(tx, accountID), tx.Description)
+		tx.ImportFingerprint = &fp
+
+		duplicate := known[fp] > 0
+		if duplicate {
+			known[fp]--
+		}
+		rows[i] = ImportRow{Transaction: *tx, Duplicate: duplicate}
+	}
+	return rows
+}
+
+// fingerprintOf prefers the fingerprint stored at import time, so later
+// edits to an imported transaction don't make it look new.
+func fingerprintOf(tx *model.Transaction, accountID uint) string {
+	if tx.ImportFingerprint != nil {
+		return *tx.ImportFingerprint
+	}
+	return fingerprint(accountID, tx.TransactionDate, signedAmount(tx, accountID), tx.Description)
+}
+
+func fingerprint(accountID uint, date time.Time, amount decimal.Decimal, description string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(description)), " ")
+	key := fmt.Sprintf("%d|%s|%s|%s", accountID, date.Format("2006-01-02"), amount.StringFixed(4), normalized)
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// signedAmount is the effect of tx on the account as a statement shows it.
+func signedAmount(tx *model.Transaction, accountID uint) decimal.Decimal {
+	switch tx.Type {
+	case model.TransactionTypeIncome:
+		return tx.Amount
+	case model.TransactionTypeTransfer:
+		if tx.AccountID != accountID {
+			return tx.CreditedAmount()
+		}
+	}
+	return tx.Amount.Neg()
+}
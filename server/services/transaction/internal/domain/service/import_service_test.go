@@ -0,0 +1,88 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"transaction/internal/domain/model"
+	"transaction/internal/infra/statement"
+
+	"github.com/shopspring/decimal"
+)
+
+const importStatement = `<CURDEF>RUB
+<STMTTRN><DTPOSTED>20260301<TRNAMT>-250,00<NAME>Coffee</STMTTRN>
+<STMTTRN><DTPOSTED>20260301<TRNAMT>-250,00<NAME>Coffee</STMTTRN>
+<STMTTRN><DTPOSTED>20260302<TRNAMT>50000<NAME>Salary</STMTTRN>
+`
+
+func parseImport(t *testing.T) []model.Transaction {
+	t.Helper()
+	txs, err := statement.ParseOFX(strings.NewReader(importStatement))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range txs {
+		txs[i].AccountID, txs[i].UserID = 1, 1
+	}
+	return txs
+}
+
+func duplicates(rows []ImportRow) []bool {
+	dup := make([]bool, len(rows))
+	for i, r := range rows {
+		dup[i] = r.Duplicate
+	}
+	return dup
+}
+
+func TestMatchExistingReimport(t *testing.T) {
+	rows := matchExisting(1, nil, parseImport(t))
+	if got := duplicates(rows); got[0] || got[1] || got[2] {
+		t.Fatalf("first import duplicates = %v, want none", got)
+	}
+
+	// What the first import stored, one line edited since
+	var stored []model.Transaction
+	for _, r := range rows {
+		if r.Transaction.ImportFingerprint == nil {
+			t.Fatal("imported line has no fingerprint")
+		}
+		stored = append(stored, r.Transaction)
+	}
+	stored[2].Description = "Salary for February"
+
+	rows = matchExisting(1, stored, parseImport(t))
+	if got := duplicates(rows); !got[0] || !got[1] || !got[2] {
+		t.Fatalf("re-import duplicates = %v, want all", got)
+	}
+}
+
+func TestMatchExistingRepeatedLines(t *testing.T) {
+	// One of the two identical coffees is on the account already, entered by hand
+	existing := []model.Transaction{{
+		AccountID:       1,
+		Type:            model.TransactionTypeExpense,
+		Amount:          decimal.NewFromInt(250),
+		Description:     "  coffee ",
+		TransactionDate: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+	}}
+
+	rows := matchExisting(1, existing, parseImport(t))
+	if got := duplicates(rows); !got[0] || got[1] || got[2] {
+		t.Fatalf("duplicates = %v, want only the first coffee", got)
+	}
+}
+
+func TestMatchExistingOtherAccount(t *testing.T) {
+	stored := matchExisting(2, nil, parseImport(t))
+	var existing []model.Transaction
+	for _, r := range stored {
+		existing = append(existing, r.Transaction)
+	}
+
+	rows := matchExisting(1, existing, parseImport(t))
+	if got := duplicates(rows); got[0] || got[1] || got[2] {
+		t.Fatalf("duplicates = %v, want none across accounts", got)
+	}
+}
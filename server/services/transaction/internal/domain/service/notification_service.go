@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"transaction/internal/data/repository"
+	"transaction/internal/domain/model"
+)
+
+const (
+	// maxNotificationAttempts is how often delivery is tried before a
+	// notification is marked failed.
+	maxNotificationAttempts = 8
+	maxNotificationList     = 100
+	// notificationLease is how long a dispatcher has to deliver the batch it
+	// claimed. Whatever it hasn't delivered by then is due again.
+	notificationLease = 5 * time.Minute
+)
+
+// Notifier delivers a notification to the user, e.g. over a webhook.
+type Notifier interface {
+	Notify(ctx context.Context, n *model.Notification) error
+}
+
+// DispatchResult counts the outcome of one delivery run.
+type DispatchResult struct {
+	Sent   int
+	Retry  int
+	Failed int
+}
+
+// NotificationService delivers the notification outbox through a Notifier.
+type NotificationService struct {
+	repo     *repository.NotificationRepository
+	notifier Notifier
+}
+
+func NewNotificationService(repo *repository.NotificationRepository, notifier Notifier) *NotificationService {
+	return &NotificationService{repo: repo, notifier: notifier}
+}
+
+func (s *NotificationService) GetByUser(userID uint, status model.NotificationStatus) ([]model.Notification, error) {
+	notifications, err := s.repo.GetByUserID(userID, status, maxNotificationList)
+	if err != nil {
+		return nil, fmt.Errorf("get notifications: %w", err)
+	}
+	return notifications, nil
+}
+
+// Dispatch delivers up to limit due notifications. The batch is leased
+// rather than locked, so concurrent dispatchers never send the same one and
+// no database transaction stays open while endpoints are called. Failed
+// deliveries are retried with exponential backoff until
+// maxNotificationAttempts is reached.
+func (s *NotificationService) Dispatch(ctx context.Context, limit int) (DispatchResult, error) {
+	return s.deliverDue(ctx, s.repo, time.Now(), limit)
+}
+
+// deliverDue claims up to limit notifications of outbox due at now, delivers
+// them and records each outcome as it comes. A failed delivery waits a
+// minute before its first retry and twice as long before each further one.
+// Deliveries stop when the lease runs out; the rest are due again then.
+func (s *NotificationService) deliverDue(ctx context.Context, outbox NotificationOutbox, now time.Time, limit int) (DispatchResult, error) {
+	var result DispatchResult
+	due, err := outbox.ClaimDue(now, now.Add(notificationLease), limit)
+	if err != nil {
+		return result, fmt.Errorf("claim due notifications: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notificationLease)
+	defer cancel()
+	for i := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		n := &due[i]
+		n.Attempts++
+		if err := s.notifier.Notify(ctx, n); err != nil {
+			n.LastError = err.Error()
+			if n.Attempts >= maxNotificationAttempts {
+				n.Status = model.NotificationFailed
+				result.Failed++
+			} else {
+				n.NextAttemptAt = now.Add(time.Minute << (n.Attempts - 1))
+				result.Retry++
+			}
+		} else {
+			sentAt := now
+			n.Status = model.NotificationSent
+			n.SentAt = &sentAt
+			n.LastError = ""
+			result.Sent++
+		}
+		if err := outbox.Update(n); err != nil {
+			return result, fmt.Errorf("update notification: %w", err)
+		}
+	}
+	return result, nil
+}
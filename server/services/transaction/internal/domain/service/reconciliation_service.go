@@ -0,0 +1,156 @@
+package service
+
+import (
+	"fmt"
+	"transaction/internal/data/repository"
+	"transaction/internal/domain/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// Reconciliation compares an account's cached balance with the balance
+// recomputed from its opening balance and completed transactions.
+type Reconciliation struct {
+	AccountID       uint
+	UserID          uint
+	AccountName     string
+	Currency        string
+	OpeningBalance  decimal.Decimal
+	LedgerNet       decimal.Decimal
+	ExpectedBalance decimal.Decimal
+	CachedBalance   decimal.Decimal
+	Difference      decimal.Decimal
+	Fixed           bool
+}
+
+func (r Reconciliation) IsBalanced() bool {
+	return r.Difference.IsZero()
+}
+
+type ReconciliationService struct {
+	accountRepo *repository.AccountRepository
+	uow         *repository.UnitOfWork
+}
+
+func NewReconciliationService(accountRepo *repository.AccountRepository, uow *repository.UnitOfWork) *ReconciliationService {
+	return &ReconciliationService{
+		accountRepo: accountRepo,
+		uow:         uow,
+	}
+}
+
+func (s *ReconciliationService) ReconcileAccount(id, userID uint) (*Reconciliation, error) {
+	account, err := s.accountRepo.GetByID(id)
+	if err != nil {
+		return nil, ErrAccountNotFound
+	}
+	if account.UserID != userID {
+		return nil, ErrAccountAccessDenied
+	}
+
+	results, err := reconcile(s.accountRepo, []model.Account{*account})
+	if err != nil {
+		return nil, err
+	}
+	return &results[0], nil
+}
+
+// ReconcileAll checks every account, or only those of userID when it is
+// non-zero. With fix set, drifted cached balances are rewritten from the ledger.
+func (s *ReconciliationService) ReconcileAll(userID uint, fix bool) ([]Reconciliation, error) {
+	var accounts []model.Account
+	var err error
+	if userID != 0 {
+		accounts, err = s.accountRepo.GetByUserID(userID)
+	} else {
+		accounts, err = s.accountRepo.GetAll()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get accounts: %w", err)
+	}
+
+	results, err := reconcile(s.accountRepo, accounts)
+	if err != nil {
+		return nil, err
+	}
+	if !fix {
+		return results, nil
+	}
+
+	for i := range results {
+		if results[i].IsBalanced() {
+			continue
+		}
+		fixed, err := s.rebuildBalance(results[i].AccountID)
+		if err != nil {
+			return results, fmt.Errorf("rebuild account %d: %w", results[i].AccountID, err)
+		}
+		results[i] = *fixed
+	}
+	return results, nil
+}
+
+// rebuildBalance recomputes one account while holding its row lock, so a
+// transaction written concurrently can't slip in between reading the ledger
+// and storing the new balance.
+func (s *ReconciliationService) rebuildBalance(accountID uint) (*Reconciliation, error) {
+	var result *Reconciliation
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		accounts, err := repos.Accounts.GetByIDsForUpdate([]uint{accountID})
+		if err != nil {
+			return err
+		}
+		if len(accounts) == 0 {
+			return ErrAccountNotFound
+		}
+
+		results, err := reconcile(repos.Accounts, accounts)
+		if err != nil {
+			return err
+		}
+		result = &results[0]
+		if result.IsBalanced() {
+			return nil
+		}
+
+		if err := repos.Accounts.UpdateBalance(accountID, result.ExpectedBalance); err != nil {
+			return err
+		}
+		result.Fixed = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func reconcile(repo *repository.AccountRepository, accounts []model.Account) ([]Reconciliation, error) {
+	ids := make([]uint, len(accounts))
+	for i, a := range accounts {
+		ids[i] = a.ID
+	}
+
+	nets, err := repo.GetLedgerNet(ids)
+	if err != nil {
+		return nil, fmt.Errorf("get ledger: %w", err)
+	}
+
+	results := make([]Reconciliation, len(accounts))
+	for i, a := range accounts {
+		net := nets[a.ID]
+		expected := a.OpeningBalance.Add(net)
+		results[i] = Reconciliation{
+			AccountID:       a.ID,
+			UserID:          a.UserID,
+			AccountName:     a.Name,
+			Currency:        a.Currency,
+			OpeningBalance:  a.OpeningBalance,
+			LedgerNet:       net,
+			ExpectedBalance: expected,
+			CachedBalance:   a.Balance,
+			Difference:      a.Balance.Sub(expected),
+		}
+	}
+	return results, nil
+}
@@ -21,20 +21,30 @@ type RecurringTransactionService struct {
 	repo        *repository.RecurringTransactionRepository
 	txRepo      *repository.TransactionRepository
 	accountRepo *repository.AccountRepository
+	uow         *repository.UnitOfWork
+	listener    ChangeListener
 }
 
 func NewRecurringTransactionService(
 	repo *repository.RecurringTransactionRepository,
 	txRepo *repository.TransactionRepository,
 	accountRepo *repository.AccountRepository,
+	uow *repository.UnitOfWork,
 ) *RecurringTransactionService {
 	return &RecurringTransactionService{
 		repo:        repo,
 		txRepo:      txRepo,
 		accountRepo: accountRepo,
+		uow:         uow,
 	}
 }
 
+// UseChangeListener reports generated transactions to l inside the unit of
+// work that creates them.
+func (s *RecurringTransactionService) UseChangeListener(l ChangeListener) {
+	s.listener = l
+}
+
 func (s *RecurringTransactionService) Create(rt *model.RecurringTransaction) (*model.RecurringTransaction, error) {
 	if rt.Amount.LessThanOrEqual(decimal.Zero) {
 		return nil, ErrInvalidAmount
@@ -128,7 +138,6 @@ func (s *RecurringTransactionService) Execute(id, userID uint) (*model.Recurring
 		return nil, ErrRecurringInactive
 	}
 
-	txService := NewWithAccountRepo(s.txRepo, s.accountRepo)
 	now := time.Now()
 	tx := &model.Transaction{
 		UserID:          rt.UserID,
@@ -141,10 +150,6 @@ func (s *RecurringTransactionService) Execute(id, userID uint) (*model.Recurring
 		TransactionDate: now,
 	}
 
-	if _, err := txService.CreateTransaction(tx); err != nil {
-		return nil, err
-	}
-
 	baseDate := rt.NextDate
 	if baseDate.Before(now) {
 		baseDate = now
@@ -154,7 +159,20 @@ func (s *RecurringTransactionService) Execute(id, userID uint) (*model.Recurring
 		rt.IsActive = false
 	}
 
-	return s.repo.Update(rt)
+	var updated *model.RecurringTransaction
+	err = s.inTransaction(func(txService *TransactionService, repo *repository.RecurringTransactionRepository) error {
+		if _, err := txService.CreateTransaction(tx); err != nil {
+			return err
+		}
+		var err error
+		updated, err = repo.Update(rt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
 }
 
 func (s *RecurringTransactionService) ProcessDue() (int, error) {
@@ -164,7 +182,6 @@ func (s *RecurringTransactionService) ProcessDue() (int, error) {
 	}
 
 	count := 0
-	txService := NewWithAccountRepo(s.txRepo, s.accountRepo)
 
 	for _, rt := range due {
 		if rt.EndDate != nil && rt.NextDate.After(*rt.EndDate) {
@@ -184,21 +201,39 @@ func (s *RecurringTransactionService) ProcessDue() (int, error) {
 			TransactionDate: rt.NextDate,
 		}
 
-		if _, err := txService.CreateTransaction(tx); err != nil {
-			continue
-		}
-
 		rt.NextDate = advanceDate(rt.NextDate, rt.Frequency)
 		if rt.EndDate != nil && rt.NextDate.After(*rt.EndDate) {
 			rt.IsActive = false
 		}
-		s.repo.Update(&rt)
+
+		// The generated transaction and the schedule advance commit together,
+		// so a crash can't execute the same occurrence twice.
+		err := s.inTransaction(func(txService *TransactionService, repo *repository.RecurringTransactionRepository) error {
+			if _, err := txService.CreateTransaction(tx); err != nil {
+				return err
+			}
+			_, err := repo.Update(&rt)
+			return err
+		})
+		if err != nil {
+			continue
+		}
 		count++
 	}
 
 	return count, nil
 }
 
+// inTransaction runs fn inside the unit of work when one is configured.
+func (s *RecurringTransactionService) inTransaction(fn func(txService *TransactionService, repo *repository.RecurringTransactionRepository) error) error {
+	if s.uow == nil {
+		return fn(NewWithAccountRepo(s.txRepo, s.accountRepo), s.repo)
+	}
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		return fn(boundTransactionService(repos, s.listener), repos.Recurring)
+	})
+}
+
 func advanceDate(date time.Time, freq model.RecurrenceFrequency) time.Time {
 	switch freq {
 	case model.FrequencyDaily:
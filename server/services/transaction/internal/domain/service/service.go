@@ -3,11 +3,14 @@ package service
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 	"transaction/internal/data/repository"
 	"transaction/internal/domain/model"
+	"transaction/pkg/cursor"
 
 	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 )
 
 var (
@@ -16,11 +19,25 @@ var (
 	ErrInvalidTransactionType     = errors.New("invalid transaction type")
 	ErrDestinationAccountRequired = errors.New("destination account required for transfer")
 	ErrDestinationAccountNotFound = errors.New("destination account not found")
+	ErrTransactionNotFound        = errors.New("transaction not found")
+	ErrTransactionAccessDenied    = errors.New("access denied to this transaction")
+	ErrDestinationAmountRequired  = errors.New("destination amount or exchange rate required for cross-currency transfer")
+	ErrDestinationAmountMismatch  = errors.New("destination amount does not match amount and exchange rate")
+	ErrDestinationCurrencyInvalid = errors.New("destination currency does not match destination account")
+	ErrSplitOnTransfer            = errors.New("transfers cannot be split")
+	ErrSplitSumMismatch           = errors.New("split amounts must sum to the transaction amount")
+	ErrSplitsRequired             = errors.New("amount and category of a split transaction can only change together with its splits")
 )
 
 type TransactionService struct {
 	repo        *repository.TransactionRepository
 	accountRepo *repository.AccountRepository
+	uow         *repository.UnitOfWork
+	categorizer Categorizer
+	listener    ChangeListener
+	// repos is set on services bound to a unit of work; listeners write
+	// through it
+	repos *repository.Repositories
 }
 
 func New(repo *repository.TransactionRepository) *TransactionService {
@@ -36,6 +53,47 @@ func NewWithAccountRepo(repo *repository.TransactionRepository, accountRepo *rep
 	}
 }
 
+// NewWithUnitOfWork returns a service whose balance-affecting operations run
+// in a single database transaction with the involved account rows locked.
+func NewWithUnitOfWork(repo *repository.TransactionRepository, accountRepo *repository.AccountRepository, uow *repository.UnitOfWork) *TransactionService {
+	return &TransactionService{
+		repo:        repo,
+		accountRepo: accountRepo,
+		uow:         uow,
+	}
+}
+
+// UseCategorizer makes CreateTransaction fill in missing categories.
+func (s *TransactionService) UseCategorizer(c Categorizer) {
+	s.categorizer = c
+}
+
+// UseChangeListener reports creates and updates to l inside their unit of
+// work.
+func (s *TransactionService) UseChangeListener(l ChangeListener) {
+	s.listener = l
+}
+
+// boundTransactionService returns a service working on repos, which reports
+// changes to l within the same unit of work.
+func boundTransactionService(repos *repository.Repositories, l ChangeListener) *TransactionService {
+	return &TransactionService{
+		repo:        repos.Transactions,
+		accountRepo: repos.Accounts,
+		listener:    l,
+		repos:       repos,
+	}
+}
+
+// notifyChanged tells the listener about txs. Only services bound to a unit
+// of work report changes.
+func (s *TransactionService) notifyChanged(txs ...model.Transaction) error {
+	if s.listener == nil || s.repos == nil || len(txs) == 0 {
+		return nil
+	}
+	return s.listener.TransactionsChanged(s.repos, txs)
+}
+
 func (s *TransactionService) GetTransactions() ([]model.Transaction, error) {
 	transactions, err := s.repo.GetAll()
 	if err != nil {
@@ -76,6 +134,65 @@ func (s *TransactionService) GetTransactionsByAccountPaginated(accountID, userID
 	return txs, count, nil
 }
 
+func (s *TransactionService) SearchTransactions(userID uint, filter TransactionFilter) ([]model.Transaction, error) {
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+	txs, err := s.repo.Search(filter.criteria(userID)...)
+	if err != nil {
+		return nil, fmt.Errorf("search transactions: %w", err)
+	}
+	return txs, nil
+}
+
+func (s *TransactionService) SearchTransactionsPaginated(userID uint, filter TransactionFilter, limit, offset int) ([]model.Transaction, int64, error) {
+	if err := filter.validate(); err != nil {
+		return nil, 0, err
+	}
+	txs, count, err := s.repo.SearchPaginated(limit, offset, filter.criteria(userID)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("search transactions paginated: %w", err)
+	}
+	return txs, count, nil
+}
+
+// SearchTransactionsPage returns one keyset page of matching transactions
+// ordered by (transaction_date, id), starting after the row encoded in token.
+// The returned token is empty on the last page.
+func (s *TransactionService) SearchTransactionsPage(userID uint, filter TransactionFilter, token string, limit int) ([]model.Transaction, string, error) {
+	if err := filter.validate(); err != nil {
+		return nil, "", err
+	}
+	sort := repository.TransactionSort(filter.Sort)
+	if sort != "" && sort != repository.SortDateDesc && sort != repository.SortDateAsc {
+		return nil, "", ErrCursorSortUnsupported
+	}
+
+	criteria := filter.criteria(userID)
+	if token != "" {
+		key, err := cursor.Decode(token)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		criteria = append(criteria, repository.AfterKey(key.Time, key.ID, sort))
+	}
+	// One extra row tells whether another page exists
+	criteria = append(criteria, repository.Limited(limit+1))
+
+	txs, err := s.repo.Search(criteria...)
+	if err != nil {
+		return nil, "", fmt.Errorf("search transactions page: %w", err)
+	}
+
+	next := ""
+	if len(txs) > limit {
+		txs = txs[:limit]
+		last := txs[limit-1]
+		next = cursor.Encode(cursor.Key{Time: last.TransactionDate, ID: last.ID})
+	}
+	return txs, next, nil
+}
+
 func (s *TransactionService) GetTransaction(id uint) (*model.Transaction, error) {
 	transaction, err := s.repo.GetByID(id)
 	if err != nil {
@@ -84,20 +201,172 @@ func (s *TransactionService) GetTransaction(id uint) (*model.Transaction, error)
 	return transaction, nil
 }
 
+func (s *TransactionService) GetUserTransaction(id, userID uint) (*model.Transaction, error) {
+	transaction, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, ErrTransactionNotFound
+	}
+	if transaction.UserID != userID {
+		return nil, ErrTransactionAccessDenied
+	}
+	return transaction, nil
+}
+
 func (s *TransactionService) CreateTransaction(tx *model.Transaction) (*model.Transaction, error) {
+	if err := validateTransaction(tx); err != nil {
+		return nil, err
+	}
+	if tx.CategoryID == nil && len(tx.Splits) == 0 && s.categorizer != nil {
+		if err := s.categorizer.Categorize(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	var created *model.Transaction
+	err := s.inTransaction(func(ts *TransactionService) error {
+		// Update account balances if accountRepo is available
+		if ts.accountRepo != nil {
+			if err := ts.resolveTransferLeg(tx); err != nil {
+				return err
+			}
+			if err := ts.applyBalances(tx); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		created, err = ts.repo.Create(tx)
+		if err != nil {
+			return fmt.Errorf("create transaction: %w", err)
+		}
+		return ts.notifyChanged(*created)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdateTransaction replaces a stored transaction with tx. The balance effect
+// of the stored version is reversed before the new one is applied, so changes
+// of amount, type or accounts keep account balances consistent.
+func (s *TransactionService) UpdateTransaction(tx *model.Transaction) (*model.Transaction, error) {
+	if err := validateTransaction(tx); err != nil {
+		return nil, err
+	}
+	if tx.Type != model.TransactionTypeTransfer {
+		tx.DestinationAccountID = nil
+		tx.DestinationAmount = nil
+		tx.DestinationCurrency = nil
+		tx.ExchangeRate = nil
+	}
+
+	var updated *model.Transaction
+	err := s.inTransaction(func(ts *TransactionService) error {
+		existing, err := ts.lockUserTransaction(tx.ID, tx.UserID)
+		if err != nil {
+			return err
+		}
+
+		if ts.accountRepo != nil {
+			if err := ts.resolveTransferLeg(tx); err != nil {
+				return err
+			}
+			// Both versions are settled in one step, so the old and new
+			// accounts are locked together in id order
+			if err := ts.replaceBalances(existing, tx); err != nil {
+				return err
+			}
+		}
+
+		updated, err = ts.repo.Update(tx)
+		if err != nil {
+			return fmt.Errorf("update transaction: %w", err)
+		}
+		return ts.notifyChanged(*updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// DeleteTransaction removes a transaction and reverses its effect on account balances.
+func (s *TransactionService) DeleteTransaction(id, userID uint) error {
+	return s.inTransaction(func(ts *TransactionService) error {
+		existing, err := ts.lockUserTransaction(id, userID)
+		if err != nil {
+			return err
+		}
+
+		if ts.accountRepo != nil {
+			if err := ts.revertBalances(existing); err != nil {
+				return err
+			}
+		}
+
+		if err := ts.repo.Delete(id); err != nil {
+			return fmt.Errorf("delete transaction: %w", err)
+		}
+		return nil
+	})
+}
+
+// moveTransaction moves the balance effect of a stored transaction to
+// accountID. The caller updates the stored account.
+func (s *TransactionService) moveTransaction(id, userID, accountID uint) error {
+	existing, err := s.lockUserTransaction(id, userID)
+	if err != nil {
+		return err
+	}
+	moved := *existing
+	moved.AccountID = accountID
+	return s.replaceBalances(existing, &moved)
+}
+
+// inTransaction runs fn with a service bound to a single database transaction.
+// Without a unit of work fn gets the service itself and runs unguarded.
+func (s *TransactionService) inTransaction(fn func(ts *TransactionService) error) error {
+	if s.uow == nil {
+		return fn(s)
+	}
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		return fn(boundTransactionService(repos, s.listener))
+	})
+}
+
+func (s *TransactionService) lockUserTransaction(id, userID uint) (*model.Transaction, error) {
+	transaction, err := s.repo.GetByIDForUpdate(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTransactionNotFound
+		}
+		return nil, fmt.Errorf("lock transaction: %w", err)
+	}
+	if transaction.UserID != userID {
+		return nil, ErrTransactionAccessDenied
+	}
+	return transaction, nil
+}
+
+func validateTransaction(tx *model.Transaction) error {
 	// Validate amount
 	if tx.Amount.LessThanOrEqual(decimal.Zero) {
-		return nil, ErrInvalidAmount
+		return ErrInvalidAmount
 	}
 
 	// Validate currency
 	if len(tx.Currency) != 3 {
-		return nil, ErrInvalidCurrency
+		return ErrInvalidCurrency
 	}
 
 	// Validate transaction type
 	if !model.IsValidTransactionType(tx.Type) {
-		return nil, ErrInvalidTransactionType
+		return ErrInvalidTransactionType
+	}
+
+	if err := validateSplits(tx); err != nil {
+		return err
 	}
 
 	// Set defaults
@@ -108,25 +377,219 @@ func (s *TransactionService) CreateTransaction(tx *model.Transaction) (*model.Tr
 		tx.Status = model.TransactionStatusCompleted
 	}
 
-	// Update account balances if accountRepo is available
-	if s.accountRepo != nil {
-		if err := s.updateBalances(tx); err != nil {
-			return nil, err
+	return nil
+}
+
+// resolveTransferLeg fills in the destination side of a transfer. Between
+// accounts of one currency the source amount moves as is. Otherwise the caller
+// gives the destination amount, the rate or both; the missing value is derived
+// and the rate is kept with the transaction for reporting.
+func (s *TransactionService) resolveTransferLeg(tx *model.Transaction) error {
+	if tx.Type != model.TransactionTypeTransfer {
+		return nil
+	}
+	if tx.DestinationAccountID == nil {
+		return ErrDestinationAccountRequired
+	}
+
+	dest, err := s.accountRepo.GetByID(*tx.DestinationAccountID)
+	if err != nil {
+		return ErrDestinationAccountNotFound
+	}
+	if tx.DestinationCurrency != nil && !strings.EqualFold(*tx.DestinationCurrency, dest.Currency) {
+		return ErrDestinationCurrencyInvalid
+	}
+	currency := dest.Currency
+	tx.DestinationCurrency = &currency
+
+	if tx.DestinationAmount != nil && !tx.DestinationAmount.IsPositive() {
+		return ErrInvalidAmount
+	}
+	if tx.ExchangeRate != nil && !tx.ExchangeRate.IsPositive() {
+		return ErrInvalidRate
+	}
+
+	if strings.EqualFold(tx.Currency, dest.Currency) {
+		one := decimal.NewFromInt(1)
+		if (tx.DestinationAmount != nil && !tx.DestinationAmount.Equal(tx.Amount)) ||
+			(tx.ExchangeRate != nil && !tx.ExchangeRate.Equal(one)) {
+			return ErrDestinationAmountMismatch
+		}
+		amount := tx.Amount
+		tx.DestinationAmount = &amount
+		tx.ExchangeRate = &one
+		return nil
+	}
+
+	switch {
+	case tx.DestinationAmount != nil && tx.ExchangeRate != nil:
+		// Quoted rates are rounded, so allow a difference of one minor unit
+		expected := tx.Amount.Mul(*tx.ExchangeRate)
+		if expected.Sub(*tx.DestinationAmount).Abs().GreaterThan(decimal.New(1, -2)) {
+			return ErrDestinationAmountMismatch
+		}
+	case tx.DestinationAmount != nil:
+		rate := tx.DestinationAmount.DivRound(tx.Amount, 8)
+		tx.ExchangeRate = &rate
+	case tx.ExchangeRate != nil:
+		amount := tx.Amount.Mul(*tx.ExchangeRate).Round(4)
+		tx.DestinationAmount = &amount
+	default:
+		return ErrDestinationAmountRequired
+	}
+	return nil
+}
+
+// validateSplits checks that split lines cover the amount exactly. A split
+// transaction has no category of its own; each line carries one.
+func validateSplits(tx *model.Transaction) error {
+	if len(tx.Splits) == 0 {
+		return nil
+	}
+	if tx.Type == model.TransactionTypeTransfer {
+		return ErrSplitOnTransfer
+	}
+
+	sum := decimal.Zero
+	for _, split := range tx.Splits {
+		if !split.Amount.IsPositive() {
+			return ErrInvalidAmount
 		}
+		sum = sum.Add(split.Amount)
+	}
+	if !sum.Equal(tx.Amount) {
+		return ErrSplitSumMismatch
 	}
 
-	created, err := s.repo.Create(tx)
+	tx.CategoryID = nil
+	tx.Category = nil
+	return nil
+}
+
+func (s *TransactionService) applyBalances(tx *model.Transaction) error {
+	return s.replaceBalances(nil, tx)
+}
+
+func (s *TransactionService) revertBalances(tx *model.Transaction) error {
+	return s.replaceBalances(tx, nil)
+}
+
+// replaceBalances undoes the balance effect of prev and applies that of next;
+// either may be nil. Every involved account row is locked with one
+// SELECT ... FOR UPDATE before anything changes, so rows are always taken in
+// id order: concurrent writers wait instead of losing updates or deadlocking.
+// The accounts of next must belong to its user whatever its status.
+func (s *TransactionService) replaceBalances(prev, next *model.Transaction) error {
+	ids, err := balanceAccountIDs(prev)
 	if err != nil {
-		return nil, fmt.Errorf("create transaction: %w", err)
+		return err
+	}
+	nextIDs, err := transactionAccountIDs(next)
+	if err != nil {
+		return err
+	}
+	ids = append(ids, nextIDs...)
+	if len(ids) == 0 {
+		return nil
 	}
 
-	return created, nil
+	accounts, err := s.accountRepo.GetByIDsForUpdate(ids)
+	if err != nil {
+		return fmt.Errorf("lock accounts: %w", err)
+	}
+	byID := make(map[uint]*model.Account, len(accounts))
+	for i := range accounts {
+		byID[accounts[i].ID] = &accounts[i]
+	}
+
+	if next != nil {
+		if err := checkAccountOwner(byID, next); err != nil {
+			return err
+		}
+	}
+
+	changed := map[uint]bool{}
+	if prev != nil {
+		if err := adjustBalances(byID, changed, prev, decimal.NewFromInt(-1)); err != nil {
+			return err
+		}
+	}
+	if next != nil {
+		if err := adjustBalances(byID, changed, next, decimal.NewFromInt(1)); err != nil {
+			return err
+		}
+	}
+
+	for i := range accounts {
+		a := &accounts[i]
+		if !changed[a.ID] {
+			continue
+		}
+		if err := s.accountRepo.UpdateBalance(a.ID, a.Balance); err != nil {
+			return fmt.Errorf("update account balance: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// balanceAccountIDs lists the accounts whose balance tx affects.
+func balanceAccountIDs(tx *model.Transaction) ([]uint, error) {
+	// Only completed transactions count towards the balance, matching reconciliation
+	if tx == nil || tx.Status != model.TransactionStatusCompleted {
+		return nil, nil
+	}
+	return transactionAccountIDs(tx)
+}
+
+// transactionAccountIDs lists the accounts tx names.
+func transactionAccountIDs(tx *model.Transaction) ([]uint, error) {
+	if tx == nil {
+		return nil, nil
+	}
+	if tx.Type != model.TransactionTypeTransfer {
+		return []uint{tx.AccountID}, nil
+	}
+	if tx.DestinationAccountID == nil {
+		return nil, ErrDestinationAccountRequired
+	}
+	return []uint{tx.AccountID, *tx.DestinationAccountID}, nil
 }
 
-func (s *TransactionService) updateBalances(tx *model.Transaction) error {
+// checkAccountOwner verifies that the accounts tx names, locked in byID,
+// exist and belong to its user.
+func checkAccountOwner(byID map[uint]*model.Account, tx *model.Transaction) error {
+	account, ok := byID[tx.AccountID]
+	if !ok {
+		return ErrAccountNotFound
+	}
+	if account.UserID != tx.UserID {
+		return ErrAccountAccessDenied
+	}
+	if tx.Type != model.TransactionTypeTransfer {
+		return nil
+	}
+	dest, ok := byID[*tx.DestinationAccountID]
+	if !ok {
+		return ErrDestinationAccountNotFound
+	}
+	if dest.UserID != tx.UserID {
+		return ErrAccountAccessDenied
+	}
+	return nil
+}
+
+// adjustBalances adds the effect of tx to the locked accounts in byID,
+// multiplied by sign: 1 applies the transaction, -1 undoes it. The accounts
+// it touches are marked in changed.
+func adjustBalances(byID map[uint]*model.Account, changed map[uint]bool, tx *model.Transaction, sign decimal.Decimal) error {
+	if tx.Status != model.TransactionStatusCompleted {
+		return nil
+	}
+
 	// Get source account
-	account, err := s.accountRepo.GetByID(tx.AccountID)
-	if err != nil {
+	account, ok := byID[tx.AccountID]
+	if !ok {
 		return ErrAccountNotFound
 	}
 
@@ -135,23 +598,22 @@ func (s *TransactionService) updateBalances(tx *model.Transaction) error {
 		return ErrAccountAccessDenied
 	}
 
+	amount := tx.Amount.Mul(sign)
+	changed[account.ID] = true
+
 	// Apply to balance based on type
 	switch tx.Type {
 	case model.TransactionTypeIncome:
-		account.Balance = account.Balance.Add(tx.Amount)
+		account.Balance = account.Balance.Add(amount)
 	case model.TransactionTypeExpense:
-		account.Balance = account.Balance.Sub(tx.Amount)
+		account.Balance = account.Balance.Sub(amount)
 	case model.TransactionTypeTransfer:
-		if tx.DestinationAccountID == nil {
-			return ErrDestinationAccountRequired
-		}
-
 		// Subtract from source
-		account.Balance = account.Balance.Sub(tx.Amount)
+		account.Balance = account.Balance.Sub(amount)
 
 		// Add to destination
-		destAccount, err := s.accountRepo.GetByID(*tx.DestinationAccountID)
-		if err != nil {
+		destAccount, ok := byID[*tx.DestinationAccountID]
+		if !ok {
 			return ErrDestinationAccountNotFound
 		}
 
@@ -159,14 +621,8 @@ func (s *TransactionService) updateBalances(tx *model.Transaction) error {
 			return ErrAccountAccessDenied
 		}
 
-		destAccount.Balance = destAccount.Balance.Add(tx.Amount)
-		if err := s.accountRepo.Update(destAccount); err != nil {
-			return fmt.Errorf("update destination account balance: %w", err)
-		}
-	}
-
-	if err := s.accountRepo.Update(account); err != nil {
-		return fmt.Errorf("update account balance: %w", err)
+		destAccount.Balance = destAccount.Balance.Add(tx.CreditedAmount().Mul(sign))
+		changed[destAccount.ID] = true
 	}
 
 	return nil
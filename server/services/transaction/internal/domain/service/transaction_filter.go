@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	"time"
+	"transaction/internal/data/repository"
+	"transaction/internal/domain/model"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidTransactionStatus = errors.New("invalid transaction status")
+	ErrInvalidSort              = errors.New("invalid sort order")
+	ErrInvalidAmountRange       = errors.New("min_amount must not exceed max_amount")
+	ErrInvalidDateRange         = errors.New("'to' must be after 'from'")
+	ErrInvalidCursor            = errors.New("invalid cursor")
+	ErrCursorSortUnsupported    = errors.New("cursor pagination supports only date sort orders")
+)
+
+// TransactionFilter describes a transaction search. Zero-valued fields don't
+// restrict the result.
+type TransactionFilter struct {
+	AccountID     *uint
+	From          *time.Time
+	To            *time.Time
+	Types         []model.TransactionType
+	Statuses      []model.TransactionStatus
+	CategoryIDs   []uint
+	SubCategories bool
+	MinAmount     *decimal.Decimal
+	MaxAmount     *decimal.Decimal
+	Currency      string
+	Search        string
+	Sort          string
+}
+
+func (f TransactionFilter) validate() error {
+	for _, t := range f.Types {
+		if !model.IsValidTransactionType(t) {
+			return ErrInvalidTransactionType
+		}
+	}
+	for _, st := range f.Statuses {
+		if !model.IsValidTransactionStatus(st) {
+			return ErrInvalidTransactionStatus
+		}
+	}
+	if f.Sort != "" && !repository.IsValidTransactionSort(repository.TransactionSort(f.Sort)) {
+		return ErrInvalidSort
+	}
+	if f.Currency != "" && len(f.Currency) != 3 {
+		return ErrInvalidCurrency
+	}
+	if f.MinAmount != nil && f.MaxAmount != nil && f.MinAmount.GreaterThan(*f.MaxAmount) {
+		return ErrInvalidAmountRange
+	}
+	if f.From != nil && f.To != nil && f.To.Before(*f.From) {
+		return ErrInvalidDateRange
+	}
+	return nil
+}
+
+func (f TransactionFilter) criteria(userID uint) []repository.TransactionCriterion {
+	criteria := []repository.TransactionCriterion{repository.ByUser(userID)}
+
+	if f.AccountID != nil {
+		criteria = append(criteria, repository.ByAccount(*f.AccountID))
+	}
+	if f.From != nil {
+		criteria = append(criteria, repository.DateFrom(*f.From))
+	}
+	if f.To != nil {
+		criteria = append(criteria, repository.DateTo(*f.To))
+	}
+	if len(f.Types) > 0 {
+		criteria = append(criteria, repository.OfTypes(f.Types...))
+	}
+	if len(f.Statuses) > 0 {
+		criteria = append(criteria, repository.WithStatuses(f.Statuses...))
+	}
+	if len(f.CategoryIDs) > 0 {
+		if f.SubCategories {
+			criteria = append(criteria, repository.InCategoryTrees(f.CategoryIDs...))
+		} else {
+			criteria = append(criteria, repository.InCategories(f.CategoryIDs...))
+		}
+	}
+	if f.MinAmount != nil {
+		criteria = append(criteria, repository.MinAmount(*f.MinAmount))
+	}
+	if f.MaxAmount != nil {
+		criteria = append(criteria, repository.MaxAmount(*f.MaxAmount))
+	}
+	if f.Currency != "" {
+		criteria = append(criteria, repository.WithCurrency(f.Currency))
+	}
+	if f.Search != "" {
+		criteria = append(criteria, repository.DescriptionContains(f.Search))
+	}
+
+	return append(criteria, repository.OrderedBy(repository.TransactionSort(f.Sort)))
+}
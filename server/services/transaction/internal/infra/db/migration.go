@@ -7,17 +7,50 @@ import (
 )
 
 func Migrate(db *gorm.DB) error {
+	hadOpeningBalance := db.Migrator().HasColumn(&model.Account{}, "opening_balance")
+
 	err := db.AutoMigrate(
 		&model.Account{},
 		&model.Category{},
 		&model.Transaction{},
+		&model.TransactionSplit{},
 		&model.Budget{},
 		&model.RecurringTransaction{},
+		&model.ExchangeRate{},
+		&model.CategoryRule{},
+		&model.Notification{},
 	)
 
 	if err != nil {
 		return err
 	}
 
+	if !hadOpeningBalance {
+		if err := backfillOpeningBalances(db); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// backfillOpeningBalances derives opening balances for accounts created before
+// the column existed, treating their current cached balance as correct.
+func backfillOpeningBalances(db *gorm.DB) error {
+	return db.Exec(`
+		UPDATE accounts a
+		SET opening_balance = a.balance - COALESCE((
+			SELECT SUM(CASE
+				WHEN t.type = 'income' AND t.account_id = a.id THEN t.amount
+				WHEN t.type = 'expense' AND t.account_id = a.id THEN -t.amount
+				WHEN t.type = 'transfer' AND t.account_id = a.id THEN -t.amount
+				WHEN t.type = 'transfer' AND t.destination_account_id = a.id THEN COALESCE(t.destination_amount, t.amount)
+				ELSE 0
+			END)
+			FROM transactions t
+			WHERE (t.account_id = a.id OR t.destination_account_id = a.id)
+			  AND t.status = 'completed'
+			  AND t.deleted_at IS NULL
+		), 0)
+	`).Error
+}
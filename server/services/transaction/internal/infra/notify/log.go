@@ -0,0 +1,28 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"transaction/internal/domain/model"
+)
+
+// LogNotifier writes notifications to the service log instead of delivering
+// them. It never fails.
+type LogNotifier struct {
+	log *slog.Logger
+}
+
+func NewLogNotifier(log *slog.Logger) *LogNotifier {
+	return &LogNotifier{log: log}
+}
+
+func (l *LogNotifier) Notify(_ context.Context, n *model.Notification) error {
+	l.log.Info("notification",
+		slog.Uint64("id", uint64(n.ID)),
+		slog.Uint64("user_id", uint64(n.UserID)),
+		slog.String("kind", string(n.Kind)),
+		slog.String("subject", n.Subject),
+		slog.String("body", n.Body),
+	)
+	return nil
+}
@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"transaction/internal/domain/model"
+)
+
+// MemoryNotifier records notifications in memory. It stands in for a real
+// notifier in tests and local runs; setting Err makes every delivery fail.
+type MemoryNotifier struct {
+	mu   sync.Mutex
+	sent []model.Notification
+	Err  error
+}
+
+func NewMemoryNotifier() *MemoryNotifier {
+	return &MemoryNotifier{}
+}
+
+func (m *MemoryNotifier) Notify(_ context.Context, n *model.Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return m.Err
+	}
+	m.sent = append(m.sent, *n)
+	return nil
+}
+
+// Sent returns a copy of the notifications delivered so far.
+func (m *MemoryNotifier) Sent() []model.Notification {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sent := make([]model.Notification, len(m.sent))
+	copy(sent, m.sent)
+	return sent
+}
+
+// Reset forgets the delivered notifications.
+func (m *MemoryNotifier) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = nil
+}
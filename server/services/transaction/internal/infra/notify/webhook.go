@@ -0,0 +1,90 @@
+// Package notify delivers outbox notifications. Every notifier implements
+// service.Notifier.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"transaction/internal/domain/model"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body when the
+// webhook has a secret.
+const SignatureHeader = "X-Bux-Signature"
+
+const webhookTimeout = 10 * time.Second
+
+type webhookMessage struct {
+	ID        uint                   `json:"id"`
+	UserID    uint                   `json:"user_id"`
+	Kind      model.NotificationKind `json:"kind"`
+	Subject   string                 `json:"subject"`
+	Body      string                 `json:"body"`
+	Payload   json.RawMessage        `json:"payload,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// WebhookNotifier POSTs each notification as JSON to a fixed URL. Any
+// non-2xx response counts as a failed delivery.
+type WebhookNotifier struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, n *model.Notification) error {
+	msg := webhookMessage{
+		ID:        n.ID,
+		UserID:    n.UserID,
+		Kind:      n.Kind,
+		Subject:   n.Subject,
+		Body:      n.Body,
+		CreatedAt: n.CreatedAt,
+	}
+	if n.Payload != "" {
+		msg.Payload = json.RawMessage(n.Payload)
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("encode webhook: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package statement
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"transaction/internal/domain/model"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrInvalidMapping = errors.New("csv mapping needs a date column and either an amount column or debit/credit columns")
+
+// CSVMapping says where each field lives in a CSV statement. Columns are
+// given by header name or by zero-based index. A single signed Amount column
+// or a pair of Debit/Credit columns can be used.
+type CSVMapping struct {
+	Date         string
+	Amount       string
+	Debit        string
+	Credit       string
+	Description  string
+	Currency     string
+	DateFormat   string
+	Delimiter    rune
+	DecimalComma bool
+	HasHeader    bool
+	SkipRows     int
+}
+
+func (m CSVMapping) validate() error {
+	if m.Date == "" {
+		return ErrInvalidMapping
+	}
+	if m.Amount == "" && m.Debit == "" && m.Credit == "" {
+		return ErrInvalidMapping
+	}
+	return nil
+}
+
+func ParseCSV(r io.Reader, m CSVMapping) ([]model.Transaction, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	if m.DateFormat == "" {
+		m.DateFormat = "2006-01-02"
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	if m.Delimiter != 0 {
+		reader.Comma = m.Delimiter
+	}
+
+	for i := 0; i < m.SkipRows; i++ {
+		if _, err := reader.Read(); err != nil {
+			return nil, csvError(reader, err)
+		}
+	}
+
+	var header []string
+	if m.HasHeader {
+		row, err := reader.Read()
+		if err != nil {
+			return nil, csvError(reader, err)
+		}
+		if len(row) > 0 {
+			row[0] = strings.TrimPrefix(row[0], "\ufeff")
+		}
+		header = row
+	}
+
+	cols := map[string]int{}
+	for field, ref := range map[string]string{
+		"date":        m.Date,
+		"amount":      m.Amount,
+		"debit":       m.Debit,
+		"credit":      m.Credit,
+		"description": m.Description,
+		"currency":    m.Currency,
+	} {
+		if ref == "" {
+			continue
+		}
+		idx, err := columnIndex(ref, header)
+		if err != nil {
+			return nil, fmt.Errorf("%s column: %w", field, err)
+		}
+		cols[field] = idx
+	}
+
+	var txs []model.Transaction
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, csvError(reader, err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		get := func(field string) string {
+			idx, ok := cols[field]
+			if !ok || idx >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[idx])
+		}
+
+		date, err := time.Parse(m.DateFormat, get("date"))
+		if err != nil {
+			return nil, &ParseError{Line: line, Msg: fmt.Sprintf("invalid date %q", get("date"))}
+		}
+
+		signed, err := csvAmount(get, m)
+		if err != nil {
+			return nil, &ParseError{Line: line, Msg: err.Error()}
+		}
+
+		if tx, ok := newTransaction(date, signed, get("currency"), get("description")); ok {
+			txs = append(txs, tx)
+		}
+	}
+	return txs, nil
+}
+
+func csvAmount(get func(string) string, m CSVMapping) (decimal.Decimal, error) {
+	if m.Amount != "" {
+		amount, err := parseAmount(get("amount"), m.DecimalComma)
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("invalid amount %q", get("amount"))
+		}
+		return amount, nil
+	}
+
+	signed := decimal.Zero
+	if v := get("credit"); v != "" {
+		credit, err := parseAmount(v, m.DecimalComma)
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("invalid credit %q", v)
+		}
+		signed = signed.Add(credit.Abs())
+	}
+	if v := get("debit"); v != "" {
+		debit, err := parseAmount(v, m.DecimalComma)
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("invalid debit %q", v)
+		}
+		signed = signed.Sub(debit.Abs())
+	}
+	return signed, nil
+}
+
+func columnIndex(ref string, header []string) (int, error) {
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), ref) {
+			return i, nil
+		}
+	}
+	if idx, err := strconv.Atoi(ref); err == nil && idx >= 0 {
+		return idx, nil
+	}
+	return 0, fmt.Errorf("column %q not found", ref)
+}
+
+func csvError(reader *csv.Reader, err error) error {
+	if err == io.EOF {
+		return &ParseError{Line: 1, Msg: "statement is empty"}
+	}
+	var perr *csv.ParseError
+	if errors.As(err, &perr) {
+		return &ParseError{Line: perr.Line, Msg: perr.Err.Error()}
+	}
+	line, _ := reader.FieldPos(0)
+	return &ParseError{Line: line, Msg: err.Error()}
+}
@@ -0,0 +1,97 @@
+package statement
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"transaction/internal/domain/model"
+)
+
+func TestParseCSVSignedAmount(t *testing.T) {
+	const csv = "\ufeffBank export\n" +
+		"Дата;Сумма;Валюта;Описание\n" +
+		"01.03.2026;-1 234,50;rub;Продукты\n" +
+		"02.03.2026;50 000,00;RUB;Зарплата\n" +
+		"03.03.2026;0,00;RUB;Проверка карты\n"
+
+	txs, err := ParseCSV(strings.NewReader(csv), CSVMapping{
+		Date:         "Дата",
+		Amount:       "Сумма",
+		Currency:     "Валюта",
+		Description:  "2",
+		DateFormat:   "02.01.2006",
+		Delimiter:    ';',
+		DecimalComma: true,
+		HasHeader:    true,
+		SkipRows:     1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 2 {
+		t.Fatalf("got %d transactions, want 2: %+v", len(txs), txs)
+	}
+	if tx := txs[0]; tx.Type != model.TransactionTypeExpense || tx.Amount.String() != "1234.5" ||
+		tx.Currency != "RUB" || tx.TransactionDate.Format("2006-01-02") != "2026-03-01" {
+		t.Errorf("first transaction = %+v", tx)
+	}
+	if tx := txs[1]; tx.Type != model.TransactionTypeIncome || tx.Amount.String() != "50000" {
+		t.Errorf("second transaction = %+v", tx)
+	}
+}
+
+func TestParseCSVDebitCredit(t *testing.T) {
+	const csv = "2026-03-01,Shop,\"1,200.00\",\n" +
+		"2026-03-02,Refund,,(15.25)\n"
+
+	txs, err := ParseCSV(strings.NewReader(csv), CSVMapping{
+		Date:        "0",
+		Description: "1",
+		Debit:       "2",
+		Credit:      "3",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 2 {
+		t.Fatalf("got %d transactions, want 2: %+v", len(txs), txs)
+	}
+	if tx := txs[0]; tx.Type != model.TransactionTypeExpense || tx.Amount.String() != "1200" {
+		t.Errorf("debit = %+v", tx)
+	}
+	// Credits count as money in whatever their sign
+	if tx := txs[1]; tx.Type != model.TransactionTypeIncome || tx.Amount.String() != "15.25" {
+		t.Errorf("credit = %+v", tx)
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		mapping CSVMapping
+		line    int
+	}{
+		{"bad date", "date,amount\n2026-03-01,1\n01/03/2026,1\n",
+			CSVMapping{Date: "date", Amount: "amount", HasHeader: true}, 3},
+		{"bad amount", "date,amount\n2026-03-01,abc\n",
+			CSVMapping{Date: "date", Amount: "amount", HasHeader: true}, 2},
+		{"empty", "", CSVMapping{Date: "date", Amount: "amount", HasHeader: true}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCSV(strings.NewReader(tt.csv), tt.mapping)
+			var perr *ParseError
+			if !errors.As(err, &perr) || perr.Line != tt.line {
+				t.Fatalf("err = %v, want a parse error on line %d", err, tt.line)
+			}
+		})
+	}
+
+	if _, err := ParseCSV(strings.NewReader("x"), CSVMapping{Date: "0"}); !errors.Is(err, ErrInvalidMapping) {
+		t.Errorf("mapping without amounts: err = %v", err)
+	}
+	if _, err := ParseCSV(strings.NewReader("a,b\n"), CSVMapping{Date: "date", Amount: "b", HasHeader: true}); err == nil {
+		t.Error("missing date column accepted")
+	}
+}
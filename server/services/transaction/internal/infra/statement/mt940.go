@@ -0,0 +1,138 @@
+package statement
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"transaction/internal/domain/model"
+)
+
+var (
+	// :61: value date YYMMDD, optional entry date MMDD, mark (C, D, RC, RD),
+	// optional funds code, amount with decimal comma
+	mt940LineRe    = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)([A-Z])?(\d+,\d*)`)
+	mt940BalanceRe = regexp.MustCompile(`^[CD]\d{6}([A-Z]{3})`)
+	mt940TagRe     = regexp.MustCompile(`^:(\d{2}[A-Z]?):(.*)$`)
+)
+
+type mt940Field struct {
+	tag   string
+	value string
+	line  int
+}
+
+// ParseMT940 reads SWIFT MT940 statements. Each :61: statement line becomes a
+// transaction; the following :86: field, if any, is its description.
+func ParseMT940(r io.Reader) ([]model.Transaction, error) {
+	fields, err := readMT940Fields(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		txs      []model.Transaction
+		currency string
+		pending  *model.Transaction
+	)
+	flush := func() {
+		if pending != nil {
+			txs = append(txs, *pending)
+			pending = nil
+		}
+	}
+
+	for _, f := range fields {
+		switch f.tag {
+		case "60F", "60M":
+			if m := mt940BalanceRe.FindStringSubmatch(f.value); m != nil {
+				currency = m[1]
+			}
+		case "61":
+			flush()
+			tx, ok, err := parseMT940Line(f, currency)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				pending = &tx
+			}
+		case "86":
+			if pending != nil {
+				pending.Description = strings.Join(strings.Fields(f.value), " ")
+				flush()
+			}
+		}
+	}
+	flush()
+
+	if len(fields) == 0 {
+		return nil, &ParseError{Line: 1, Msg: "no MT940 fields found"}
+	}
+	return txs, nil
+}
+
+func parseMT940Line(f mt940Field, currency string) (model.Transaction, bool, error) {
+	// Supplementary details follow on continuation lines and are not needed
+	first, rest, _ := strings.Cut(f.value, "\n")
+	m := mt940LineRe.FindStringSubmatch(first)
+	if m == nil {
+		return model.Transaction{}, false, &ParseError{Line: f.line, Msg: fmt.Sprintf("invalid :61: field %q", first)}
+	}
+
+	date, err := time.Parse("060102", m[1])
+	if err != nil {
+		return model.Transaction{}, false, &ParseError{Line: f.line, Msg: fmt.Sprintf("invalid value date %q", m[1])}
+	}
+	amount, err := parseAmount(m[5], true)
+	if err != nil {
+		return model.Transaction{}, false, &ParseError{Line: f.line, Msg: fmt.Sprintf("invalid amount %q", m[5])}
+	}
+	// Debits and reversals of credits take money out
+	if m[3] == "D" || m[3] == "RC" {
+		amount = amount.Neg()
+	}
+
+	// Without a :86: field the reference after // is the best description
+	description := ""
+	if _, ref, ok := strings.Cut(first, "//"); ok {
+		description = ref
+	}
+	if rest != "" {
+		description = strings.TrimSpace(description + " " + rest)
+	}
+
+	tx, ok := newTransaction(date, amount, currency, description)
+	return tx, ok, nil
+}
+
+// readMT940Fields splits the message into tagged fields, joining continuation
+// lines onto the field they belong to.
+func readMT940Fields(r io.Reader) ([]mt940Field, error) {
+	var fields []mt940Field
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" || line == "-" || strings.HasPrefix(line, "{") {
+			continue
+		}
+		if m := mt940TagRe.FindStringSubmatch(line); m != nil {
+			fields = append(fields, mt940Field{tag: m[1], value: m[2], line: lineNo})
+			continue
+		}
+		if len(fields) > 0 {
+			fields[len(fields)-1].value += "\n" + line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
@@ -0,0 +1,62 @@
+package statement
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"transaction/internal/domain/model"
+)
+
+func TestParseMT940(t *testing.T) {
+	const mt940 = "{1:F01BANKRUMMAXXX0000000000}{4:\r\n" +
+		":20:STATEMENT1\r\n" +
+		":25:40702810000000000001\r\n" +
+		":60F:C260228RUB100000,00\r\n" +
+		":61:2603010301D1500,50NTRFNONREF//PAY-1\r\n" +
+		":86:Rent for\r\n" +
+		"March\r\n" +
+		":61:260302C250000,NTRFNONREF//SALARY-03\r\n" +
+		":61:260303RC100,00NTRFNONREF\r\n" +
+		":86:Refund reversed\r\n" +
+		":62F:C260303RUB348399,50\r\n" +
+		"-}\r\n"
+
+	txs, err := ParseMT940(strings.NewReader(mt940))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		date, amount, description string
+		typ                       model.TransactionType
+	}{
+		{"2026-03-01", "1500.5", "Rent for March", model.TransactionTypeExpense},
+		// Without :86: the reference is the description
+		{"2026-03-02", "250000", "SALARY-03", model.TransactionTypeIncome},
+		// A reversed credit takes money out
+		{"2026-03-03", "100", "Refund reversed", model.TransactionTypeExpense},
+	}
+	if len(txs) != len(want) {
+		t.Fatalf("got %d transactions, want %d: %+v", len(txs), len(want), txs)
+	}
+	for i, w := range want {
+		tx := txs[i]
+		if tx.TransactionDate.Format("2006-01-02") != w.date || tx.Amount.String() != w.amount ||
+			tx.Currency != "RUB" || tx.Description != w.description || tx.Type != w.typ {
+			t.Errorf("transaction %d = %s %s %s %q %s, want %+v", i,
+				tx.TransactionDate.Format("2006-01-02"), tx.Amount, tx.Currency, tx.Description, tx.Type, w)
+		}
+	}
+}
+
+func TestParseMT940Errors(t *testing.T) {
+	if _, err := ParseMT940(strings.NewReader("not a statement\n")); err == nil {
+		t.Fatal("parsed a file without fields")
+	}
+
+	_, err := ParseMT940(strings.NewReader(":20:X\n:60F:C260228RUB0,00\n:61:2603XXD1,00NTRF\n"))
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Line != 3 {
+		t.Fatalf("err = %v, want a parse error on line 3", err)
+	}
+}
@@ -0,0 +1,119 @@
+package statement
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"transaction/internal/domain/model"
+)
+
+var (
+	ofxTransactionRe = regexp.MustCompile(`(?i)<STMTTRN>`)
+	ofxBlockEndRe    = regexp.MustCompile(`(?i)</STMTTRN>|</BANKTRANLIST>`)
+	ofxCurrencyRe    = regexp.MustCompile(`(?i)<CURDEF>\s*([A-Za-z]{3})`)
+	// OFX allows a comma as the decimal mark; a lone comma before one or two
+	// trailing digits is read as one rather than as a thousands separator
+	ofxDecimalCommaRe = regexp.MustCompile(`^[^.,]*,\d{1,2}$`)
+
+	ofxDatePostedRe = ofxFieldRe("DTPOSTED")
+	ofxAmountRe     = ofxFieldRe("TRNAMT")
+	ofxNameRe       = ofxFieldRe("NAME")
+	ofxMemoRe       = ofxFieldRe("MEMO")
+	ofxCurSymRe     = ofxFieldRe("CURSYM")
+)
+
+// ParseOFX reads OFX 1.x (SGML) and 2.x (XML) statements; QFX is OFX with
+// Quicken headers and goes through the same path.
+func ParseOFX(r io.Reader) ([]model.Transaction, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	body := string(data)
+
+	currency := ""
+	if m := ofxCurrencyRe.FindStringSubmatch(body); m != nil {
+		currency = m[1]
+	}
+
+	// SGML OFX may leave <STMTTRN> unclosed, so a record runs until its
+	// closing tag, the end of the list or the next record
+	starts := ofxTransactionRe.FindAllStringIndex(body, -1)
+	if len(starts) == 0 {
+		return nil, &ParseError{Line: 1, Msg: "no <STMTTRN> records found"}
+	}
+
+	var txs []model.Transaction
+	for i, start := range starts {
+		end := len(body)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		block := body[start[1]:end]
+		if loc := ofxBlockEndRe.FindStringIndex(block); loc != nil {
+			block = block[:loc[0]]
+		}
+		line := strings.Count(body[:start[0]], "\n") + 1
+
+		date, err := parseOFXDate(ofxField(block, ofxDatePostedRe))
+		if err != nil {
+			return nil, &ParseError{Line: line, Msg: err.Error()}
+		}
+		rawAmount := ofxField(block, ofxAmountRe)
+		amount, err := parseAmount(rawAmount, ofxDecimalCommaRe.MatchString(rawAmount))
+		if err != nil {
+			return nil, &ParseError{Line: line, Msg: fmt.Sprintf("invalid TRNAMT %q", rawAmount)}
+		}
+
+		description := ofxField(block, ofxNameRe)
+		if memo := ofxField(block, ofxMemoRe); memo != "" && memo != description {
+			description = strings.TrimSpace(description + " " + memo)
+		}
+
+		trnCurrency := currency
+		if c := ofxField(block, ofxCurSymRe); c != "" {
+			trnCurrency = c
+		}
+
+		if tx, ok := newTransaction(date, amount, trnCurrency, description); ok {
+			txs = append(txs, tx)
+		}
+	}
+	return txs, nil
+}
+
+// ofxFieldRe matches an element and captures its value, whether or not it
+// is closed.
+func ofxFieldRe(tag string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)<` + tag + `>([^<\r\n]*)`)
+}
+
+// ofxField returns the value of the element re matches.
+func ofxField(block string, re *regexp.Regexp) string {
+	m := re.FindStringSubmatch(block)
+	if m == nil {
+		return ""
+	}
+	return unescapeOFX(strings.TrimSpace(m[1]))
+}
+
+var ofxEntities = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'")
+
+func unescapeOFX(s string) string {
+	return ofxEntities.Replace(s)
+}
+
+// parseOFXDate reads YYYYMMDD[HHMMSS[.XXX]][[offset:TZ]]; only the date part
+// matters for bookkeeping.
+func parseOFXDate(s string) (time.Time, error) {
+	if len(s) < 8 {
+		return time.Time{}, fmt.Errorf("invalid DTPOSTED %q", s)
+	}
+	date, err := time.Parse("20060102", s[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid DTPOSTED %q", s)
+	}
+	return date, nil
+}
@@ -0,0 +1,123 @@
+package statement
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"transaction/internal/domain/model"
+)
+
+func TestParseOFX(t *testing.T) {
+	// SGML OFX leaves elements and even records unclosed
+	const sgml = `OFXHEADER:100
+DATA:OFXSGML
+
+<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>RUB
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20260301120000.000[+3:MSK]
+<TRNAMT>-1,234.50
+<NAME>Coffee &amp; Co
+<MEMO>card 1234
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20260302
+<TRNAMT>50000
+<NAME>Salary
+<MEMO>Salary
+</STMTTRN>
+<STMTTRN>
+<DTPOSTED>20260303
+<TRNAMT>0.00
+<NAME>Card check
+</STMTTRN>
+<STMTTRN>
+<DTPOSTED>20260304
+<TRNAMT>-20
+<NAME>Exchange
+<CURRENCY><CURSYM>USD</CURSYM></CURRENCY>
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>
+`
+	txs, err := ParseOFX(strings.NewReader(sgml))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		date, amount, currency, description string
+		typ                                 model.TransactionType
+	}{
+		{"2026-03-01", "1234.5", "RUB", "Coffee & Co card 1234", model.TransactionTypeExpense},
+		// A memo repeating the name isn't added twice
+		{"2026-03-02", "50000", "RUB", "Salary", model.TransactionTypeIncome},
+		// The zero amount line is skipped
+		{"2026-03-04", "20", "USD", "Exchange", model.TransactionTypeExpense},
+	}
+	if len(txs) != len(want) {
+		t.Fatalf("got %d transactions, want %d: %+v", len(txs), len(want), txs)
+	}
+	for i, w := range want {
+		tx := txs[i]
+		if tx.TransactionDate.Format("2006-01-02") != w.date || tx.Amount.String() != w.amount ||
+			tx.Currency != w.currency || tx.Description != w.description || tx.Type != w.typ {
+			t.Errorf("transaction %d = %s %s %s %q %s, want %+v", i,
+				tx.TransactionDate.Format("2006-01-02"), tx.Amount, tx.Currency, tx.Description, tx.Type, w)
+		}
+	}
+}
+
+func TestParseOFXAmounts(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"-12.50", "-12.5"},
+		{"-12,50", "-12.5"},
+		{"-12,5", "-12.5"},
+		{"1 234,56", "1234.56"},
+		{"1,234", "1234"},
+		{"-1,234.56", "-1234.56"},
+		{"100", "100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			ofx := "<CURDEF>RUB<STMTTRN><DTPOSTED>20260301<TRNAMT>" + tt.raw + "<NAME>x</STMTTRN>"
+			txs, err := ParseOFX(strings.NewReader(ofx))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := txs[0].Amount
+			if txs[0].Type == model.TransactionTypeExpense {
+				got = got.Neg()
+			}
+			if got.String() != tt.want {
+				t.Errorf("amount = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOFXErrors(t *testing.T) {
+	tests := []struct {
+		name, ofx string
+		line      int
+		msg       string
+	}{
+		{"no records", "<OFX></OFX>", 1, "no <STMTTRN> records"},
+		{"bad date", "<OFX>\n<STMTTRN><DTPOSTED>2026<TRNAMT>1</STMTTRN>", 2, "invalid DTPOSTED"},
+		{"bad amount", "<OFX>\n\n<STMTTRN><DTPOSTED>20260301<TRNAMT>abc</STMTTRN>", 3, "invalid TRNAMT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseOFX(strings.NewReader(tt.ofx))
+			var perr *ParseError
+			if !errors.As(err, &perr) || perr.Line != tt.line || !strings.Contains(perr.Msg, tt.msg) {
+				t.Fatalf("err = %v, want line %d: %s", err, tt.line, tt.msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Package statement parses bank statement files into transactions. Parsed
+// transactions carry only date, type, amount, currency and description; the
+// caller assigns the account and user.
+package statement
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+	"transaction/internal/domain/model"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrUnknownFormat = errors.New("unknown statement format")
+
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatOFX   Format = "ofx"
+	FormatQFX   Format = "qfx"
+	FormatMT940 Format = "mt940"
+)
+
+func IsValidFormat(f Format) bool {
+	switch f {
+	case FormatCSV, FormatOFX, FormatQFX, FormatMT940:
+		return true
+	}
+	return false
+}
+
+// DetectFormat guesses the format from the file extension.
+func DetectFormat(filename string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv", ".txt":
+		return FormatCSV, nil
+	case ".ofx":
+		return FormatOFX, nil
+	case ".qfx":
+		return FormatQFX, nil
+	case ".sta", ".940", ".mt940":
+		return FormatMT940, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// ParseError points at the line of the statement that could not be read.
+type ParseError struct {
+	Line int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// newTransaction turns a signed statement amount into an income or expense.
+// Zero amounts carry no movement and are reported as skipped.
+func newTransaction(date time.Time, signed decimal.Decimal, currency, description string) (model.Transaction, bool) {
+	if signed.IsZero() {
+		return model.Transaction{}, false
+	}
+	txType := model.TransactionTypeIncome
+	if signed.IsNegative() {
+		txType = model.TransactionTypeExpense
+	}
+	return model.Transaction{
+		Type:            txType,
+		Amount:          signed.Abs(),
+		Currency:        strings.ToUpper(strings.TrimSpace(currency)),
+		Description:     strings.Join(strings.Fields(description), " "),
+		TransactionDate: date,
+		Status:          model.TransactionStatusCompleted,
+	}, true
+}
+
+// parseAmount accepts grouping spaces, a leading currency-less sign and
+// accounting-style parentheses for negatives.
+func parseAmount(s string, decimalComma bool) (decimal.Decimal, error) {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\u00a0', '\u202f', '\'':
+			return -1
+		}
+		return r
+	}, s)
+
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = s[1 : len(s)-1]
+	}
+	if decimalComma {
+		s = strings.ReplaceAll(s, ".", "")
+		s = strings.ReplaceAll(s, ",", ".")
+	} else {
+		s = strings.ReplaceAll(s, ",", "")
+	}
+
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if negative {
+		d = d.Neg()
+	}
+	return d, nil
+}
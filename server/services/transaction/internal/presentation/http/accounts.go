@@ -1,6 +1,7 @@
 package http
 
 import (
+	"errors"
 	"net/http"
 	"transaction/internal/domain/model"
 	"transaction/internal/domain/service"
@@ -24,6 +25,7 @@ func NewAccountHTTP(r *gin.Engine, s *service.AccountService) {
 	{
 		accounts.GET("", h.GetAccounts)
 		accounts.POST("", h.CreateAccount)
+		accounts.GET("/total", h.GetTotalBalance)
 		accounts.GET("/:id", h.GetAccount)
 		accounts.PUT("/:id", h.UpdateAccount)
 		accounts.DELETE("/:id", h.DeleteAccount)
@@ -46,6 +48,35 @@ func (h *AccountHTTP) GetAccounts(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, dto.AccountListFromModel(accounts))
 }
 
+func (h *AccountHTTP) GetTotalBalance(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	total, err := h.service.GetTotalBalance(userID.(uint), ctx.Query("currency"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrRateNotFound) {
+			status = http.StatusUnprocessableEntity
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	byCurrency := make(map[string]string, len(total.ByCurrency))
+	for c, amount := range total.ByCurrency {
+		byCurrency[c] = amount.String()
+	}
+
+	ctx.JSON(http.StatusOK, dto.TotalBalanceResponse{
+		Currency:   total.Currency,
+		Total:      total.Total.String(),
+		ByCurrency: byCurrency,
+	})
+}
+
 func (h *AccountHTTP) GetAccount(ctx *gin.Context) {
 	var uri struct {
 		ID uint `uri:"id" binding:"required"`
@@ -1,8 +1,10 @@
 package http
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 	"transaction/internal/domain/service"
 	"transaction/internal/presentation/http/dto"
@@ -25,6 +27,12 @@ func NewAnalyticsHTTP(r *gin.Engine, s *service.AnalyticsService) {
 		analytics.GET("/insights/trends", h.GetTrends)
 		analytics.GET("/insights/top-categories", h.GetTopCategories)
 	}
+
+	categories := r.Group("/categories")
+	categories.Use(middleware.AuthMiddleware())
+	{
+		categories.GET("/tree", h.GetCategoryTree)
+	}
 }
 
 func (h *AnalyticsHTTP) GetSummary(ctx *gin.Context) {
@@ -65,9 +73,9 @@ func (h *AnalyticsHTTP) GetSummary(ctx *gin.Context) {
 		to = time.Now()
 	}
 
-	summary, err := h.service.GetSummary(userID.(uint), from, to)
+	summary, err := h.service.GetSummary(userID.(uint), from, to, ctx.Query("currency"), rollupQuery(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(analyticsErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -100,6 +108,7 @@ func (h *AnalyticsHTTP) GetSummary(ctx *gin.Context) {
 	net := summary.TotalIncome.Sub(summary.TotalExpense)
 
 	ctx.JSON(http.StatusOK, dto.TransactionSummaryResponse{
+		Currency:     summary.Currency,
 		TotalIncome:  summary.TotalIncome.String(),
 		TotalExpense: summary.TotalExpense.String(),
 		Net:          net.String(),
@@ -127,9 +136,9 @@ func (h *AnalyticsHTTP) GetTrends(ctx *gin.Context) {
 	from := time.Date(now.Year(), now.Month()-time.Month(months-1), 1, 0, 0, 0, 0, time.UTC)
 	to := now
 
-	summary, err := h.service.GetSummary(userID.(uint), from, to)
+	summary, err := h.service.GetSummary(userID.(uint), from, to, ctx.Query("currency"), true)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(analyticsErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -151,7 +160,7 @@ func (h *AnalyticsHTTP) GetTrends(ctx *gin.Context) {
 		}
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"trends": trends})
+	ctx.JSON(http.StatusOK, gin.H{"currency": summary.Currency, "trends": trends})
 }
 
 func (h *AnalyticsHTTP) GetTopCategories(ctx *gin.Context) {
@@ -184,9 +193,9 @@ func (h *AnalyticsHTTP) GetTopCategories(ctx *gin.Context) {
 		}
 	}
 
-	summary, err := h.service.GetSummary(userID.(uint), from, to)
+	summary, err := h.service.GetSummary(userID.(uint), from, to, ctx.Query("currency"), rollupQuery(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(analyticsErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -208,5 +217,76 @@ func (h *AnalyticsHTTP) GetTopCategories(ctx *gin.Context) {
 		}
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"categories": categories})
+	ctx.JSON(http.StatusOK, gin.H{"currency": summary.Currency, "categories": categories})
+}
+
+// GetCategoryTree returns nested categories with totals for ?from=..?to=
+// (default: the current month), subcategory spending rolled up into parents.
+func (h *AnalyticsHTTP) GetCategoryTree(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := now
+
+	if f := ctx.Query("from"); f != "" {
+		parsed, err := time.Parse("2006-01-02", f)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'from' date format, use YYYY-MM-DD"})
+			return
+		}
+		from = parsed
+	}
+	if t := ctx.Query("to"); t != "" {
+		parsed, err := time.Parse("2006-01-02", t)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'to' date format, use YYYY-MM-DD"})
+			return
+		}
+		to = parsed.Add(24*time.Hour - time.Nanosecond)
+	}
+
+	currency := strings.ToUpper(ctx.Query("currency"))
+	if currency == "" {
+		currency = service.DefaultReportingCurrency
+	}
+
+	roots, err := h.service.GetCategoryTree(userID.(uint), from, to, currency)
+	if err != nil {
+		ctx.JSON(analyticsErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"currency": currency, "categories": categoryTreeResponse(roots)})
+}
+
+func categoryTreeResponse(nodes []*service.CategoryNode) []dto.CategoryTreeNode {
+	res := make([]dto.CategoryTreeNode, len(nodes))
+	for i, n := range nodes {
+		res[i] = dto.CategoryTreeNode{
+			CategoryResponse: dto.CategoryFromModel(n.Category),
+			OwnTotal:         n.OwnTotal.String(),
+			Total:            n.Total.String(),
+			Count:            n.Count,
+			Children:         categoryTreeResponse(n.Children),
+		}
+	}
+	return res
+}
+
+// rollupQuery reads ?rollup=, which defaults to true.
+func rollupQuery(ctx *gin.Context) bool {
+	rollup, err := strconv.ParseBool(ctx.DefaultQuery("rollup", "true"))
+	return err != nil || rollup
+}
+
+func analyticsErrorStatus(err error) int {
+	if errors.Is(err, service.ErrRateNotFound) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
 }
@@ -1,7 +1,10 @@
 package http
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 	"transaction/internal/domain/model"
 	"transaction/internal/domain/service"
 	"transaction/internal/presentation/http/dto"
@@ -26,6 +29,7 @@ func NewBudgetHTTP(r *gin.Engine, s *service.BudgetService) {
 		budgets.PUT("/:id", h.UpdateBudget)
 		budgets.DELETE("/:id", h.DeleteBudget)
 		budgets.GET("/status", h.GetBudgetStatus)
+		budgets.GET("/:id/history", h.GetBudgetHistory)
 	}
 }
 
@@ -54,12 +58,22 @@ func (h *BudgetHTTP) CreateBudget(ctx *gin.Context) {
 		currency = req.Currency
 	}
 
+	anchor, err := parseBudgetAnchor(req.Anchor)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid anchor format, use YYYY-MM-DD"})
+		return
+	}
+
 	budget := &model.Budget{
-		UserID:     userID.(uint),
-		CategoryID: req.CategoryID,
-		Amount:     amount,
-		Currency:   currency,
-		Period:     period,
+		UserID:          userID.(uint),
+		CategoryID:      req.CategoryID,
+		Amount:          amount,
+		Currency:        currency,
+		Period:          period,
+		Anchor:          anchor,
+		PeriodDays:      req.PeriodDays,
+		Rollover:        req.Rollover,
+		AlertThresholds: req.AlertThresholds,
 	}
 
 	created, err := h.service.CreateBudget(budget)
@@ -110,9 +124,28 @@ func (h *BudgetHTTP) UpdateBudget(ctx *gin.Context) {
 		}
 	}
 
-	budget, err := h.service.UpdateBudget(uri.ID, userID.(uint), amount, model.BudgetPeriod(req.Period))
+	anchor, err := parseBudgetAnchor(req.Anchor)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid anchor format, use YYYY-MM-DD"})
+		return
+	}
+
+	budget, err := h.service.UpdateBudget(uri.ID, userID.(uint), service.BudgetChanges{
+		Amount:          amount,
+		Period:          model.BudgetPeriod(req.Period),
+		Anchor:          anchor,
+		PeriodDays:      req.PeriodDays,
+		Rollover:        req.Rollover,
+		AlertThresholds: req.AlertThresholds,
+	})
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrBudgetNotFound {
+			status = http.StatusNotFound
+		} else if err == service.ErrBudgetAccessDenied {
+			status = http.StatusForbidden
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -138,15 +171,64 @@ func (h *BudgetHTTP) DeleteBudget(ctx *gin.Context) {
 	ctx.JSON(http.StatusNoContent, nil)
 }
 
+// GetBudgetStatus reports every budget in its period containing ?date=
+// (YYYY-MM-DD, default today).
 func (h *BudgetHTTP) GetBudgetStatus(ctx *gin.Context) {
 	userID, _ := ctx.Get("userID")
 
-	statuses, err := h.service.GetBudgetStatus(userID.(uint))
+	ref, err := parseReferenceDate(ctx.Query("date"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+		return
+	}
+
+	statuses, err := h.service.GetBudgetStatus(userID.(uint), ctx.Query("currency"), ref)
+	if err != nil {
+		ctx.JSON(budgetStatusErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
+	ctx.JSON(http.StatusOK, budgetStatusResponses(statuses))
+}
+
+// GetBudgetHistory lists the last ?periods= periods (default 12) of a budget
+// up to the one containing ?date=.
+func (h *BudgetHTTP) GetBudgetHistory(ctx *gin.Context) {
+	var uri struct {
+		ID uint `uri:"id" binding:"required"`
+	}
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	userID, _ := ctx.Get("userID")
+
+	ref, err := parseReferenceDate(ctx.Query("date"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+		return
+	}
+
+	periods := 12
+	if p := ctx.Query("periods"); p != "" {
+		periods, err = strconv.Atoi(p)
+		if err != nil || periods < 1 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "periods must be a positive integer"})
+			return
+		}
+	}
+
+	statuses, err := h.service.GetBudgetHistory(uri.ID, userID.(uint), ctx.Query("currency"), ref, periods)
+	if err != nil {
+		ctx.JSON(budgetStatusErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, budgetStatusResponses(statuses))
+}
+
+func budgetStatusResponses(statuses []service.BudgetStatus) []dto.BudgetStatusResponse {
 	response := make([]dto.BudgetStatusResponse, len(statuses))
 	for i, s := range statuses {
 		response[i] = dto.BudgetStatusResponse{
@@ -156,13 +238,46 @@ func (h *BudgetHTTP) GetBudgetStatus(ctx *gin.Context) {
 			CategoryIcon:  s.CategoryIcon,
 			CategoryColor: s.CategoryColor,
 			BudgetAmount:  s.BudgetAmount.String(),
+			Carried:       s.Carried.String(),
+			Available:     s.Available.String(),
 			SpentAmount:   s.SpentAmount.String(),
 			Remaining:     s.Remaining.String(),
 			SpentPercent:  s.SpentPercent.StringFixed(1),
 			Period:        s.Period,
+			PeriodStart:   s.PeriodStart,
+			PeriodEnd:     s.PeriodEnd,
 			Currency:      s.Currency,
 		}
 	}
+	return response
+}
+
+func budgetStatusErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrBudgetNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrBudgetAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrRateNotFound):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+func parseReferenceDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Now(), nil
+	}
+	return time.Parse("2006-01-02", s)
+}
 
-	ctx.JSON(http.StatusOK, response)
+func parseBudgetAnchor(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	anchor, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, err
+	}
+	return &anchor, nil
 }
@@ -0,0 +1,247 @@
+package http
+
+import (
+	"net/http"
+	"transaction/internal/domain/model"
+	"transaction/internal/domain/service"
+	"transaction/internal/presentation/http/dto"
+	"transaction/internal/presentation/http/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryRuleHTTP struct {
+	service *service.CategoryRuleService
+}
+
+func NewCategoryRuleHTTP(r *gin.Engine, s *service.CategoryRuleService) {
+	h := &CategoryRuleHTTP{service: s}
+
+	rules := r.Group("/category-rules")
+	rules.Use(middleware.AuthMiddleware())
+	{
+		rules.GET("", h.GetRules)
+		rules.POST("", h.CreateRule)
+		rules.POST("/apply", h.ApplyRules)
+		rules.GET("/:id", h.GetRule)
+		rules.PUT("/:id", h.UpdateRule)
+		rules.DELETE("/:id", h.DeleteRule)
+	}
+}
+
+func (h *CategoryRuleHTTP) GetRules(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	rules, err := h.service.GetByUser(userID.(uint))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.CategoryRuleListFromModel(rules))
+}
+
+func (h *CategoryRuleHTTP) GetRule(ctx *gin.Context) {
+	var uri struct {
+		ID uint `uri:"id" binding:"required"`
+	}
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	rule, err := h.service.GetByID(uri.ID, userID.(uint))
+	if err != nil {
+		ctx.JSON(categoryRuleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.CategoryRuleFromModel(*rule))
+}
+
+func (h *CategoryRuleHTTP) CreateRule(ctx *gin.Context) {
+	var req dto.CategoryRuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	rule := &model.CategoryRule{UserID: userID.(uint)}
+	if err := applyCategoryRuleRequest(rule, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid amount format"})
+		return
+	}
+
+	created, err := h.service.Create(rule)
+	if err != nil {
+		ctx.JSON(categoryRuleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, dto.CategoryRuleFromModel(*created))
+}
+
+func (h *CategoryRuleHTTP) UpdateRule(ctx *gin.Context) {
+	var uri struct {
+		ID uint `uri:"id" binding:"required"`
+	}
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req dto.CategoryRuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	rule, err := h.service.GetByID(uri.ID, userID.(uint))
+	if err != nil {
+		ctx.JSON(categoryRuleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if err := applyCategoryRuleRequest(rule, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid amount format"})
+		return
+	}
+
+	updated, err := h.service.Update(rule)
+	if err != nil {
+		ctx.JSON(categoryRuleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.CategoryRuleFromModel(*updated))
+}
+
+func (h *CategoryRuleHTTP) DeleteRule(ctx *gin.Context) {
+	var uri struct {
+		ID uint `uri:"id" binding:"required"`
+	}
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := h.service.Delete(uri.ID, userID.(uint)); err != nil {
+		ctx.JSON(categoryRuleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "category rule deleted"})
+}
+
+// ApplyRules re-categorises existing transactions. With dry_run the changes
+// are only listed.
+func (h *CategoryRuleHTTP) ApplyRules(ctx *gin.Context) {
+	var req dto.RecategorizeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	changes, err := h.service.Recategorize(userID.(uint), service.RecategorizeOptions{
+		RuleID:    req.RuleID,
+		Overwrite: req.Overwrite,
+		DryRun:    req.DryRun,
+	})
+	if err != nil {
+		ctx.JSON(categoryRuleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	res := make([]dto.RecategorizationResponse, len(changes))
+	for i, c := range changes {
+		res[i] = dto.RecategorizationResponse{
+			TransactionID:   c.TransactionID,
+			Description:     c.Description,
+			Amount:          c.Amount.String(),
+			TransactionDate: c.TransactionDate,
+			OldCategoryID:   c.OldCategoryID,
+			NewCategoryID:   c.NewCategoryID,
+			OldAccountID:    c.OldAccountID,
+			NewAccountID:    c.NewAccountID,
+			RuleID:          c.RuleID,
+		}
+	}
+
+	ctx.JSON(http.StatusOK, dto.RecategorizeResponse{
+		DryRun:  req.DryRun,
+		Count:   len(res),
+		Changes: res,
+	})
+}
+
+func applyCategoryRuleRequest(rule *model.CategoryRule, req *dto.CategoryRuleRequest) error {
+	minAmount, maxAmount, err := req.ParseAmounts()
+	if err != nil {
+		return err
+	}
+	rule.Name = req.Name
+	rule.Priority = req.Priority
+	rule.DescriptionContains = req.DescriptionContains
+	rule.MinAmount = minAmount
+	rule.MaxAmount = maxAmount
+	rule.AccountID = req.AccountID
+	rule.Type = model.TransactionType(req.Type)
+	rule.CategoryID = req.CategoryID
+	rule.SetAccountID = req.SetAccountID
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+	return nil
+}
+
+func categoryRuleErrorStatus(err error) int {
+	switch err {
+	case service.ErrRuleNoConditions,
+		service.ErrInvalidTransactionType,
+		service.ErrInvalidAmount,
+		service.ErrInvalidAmountRange:
+		return http.StatusBadRequest
+	case service.ErrRuleNotFound,
+		service.ErrCategoryNotFound,
+		service.ErrAccountNotFound:
+		return http.StatusNotFound
+	case service.ErrRuleAccessDenied,
+		service.ErrCategoryAccessDenied,
+		service.ErrAccountAccessDenied:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
@@ -7,15 +7,16 @@ import (
 )
 
 type AccountResponse struct {
-	ID        uint   `json:"id"`
-	Type      string `json:"type"`
-	Name      string `json:"name"`
-	Currency  string `json:"currency"`
-	Balance   string `json:"balance"`
-	Icon      string `json:"icon"`
-	Color     string `json:"color"`
-	IsActive  bool   `json:"is_active"`
-	SortOrder int    `json:"sort_order"`
+	ID             uint   `json:"id"`
+	Type           string `json:"type"`
+	Name           string `json:"name"`
+	Currency       string `json:"currency"`
+	Balance        string `json:"balance"`
+	OpeningBalance string `json:"opening_balance"`
+	Icon           string `json:"icon"`
+	Color          string `json:"color"`
+	IsActive       bool   `json:"is_active"`
+	SortOrder      int    `json:"sort_order"`
 }
 
 type CreateAccountRequest struct {
@@ -44,15 +45,16 @@ func (r *CreateAccountRequest) ParseBalance() (decimal.Decimal, error) {
 
 func AccountFromModel(a model.Account) AccountResponse {
 	return AccountResponse{
-		ID:        a.ID,
-		Type:      string(a.Type),
-		Name:      a.Name,
-		Currency:  a.Currency,
-		Balance:   a.Balance.String(),
-		Icon:      a.Icon,
-		Color:     a.Color,
-		IsActive:  a.IsActive,
-		SortOrder: a.SortOrder,
+		ID:             a.ID,
+		Type:           string(a.Type),
+		Name:           a.Name,
+		Currency:       a.Currency,
+		Balance:        a.Balance.String(),
+		OpeningBalance: a.OpeningBalance.String(),
+		Icon:           a.Icon,
+		Color:          a.Color,
+		IsActive:       a.IsActive,
+		SortOrder:      a.SortOrder,
 	}
 }
 
@@ -63,3 +65,15 @@ func AccountListFromModel(accounts []model.Account) []AccountResponse {
 	}
 	return res
 }
+
+type ReconciliationResponse struct {
+	AccountID       uint   `json:"account_id"`
+	AccountName     string `json:"account_name"`
+	Currency        string `json:"currency"`
+	OpeningBalance  string `json:"opening_balance"`
+	LedgerNet       string `json:"ledger_net"`
+	ExpectedBalance string `json:"expected_balance"`
+	CachedBalance   string `json:"cached_balance"`
+	Difference      string `json:"difference"`
+	Balanced        bool   `json:"balanced"`
+}
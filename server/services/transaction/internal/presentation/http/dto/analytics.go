@@ -29,9 +29,18 @@ type TrendItem struct {
 }
 
 type TransactionSummaryResponse struct {
+	Currency     string            `json:"currency"`
 	TotalIncome  string            `json:"total_income"`
 	TotalExpense string            `json:"total_expense"`
 	Net          string            `json:"net"`
 	ByCategory   []CategorySummary `json:"by_category"`
 	ByMonth      []MonthlySummary  `json:"by_month"`
 }
+
+type CategoryTreeNode struct {
+	CategoryResponse
+	OwnTotal string             `json:"own_total"`
+	Total    string             `json:"total"`
+	Count    int64              `json:"count"`
+	Children []CategoryTreeNode `json:"children"`
+}
@@ -1,49 +1,82 @@
 package dto
 
-import "transaction/internal/domain/model"
+import (
+	"time"
+	"transaction/internal/domain/model"
+)
 
 type BudgetResponse struct {
-	ID         uint              `json:"id"`
-	CategoryID uint              `json:"category_id"`
-	Category   *CategoryResponse `json:"category,omitempty"`
-	Amount     string            `json:"amount"`
-	Currency   string            `json:"currency"`
-	Period     string            `json:"period"`
+	ID              uint              `json:"id"`
+	CategoryID      uint              `json:"category_id"`
+	Category        *CategoryResponse `json:"category,omitempty"`
+	Amount          string            `json:"amount"`
+	Currency        string            `json:"currency"`
+	Period          string            `json:"period"`
+	Anchor          string            `json:"anchor,omitempty"`
+	PeriodDays      int               `json:"period_days,omitempty"`
+	Rollover        bool              `json:"rollover"`
+	AlertThresholds []int             `json:"alert_thresholds"`
 }
 
+// CreateBudgetRequest: anchor (YYYY-MM-DD) is the first day of any one
+// period, e.g. a payday; custom periods also need period_days.
+// alert_thresholds, e.g. [80, 100], queue a notification when spending
+// reaches that percentage of the period's available amount.
 type CreateBudgetRequest struct {
-	CategoryID uint   `json:"category_id" binding:"required"`
-	Amount     string `json:"amount" binding:"required"`
-	Currency   string `json:"currency"`
-	Period     string `json:"period"`
+	CategoryID      uint   `json:"category_id" binding:"required"`
+	Amount          string `json:"amount" binding:"required"`
+	Currency        string `json:"currency"`
+	Period          string `json:"period"`
+	Anchor          string `json:"anchor"`
+	PeriodDays      int    `json:"period_days" binding:"min=0"`
+	Rollover        bool   `json:"rollover"`
+	AlertThresholds []int  `json:"alert_thresholds"`
 }
 
 type UpdateBudgetRequest struct {
-	Amount string `json:"amount"`
-	Period string `json:"period"`
+	Amount     string `json:"amount"`
+	Period     string `json:"period"`
+	Anchor     string `json:"anchor"`
+	PeriodDays *int   `json:"period_days" binding:"omitempty,min=0"`
+	Rollover   *bool  `json:"rollover"`
+	// AlertThresholds replaces the thresholds when present; [] clears them
+	AlertThresholds *[]int `json:"alert_thresholds"`
 }
 
 type BudgetStatusResponse struct {
-	BudgetID      uint   `json:"budget_id"`
-	CategoryID    uint   `json:"category_id"`
-	CategoryName  string `json:"category_name"`
-	CategoryIcon  string `json:"category_icon"`
-	CategoryColor string `json:"category_color"`
-	BudgetAmount  string `json:"budget_amount"`
-	SpentAmount   string `json:"spent_amount"`
-	Remaining     string `json:"remaining"`
-	SpentPercent  string `json:"spent_percent"`
-	Period        string `json:"period"`
-	Currency      string `json:"currency"`
+	BudgetID      uint      `json:"budget_id"`
+	CategoryID    uint      `json:"category_id"`
+	CategoryName  string    `json:"category_name"`
+	CategoryIcon  string    `json:"category_icon"`
+	CategoryColor string    `json:"category_color"`
+	BudgetAmount  string    `json:"budget_amount"`
+	Carried       string    `json:"carried"`
+	Available     string    `json:"available"`
+	SpentAmount   string    `json:"spent_amount"`
+	Remaining     string    `json:"remaining"`
+	SpentPercent  string    `json:"spent_percent"`
+	Period        string    `json:"period"`
+	PeriodStart   time.Time `json:"period_start"`
+	PeriodEnd     time.Time `json:"period_end"`
+	Currency      string    `json:"currency"`
 }
 
 func BudgetFromModel(b model.Budget) BudgetResponse {
 	resp := BudgetResponse{
-		ID:         b.ID,
-		CategoryID: b.CategoryID,
-		Amount:     b.Amount.String(),
-		Currency:   b.Currency,
-		Period:     string(b.Period),
+		ID:              b.ID,
+		CategoryID:      b.CategoryID,
+		Amount:          b.Amount.String(),
+		Currency:        b.Currency,
+		Period:          string(b.Period),
+		PeriodDays:      b.PeriodDays,
+		Rollover:        b.Rollover,
+		AlertThresholds: b.AlertThresholds,
+	}
+	if resp.AlertThresholds == nil {
+		resp.AlertThresholds = []int{}
+	}
+	if b.Anchor != nil {
+		resp.Anchor = b.Anchor.Format("2006-01-02")
 	}
 	if b.Category != nil {
 		cat := CategoryFromModel(*b.Category)
@@ -0,0 +1,123 @@
+package dto
+
+import (
+	"time"
+	"transaction/internal/domain/model"
+
+	"github.com/shopspring/decimal"
+)
+
+type CategoryRuleResponse struct {
+	ID                  uint              `json:"id"`
+	Name                string            `json:"name"`
+	Priority            int               `json:"priority"`
+	IsActive            bool              `json:"is_active"`
+	DescriptionContains string            `json:"description_contains,omitempty"`
+	MinAmount           *string           `json:"min_amount,omitempty"`
+	MaxAmount           *string           `json:"max_amount,omitempty"`
+	AccountID           *uint             `json:"account_id,omitempty"`
+	Type                string            `json:"type,omitempty"`
+	CategoryID          uint              `json:"category_id"`
+	Category            *CategoryResponse `json:"category,omitempty"`
+	SetAccountID        *uint             `json:"set_account_id,omitempty"`
+}
+
+// CategoryRuleRequest is used for both create and full update. At least one
+// of description_contains, min_amount, max_amount, account_id and type is required.
+// account_id is a condition; set_account_id moves matching transactions to
+// that account along with setting category_id.
+type CategoryRuleRequest struct {
+	Name                string  `json:"name"`
+	Priority            int     `json:"priority"`
+	IsActive            *bool   `json:"is_active"`
+	DescriptionContains string  `json:"description_contains"`
+	MinAmount           *string `json:"min_amount"`
+	MaxAmount           *string `json:"max_amount"`
+	AccountID           *uint   `json:"account_id"`
+	Type                string  `json:"type"`
+	CategoryID          uint    `json:"category_id" binding:"required"`
+	SetAccountID        *uint   `json:"set_account_id"`
+}
+
+func (r *CategoryRuleRequest) ParseAmounts() (min, max *decimal.Decimal, err error) {
+	if r.MinAmount != nil {
+		d, err := decimal.NewFromString(*r.MinAmount)
+		if err != nil {
+			return nil, nil, err
+		}
+		min = &d
+	}
+	if r.MaxAmount != nil {
+		d, err := decimal.NewFromString(*r.MaxAmount)
+		if err != nil {
+			return nil, nil, err
+		}
+		max = &d
+	}
+	return min, max, nil
+}
+
+type RecategorizeRequest struct {
+	RuleID    *uint `json:"rule_id"`
+	Overwrite bool  `json:"overwrite"`
+	DryRun    bool  `json:"dry_run"`
+}
+
+type RecategorizationResponse struct {
+	TransactionID   uint      `json:"transaction_id"`
+	Description     string    `json:"description"`
+	Amount          string    `json:"amount"`
+	TransactionDate time.Time `json:"transaction_date"`
+	OldCategoryID   *uint     `json:"old_category_id"`
+	NewCategoryID   uint      `json:"new_category_id"`
+	OldAccountID    uint      `json:"old_account_id"`
+	NewAccountID    uint      `json:"new_account_id"`
+	RuleID          uint      `json:"rule_id"`
+}
+
+type RecategorizeResponse struct {
+	DryRun  bool                       `json:"dry_run"`
+	Count   int                        `json:"count"`
+	Changes []RecategorizationResponse `json:"changes"`
+}
+
+func CategoryRuleFromModel(r model.CategoryRule) CategoryRuleResponse {
+	var category *CategoryResponse
+	if r.Category != nil {
+		c := CategoryFromModel(*r.Category)
+		category = &c
+	}
+
+	var minAmount, maxAmount *string
+	if r.MinAmount != nil {
+		v := r.MinAmount.String()
+		minAmount = &v
+	}
+	if r.MaxAmount != nil {
+		v := r.MaxAmount.String()
+		maxAmount = &v
+	}
+
+	return CategoryRuleResponse{
+		ID:                  r.ID,
+		Name:                r.Name,
+		Priority:            r.Priority,
+		IsActive:            r.IsActive,
+		DescriptionContains: r.DescriptionContains,
+		MinAmount:           minAmount,
+		MaxAmount:           maxAmount,
+		AccountID:           r.AccountID,
+		Type:                string(r.Type),
+		CategoryID:          r.CategoryID,
+		Category:            category,
+		SetAccountID:        r.SetAccountID,
+	}
+}
+
+func CategoryRuleListFromModel(rules []model.CategoryRule) []CategoryRuleResponse {
+	res := make([]CategoryRuleResponse, len(rules))
+	for i, r := range rules {
+		res[i] = CategoryRuleFromModel(r)
+	}
+	return res
+}
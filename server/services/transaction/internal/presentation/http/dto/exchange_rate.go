@@ -0,0 +1,70 @@
+package dto
+
+import (
+	"time"
+	"transaction/internal/domain/model"
+
+	"github.com/shopspring/decimal"
+)
+
+type ExchangeRateItem struct {
+	Base   string `json:"base" binding:"required,len=3"`
+	Quote  string `json:"quote" binding:"required,len=3"`
+	Date   string `json:"date" binding:"required"`
+	Rate   string `json:"rate" binding:"required"`
+	Source string `json:"source"`
+}
+
+type LoadExchangeRatesRequest struct {
+	Rates []ExchangeRateItem `json:"rates" binding:"required,min=1,dive"`
+}
+
+func (i *ExchangeRateItem) ToModel() (model.ExchangeRate, error) {
+	date, err := time.Parse("2006-01-02", i.Date)
+	if err != nil {
+		return model.ExchangeRate{}, err
+	}
+	rate, err := decimal.NewFromString(i.Rate)
+	if err != nil {
+		return model.ExchangeRate{}, err
+	}
+	return model.ExchangeRate{
+		Base:   i.Base,
+		Quote:  i.Quote,
+		Date:   date,
+		Rate:   rate,
+		Source: i.Source,
+	}, nil
+}
+
+type ExchangeRateResponse struct {
+	Base   string `json:"base"`
+	Quote  string `json:"quote"`
+	Date   string `json:"date"`
+	Rate   string `json:"rate"`
+	Source string `json:"source,omitempty"`
+}
+
+func ExchangeRateFromModel(r model.ExchangeRate) ExchangeRateResponse {
+	return ExchangeRateResponse{
+		Base:   r.Base,
+		Quote:  r.Quote,
+		Date:   r.Date.Format("2006-01-02"),
+		Rate:   r.Rate.String(),
+		Source: r.Source,
+	}
+}
+
+func ExchangeRateListFromModel(rates []model.ExchangeRate) []ExchangeRateResponse {
+	res := make([]ExchangeRateResponse, len(rates))
+	for i, r := range rates {
+		res[i] = ExchangeRateFromModel(r)
+	}
+	return res
+}
+
+type TotalBalanceResponse struct {
+	Currency   string            `json:"currency"`
+	Total      string            `json:"total"`
+	ByCurrency map[string]string `json:"by_currency"`
+}
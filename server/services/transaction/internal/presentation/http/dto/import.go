@@ -0,0 +1,33 @@
+package dto
+
+// ImportStatementForm is the multipart body of POST /accounts/:id/import.
+// The csv_* fields describe the column mapping and are ignored for OFX, QFX
+// and MT940 files. Columns are header names or zero-based indexes.
+type ImportStatementForm struct {
+	Format            string `form:"format"`
+	DryRun            bool   `form:"dry_run"`
+	DateColumn        string `form:"csv_date"`
+	AmountColumn      string `form:"csv_amount"`
+	DebitColumn       string `form:"csv_debit"`
+	CreditColumn      string `form:"csv_credit"`
+	DescriptionColumn string `form:"csv_description"`
+	CurrencyColumn    string `form:"csv_currency"`
+	DateFormat        string `form:"csv_date_format"`
+	Delimiter         string `form:"csv_delimiter"`
+	DecimalComma      bool   `form:"csv_decimal_comma"`
+	NoHeader          bool   `form:"csv_no_header"`
+	SkipRows          int    `form:"csv_skip_rows" binding:"min=0"`
+}
+
+type ImportRowResponse struct {
+	TransactionResponse
+	Duplicate bool `json:"duplicate"`
+}
+
+type ImportResponse struct {
+	DryRun       bool                `json:"dry_run"`
+	Total        int                 `json:"total"`
+	Imported     int                 `json:"imported"`
+	Duplicates   int                 `json:"duplicates"`
+	Transactions []ImportRowResponse `json:"transactions"`
+}
@@ -0,0 +1,46 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+	"transaction/internal/domain/model"
+)
+
+type NotificationResponse struct {
+	ID        uint            `json:"id"`
+	Kind      string          `json:"kind"`
+	Subject   string          `json:"subject"`
+	Body      string          `json:"body"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Status    string          `json:"status"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error,omitempty"`
+	SentAt    *time.Time      `json:"sent_at,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func NotificationFromModel(n model.Notification) NotificationResponse {
+	resp := NotificationResponse{
+		ID:        n.ID,
+		Kind:      string(n.Kind),
+		Subject:   n.Subject,
+		Body:      n.Body,
+		Status:    string(n.Status),
+		Attempts:  n.Attempts,
+		LastError: n.LastError,
+		SentAt:    n.SentAt,
+		CreatedAt: n.CreatedAt,
+	}
+	if n.Payload != "" {
+		resp.Payload = json.RawMessage(n.Payload)
+	}
+	return resp
+}
+
+func NotificationListFromModel(notifications []model.Notification) []NotificationResponse {
+	res := make([]NotificationResponse, len(notifications))
+	for i, n := range notifications {
+		res[i] = NotificationFromModel(n)
+	}
+	return res
+}
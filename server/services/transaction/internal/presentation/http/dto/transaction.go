@@ -11,6 +11,9 @@ type TransactionResponse struct {
 	ID                   uint              `json:"id"`
 	AccountID            uint              `json:"account_id"`
 	DestinationAccountID *uint             `json:"destination_account_id,omitempty"`
+	DestinationAmount    *string           `json:"destination_amount,omitempty"`
+	DestinationCurrency  *string           `json:"destination_currency,omitempty"`
+	ExchangeRate         *string           `json:"exchange_rate,omitempty"`
 	Type                 string            `json:"type"`
 	Status               string            `json:"status"`
 	Amount               string            `json:"amount"`
@@ -18,23 +21,108 @@ type TransactionResponse struct {
 	Description          string            `json:"description"`
 	TransactionDate      time.Time         `json:"transaction_date"`
 	Category             *CategoryResponse `json:"category,omitempty"`
+	Splits               []SplitResponse   `json:"splits,omitempty"`
 }
 
+type SplitResponse struct {
+	ID         uint              `json:"id"`
+	CategoryID *uint             `json:"category_id,omitempty"`
+	Category   *CategoryResponse `json:"category,omitempty"`
+	Amount     string            `json:"amount"`
+	Note       string            `json:"note"`
+}
+
+// SplitRequest is one line of a split transaction. The lines must sum to the
+// transaction amount.
+type SplitRequest struct {
+	CategoryID *uint  `json:"category_id"`
+	Amount     string `json:"amount"`
+	Note       string `json:"note"`
+}
+
+// CreateTransactionRequest describes a new transaction. For transfers between
+// accounts in different currencies either destination_amount or exchange_rate
+// (destination units per source unit) is required.
 type CreateTransactionRequest struct {
-	AccountID            uint   `json:"account_id" binding:"required"`
-	DestinationAccountID *uint  `json:"destination_account_id"`
-	Type                 string `json:"type" binding:"required"`
-	Amount               string `json:"amount" binding:"required"`
-	Currency             string `json:"currency" binding:"required,len=3"`
-	Description          string `json:"description"`
-	CategoryID           *uint  `json:"category_id"`
-	TransactionDate      string `json:"transaction_date"`
+	AccountID            uint           `json:"account_id" binding:"required"`
+	DestinationAccountID *uint          `json:"destination_account_id"`
+	DestinationAmount    *string        `json:"destination_amount"`
+	DestinationCurrency  *string        `json:"destination_currency" binding:"omitempty,len=3"`
+	ExchangeRate         *string        `json:"exchange_rate"`
+	Type                 string         `json:"type" binding:"required"`
+	Amount               string         `json:"amount" binding:"required"`
+	Currency             string         `json:"currency" binding:"required,len=3"`
+	Description          string         `json:"description"`
+	CategoryID           *uint          `json:"category_id"`
+	Splits               []SplitRequest `json:"splits"`
+	TransactionDate      string         `json:"transaction_date"`
+}
+
+type UpdateTransactionRequest struct {
+	AccountID            *uint           `json:"account_id"`
+	DestinationAccountID *uint           `json:"destination_account_id"`
+	DestinationAmount    *string         `json:"destination_amount"`
+	DestinationCurrency  *string         `json:"destination_currency" binding:"omitempty,len=3"`
+	ExchangeRate         *string         `json:"exchange_rate"`
+	Type                 *string         `json:"type"`
+	Amount               *string         `json:"amount"`
+	Currency             *string         `json:"currency" binding:"omitempty,len=3"`
+	Description          *string         `json:"description"`
+	CategoryID           *uint           `json:"category_id"`
+	Splits               *[]SplitRequest `json:"splits"`
+	TransactionDate      *string         `json:"transaction_date"`
 }
 
 func (r *CreateTransactionRequest) ParseAmount() (decimal.Decimal, error) {
 	return decimal.NewFromString(r.Amount)
 }
 
+func (r *CreateTransactionRequest) ParseTransferLeg() (amount, rate *decimal.Decimal, err error) {
+	return parseTransferLeg(r.DestinationAmount, r.ExchangeRate)
+}
+
+func (r *UpdateTransactionRequest) ParseTransferLeg() (amount, rate *decimal.Decimal, err error) {
+	return parseTransferLeg(r.DestinationAmount, r.ExchangeRate)
+}
+
+func parseTransferLeg(amountStr, rateStr *string) (amount, rate *decimal.Decimal, err error) {
+	if amountStr != nil {
+		d, err := decimal.NewFromString(*amountStr)
+		if err != nil {
+			return nil, nil, err
+		}
+		amount = &d
+	}
+	if rateStr != nil {
+		d, err := decimal.NewFromString(*rateStr)
+		if err != nil {
+			return nil, nil, err
+		}
+		rate = &d
+	}
+	return amount, rate, nil
+}
+
+// ParseSplits converts split lines; an empty list means the transaction is not split.
+func ParseSplits(reqs []SplitRequest) ([]model.TransactionSplit, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	splits := make([]model.TransactionSplit, len(reqs))
+	for i, req := range reqs {
+		amount, err := decimal.NewFromString(req.Amount)
+		if err != nil {
+			return nil, err
+		}
+		splits[i] = model.TransactionSplit{
+			CategoryID: req.CategoryID,
+			Amount:     amount,
+			Note:       req.Note,
+		}
+	}
+	return splits, nil
+}
+
 func (r *CreateTransactionRequest) ParseTransactionDate() (time.Time, error) {
 	if r.TransactionDate == "" {
 		return time.Now(), nil
@@ -50,10 +138,23 @@ func FromModel(tx model.Transaction) TransactionResponse {
 		category = &cat
 	}
 
+	var destinationAmount, exchangeRate *string
+	if tx.DestinationAmount != nil {
+		v := tx.DestinationAmount.String()
+		destinationAmount = &v
+	}
+	if tx.ExchangeRate != nil {
+		v := tx.ExchangeRate.String()
+		exchangeRate = &v
+	}
+
 	return TransactionResponse{
 		ID:                   tx.ID,
 		AccountID:            tx.AccountID,
 		DestinationAccountID: tx.DestinationAccountID,
+		DestinationAmount:    destinationAmount,
+		DestinationCurrency:  tx.DestinationCurrency,
+		ExchangeRate:         exchangeRate,
 		Type:                 string(tx.Type),
 		Status:               string(tx.Status),
 		Amount:               tx.Amount.String(),
@@ -61,9 +162,32 @@ func FromModel(tx model.Transaction) TransactionResponse {
 		Description:          tx.Description,
 		TransactionDate:      tx.TransactionDate,
 		Category:             category,
+		Splits:               splitsFromModel(tx.Splits),
 	}
 }
 
+func splitsFromModel(splits []model.TransactionSplit) []SplitResponse {
+	if len(splits) == 0 {
+		return nil
+	}
+	res := make([]SplitResponse, len(splits))
+	for i, sp := range splits {
+		var category *CategoryResponse
+		if sp.Category != nil {
+			cat := CategoryFromModel(*sp.Category)
+			category = &cat
+		}
+		res[i] = SplitResponse{
+			ID:         sp.ID,
+			CategoryID: sp.CategoryID,
+			Category:   category,
+			Amount:     sp.Amount.String(),
+			Note:       sp.Note,
+		}
+	}
+	return res
+}
+
 func FromModelList(txs []model.Transaction) []TransactionResponse {
 	res := make([]TransactionResponse, len(txs))
 	for i, t := range txs {
@@ -71,3 +195,20 @@ func FromModelList(txs []model.Transaction) []TransactionResponse {
 	}
 	return res
 }
+
+// TransactionFilterQuery holds the GET /transactions search parameters. List
+// parameters (type, status, category_id) are comma-separated.
+type TransactionFilterQuery struct {
+	AccountID     *uint  `form:"account_id"`
+	From          string `form:"from"`
+	To            string `form:"to"`
+	Type          string `form:"type"`
+	Status        string `form:"status"`
+	CategoryID    string `form:"category_id"`
+	SubCategories *bool  `form:"include_subcategories"`
+	MinAmount     string `form:"min_amount"`
+	MaxAmount     string `form:"max_amount"`
+	Currency      string `form:"currency"`
+	Query         string `form:"q"`
+	Sort          string `form:"sort"`
+}
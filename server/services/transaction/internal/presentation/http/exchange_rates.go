@@ -0,0 +1,137 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"transaction/internal/domain/model"
+	"transaction/internal/domain/service"
+	"transaction/internal/presentation/http/dto"
+	"transaction/internal/presentation/http/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExchangeRateHTTP struct {
+	service *service.ExchangeRateService
+}
+
+func NewExchangeRateHTTP(r *gin.Engine, s *service.ExchangeRateService) {
+	h := &ExchangeRateHTTP{service: s}
+
+	rates := r.Group("/exchange-rates")
+	rates.Use(middleware.AuthMiddleware())
+	{
+		rates.GET("", h.GetHistory)
+		rates.GET("/rate", h.GetRate)
+	}
+
+	admin := r.Group("/admin/exchange-rates")
+	admin.Use(middleware.AdminMiddleware())
+	{
+		admin.POST("", h.LoadRates)
+	}
+}
+
+func (h *ExchangeRateHTTP) LoadRates(ctx *gin.Context) {
+	var req dto.LoadExchangeRatesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rates := make([]model.ExchangeRate, len(req.Rates))
+	for i := range req.Rates {
+		rate, err := req.Rates[i].ToModel()
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("rates[%d]: invalid date or rate format", i)})
+			return
+		}
+		rates[i] = rate
+	}
+
+	count, err := h.service.LoadRates(rates)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == service.ErrInvalidCurrency || err == service.ErrInvalidRate || err == service.ErrInvalidDate {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"loaded": count})
+}
+
+func (h *ExchangeRateHTTP) GetRate(ctx *gin.Context) {
+	base := ctx.Query("base")
+	quote := ctx.Query("quote")
+	if len(base) != 3 || len(quote) != 3 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "base and quote must be 3-letter currency codes"})
+		return
+	}
+
+	on := time.Now()
+	if d := ctx.Query("date"); d != "" {
+		parsed, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return
+		}
+		on = parsed
+	}
+
+	rate, err := h.service.GetRate(base, quote, on)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrRateNotFound) {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"base":  base,
+		"quote": quote,
+		"date":  on.Format("2006-01-02"),
+		"rate":  rate.String(),
+	})
+}
+
+func (h *ExchangeRateHTTP) GetHistory(ctx *gin.Context) {
+	base := ctx.Query("base")
+	quote := ctx.Query("quote")
+	if len(base) != 3 || len(quote) != 3 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "base and quote must be 3-letter currency codes"})
+		return
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, -1, 0)
+	if f := ctx.Query("from"); f != "" {
+		parsed, err := time.Parse("2006-01-02", f)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'from' date format, use YYYY-MM-DD"})
+			return
+		}
+		from = parsed
+	}
+	if t := ctx.Query("to"); t != "" {
+		parsed, err := time.Parse("2006-01-02", t)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'to' date format, use YYYY-MM-DD"})
+			return
+		}
+		to = parsed
+	}
+
+	rates, err := h.service.GetHistory(base, quote, from, to)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.ExchangeRateListFromModel(rates))
+}
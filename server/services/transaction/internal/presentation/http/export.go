@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+	"transaction/internal/domain/model"
 	"transaction/internal/domain/service"
 	"transaction/internal/presentation/http/middleware"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 )
 
 type ExportHTTP struct {
@@ -65,7 +67,7 @@ func (h *ExportHTTP) ExportCSV(ctx *gin.Context) {
 	ctx.Writer.Write([]byte{0xEF, 0xBB, 0xBF})
 
 	// CSV header
-	ctx.Writer.WriteString("ID,Date,Type,Amount,Currency,Category,Description,Account ID,Status\n")
+	ctx.Writer.WriteString("ID,Date,Type,Amount,Currency,Category,Description,Account ID,Status,Note\n")
 
 	for _, tx := range transactions {
 		// Apply date filter
@@ -76,29 +78,49 @@ func (h *ExportHTTP) ExportCSV(ctx *gin.Context) {
 			continue
 		}
 
-		categoryName := ""
-		if tx.Category != nil {
-			categoryName = tx.Category.Name
+		// Split transactions are exported one row per split line
+		for _, line := range exportLines(tx) {
+			ctx.Writer.WriteString(line)
+		}
+	}
+}
+
+func exportLines(tx model.Transaction) []string {
+	type exportLine struct {
+		amount   decimal.Decimal
+		category *model.Category
+		note     string
+	}
+
+	lines := []exportLine{{amount: tx.Amount, category: tx.Category}}
+	if len(tx.Splits) > 0 {
+		lines = lines[:0]
+		for _, sp := range tx.Splits {
+			lines = append(lines, exportLine{amount: sp.Amount, category: sp.Category, note: sp.Note})
 		}
+	}
 
-		description := tx.Description
-		// Escape CSV fields
-		description = escapeCSV(description)
-		categoryName = escapeCSV(categoryName)
+	res := make([]string, len(lines))
+	for i, l := range lines {
+		categoryName := ""
+		if l.category != nil {
+			categoryName = l.category.Name
+		}
 
-		line := fmt.Sprintf("%d,%s,%s,%s,%s,%s,%s,%d,%s\n",
+		res[i] = fmt.Sprintf("%d,%s,%s,%s,%s,%s,%s,%d,%s,%s\n",
 			tx.ID,
 			tx.TransactionDate.Format("2006-01-02 15:04:05"),
 			tx.Type,
-			tx.Amount.String(),
+			l.amount.String(),
 			tx.Currency,
-			categoryName,
-			description,
+			escapeCSV(categoryName),
+			escapeCSV(tx.Description),
 			tx.AccountID,
 			tx.Status,
+			escapeCSV(l.note),
 		)
-		ctx.Writer.WriteString(line)
 	}
+	return res
 }
 
 func escapeCSV(s string) string {
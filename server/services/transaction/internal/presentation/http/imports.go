@@ -0,0 +1,157 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"transaction/internal/domain/model"
+	"transaction/internal/domain/service"
+	"transaction/internal/infra/statement"
+	"transaction/internal/presentation/http/dto"
+	"transaction/internal/presentation/http/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxStatementSize = 10 << 20
+
+type ImportHTTP struct {
+	service *service.ImportService
+}
+
+func NewImportHTTP(r *gin.Engine, s *service.ImportService) {
+	h := &ImportHTTP{service: s}
+
+	accounts := r.Group("/accounts")
+	accounts.Use(middleware.AuthMiddleware())
+	{
+		accounts.POST("/:id/import", h.ImportStatement)
+	}
+}
+
+// ImportStatement accepts a multipart upload with the statement in "file".
+// The format comes from the "format" field or the file extension.
+func (h *ImportHTTP) ImportStatement(ctx *gin.Context) {
+	var uri struct {
+		ID uint `uri:"id" binding:"required"`
+	}
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxStatementSize)
+
+	var form dto.ImportStatementForm
+	if err := ctx.ShouldBind(&form); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "statement file is required"})
+		return
+	}
+
+	format := statement.Format(strings.ToLower(form.Format))
+	if format == "" {
+		format, err = statement.DetectFormat(header.Filename)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "cannot detect statement format, pass format=csv|ofx|qfx|mt940"})
+			return
+		}
+	} else if !statement.IsValidFormat(format) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": statement.ErrUnknownFormat.Error()})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	txs, err := parseStatement(file, format, form)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.Import(uri.ID, userID.(uint), txs, form.DryRun)
+	if err != nil {
+		ctx.JSON(importErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	rows := make([]dto.ImportRowResponse, len(result.Rows))
+	for i, row := range result.Rows {
+		rows[i] = dto.ImportRowResponse{
+			TransactionResponse: dto.FromModel(row.Transaction),
+			Duplicate:           row.Duplicate,
+		}
+	}
+
+	status := http.StatusCreated
+	if result.DryRun {
+		status = http.StatusOK
+	}
+	ctx.JSON(status, dto.ImportResponse{
+		DryRun:       result.DryRun,
+		Total:        len(result.Rows),
+		Imported:     result.Imported,
+		Duplicates:   result.Duplicates,
+		Transactions: rows,
+	})
+}
+
+func parseStatement(r io.Reader, format statement.Format, form dto.ImportStatementForm) ([]model.Transaction, error) {
+	switch format {
+	case statement.FormatOFX, statement.FormatQFX:
+		return statement.ParseOFX(r)
+	case statement.FormatMT940:
+		return statement.ParseMT940(r)
+	}
+
+	mapping := statement.CSVMapping{
+		Date:         form.DateColumn,
+		Amount:       form.AmountColumn,
+		Debit:        form.DebitColumn,
+		Credit:       form.CreditColumn,
+		Description:  form.DescriptionColumn,
+		Currency:     form.CurrencyColumn,
+		DateFormat:   form.DateFormat,
+		DecimalComma: form.DecimalComma,
+		HasHeader:    !form.NoHeader,
+		SkipRows:     form.SkipRows,
+	}
+	switch form.Delimiter {
+	case "":
+	case `\t`, "tab":
+		mapping.Delimiter = '\t'
+	default:
+		mapping.Delimiter = []rune(form.Delimiter)[0]
+	}
+	return statement.ParseCSV(r, mapping)
+}
+
+func importErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrAccountNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrAccountAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrEmptyStatement),
+		errors.Is(err, service.ErrStatementCurrencyMismatch):
+		return http.StatusBadRequest
+	}
+	return transactionErrorStatus(errors.Unwrap(err))
+}
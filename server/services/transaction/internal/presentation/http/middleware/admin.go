@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware guards operator endpoints with the shared ADMIN_TOKEN
+// secret, sent in the X-Admin-Token header. Without ADMIN_TOKEN set every
+// request is refused.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		expected := os.Getenv("ADMIN_TOKEN")
+		token := ctx.GetHeader("X-Admin-Token")
+		if expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
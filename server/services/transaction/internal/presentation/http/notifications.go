@@ -0,0 +1,49 @@
+package http
+
+import (
+	"net/http"
+	"transaction/internal/domain/model"
+	"transaction/internal/domain/service"
+	"transaction/internal/presentation/http/dto"
+	"transaction/internal/presentation/http/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationHTTP struct {
+	service *service.NotificationService
+}
+
+func NewNotificationHTTP(r *gin.Engine, s *service.NotificationService) {
+	h := &NotificationHTTP{service: s}
+
+	notifications := r.Group("/notifications")
+	notifications.Use(middleware.AuthMiddleware())
+	{
+		notifications.GET("", h.GetNotifications)
+	}
+}
+
+// GetNotifications lists the user's latest notifications with their delivery
+// state, optionally filtered by ?status=pending|sent|failed.
+func (h *NotificationHTTP) GetNotifications(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	status := model.NotificationStatus(ctx.Query("status"))
+	if status != "" && !model.IsValidNotificationStatus(status) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+		return
+	}
+
+	notifications, err := h.service.GetByUser(userID.(uint), status)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NotificationListFromModel(notifications))
+}
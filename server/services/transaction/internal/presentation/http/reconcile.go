@@ -0,0 +1,64 @@
+package http
+
+import (
+	"net/http"
+	"transaction/internal/domain/service"
+	"transaction/internal/presentation/http/dto"
+	"transaction/internal/presentation/http/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReconcileHTTP struct {
+	service *service.ReconciliationService
+}
+
+func NewReconcileHTTP(r *gin.Engine, s *service.ReconciliationService) {
+	h := &ReconcileHTTP{service: s}
+
+	accounts := r.Group("/accounts")
+	accounts.Use(middleware.AuthMiddleware())
+	{
+		accounts.GET("/:id/reconcile", h.ReconcileAccount)
+	}
+}
+
+func (h *ReconcileHTTP) ReconcileAccount(ctx *gin.Context) {
+	var uri struct {
+		ID uint `uri:"id" binding:"required"`
+	}
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	rec, err := h.service.ReconcileAccount(uri.ID, userID.(uint))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == service.ErrAccountNotFound {
+			status = http.StatusNotFound
+		} else if err == service.ErrAccountAccessDenied {
+			status = http.StatusForbidden
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.ReconciliationResponse{
+		AccountID:       rec.AccountID,
+		AccountName:     rec.AccountName,
+		Currency:        rec.Currency,
+		OpeningBalance:  rec.OpeningBalance.String(),
+		LedgerNet:       rec.LedgerNet.String(),
+		ExpectedBalance: rec.ExpectedBalance.String(),
+		CachedBalance:   rec.CachedBalance.String(),
+		Difference:      rec.Difference.String(),
+		Balanced:        rec.IsBalanced(),
+	})
+}
@@ -1,14 +1,18 @@
 package http
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 	"transaction/internal/domain/model"
 	"transaction/internal/domain/service"
 	"transaction/internal/presentation/http/dto"
 	"transaction/internal/presentation/http/middleware"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 )
 
 type TransactionHTTP struct {
@@ -26,6 +30,9 @@ func New(r *gin.Engine, s *service.TransactionService) {
 		transactions.GET("", h.GetTransactions)
 		transactions.POST("", h.CreateTransaction)
 		transactions.GET("/:id", h.GetTransaction)
+		transactions.PUT("/:id", h.ReplaceTransaction)
+		transactions.PATCH("/:id", h.UpdateTransaction)
+		transactions.DELETE("/:id", h.DeleteTransaction)
 	}
 }
 
@@ -36,51 +43,44 @@ func (h *TransactionHTTP) GetTransactions(ctx *gin.Context) {
 		return
 	}
 
-	// Check if pagination is requested
-	usePagination := ctx.Query("page") != ""
-	pg := dto.ParsePagination(ctx)
-
-	// Check for account_id query param
-	accountIDStr := ctx.Query("account_id")
-	if accountIDStr != "" {
-		accountID, err := strconv.ParseUint(accountIDStr, 10, 32)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
-			return
-		}
+	var query dto.TransactionFilterQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-		if usePagination {
-			transactions, total, err := h.service.GetTransactionsByAccountPaginated(uint(accountID), userID.(uint), pg.Limit(), pg.Offset())
-			if err != nil {
-				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-			ctx.JSON(http.StatusOK, dto.NewPaginatedResponse(dto.FromModelList(transactions), pg.Page, pg.PageSize, int(total)))
-			return
-		}
+	filter, err := parseTransactionFilter(query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-		transactions, err := h.service.GetTransactionsByAccount(uint(accountID), userID.(uint))
+	// Keyset pagination takes precedence over page/offset pagination
+	if cp, ok := dto.ParseCursorPagination(ctx); ok {
+		transactions, next, err := h.service.SearchTransactionsPage(userID.(uint), filter, cp.Cursor, cp.PageSize)
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			ctx.JSON(transactionErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
-		ctx.JSON(http.StatusOK, dto.FromModelList(transactions))
+		ctx.JSON(http.StatusOK, dto.NewCursorResponse(dto.FromModelList(transactions), cp.PageSize, next))
 		return
 	}
 
-	if usePagination {
-		transactions, total, err := h.service.GetTransactionsByUserPaginated(userID.(uint), pg.Limit(), pg.Offset())
+	// Check if pagination is requested
+	if ctx.Query("page") != "" {
+		pg := dto.ParsePagination(ctx)
+		transactions, total, err := h.service.SearchTransactionsPaginated(userID.(uint), filter, pg.Limit(), pg.Offset())
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			ctx.JSON(transactionErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 		ctx.JSON(http.StatusOK, dto.NewPaginatedResponse(dto.FromModelList(transactions), pg.Page, pg.PageSize, int(total)))
 		return
 	}
 
-	transactions, err := h.service.GetTransactionsByUser(userID.(uint))
+	transactions, err := h.service.SearchTransactions(userID.(uint), filter)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(transactionErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -94,9 +94,15 @@ func (h *TransactionHTTP) GetTransaction(ctx *gin.Context) {
 		return
 	}
 
-	tx, err := h.service.GetTransaction(uri.ID)
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	tx, err := h.service.GetUserTransaction(uri.ID, userID.(uint))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+		ctx.JSON(transactionErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -122,6 +128,18 @@ func (h *TransactionHTTP) CreateTransaction(ctx *gin.Context) {
 		return
 	}
 
+	destinationAmount, exchangeRate, err := req.ParseTransferLeg()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid destination_amount or exchange_rate format"})
+		return
+	}
+
+	splits, err := dto.ParseSplits(req.Splits)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid split amount format"})
+		return
+	}
+
 	userID, ok := ctx.Get("userID")
 	if !ok {
 		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
@@ -132,30 +150,326 @@ func (h *TransactionHTTP) CreateTransaction(ctx *gin.Context) {
 		UserID:               userID.(uint),
 		AccountID:            req.AccountID,
 		DestinationAccountID: req.DestinationAccountID,
+		DestinationAmount:    destinationAmount,
+		DestinationCurrency:  req.DestinationCurrency,
+		ExchangeRate:         exchangeRate,
 		Type:                 model.TransactionType(req.Type),
 		Amount:               amount,
 		Currency:             req.Currency,
 		CategoryID:           req.CategoryID,
+		Splits:               splits,
 		Description:          req.Description,
 		TransactionDate:      transactionDate,
 	}
 
 	tx, err := h.service.CreateTransaction(transaction)
 	if err != nil {
-		status := http.StatusInternalServerError
-		if err == service.ErrInvalidAmount ||
-			err == service.ErrInvalidCurrency ||
-			err == service.ErrInvalidTransactionType ||
-			err == service.ErrDestinationAccountRequired {
-			status = http.StatusBadRequest
-		} else if err == service.ErrAccountNotFound || err == service.ErrDestinationAccountNotFound {
-			status = http.StatusNotFound
-		} else if err == service.ErrAccountAccessDenied {
-			status = http.StatusForbidden
-		}
-		ctx.JSON(status, gin.H{"error": err.Error()})
+		ctx.JSON(transactionErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	ctx.JSON(http.StatusCreated, dto.FromModel(*tx))
 }
+
+// ReplaceTransaction handles PUT and overwrites every editable field.
+func (h *TransactionHTTP) ReplaceTransaction(ctx *gin.Context) {
+	var uri TransactionURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req dto.CreateTransactionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	amount, err := req.ParseAmount()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid amount format"})
+		return
+	}
+
+	transactionDate, err := req.ParseTransactionDate()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid transaction_date format"})
+		return
+	}
+
+	destinationAmount, exchangeRate, err := req.ParseTransferLeg()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid destination_amount or exchange_rate format"})
+		return
+	}
+
+	splits, err := dto.ParseSplits(req.Splits)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid split amount format"})
+		return
+	}
+
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	existing, err := h.service.GetUserTransaction(uri.ID, userID.(uint))
+	if err != nil {
+		ctx.JSON(transactionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	existing.AccountID = req.AccountID
+	existing.DestinationAccountID = req.DestinationAccountID
+	existing.DestinationAmount = destinationAmount
+	existing.DestinationCurrency = req.DestinationCurrency
+	existing.ExchangeRate = exchangeRate
+	existing.Type = model.TransactionType(req.Type)
+	existing.Amount = amount
+	existing.Currency = req.Currency
+	existing.CategoryID = req.CategoryID
+	existing.Splits = splits
+	existing.Description = req.Description
+	existing.TransactionDate = transactionDate
+
+	tx, err := h.service.UpdateTransaction(existing)
+	if err != nil {
+		ctx.JSON(transactionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.FromModel(*tx))
+}
+
+// UpdateTransaction handles PATCH and changes only the fields present in the body.
+func (h *TransactionHTTP) UpdateTransaction(ctx *gin.Context) {
+	var uri TransactionURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req dto.UpdateTransactionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	existing, err := h.service.GetUserTransaction(uri.ID, userID.(uint))
+	if err != nil {
+		ctx.JSON(transactionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// The lines of a split transaction carry its categories and add up to
+	// its amount, so neither can change without new lines
+	if len(existing.Splits) > 0 && req.Splits == nil && (req.Amount != nil || req.CategoryID != nil) {
+		ctx.JSON(transactionErrorStatus(service.ErrSplitsRequired), gin.H{"error": service.ErrSplitsRequired.Error()})
+		return
+	}
+
+	destinationAmount, exchangeRate, err := req.ParseTransferLeg()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid destination_amount or exchange_rate format"})
+		return
+	}
+
+	// The stored destination leg was derived from the old source side. A new
+	// amount keeps the rate; a new currency or destination account drops both.
+	if req.Currency != nil || req.DestinationAccountID != nil {
+		existing.DestinationAmount = nil
+		existing.DestinationCurrency = nil
+		existing.ExchangeRate = nil
+	} else if req.Amount != nil {
+		existing.DestinationAmount = nil
+	}
+	if destinationAmount != nil || exchangeRate != nil {
+		existing.DestinationAmount = destinationAmount
+		existing.ExchangeRate = exchangeRate
+	}
+	if req.DestinationCurrency != nil {
+		existing.DestinationCurrency = req.DestinationCurrency
+	}
+
+	if req.AccountID != nil {
+		existing.AccountID = *req.AccountID
+	}
+	if req.DestinationAccountID != nil {
+		existing.DestinationAccountID = req.DestinationAccountID
+	}
+	if req.Type != nil {
+		existing.Type = model.TransactionType(*req.Type)
+	}
+	if req.Amount != nil {
+		amount, err := decimal.NewFromString(*req.Amount)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid amount format"})
+			return
+		}
+		existing.Amount = amount
+	}
+	if req.Currency != nil {
+		existing.Currency = *req.Currency
+	}
+	if req.Description != nil {
+		existing.Description = *req.Description
+	}
+	if req.CategoryID != nil {
+		existing.CategoryID = req.CategoryID
+	}
+	if req.Splits != nil {
+		splits, err := dto.ParseSplits(*req.Splits)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid split amount format"})
+			return
+		}
+		existing.Splits = splits
+	}
+	if req.TransactionDate != nil {
+		transactionDate, err := time.Parse(time.RFC3339, *req.TransactionDate)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid transaction_date format"})
+			return
+		}
+		existing.TransactionDate = transactionDate
+	}
+
+	tx, err := h.service.UpdateTransaction(existing)
+	if err != nil {
+		ctx.JSON(transactionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.FromModel(*tx))
+}
+
+func (h *TransactionHTTP) DeleteTransaction(ctx *gin.Context) {
+	var uri TransactionURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := h.service.DeleteTransaction(uri.ID, userID.(uint)); err != nil {
+		ctx.JSON(transactionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func parseTransactionFilter(q dto.TransactionFilterQuery) (service.TransactionFilter, error) {
+	filter := service.TransactionFilter{
+		AccountID:     q.AccountID,
+		SubCategories: true,
+		Currency:      q.Currency,
+		Search:        strings.TrimSpace(q.Query),
+		Sort:          q.Sort,
+	}
+
+	if q.From != "" {
+		from, err := time.Parse("2006-01-02", q.From)
+		if err != nil {
+			return filter, errors.New("invalid 'from' date format, use YYYY-MM-DD")
+		}
+		filter.From = &from
+	}
+	if q.To != "" {
+		to, err := time.Parse("2006-01-02", q.To)
+		if err != nil {
+			return filter, errors.New("invalid 'to' date format, use YYYY-MM-DD")
+		}
+		// Include the entire day
+		to = to.Add(24*time.Hour - time.Nanosecond)
+		filter.To = &to
+	}
+
+	for _, t := range splitList(q.Type) {
+		filter.Types = append(filter.Types, model.TransactionType(t))
+	}
+	for _, st := range splitList(q.Status) {
+		filter.Statuses = append(filter.Statuses, model.TransactionStatus(st))
+	}
+	for _, c := range splitList(q.CategoryID) {
+		id, err := strconv.ParseUint(c, 10, 32)
+		if err != nil {
+			return filter, errors.New("invalid category_id")
+		}
+		filter.CategoryIDs = append(filter.CategoryIDs, uint(id))
+	}
+	if q.SubCategories != nil {
+		filter.SubCategories = *q.SubCategories
+	}
+
+	if q.MinAmount != "" {
+		min, err := decimal.NewFromString(q.MinAmount)
+		if err != nil {
+			return filter, errors.New("invalid min_amount format")
+		}
+		filter.MinAmount = &min
+	}
+	if q.MaxAmount != "" {
+		max, err := decimal.NewFromString(q.MaxAmount)
+		if err != nil {
+			return filter, errors.New("invalid max_amount format")
+		}
+		filter.MaxAmount = &max
+	}
+
+	return filter, nil
+}
+
+func splitList(s string) []string {
+	var res []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			res = append(res, part)
+		}
+	}
+	return res
+}
+
+func transactionErrorStatus(err error) int {
+	switch err {
+	case service.ErrInvalidAmount,
+		service.ErrInvalidCurrency,
+		service.ErrInvalidTransactionType,
+		service.ErrInvalidTransactionStatus,
+		service.ErrInvalidSort,
+		service.ErrInvalidAmountRange,
+		service.ErrInvalidDateRange,
+		service.ErrInvalidCursor,
+		service.ErrCursorSortUnsupported,
+		service.ErrDestinationAccountRequired,
+		service.ErrDestinationAmountRequired,
+		service.ErrDestinationAmountMismatch,
+		service.ErrDestinationCurrencyInvalid,
+		service.ErrInvalidRate,
+		service.ErrSplitOnTransfer,
+		service.ErrSplitSumMismatch,
+		service.ErrSplitsRequired:
+		return http.StatusBadRequest
+	case service.ErrAccountNotFound,
+		service.ErrDestinationAccountNotFound,
+		service.ErrTransactionNotFound:
+		return http.StatusNotFound
+	case service.ErrAccountAccessDenied,
+		service.ErrTransactionAccessDenied:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
@@ -9,10 +9,14 @@ import (
 )
 
 type Config struct {
-	Env        string `yaml:"env" env-default:"local"`
-	HTTPServer `yaml:"http_server"`
-	Postgres   `yaml:"postgres"`
-	RMQ        `yaml:"rmq"`
+	Env         string `yaml:"env" env-default:"local"`
+	HTTPServer  `yaml:"http_server"`
+	Postgres    `yaml:"postgres"`
+	RMQ         `yaml:"rmq"`
+	UserService `yaml:"user_service"`
+	JWT         `yaml:"jwt"`
+	RateLimit   `yaml:"rate_limit"`
+	Notifier    `yaml:"notifier"`
 }
 
 type HTTPServer struct {
@@ -34,6 +38,40 @@ type RMQ struct {
 	URL string `yaml:"url"`
 }
 
+// Notifier selects how outbox notifications are delivered: "log" or "webhook".
+type Notifier struct {
+	Type          string        `yaml:"type" env:"NOTIFIER_TYPE" env-default:"log"`
+	WebhookURL    string        `yaml:"webhook_url" env:"NOTIFIER_WEBHOOK_URL"`
+	WebhookSecret string        `yaml:"webhook_secret" env:"NOTIFIER_WEBHOOK_SECRET"`
+	Interval      time.Duration `yaml:"interval" env-default:"1m"`
+	BatchSize     int           `yaml:"batch_size" env-default:"100"`
+}
+
+// UserService locates the user service, whose published keys access tokens
+// are verified with. Tokens of sessions it has revoked are rejected, the
+// list refreshed every RevocationInterval.
+type UserService struct {
+	URL                string        `yaml:"url" env:"USER_SERVICE_URL"`
+	RevocationInterval time.Duration `yaml:"revocation_interval" env-default:"30s"`
+}
+
+// JWT is the issuer and audience access tokens must carry.
+type JWT struct {
+	Issuer   string `yaml:"issuer" env:"JWT_ISSUER" env-default:"bux-user"`
+	Audience string `yaml:"audience" env:"JWT_AUDIENCE" env-default:"bux"`
+}
+
+// RateLimit caps requests per client address at Limit per Window. Store is
+// "memory", counted per instance, or "postgres", shared by all of them.
+// Client addresses are taken from X-Forwarded-For only for requests from
+// TrustedProxies.
+type RateLimit struct {
+	Store          string        `yaml:"store" env:"RATE_LIMIT_STORE" env-default:"memory"`
+	Limit          int           `yaml:"limit" env:"RATE_LIMIT_LIMIT" env-default:"300"`
+	Window         time.Duration `yaml:"window" env:"RATE_LIMIT_WINDOW" env-default:"1m"`
+	TrustedProxies []string      `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+}
+
 type DBConfig struct {
 	User     string
 	Password string
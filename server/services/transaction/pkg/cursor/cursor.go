@@ -0,0 +1,35 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Key is a keyset position: the sort timestamp of the last row seen plus its
+// id to break ties between rows with the same timestamp.
+type Key struct {
+	Time time.Time `json:"t"`
+	ID   uint      `json:"id"`
+}
+
+// Encode returns an opaque, URL-safe token for the key.
+func Encode(k Key) string {
+	raw, _ := json.Marshal(k)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func Decode(token string) (Key, error) {
+	var k Key
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return k, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &k); err != nil || k.ID == 0 {
+		return k, ErrInvalidCursor
+	}
+	return k, nil
+}
@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const memorySweepInterval = time.Minute
+
+type memoryBucket struct {
+	start time.Time
+	end   time.Time
+	count int
+}
+
+// MemoryStore counts requests in process memory. Counts are per instance
+// and lost on restart.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*memoryBucket{}, lastSweep: time.Now(), now: time.Now}
+}
+
+func (s *MemoryStore) Hit(_ context.Context, key string, window time.Duration) (int, error) {
+	now := s.now()
+	start := now.Truncate(window)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > memorySweepInterval {
+		for k, b := range s.buckets {
+			if !now.Before(b.end) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok || !b.start.Equal(start) {
+		b = &memoryBucket{start: start, end: start.Add(window)}
+		s.buckets[key] = b
+	}
+	b.count++
+	return b.count, nil
+}
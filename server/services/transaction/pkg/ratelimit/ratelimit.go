@@ -0,0 +1,78 @@
+// Package ratelimit caps how many requests a client makes in a time window.
+// Requests are counted per key in fixed windows held by a Store: MemoryStore
+// for a single instance, PostgresStore to share counts between instances.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Store counts requests.
+type Store interface {
+	// Hit counts a request for key in the current window of the given
+	// length and returns how many the window now holds.
+	Hit(ctx context.Context, key string, window time.Duration) (int, error)
+}
+
+// Rule allows Limit requests per Window for each key. Name keeps the
+// counters of different rules apart.
+type Rule struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+	Key    func(ctx *gin.Context) string
+}
+
+// ByIP keys requests by client address.
+func ByIP(ctx *gin.Context) string {
+	return "ip:" + ctx.ClientIP()
+}
+
+// ByUserOrIP keys requests by the signed-in user, falling back to the
+// client address. It only sees the user when mounted after the auth
+// middleware.
+func ByUserOrIP(ctx *gin.Context) string {
+	if userID, ok := ctx.Get("userID"); ok {
+		return fmt.Sprintf("user:%v", userID)
+	}
+	return ByIP(ctx)
+}
+
+// Middleware rejects requests over the rule's limit with 429 and a
+// Retry-After header, and reports the remaining allowance in X-RateLimit-*
+// headers. If the store fails the request is let through, since an outage
+// of the limiter shouldn't take the API down with it.
+func Middleware(store Store, rule Rule, log *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		count, err := store.Hit(ctx.Request.Context(), rule.Name+":"+rule.Key(ctx), rule.Window)
+		if err != nil {
+			log.Error("rate limit store failed, letting request through",
+				slog.String("rule", rule.Name),
+				slog.String("error", err.Error()),
+			)
+			ctx.Next()
+			return
+		}
+
+		now := time.Now()
+		reset := now.Truncate(rule.Window).Add(rule.Window)
+		ctx.Header("X-RateLimit-Limit", strconv.Itoa(rule.Limit))
+		ctx.Header("X-RateLimit-Remaining", strconv.Itoa(max(0, rule.Limit-count)))
+		ctx.Header("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+		if count > rule.Limit {
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(reset.Sub(now).Seconds()))))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests, try again later"})
+			return
+		}
+		ctx.Next()
+	}
+}
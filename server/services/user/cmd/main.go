@@ -6,13 +6,17 @@ import (
 	"os"
 	"user/internal/data/repository"
 	"user/internal/domain/service"
+	"user/internal/infra/auth"
 	"user/internal/infra/db"
+	"user/internal/infra/mail"
 	"user/internal/presentation/http"
 	"user/pkg/config"
 	"user/pkg/logger"
 	"user/pkg/logger/sl"
+	"user/pkg/ratelimit"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -32,14 +36,105 @@ func main() {
 		log.Error("Error in migration", sl.Err(err))
 	}
 
+	keys, err := loadKeySet(cfg.JWT, log)
+	if err != nil {
+		log.Error("Failed to load JWT signing keys", sl.Err(err))
+		os.Exit(1)
+	}
+	auth.UseKeySet(keys, cfg.JWT.Issuer, cfg.JWT.Audience)
+
+	limits := newRateLimitStore(cfg.RateLimit, postgres, log)
+	mailer := newMailer(cfg.Mail, log)
+	emailConfig := service.EmailConfig{
+		AppURL:          cfg.Mail.AppURL,
+		VerificationTTL: cfg.Mail.VerificationTTL,
+		ResetTTL:        cfg.Mail.ResetTTL,
+	}
+
 	repo := repository.New(postgres)
-	service := service.New(repo)
+	service := service.New(repo, service.SessionConfig{
+		AccessTTL:  cfg.Session.AccessTTL,
+		RefreshTTL: cfg.Session.RefreshTTL,
+	}, service.MFAConfig{
+		Issuer:       cfg.MFA.Issuer,
+		ChallengeTTL: cfg.MFA.ChallengeTTL,
+	}, service.LockoutConfig{
+		FreeAttempts:    cfg.Lockout.FreeAttempts,
+		BaseDelay:       cfg.Lockout.BaseDelay,
+		MaxDelay:        cfg.Lockout.MaxDelay,
+		LockoutAfter:    cfg.Lockout.LockoutAfter,
+		LockoutDuration: cfg.Lockout.LockoutDuration,
+		IPLockoutAfter:  cfg.Lockout.IPLockoutAfter,
+		Window:          cfg.Lockout.Window,
+	}, log)
+	auth.UseRevocationChecker(service)
+	service.UseMailer(mailer, emailConfig)
 
 	r := gin.Default()
-	http.New(r, service)
+	if err := r.SetTrustedProxies(cfg.RateLimit.TrustedProxies); err != nil {
+		log.Error("Invalid trusted proxies", sl.Err(err))
+		os.Exit(1)
+	}
+	r.Use(ratelimit.Middleware(limits, ratelimit.Rule{
+		Name:   "api",
+		Limit:  cfg.RateLimit.Limit,
+		Window: cfg.RateLimit.Window,
+		Key:    ratelimit.ByIP,
+	}, log))
+	http.New(r, service, ratelimit.Middleware(limits, ratelimit.Rule{
+		Name:   "auth",
+		Limit:  cfg.RateLimit.AuthLimit,
+		Window: cfg.RateLimit.AuthWindow,
+		Key:    ratelimit.ByIP,
+	}, log))
 
 	if err := r.Run(fmt.Sprintf(":%d", cfg.HTTPServer.Port)); err != nil {
 		log.Error("Unable to start the server: ", sl.Err(err))
 	}
 
 }
+
+func loadKeySet(cfg config.JWT, log *slog.Logger) (*auth.KeySet, error) {
+	if cfg.KeysDir == "" {
+		log.Warn("JWT_KEYS_DIR not set, signing with a generated key; tokens won't survive a restart")
+		return auth.GenerateKeySet()
+	}
+	keys, err := auth.LoadKeySet(cfg.KeysDir, cfg.ActiveKID)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("JWT signing keys loaded", slog.String("active_kid", keys.ActiveKID()))
+	return keys, nil
+}
+
+func newMailer(cfg config.Mail, log *slog.Logger) service.Mailer {
+	switch cfg.Type {
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			log.Error("Mail type smtp needs MAIL_SMTP_HOST, logging emails instead")
+			break
+		}
+		return mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From)
+	case "file":
+		return mail.NewFileMailer(cfg.Dir, cfg.From)
+	case "log", "":
+	default:
+		log.Error("Unknown mail type, logging emails instead", slog.String("type", cfg.Type))
+	}
+	return mail.NewLogMailer(log)
+}
+
+func newRateLimitStore(cfg config.RateLimit, db *gorm.DB, log *slog.Logger) ratelimit.Store {
+	switch cfg.Store {
+	case "postgres":
+		store, err := ratelimit.NewPostgresStore(db)
+		if err == nil {
+			return store
+		}
+		log.Error("Failed to init rate limit table, counting in memory instead", sl.Err(err))
+	case "memory", "":
+	default:
+		log.Error("Unknown rate limit store, counting in memory instead", slog.String("store", cfg.Store))
+	}
+	return ratelimit.NewMemoryStore()
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"time"
+	"user/internal/domain/model"
+)
+
+func (r *UserRepository) CreateAuthEvent(e *model.AuthEvent) error {
+	return r.db.Create(e).Error
+}
+
+// GetAuthEvents lists the user's most recent sign-in events, newest first.
+func (r *UserRepository) GetAuthEvents(userID, limit int) ([]model.AuthEvent, error) {
+	var events []model.AuthEvent
+	err := r.db.Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+func (r *UserRepository) GetLoginThrottles(keys []string) ([]model.LoginThrottle, error) {
+	var throttles []model.LoginThrottle
+	err := r.db.Where("key IN ?", keys).Find(&throttles).Error
+	return throttles, err
+}
+
+// AddLoginFailure counts a failed sign-in for key and returns the count.
+// Failures before windowStart are forgotten and counting starts over.
+func (r *UserRepository) AddLoginFailure(key string, at, windowStart time.Time) (int, error) {
+	var failures int
+	err := r.db.Raw(`
+		INSERT INTO login_throttles (key, failures, last_failure_at)
+		VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failure_at < ?
+				THEN 1 ELSE login_throttles.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING failures`,
+		key, at, windowStart,
+	).Scan(&failures).Error
+	return failures, err
+}
+
+func (r *UserRepository) BlockLogin(key string, until time.Time) error {
+	return r.db.Model(&model.LoginThrottle{}).
+		Where("key = ?", key).
+		Update("blocked_until", until).Error
+}
+
+func (r *UserRepository) ClearLoginThrottle(key string) error {
+	return r.db.Delete(&model.LoginThrottle{}, "key = ?", key).Error
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"time"
+	"user/internal/domain/model"
+
+	"gorm.io/gorm"
+)
+
+// AdvanceTOTPStep records step as the last one a code was accepted for,
+// unless an equal or later one already was, so a code can only be used once
+// even by concurrent logins.
+func (r *UserRepository) AdvanceTOTPStep(userID int, step int64) (bool, error) {
+	res := r.db.Model(&model.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	return res.RowsAffected == 1, res.Error
+}
+
+// ReplaceRecoveryCodes swaps the user's recovery codes for new ones.
+func (r *UserRepository) ReplaceRecoveryCodes(userID int, hashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(hashes) == 0 {
+			return nil
+		}
+		codes := make([]model.RecoveryCode, len(hashes))
+		for i, hash := range hashes {
+			codes[i] = model.RecoveryCode{UserID: userID, CodeHash: hash}
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseRecoveryCode marks an unused code of the user as used, reporting
+// whether there was one.
+func (r *UserRepository) UseRecoveryCode(userID int, hash string, at time.Time) (bool, error) {
+	res := r.db.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", at)
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *UserRepository) CountUnusedRecoveryCodes(userID int) (int64, error) {
+	var n int64
+	err := r.db.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).Count(&n).Error
+	return n, err
+}
+
+func (r *UserRepository) CreateLoginChallenge(c *model.LoginChallenge) error {
+	return r.db.Create(c).Error
+}
+
+func (r *UserRepository) GetLoginChallenge(id string) (*model.LoginChallenge, error) {
+	var challenge model.LoginChallenge
+	if err := r.db.First(&challenge, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// CountChallengeAttempt records a wrong code against the challenge, but
+// only while it has attempts left.
+func (r *UserRepository) CountChallengeAttempt(id string, maxAttempts int) (bool, error) {
+	res := r.db.Model(&model.LoginChallenge{}).
+		Where("id = ? AND attempts < ?", id, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	return res.RowsAffected == 1, res.Error
+}
+
+// DeleteLoginChallenge removes the challenge, reporting whether it was still
+// there, so only one redemption of it can succeed.
+func (r *UserRepository) DeleteLoginChallenge(id string) (bool, error) {
+	res := r.db.Delete(&model.LoginChallenge{}, "id = ?", id)
+	return res.RowsAffected == 1, res.Error
+}
+
+// DeleteExpiredLoginChallenges clears challenges that can no longer be
+// redeemed.
+func (r *UserRepository) DeleteExpiredLoginChallenges(now time.Time) error {
+	return r.db.Delete(&model.LoginChallenge{}, "expires_at < ?", now).Error
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"time"
+	"user/internal/domain/model"
+)
+
+func (r *UserRepository) CreateSession(s *model.Session) error {
+	return r.db.Create(s).Error
+}
+
+func (r *UserRepository) GetSessionByID(id string) (*model.Session, error) {
+	var session model.Session
+	if err := r.db.First(&session, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *UserRepository) GetSessionByRefreshHash(hash string) (*model.Session, error) {
+	var session model.Session
+	if err := r.db.First(&session, "refresh_token_hash = ?", hash).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *UserRepository) GetSessionByPreviousHash(hash string) (*model.Session, error) {
+	var session model.Session
+	if err := r.db.First(&session, "previous_token_hash = ?", hash).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RotateSession swaps the session's refresh token hash, but only if it is
+// still oldHash, so two concurrent refreshes can't both succeed.
+func (r *UserRepository) RotateSession(id, oldHash, newHash string, lastUsed, expires time.Time) (bool, error) {
+	res := r.db.Model(&model.Session{}).
+		Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", id, oldHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  newHash,
+			"previous_token_hash": oldHash,
+			"last_used_at":        lastUsed,
+			"expires_at":          expires,
+		})
+	return res.RowsAffected == 1, res.Error
+}
+
+// GetActiveSessions lists the user's unrevoked, unexpired sessions, most
+// recently used first.
+func (r *UserRepository) GetActiveSessions(userID int, now time.Time) ([]model.Session, error) {
+	var sessions []model.Session
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_used_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *UserRepository) RevokeSession(id string, at time.Time) error {
+	return r.db.Model(&model.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at).Error
+}
+
+// RevokeUserSessions revokes every active session of the user except keep.
+func (r *UserRepository) RevokeUserSessions(userID int, keep string, at time.Time) (int64, error) {
+	res := r.db.Model(&model.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keep).
+		Update("revoked_at", at)
+	return res.RowsAffected, res.Error
+}
+
+// GetRevokedSessionIDs lists sessions revoked since the given time.
+func (r *UserRepository) GetRevokedSessionIDs(since time.Time) ([]model.Session, error) {
+	var sessions []model.Session
+	err := r.db.Select("id", "revoked_at").
+		Where("revoked_at > ?", since).
+		Order("revoked_at ASC").Find(&sessions).Error
+	return sessions, err
+}
@@ -1,8 +1,93 @@
 package model
 
+import "time"
+
 type User struct {
 	ID       int    `json:"id" gorm:"primaryKey"`
 	Username string `json:"username" gorm:"not null"`
 	Email    string `json:"email" gorm:"uniqueIndex"`
 	Password string `json:"-" gorm:"not null"`
+
+	// EmailVerified is set when a link sent to Email is followed, and
+	// cleared when Email changes.
+	EmailVerified bool `json:"email_verified" gorm:"not null;default:false"`
+
+	// TOTPEnabled is set once an authenticator is confirmed; login then asks
+	// for a code. TOTPPendingSecret holds a secret being enrolled until it is
+	// confirmed, and TOTPLastStep the last time step a code was accepted
+	// for, so codes can't be replayed.
+	TOTPEnabled       bool   `json:"totp_enabled" gorm:"column:totp_enabled;not null;default:false"`
+	TOTPSecret        string `json:"-" gorm:"column:totp_secret"`
+	TOTPPendingSecret string `json:"-" gorm:"column:totp_pending_secret"`
+	TOTPLastStep      int64  `json:"-" gorm:"column:totp_last_step;not null;default:0"`
+}
+
+// RecoveryCode is a single-use code that stands in for a TOTP code when the
+// authenticator is lost. Only its hash is stored.
+type RecoveryCode struct {
+	ID       int    `gorm:"primaryKey"`
+	UserID   int    `gorm:"index;not null"`
+	CodeHash string `gorm:"size:64;not null"`
+	UsedAt   *time.Time
+}
+
+// LoginChallenge is a password-checked login waiting for a TOTP code. The
+// client holds the token; ID is its hash.
+type LoginChallenge struct {
+	ID        string `gorm:"primaryKey;size:64"`
+	UserID    int    `gorm:"index;not null"`
+	Attempts  int    `gorm:"not null;default:0"`
+	ExpiresAt time.Time
+}
+
+type AuthEventType string
+
+const (
+	AuthEventLoginSucceeded AuthEventType = "login_succeeded"
+	AuthEventLoginFailed    AuthEventType = "login_failed"
+	AuthEventLoginBlocked   AuthEventType = "login_blocked"
+	AuthEventMFAChallenged  AuthEventType = "mfa_challenged"
+	AuthEventMFAFailed      AuthEventType = "mfa_failed"
+)
+
+// AuthEvent records a sign-in attempt. UserID is nil when the username
+// matched no account.
+type AuthEvent struct {
+	ID        int           `json:"id" gorm:"primaryKey"`
+	UserID    *int          `json:"-" gorm:"index"`
+	Username  string        `json:"username"`
+	Type      AuthEventType `json:"type" gorm:"size:32;not null"`
+	IP        string        `json:"ip"`
+	UserAgent string        `json:"user_agent"`
+	CreatedAt time.Time     `json:"created_at" gorm:"index"`
+}
+
+// LoginThrottle counts recent failed sign-ins for one username or client
+// address; Key is "user:<name>" or "ip:<address>". Sign-in is refused
+// until BlockedUntil.
+type LoginThrottle struct {
+	Key           string `gorm:"primaryKey;size:255"`
+	Failures      int    `gorm:"not null"`
+	LastFailureAt time.Time
+	BlockedUntil  *time.Time
+}
+
+// Session is one signed-in device. Its refresh token is stored hashed and
+// replaced on every refresh; PreviousTokenHash keeps the one it replaced so a
+// replayed token can be recognised.
+type Session struct {
+	ID                string     `json:"id" gorm:"primaryKey;size:32"`
+	UserID            int        `json:"-" gorm:"index;not null"`
+	RefreshTokenHash  string     `json:"-" gorm:"uniqueIndex;size:64;not null"`
+	PreviousTokenHash string     `json:"-" gorm:"index;size:64"`
+	UserAgent         string     `json:"user_agent"`
+	IP                string     `json:"ip"`
+	CreatedAt         time.Time  `json:"created_at"`
+	LastUsedAt        time.Time  `json:"last_used_at"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty" gorm:"index"`
+}
+
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
 }
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+	"user/internal/domain/model"
+	"user/internal/infra/auth"
+	"user/pkg/logger/sl"
+)
+
+var (
+	ErrInvalidLink          = errors.New("link is invalid, expired or already used")
+	ErrNoEmail              = errors.New("no email address on the account")
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+)
+
+const mailTimeout = time.Minute
+
+// Mailer sends an email. Implementations live in infra/mail.
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// EmailConfig sets where emailed links point and how long they work.
+type EmailConfig struct {
+	AppURL          string
+	VerificationTTL time.Duration
+	ResetTTL        time.Duration
+}
+
+// UseMailer enables account emails. Without a mailer none are sent.
+func (s *UserService) UseMailer(m Mailer, cfg EmailConfig) {
+	s.mailer, s.email = m, cfg
+}
+
+// VerifyEmail marks the address a verification link was sent to as
+// verified. The link stops working once used or once the address changes.
+func (s *UserService) VerifyEmail(token string) error {
+	const tag = "service.VerifyEmail"
+
+	claims, err := auth.ParseActionToken(token, auth.PurposeVerifyEmail)
+	if err != nil {
+		return fmt.Errorf("%s: %w", tag, ErrInvalidLink)
+	}
+	user, err := s.repo.GetByID(claims.UserID)
+	if err != nil || user == nil || user.EmailVerified || !strings.EqualFold(user.Email, claims.Binding) {
+		return fmt.Errorf("%s: %w", tag, ErrInvalidLink)
+	}
+
+	user.EmailVerified = true
+	if _, err := s.repo.Update(user); err != nil {
+		return fmt.Errorf("%s: %w", tag, err)
+	}
+	return nil
+}
+
+// ResendVerification sends the user a new verification link.
+func (s *UserService) ResendVerification(userID int) error {
+	const tag = "service.ResendVerification"
+
+	user, err := s.repo.GetByID(userID)
+	if err != nil || user == nil {
+		return fmt.Errorf("%s: %w", tag, ErrUserNotFound)
+	}
+	if user.Email == "" {
+		return fmt.Errorf("%s: %w", tag, ErrNoEmail)
+	}
+	if user.EmailVerified {
+		return fmt.Errorf("%s: %w", tag, ErrEmailAlreadyVerified)
+	}
+	if err := s.sendVerification(user); err != nil {
+		return fmt.Errorf("%s: %w", tag, err)
+	}
+	return nil
+}
+
+// ForgotPassword emails a password reset link if the address belongs to an
+// account. It reports nothing either way, so it can't be used to find out
+// which addresses are registered.
+func (s *UserService) ForgotPassword(email string) {
+	user, err := s.repo.GetUserByEmail(email)
+	if err != nil || user == nil {
+		return
+	}
+
+	token, err := auth.GenerateActionToken(auth.PurposeResetPassword, user.ID, passwordFingerprint(user.Password), s.email.ResetTTL)
+	if err != nil {
+		s.logError("failed to issue password reset token", user.ID, err)
+		return
+	}
+	body := fmt.Sprintf("Someone asked to reset the password of your Bux account %s.\n\n"+
+		"To choose a new password, open this link within %s:\n%s\n\n"+
+		"If it wasn't you, ignore this email; your password stays as it is.\n",
+		user.Username, s.email.ResetTTL, s.link("/reset-password", token))
+	s.sendAsync(user, "Reset your Bux password", body)
+}
+
+// ResetPassword sets a new password with a reset link and signs out every
+// session. The link stops working once the password has changed.
+func (s *UserService) ResetPassword(token, newPassword string) error {
+	const tag = "service.ResetPassword"
+
+	claims, err := auth.ParseActionToken(token, auth.PurposeResetPassword)
+	if err != nil {
+		return fmt.Errorf("%s: %w", tag, ErrInvalidLink)
+	}
+	user, err := s.repo.GetByID(claims.UserID)
+	if err != nil || user == nil || passwordFingerprint(user.Password) != claims.Binding {
+		return fmt.Errorf("%s: %w", tag, ErrInvalidLink)
+	}
+	if len(newPassword) < 8 {
+		return fmt.Errorf("%s: %w", tag, ErrPasswordTooShort)
+	}
+
+	hashedPassword, err := auth.HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("%s: %w", tag, ErrInvalidPassword)
+	}
+	user.Password = hashedPassword
+	// The link reached the inbox, which proves the address
+	user.EmailVerified = true
+	if _, err := s.repo.Update(user); err != nil {
+		return fmt.Errorf("%s: %w", tag, err)
+	}
+
+	if _, err := s.RevokeOtherSessions(user.ID, ""); err != nil {
+		return fmt.Errorf("%s: %w", tag, err)
+	}
+	s.clearLoginFailures(user.Username)
+	return nil
+}
+
+// sendVerification emails a link verifying the user's current address.
+func (s *UserService) sendVerification(user *model.User) error {
+	token, err := auth.GenerateActionToken(auth.PurposeVerifyEmail, user.ID, user.Email, s.email.VerificationTTL)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("Welcome to Bux, %s!\n\n"+
+		"Please confirm your email address by opening this link within %s:\n%s\n",
+		user.Username, s.email.VerificationTTL, s.link("/verify-email", token))
+	s.sendAsync(user, "Confirm your email for Bux", body)
+	return nil
+}
+
+// sendAsync sends in the background, so responses don't wait on the mail
+// server and take the same time whether or not an email went out. Failures
+// are only logged.
+func (s *UserService) sendAsync(user *model.User, subject, body string) {
+	if s.mailer == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+		if err := s.mailer.Send(ctx, user.Email, subject, body); err != nil {
+			s.logError("failed to send email", user.ID, err)
+		}
+	}()
+}
+
+func (s *UserService) link(path, token string) string {
+	return strings.TrimRight(s.email.AppURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+func (s *UserService) logError(msg string, userID int, err error) {
+	if s.log != nil {
+		s.log.Error(msg, slog.Int("user_id", userID), sl.Err(err))
+	}
+}
+
+// passwordFingerprint identifies the current password hash without
+// revealing it, so a reset link dies once the password changes.
+func passwordFingerprint(hash string) string {
+	sum := sha256.Sum256([]byte(hash))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,299 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+	"user/internal/domain/model"
+	"user/internal/infra/auth"
+	"user/internal/infra/mail"
+
+	"gorm.io/gorm"
+)
+
+// memoryUsers keeps users in memory for the email flows. Methods those
+// flows don't reach are left to the embedded nil interface.
+type memoryUsers struct {
+	UserRepository
+
+	mu      sync.Mutex
+	users   map[int]model.User
+	revoked []int
+}
+
+func newMemoryUsers() *memoryUsers {
+	return &memoryUsers{users: map[int]model.User{}}
+}
+
+func (r *memoryUsers) Create(user *model.User) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user.ID = len(r.users) + 1
+	r.users[user.ID] = *user
+	return user, nil
+}
+
+func (r *memoryUsers) GetByID(id int) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+func (r *memoryUsers) GetUserByUsername(username string) (*model.User, error) {
+	return r.find(func(u model.User) bool { return u.Username == username })
+}
+
+func (r *memoryUsers) GetUserByEmail(email string) (*model.User, error) {
+	return r.find(func(u model.User) bool { return u.Email == email })
+}
+
+func (r *memoryUsers) find(match func(model.User) bool) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if match(u) {
+			return &u, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryUsers) Update(user *model.User) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.ID] = *user
+	return user, nil
+}
+
+func (r *memoryUsers) RevokeUserSessions(userID int, keep string, at time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revoked = append(r.revoked, userID)
+	return 1, nil
+}
+
+func (r *memoryUsers) ClearLoginThrottle(key string) error {
+	return nil
+}
+
+// newEmailService returns a service whose emails land in a temporary
+// directory, and that directory.
+func newEmailService(t *testing.T, cfg EmailConfig) (*UserService, *memoryUsers, string) {
+	t.Helper()
+	keys, err := auth.GenerateKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.UseKeySet(keys, "bux-test", "bux-test")
+
+	repo := newMemoryUsers()
+	dir := t.TempDir()
+	s := New(repo, SessionConfig{}, MFAConfig{}, LockoutConfig{}, nil)
+	s.UseMailer(mail.NewFileMailer(dir, "Bux <no-reply@example.com>"), cfg)
+	return s, repo, dir
+}
+
+var defaultEmailConfig = EmailConfig{
+	AppURL:          "https://bux.example.com/",
+	VerificationTTL: time.Hour,
+	ResetTTL:        time.Hour,
+}
+
+// linkToken matches the token of an emailed link; the body always goes on
+// after the link, so a match means the email was fully written.
+var linkToken = regexp.MustCompile(`\?token=(\S+)\n`)
+
+// waitForLink waits for the nth email, counting from 1, and returns the
+// token of the link in it. Emails are sent in the background.
+func waitForLink(t *testing.T, dir string, n int) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		names, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+		if len(names) >= n {
+			slices.Sort(names)
+			data, err := os.ReadFile(names[n-1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m := linkToken.FindSubmatch(data); m != nil {
+				token, err := url.QueryUnescape(string(m[1]))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return token
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("email %d was not sent", n)
+	return ""
+}
+
+func seedUser(t *testing.T, repo *memoryUsers, email, password string) *model.User {
+	t.Helper()
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _ := repo.Create(&model.User{Username: "alice", Email: email, Password: hash})
+	return user
+}
+
+func TestVerifyEmail(t *testing.T) {
+	s, repo, dir := newEmailService(t, defaultEmailConfig)
+
+	user, err := s.RegisterUser(&model.User{Username: "alice", Email: "alice@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := waitForLink(t, dir, 1)
+
+	if err := s.VerifyEmail(token); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	if got, _ := repo.GetByID(user.ID); !got.EmailVerified {
+		t.Fatal("email not marked verified")
+	}
+	if err := s.VerifyEmail(token); !errors.Is(err, ErrInvalidLink) {
+		t.Fatalf("second use: err = %v, want ErrInvalidLink", err)
+	}
+}
+
+func TestVerifyEmailExpired(t *testing.T) {
+	cfg := defaultEmailConfig
+	cfg.VerificationTTL = -time.Minute
+	s, repo, dir := newEmailService(t, cfg)
+
+	user, err := s.RegisterUser(&model.User{Username: "alice", Email: "alice@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.VerifyEmail(waitForLink(t, dir, 1)); !errors.Is(err, ErrInvalidLink) {
+		t.Fatalf("err = %v, want ErrInvalidLink", err)
+	}
+	if got, _ := repo.GetByID(user.ID); got.EmailVerified {
+		t.Fatal("expired link verified the email")
+	}
+}
+
+func TestVerifyEmailBoundToAddress(t *testing.T) {
+	s, repo, dir := newEmailService(t, defaultEmailConfig)
+
+	user, err := s.RegisterUser(&model.User{Username: "alice", Email: "alice@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken := waitForLink(t, dir, 1)
+
+	if _, err := s.UpdateProfile(user.ID, "", "alice@example.org"); err != nil {
+		t.Fatal(err)
+	}
+	newToken := waitForLink(t, dir, 2)
+
+	// The first link proves the old address, not the new one
+	if err := s.VerifyEmail(oldToken); !errors.Is(err, ErrInvalidLink) {
+		t.Fatalf("link for the old address: err = %v, want ErrInvalidLink", err)
+	}
+	if err := s.VerifyEmail(newToken); err != nil {
+		t.Fatalf("link for the new address: %v", err)
+	}
+	if got, _ := repo.GetByID(user.ID); !got.EmailVerified || got.Email != "alice@example.org" {
+		t.Fatalf("user = %+v, want alice@example.org verified", got)
+	}
+}
+
+func TestVerifyEmailRejectsResetLink(t *testing.T) {
+	s, repo, dir := newEmailService(t, defaultEmailConfig)
+	seedUser(t, repo, "alice@example.com", "correct horse")
+
+	s.ForgotPassword("alice@example.com")
+	if err := s.VerifyEmail(waitForLink(t, dir, 1)); !errors.Is(err, ErrInvalidLink) {
+		t.Fatalf("err = %v, want ErrInvalidLink", err)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	s, repo, dir := newEmailService(t, defaultEmailConfig)
+	user := seedUser(t, repo, "alice@example.com", "correct horse")
+
+	s.ForgotPassword("alice@example.com")
+	token := waitForLink(t, dir, 1)
+
+	if err := s.ResetPassword(token, "short"); !errors.Is(err, ErrPasswordTooShort) {
+		t.Fatalf("short password: err = %v, want ErrPasswordTooShort", err)
+	}
+	// A refused password leaves the link usable
+	if err := s.ResetPassword(token, "battery staple"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+
+	got, _ := repo.GetByID(user.ID)
+	if !auth.CheckPasswordHash("battery staple", got.Password) {
+		t.Fatal("password not changed")
+	}
+	if !got.EmailVerified {
+		t.Fatal("email not marked verified by the reset link")
+	}
+	if !slices.Equal(repo.revoked, []int{user.ID}) {
+		t.Fatalf("revoked sessions of %v, want [%d]", repo.revoked, user.ID)
+	}
+
+	if err := s.ResetPassword(token, "another password"); !errors.Is(err, ErrInvalidLink) {
+		t.Fatalf("second use: err = %v, want ErrInvalidLink", err)
+	}
+	if got, _ := repo.GetByID(user.ID); !auth.CheckPasswordHash("battery staple", got.Password) {
+		t.Fatal("reused link changed the password")
+	}
+}
+
+func TestResetPasswordAfterPasswordChange(t *testing.T) {
+	s, repo, dir := newEmailService(t, defaultEmailConfig)
+	user := seedUser(t, repo, "alice@example.com", "correct horse")
+
+	s.ForgotPassword("alice@example.com")
+	token := waitForLink(t, dir, 1)
+
+	if err := s.UpdatePassword(user.ID, "", "correct horse", "battery staple"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ResetPassword(token, "another password"); !errors.Is(err, ErrInvalidLink) {
+		t.Fatalf("err = %v, want ErrInvalidLink", err)
+	}
+}
+
+func TestResetPasswordExpired(t *testing.T) {
+	cfg := defaultEmailConfig
+	cfg.ResetTTL = -time.Minute
+	s, repo, dir := newEmailService(t, cfg)
+	user := seedUser(t, repo, "alice@example.com", "correct horse")
+
+	s.ForgotPassword("alice@example.com")
+	if err := s.ResetPassword(waitForLink(t, dir, 1), "battery staple"); !errors.Is(err, ErrInvalidLink) {
+		t.Fatalf("err = %v, want ErrInvalidLink", err)
+	}
+	if got, _ := repo.GetByID(user.ID); !auth.CheckPasswordHash("correct horse", got.Password) {
+		t.Fatal("expired link changed the password")
+	}
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	s, repo, dir := newEmailService(t, defaultEmailConfig)
+	seedUser(t, repo, "alice@example.com", "correct horse")
+
+	// Nothing is sent, so no background send can still be on its way
+	s.ForgotPassword("bob@example.com")
+	if names, _ := filepath.Glob(filepath.Join(dir, "*.eml")); len(names) != 0 {
+		t.Fatalf("sent %d emails for an unknown address", len(names))
+	}
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"user/internal/domain/model"
+	"user/pkg/logger/sl"
+)
+
+var ErrLoginThrottled = errors.New("too many failed sign-in attempts")
+
+// ThrottleError refuses a sign-in until RetryAfter has passed.
+type ThrottleError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return fmt.Sprintf("%s, try again in %s", ErrLoginThrottled, e.RetryAfter.Round(time.Second))
+}
+
+func (e *ThrottleError) Unwrap() error {
+	return ErrLoginThrottled
+}
+
+// LockoutConfig sets how failed sign-ins slow down further attempts. After
+// FreeAttempts failures on a username each failure makes it wait, starting
+// at BaseDelay and doubling up to MaxDelay; at LockoutAfter it is locked
+// for LockoutDuration. A client address trying many usernames is locked
+// after IPLockoutAfter failures. Failures older than Window are forgotten.
+type LockoutConfig struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	IPLockoutAfter  int
+	Window          time.Duration
+}
+
+const maxAuthEvents = 100
+
+// GetAuthEvents lists the user's recent sign-in activity, newest first.
+func (s *UserService) GetAuthEvents(userID, limit int) ([]model.AuthEvent, error) {
+	const tag = "service.GetAuthEvents"
+
+	if limit <= 0 || limit > maxAuthEvents {
+		limit = maxAuthEvents
+	}
+	events, err := s.repo.GetAuthEvents(userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	return events, nil
+}
+
+// checkLoginThrottle refuses the attempt while the username or the client
+// address is blocked.
+func (s *UserService) checkLoginThrottle(username, ip string, now time.Time) error {
+	throttles, err := s.repo.GetLoginThrottles([]string{userThrottleKey(username), ipThrottleKey(ip)})
+	if err != nil {
+		return err
+	}
+	var wait time.Duration
+	for _, t := range throttles {
+		if t.BlockedUntil != nil && t.BlockedUntil.After(now) {
+			wait = max(wait, t.BlockedUntil.Sub(now))
+		}
+	}
+	if wait > 0 {
+		return &ThrottleError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a wrong password or code against the username
+// and the client address and blocks them as the counts grow. Failures are
+// logged rather than returned; the sign-in has failed either way.
+func (s *UserService) recordLoginFailure(username, ip string, now time.Time) {
+	windowStart := now.Add(-s.lockout.Window)
+
+	key := userThrottleKey(username)
+	failures, err := s.repo.AddLoginFailure(key, now, windowStart)
+	if err != nil {
+		s.logThrottleError(key, err)
+	} else if wait := s.lockout.userDelay(failures); wait > 0 {
+		if err := s.repo.BlockLogin(key, now.Add(wait)); err != nil {
+			s.logThrottleError(key, err)
+		}
+	}
+
+	if ip == "" {
+		return
+	}
+	key = ipThrottleKey(ip)
+	failures, err = s.repo.AddLoginFailure(key, now, windowStart)
+	if err != nil {
+		s.logThrottleError(key, err)
+	} else if s.lockout.IPLockoutAfter > 0 && failures >= s.lockout.IPLockoutAfter {
+		if err := s.repo.BlockLogin(key, now.Add(s.lockout.LockoutDuration)); err != nil {
+			s.logThrottleError(key, err)
+		}
+	}
+}
+
+// clearLoginFailures forgets the username's failures after a sign-in. The
+// client address keeps its count, or one valid account would let it keep
+// guessing at others.
+func (s *UserService) clearLoginFailures(username string) {
+	key := userThrottleKey(username)
+	if err := s.repo.ClearLoginThrottle(key); err != nil {
+		s.logThrottleError(key, err)
+	}
+}
+
+// userDelay is how long a username waits after its nth failure in a row.
+func (c LockoutConfig) userDelay(failures int) time.Duration {
+	switch {
+	case c.LockoutAfter > 0 && failures >= c.LockoutAfter:
+		return c.LockoutDuration
+	case failures <= c.FreeAttempts:
+		return 0
+	}
+	delay := c.BaseDelay
+	for i := c.FreeAttempts + 1; i < failures && delay < c.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, c.MaxDelay)
+}
+
+// recordAuthEvent stores a sign-in event; failing to is only logged.
+func (s *UserService) recordAuthEvent(typ model.AuthEventType, userID *int, username string, client ClientInfo) {
+	event := &model.AuthEvent{
+		UserID:    userID,
+		Username:  truncate(username, 255),
+		Type:      typ,
+		IP:        client.IP,
+		UserAgent: truncate(client.UserAgent, 255),
+	}
+	if err := s.repo.CreateAuthEvent(event); err != nil && s.log != nil {
+		s.log.Error("failed to record auth event", slog.String("type", string(typ)), sl.Err(err))
+	}
+}
+
+func (s *UserService) logThrottleError(key string, err error) {
+	if s.log != nil {
+		s.log.Error("failed to update login throttle", slog.String("key", key), sl.Err(err))
+	}
+}
+
+func userThrottleKey(username string) string {
+	return "user:" + truncate(strings.ToLower(username), 200)
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
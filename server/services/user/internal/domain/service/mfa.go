@@ -0,0 +1,324 @@
+package service
+
+import (
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"user/internal/domain/model"
+	"user/internal/infra/auth"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTOTPNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrNoPendingTOTP      = errors.New("no authenticator enrolment in progress")
+	ErrInvalidOTP         = errors.New("invalid two-factor code")
+	ErrInvalidChallenge   = errors.New("invalid or expired login challenge")
+)
+
+const (
+	recoveryCodeCount = 10
+	// maxChallengeAttempts is how many codes one login challenge takes
+	// before it has to be started again with the password.
+	maxChallengeAttempts = 5
+)
+
+// MFAConfig names the issuer authenticator apps show and sets how long a
+// login challenge waits for its code.
+type MFAConfig struct {
+	Issuer       string
+	ChallengeTTL time.Duration
+}
+
+// TOTPEnrollment is a secret waiting to be confirmed with a code from the
+// authenticator it was added to.
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// Challenge is the second login step: the token is redeemed together with a
+// TOTP or recovery code for the session's tokens.
+type Challenge struct {
+	Token     string    `json:"challenge_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type MFAStatus struct {
+	Enabled           bool  `json:"enabled"`
+	Pending           bool  `json:"pending"`
+	RecoveryCodesLeft int64 `json:"recovery_codes_left"`
+}
+
+// GetMFAStatus reports whether the user has 2FA on and how many recovery
+// codes are left.
+func (s *UserService) GetMFAStatus(userID int) (*MFAStatus, error) {
+	const tag = "service.GetMFAStatus"
+
+	user, err := s.repo.GetByID(userID)
+	if err != nil || user == nil {
+		return nil, fmt.Errorf("%s: %w", tag, ErrUserNotFound)
+	}
+	left, err := s.repo.CountUnusedRecoveryCodes(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	return &MFAStatus{Enabled: user.TOTPEnabled, Pending: user.TOTPPendingSecret != "", RecoveryCodesLeft: left}, nil
+}
+
+// EnrollTOTP starts adding an authenticator. 2FA stays off until the secret
+// is confirmed with ConfirmTOTP.
+func (s *UserService) EnrollTOTP(userID int) (*TOTPEnrollment, error) {
+	const tag = "service.EnrollTOTP"
+
+	user, err := s.repo.GetByID(userID)
+	if err != nil || user == nil {
+		return nil, fmt.Errorf("%s: %w", tag, ErrUserNotFound)
+	}
+	if user.TOTPEnabled {
+		return nil, fmt.Errorf("%s: %w", tag, ErrTOTPAlreadyEnabled)
+	}
+	enrollment, err := s.beginEnrollment(user)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	return enrollment, nil
+}
+
+// ResetTOTP starts moving 2FA to a new authenticator, for a lost or replaced
+// device. The current one keeps working until the new one is confirmed.
+func (s *UserService) ResetTOTP(userID int, password string) (*TOTPEnrollment, error) {
+	const tag = "service.ResetTOTP"
+
+	user, err := s.userWithPassword(userID, password)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	if !user.TOTPEnabled {
+		return nil, fmt.Errorf("%s: %w", tag, ErrTOTPNotEnabled)
+	}
+	enrollment, err := s.beginEnrollment(user)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	return enrollment, nil
+}
+
+func (s *UserService) beginEnrollment(user *model.User) (*TOTPEnrollment, error) {
+	user.TOTPPendingSecret = auth.GenerateTOTPSecret()
+	if _, err := s.repo.Update(user); err != nil {
+		return nil, err
+	}
+	account := user.Email
+	if account == "" {
+		account = user.Username
+	}
+	return &TOTPEnrollment{
+		Secret:          user.TOTPPendingSecret,
+		ProvisioningURI: auth.TOTPProvisioningURI(s.mfa.Issuer, account, user.TOTPPendingSecret),
+	}, nil
+}
+
+// ConfirmTOTP switches 2FA to the pending secret once code proves the
+// authenticator has it. It returns fresh recovery codes, shown only here,
+// and signs out every other session.
+func (s *UserService) ConfirmTOTP(userID int, sessionID, code string) ([]string, error) {
+	const tag = "service.ConfirmTOTP"
+
+	user, err := s.repo.GetByID(userID)
+	if err != nil || user == nil {
+		return nil, fmt.Errorf("%s: %w", tag, ErrUserNotFound)
+	}
+	if user.TOTPPendingSecret == "" {
+		return nil, fmt.Errorf("%s: %w", tag, ErrNoPendingTOTP)
+	}
+	step, ok := auth.ValidateTOTP(user.TOTPPendingSecret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", tag, ErrInvalidOTP)
+	}
+
+	user.TOTPEnabled = true
+	user.TOTPSecret = user.TOTPPendingSecret
+	user.TOTPPendingSecret = ""
+	user.TOTPLastStep = step
+	if _, err := s.repo.Update(user); err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+
+	codes, err := s.issueRecoveryCodes(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	if _, err := s.RevokeOtherSessions(userID, sessionID); err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	return codes, nil
+}
+
+// DisableTOTP turns 2FA off and discards the recovery codes.
+func (s *UserService) DisableTOTP(userID int, password string) error {
+	const tag = "service.DisableTOTP"
+
+	user, err := s.userWithPassword(userID, password)
+	if err != nil {
+		return fmt.Errorf("%s: %w", tag, err)
+	}
+	if !user.TOTPEnabled && user.TOTPPendingSecret == "" {
+		return fmt.Errorf("%s: %w", tag, ErrTOTPNotEnabled)
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPPendingSecret = ""
+	user.TOTPLastStep = 0
+	if _, err := s.repo.Update(user); err != nil {
+		return fmt.Errorf("%s: %w", tag, err)
+	}
+	if err := s.repo.ReplaceRecoveryCodes(userID, nil); err != nil {
+		return fmt.Errorf("%s: %w", tag, err)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes, used or not.
+func (s *UserService) RegenerateRecoveryCodes(userID int, password string) ([]string, error) {
+	const tag = "service.RegenerateRecoveryCodes"
+
+	user, err := s.userWithPassword(userID, password)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	if !user.TOTPEnabled {
+		return nil, fmt.Errorf("%s: %w", tag, ErrTOTPNotEnabled)
+	}
+	codes, err := s.issueRecoveryCodes(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	return codes, nil
+}
+
+// CompleteLogin redeems a login challenge with a TOTP code or a recovery
+// code and starts the session. A challenge takes a few wrong codes before
+// the password has to be entered again.
+func (s *UserService) CompleteLogin(challengeToken, code string, client ClientInfo) (*model.User, *TokenPair, error) {
+	const tag = "service.CompleteLogin"
+
+	id := hashToken(challengeToken)
+	challenge, err := s.repo.GetLoginChallenge(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, fmt.Errorf("%s: %w", tag, ErrInvalidChallenge)
+		}
+		return nil, nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		return nil, nil, fmt.Errorf("%s: %w", tag, ErrInvalidChallenge)
+	}
+	// Count the attempt before checking the code so parallel guesses can't
+	// exceed the limit
+	ok, err := s.repo.CountChallengeAttempt(id, maxChallengeAttempts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: %w", tag, ErrInvalidChallenge)
+	}
+
+	user, err := s.repo.GetByID(challenge.UserID)
+	if err != nil || user == nil {
+		return nil, nil, fmt.Errorf("%s: %w", tag, ErrUserNotFound)
+	}
+	now := time.Now()
+	if err := s.checkLoginThrottle(user.Username, client.IP, now); err != nil {
+		s.recordAuthEvent(model.AuthEventLoginBlocked, &user.ID, user.Username, client)
+		return nil, nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	ok, err = s.verifySecondFactor(user, code)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	if !ok {
+		s.recordLoginFailure(user.Username, client.IP, now)
+		s.recordAuthEvent(model.AuthEventMFAFailed, &user.ID, user.Username, client)
+		return nil, nil, fmt.Errorf("%s: %w", tag, ErrInvalidOTP)
+	}
+
+	if ok, err := s.repo.DeleteLoginChallenge(id); err != nil || !ok {
+		return nil, nil, fmt.Errorf("%s: %w", tag, ErrInvalidChallenge)
+	}
+	tokens, err := s.StartSession(user.ID, client)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	s.clearLoginFailures(user.Username)
+	s.recordAuthEvent(model.AuthEventLoginSucceeded, &user.ID, user.Username, client)
+	return user, tokens, nil
+}
+
+// startChallenge opens the second login step for a user whose password
+// checked out.
+func (s *UserService) startChallenge(userID int) (*Challenge, error) {
+	now := time.Now()
+	// Housekeeping; a failure here doesn't stop the login
+	_ = s.repo.DeleteExpiredLoginChallenges(now)
+
+	token := auth.RandomToken(32)
+	challenge := &model.LoginChallenge{
+		ID:        hashToken(token),
+		UserID:    userID,
+		ExpiresAt: now.Add(s.mfa.ChallengeTTL),
+	}
+	if err := s.repo.CreateLoginChallenge(challenge); err != nil {
+		return nil, err
+	}
+	return &Challenge{Token: token, ExpiresAt: challenge.ExpiresAt}, nil
+}
+
+// verifySecondFactor accepts a six-digit TOTP code, each at most once, or an
+// unused recovery code, which is then spent.
+func (s *UserService) verifySecondFactor(user *model.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == 6 && strings.Trim(code, "0123456789") == "" {
+		step, ok := auth.ValidateTOTP(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		return s.repo.AdvanceTOTPStep(user.ID, step)
+	}
+	return s.repo.UseRecoveryCode(user.ID, hashToken(normalizeRecoveryCode(code)), time.Now())
+}
+
+func (s *UserService) issueRecoveryCodes(userID int) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(auth.RandomBytes(6)))
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = hashToken(raw)
+	}
+	if err := s.repo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// userWithPassword loads the user, requiring their current password.
+func (s *UserService) userWithPassword(userID int, password string) (*model.User, error) {
+	user, err := s.repo.GetByID(userID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+	if !auth.CheckPasswordHash(password, user.Password) {
+		return nil, ErrPasswordMismatch
+	}
+	return user, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+	"user/internal/domain/model"
+	"user/internal/infra/auth"
+)
+
+// AdvanceTOTPStep moves the last accepted step forward only, like the
+// conditional update of the repository.
+func (r *memoryUsers) AdvanceTOTPStep(userID int, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user := r.users[userID]
+	if user.TOTPLastStep >= step {
+		return false, nil
+	}
+	user.TOTPLastStep = step
+	r.users[userID] = user
+	return true, nil
+}
+
+// totpAt is the code an authenticator shows for secret steps away from now.
+func totpAt(t *testing.T, secret string, steps int64) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(time.Now().Unix()/30+steps))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+func newTOTPUser(t *testing.T) (*UserService, *model.User) {
+	t.Helper()
+	repo := newMemoryUsers()
+	user, _ := repo.Create(&model.User{Username: "alice", TOTPEnabled: true, TOTPSecret: auth.GenerateTOTPSecret()})
+	return New(repo, SessionConfig{}, MFAConfig{}, LockoutConfig{}, nil), user
+}
+
+func TestVerifySecondFactorRejectsReplay(t *testing.T) {
+	s, user := newTOTPUser(t)
+	code := totpAt(t, user.TOTPSecret, 0)
+
+	if ok, err := s.verifySecondFactor(user, code); err != nil || !ok {
+		t.Fatalf("first use = %v, %v; want accepted", ok, err)
+	}
+	if ok, err := s.verifySecondFactor(user, code); err != nil || ok {
+		t.Fatalf("second use = %v, %v; want rejected", ok, err)
+	}
+}
+
+func TestVerifySecondFactorRejectsEarlierStep(t *testing.T) {
+	s, user := newTOTPUser(t)
+
+	// Once a code of the next step is in, the current one is stale although
+	// still inside the window
+	if ok, err := s.verifySecondFactor(user, totpAt(t, user.TOTPSecret, 1)); err != nil || !ok {
+		t.Fatalf("next step's code = %v, %v; want accepted", ok, err)
+	}
+	if ok, err := s.verifySecondFactor(user, totpAt(t, user.TOTPSecret, 0)); err != nil || ok {
+		t.Fatalf("earlier step's code = %v, %v; want rejected", ok, err)
+	}
+}
+
+func TestVerifySecondFactorWrongCode(t *testing.T) {
+	s, user := newTOTPUser(t)
+
+	if ok, err := s.verifySecondFactor(user, totpAt(t, user.TOTPSecret, 5)); err != nil || ok {
+		t.Fatalf("code five steps ahead = %v, %v; want rejected", ok, err)
+	}
+	// A rejected code doesn't use up its step
+	if ok, err := s.verifySecondFactor(user, totpAt(t, user.TOTPSecret, 0)); err != nil || !ok {
+		t.Fatalf("current code = %v, %v; want accepted", ok, err)
+	}
+}
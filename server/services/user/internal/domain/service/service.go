@@ -3,8 +3,9 @@ package service
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
-	"user/internal/data/repository"
+	"time"
 	"user/internal/domain/model"
 	"user/internal/infra/auth"
 )
@@ -19,13 +20,61 @@ var (
 	ErrPasswordMismatch = errors.New("current password is incorrect")
 )
 
+// UserRepository stores users, their sessions and sign-in state;
+// the Postgres one lives in data/repository.
+type UserRepository interface {
+	Create(user *model.User) (*model.User, error)
+	GetByID(id int) (*model.User, error)
+	GetAll() (*[]model.User, error)
+	GetUserByUsername(login string) (*model.User, error)
+	GetUserByEmail(email string) (*model.User, error)
+	Update(user *model.User) (*model.User, error)
+
+	CreateSession(s *model.Session) error
+	GetSessionByID(id string) (*model.Session, error)
+	GetSessionByRefreshHash(hash string) (*model.Session, error)
+	GetSessionByPreviousHash(hash string) (*model.Session, error)
+	RotateSession(id, oldHash, newHash string, lastUsed, expires time.Time) (bool, error)
+	GetActiveSessions(userID int, now time.Time) ([]model.Session, error)
+	RevokeSession(id string, at time.Time) error
+	RevokeUserSessions(userID int, keep string, at time.Time) (int64, error)
+	GetRevokedSessionIDs(since time.Time) ([]model.Session, error)
+
+	AdvanceTOTPStep(userID int, step int64) (bool, error)
+	ReplaceRecoveryCodes(userID int, hashes []string) error
+	UseRecoveryCode(userID int, hash string, at time.Time) (bool, error)
+	CountUnusedRecoveryCodes(userID int) (int64, error)
+	CreateLoginChallenge(c *model.LoginChallenge) error
+	GetLoginChallenge(id string) (*model.LoginChallenge, error)
+	CountChallengeAttempt(id string, maxAttempts int) (bool, error)
+	DeleteLoginChallenge(id string) (bool, error)
+	DeleteExpiredLoginChallenges(now time.Time) error
+
+	CreateAuthEvent(e *model.AuthEvent) error
+	GetAuthEvents(userID, limit int) ([]model.AuthEvent, error)
+	GetLoginThrottles(keys []string) ([]model.LoginThrottle, error)
+	AddLoginFailure(key string, at, windowStart time.Time) (int, error)
+	BlockLogin(key string, until time.Time) error
+	ClearLoginThrottle(key string) error
+}
+
 type UserService struct {
-	repo *repository.UserRepository
+	repo     UserRepository
+	sessions SessionConfig
+	mfa      MFAConfig
+	lockout  LockoutConfig
+	mailer   Mailer
+	email    EmailConfig
+	log      *slog.Logger
 }
 
-func New(repo *repository.UserRepository) *UserService {
+func New(repo UserRepository, sessions SessionConfig, mfa MFAConfig, lockout LockoutConfig, log *slog.Logger) *UserService {
 	return &UserService{
-		repo: repo,
+		repo:     repo,
+		sessions: sessions,
+		mfa:      mfa,
+		lockout:  lockout,
+		log:      log,
 	}
 }
 
@@ -50,8 +99,20 @@ func (s *UserService) RegisterUser(user *model.User) (*model.User, error) {
 		return nil, fmt.Errorf("%s: %w", tag, err)
 	}
 	user.Password = hashedPassword
+	// Both are earned later, not taken from the sign-up form
+	user.EmailVerified = false
+	user.TOTPEnabled = false
 
-	return s.repo.Create(user)
+	created, err := s.repo.Create(user)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	if created.Email != "" {
+		if err := s.sendVerification(created); err != nil {
+			s.logError("failed to send verification email", created.ID, err)
+		}
+	}
+	return created, nil
 }
 
 func (s *UserService) GetUsers() (*[]model.User, error) {
@@ -66,24 +127,51 @@ func (s *UserService) GetUsers() (*[]model.User, error) {
 	return users, nil
 }
 
-func (s *UserService) Login(username string, password string) (*model.User, string, error) {
+// Login checks the password and starts a session. For users with 2FA on it
+// returns a challenge instead, to be redeemed with CompleteLogin. Repeated
+// failures for a username or client address block further attempts for a
+// while with a *ThrottleError.
+func (s *UserService) Login(username string, password string, client ClientInfo) (*model.User, *TokenPair, *Challenge, error) {
 	const tag = "service.Login"
 
+	now := time.Now()
+	if err := s.checkLoginThrottle(username, client.IP, now); err != nil {
+		s.recordAuthEvent(model.AuthEventLoginBlocked, nil, username, client)
+		return nil, nil, nil, fmt.Errorf("%s: %w", tag, err)
+	}
+
 	user, err := s.repo.GetUserByUsername(username)
 	if err != nil || user == nil {
-		return nil, "", fmt.Errorf("%s: %s", tag, "Неверные данные")
+		s.recordLoginFailure(username, client.IP, now)
+		s.recordAuthEvent(model.AuthEventLoginFailed, nil, username, client)
+		return nil, nil, nil, fmt.Errorf("%s: %s", tag, "Неверные данные")
 	}
 
 	if !auth.CheckPasswordHash(password, user.Password) {
-		return nil, "", fmt.Errorf("%s: %s", tag, "Неверные данные")
+		s.recordLoginFailure(username, client.IP, now)
+		s.recordAuthEvent(model.AuthEventLoginFailed, &user.ID, username, client)
+		return nil, nil, nil, fmt.Errorf("%s: %s", tag, "Неверные данные")
+	}
+
+	if user.TOTPEnabled {
+		// Failures are cleared only once the code is right too, or each
+		// password entry would reset the count of wrong codes
+		challenge, err := s.startChallenge(user.ID)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%s: %w", tag, err)
+		}
+		s.recordAuthEvent(model.AuthEventMFAChallenged, &user.ID, username, client)
+		return user, nil, challenge, nil
 	}
 
-	token, err := auth.GenerateToken(user.ID)
+	tokens, err := s.StartSession(user.ID, client)
 	if err != nil {
-		return nil, "", fmt.Errorf("%s: %s", tag, "Ошибка генерации токена")
+		return nil, nil, nil, fmt.Errorf("%s: %s", tag, "Ошибка генерации токена")
 	}
+	s.clearLoginFailures(username)
+	s.recordAuthEvent(model.AuthEventLoginSucceeded, &user.ID, username, client)
 
-	return user, token, nil
+	return user, tokens, nil, nil
 }
 
 func (s *UserService) Me(userID int) (*model.User, error) {
@@ -112,7 +200,8 @@ func (s *UserService) UpdateProfile(userID int, username, email string) (*model.
 		user.Username = username
 	}
 
-	if email != "" && email != user.Email {
+	emailChanged := email != "" && email != user.Email
+	if emailChanged {
 		if !isValidEmail(email) {
 			return nil, fmt.Errorf("%s: %w", tag, ErrInvalidEmail)
 		}
@@ -120,12 +209,23 @@ func (s *UserService) UpdateProfile(userID int, username, email string) (*model.
 			return nil, fmt.Errorf("%s: %w", tag, ErrEmailTaken)
 		}
 		user.Email = email
+		user.EmailVerified = false
 	}
 
-	return s.repo.Update(user)
+	updated, err := s.repo.Update(user)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	if emailChanged {
+		if err := s.sendVerification(updated); err != nil {
+			s.logError("failed to send verification email", updated.ID, err)
+		}
+	}
+	return updated, nil
 }
 
-func (s *UserService) UpdatePassword(userID int, currentPassword, newPassword string) error {
+// UpdatePassword changes the password and signs out every other session.
+func (s *UserService) UpdatePassword(userID int, sessionID, currentPassword, newPassword string) error {
 	const tag = "service.UpdatePassword"
 
 	user, err := s.repo.GetByID(userID)
@@ -151,10 +251,17 @@ func (s *UserService) UpdatePassword(userID int, currentPassword, newPassword st
 		return fmt.Errorf("%s: %w", tag, err)
 	}
 
+	if _, err := s.RevokeOtherSessions(userID, sessionID); err != nil {
+		return fmt.Errorf("%s: %w", tag, err)
+	}
+
 	return nil
 }
 
 func isValidEmail(email string) bool {
+	if strings.ContainsAny(email, " \t\r\n") {
+		return false
+	}
 	at := strings.Index(email, "@")
 	if at <= 0 || at == len(email)-1 {
 		return false
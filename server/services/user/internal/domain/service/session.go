@@ -0,0 +1,191 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+	"user/internal/domain/model"
+	"user/internal/infra/auth"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, session revoked")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+// SessionConfig sets how long access tokens and idle sessions last.
+type SessionConfig struct {
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// ClientInfo describes the device a session is started from.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// TokenPair is what a client holds for one session. The refresh token is
+// shown only here; the server keeps its hash.
+type TokenPair struct {
+	AccessToken  string    `json:"token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token"`
+	SessionID    string    `json:"session_id"`
+}
+
+// RevokedSession is a session ended while its access tokens may still be
+// unexpired.
+type RevokedSession struct {
+	SessionID string    `json:"session_id"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+// StartSession opens a session for the user and issues its first tokens.
+func (s *UserService) StartSession(userID int, client ClientInfo) (*TokenPair, error) {
+	const tag = "service.StartSession"
+
+	now := time.Now()
+	refresh := auth.RandomToken(32)
+	session := &model.Session{
+		ID:               auth.RandomID(),
+		UserID:           userID,
+		RefreshTokenHash: hashToken(refresh),
+		UserAgent:        truncate(client.UserAgent, 255),
+		IP:               client.IP,
+		CreatedAt:        now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(s.sessions.RefreshTTL),
+	}
+	if err := s.repo.CreateSession(session); err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+
+	access, expiresAt, err := auth.GenerateToken(userID, session.ID, s.sessions.AccessTTL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	return &TokenPair{AccessToken: access, ExpiresAt: expiresAt, RefreshToken: refresh, SessionID: session.ID}, nil
+}
+
+// Refresh trades a refresh token for new tokens and retires it. Presenting a
+// retired token means it was copied, so the whole session is revoked.
+func (s *UserService) Refresh(refreshToken string) (*TokenPair, error) {
+	const tag = "service.Refresh"
+
+	now := time.Now()
+	hash := hashToken(refreshToken)
+	session, err := s.repo.GetSessionByRefreshHash(hash)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%s: %w", tag, err)
+		}
+		if reused, err := s.repo.GetSessionByPreviousHash(hash); err == nil {
+			if err := s.repo.RevokeSession(reused.ID, now); err != nil {
+				return nil, fmt.Errorf("%s: %w", tag, err)
+			}
+			return nil, fmt.Errorf("%s: %w", tag, ErrRefreshTokenReused)
+		}
+		return nil, fmt.Errorf("%s: %w", tag, ErrInvalidRefreshToken)
+	}
+	if !session.Active(now) {
+		return nil, fmt.Errorf("%s: %w", tag, ErrInvalidRefreshToken)
+	}
+
+	refresh := auth.RandomToken(32)
+	ok, err := s.repo.RotateSession(session.ID, hash, hashToken(refresh), now, now.Add(s.sessions.RefreshTTL))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	if !ok {
+		// Another refresh with the same token won the race
+		return nil, fmt.Errorf("%s: %w", tag, ErrRefreshTokenReused)
+	}
+
+	access, expiresAt, err := auth.GenerateToken(session.UserID, session.ID, s.sessions.AccessTTL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	return &TokenPair{AccessToken: access, ExpiresAt: expiresAt, RefreshToken: refresh, SessionID: session.ID}, nil
+}
+
+// GetSessions lists the user's active sessions.
+func (s *UserService) GetSessions(userID int) ([]model.Session, error) {
+	const tag = "service.GetSessions"
+
+	sessions, err := s.repo.GetActiveSessions(userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends one of the user's sessions.
+func (s *UserService) RevokeSession(userID int, sessionID string) error {
+	const tag = "service.RevokeSession"
+
+	session, err := s.repo.GetSessionByID(sessionID)
+	if err != nil || session.UserID != userID {
+		return fmt.Errorf("%s: %w", tag, ErrSessionNotFound)
+	}
+	if err := s.repo.RevokeSession(sessionID, time.Now()); err != nil {
+		return fmt.Errorf("%s: %w", tag, err)
+	}
+	return nil
+}
+
+// RevokeOtherSessions ends every session of the user except keep and
+// returns how many were ended.
+func (s *UserService) RevokeOtherSessions(userID int, keep string) (int64, error) {
+	const tag = "service.RevokeOtherSessions"
+
+	n, err := s.repo.RevokeUserSessions(userID, keep, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", tag, err)
+	}
+	return n, nil
+}
+
+// IsSessionRevoked implements auth.RevocationChecker. Unknown sessions
+// count as revoked; lookup errors don't, so a database hiccup doesn't sign
+// everyone out.
+func (s *UserService) IsSessionRevoked(sessionID string) bool {
+	session, err := s.repo.GetSessionByID(sessionID)
+	if err != nil {
+		return errors.Is(err, gorm.ErrRecordNotFound)
+	}
+	return session.RevokedAt != nil
+}
+
+// GetRecentRevocations lists sessions revoked within the access token
+// lifetime; older revocations have no unexpired tokens left to reject.
+func (s *UserService) GetRecentRevocations() ([]RevokedSession, error) {
+	const tag = "service.GetRecentRevocations"
+
+	sessions, err := s.repo.GetRevokedSessionIDs(time.Now().Add(-s.sessions.AccessTTL))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	revoked := make([]RevokedSession, len(sessions))
+	for i, session := range sessions {
+		revoked[i] = RevokedSession{SessionID: session.ID, RevokedAt: *session.RevokedAt}
+	}
+	return revoked, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
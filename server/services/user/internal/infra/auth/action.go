@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Purposes of action tokens, the signed links sent by email. Each is its own
+// audience, so an action token is never accepted as an access token or for
+// another action.
+const (
+	PurposeVerifyEmail   = "verify-email"
+	PurposeResetPassword = "reset-password"
+)
+
+// ActionClaims are what an action token says. Binding ties the token to the
+// state it was issued for, such as the email address being verified, so
+// that once the state changes the token stops working.
+type ActionClaims struct {
+	UserID  int
+	Binding string
+}
+
+// GenerateActionToken signs a token for one purpose that expires after ttl.
+func GenerateActionToken(purpose string, sub int, binding string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": sub,
+		"bnd": binding,
+		"iss": issuer,
+		"aud": audience + ":" + purpose,
+		"jti": RandomID(),
+		"iat": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+	}
+
+	token := jwt.NewWithClaims(keys.active.method, claims)
+	token.Header["kid"] = keys.active.kid
+	return token.SignedString(keys.active.private)
+}
+
+// ParseActionToken verifies a token issued for purpose.
+func ParseActionToken(tokenString, purpose string) (*ActionClaims, error) {
+	token, err := jwt.Parse(tokenString, keys.verificationKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audience+":"+purpose),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	sub, ok := claims["sub"].(float64)
+	if !ok {
+		return nil, errors.New("invalid token")
+	}
+	binding, _ := claims["bnd"].(string)
+	return &ActionClaims{UserID: int(sub), Binding: binding}, nil
+}
@@ -1,77 +1,129 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
-	"log"
-	"os"
-	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 var (
-	jwtKey     []byte
-	jwtKeyOnce sync.Once
+	keys     *KeySet
+	issuer   string
+	audience string
 )
 
-func getJWTKey() []byte {
-	jwtKeyOnce.Do(func() {
-		secret := os.Getenv("JWT_SECRET")
-		if secret == "" {
-			log.Fatal("JWT_SECRET environment variable is required")
-		}
-		if len(secret) < 32 {
-			log.Fatal("JWT_SECRET must be at least 32 characters")
-		}
-		jwtKey = []byte(secret)
-	})
-	return jwtKey
+// UseKeySet sets the keys tokens are signed and verified with, and the
+// issuer and audience written into and required of every token.
+func UseKeySet(ks *KeySet, iss, aud string) {
+	keys, issuer, audience = ks, iss, aud
+}
+
+// PublicKeys returns the JWKS other services verify tokens against.
+func PublicKeys() JWKSet {
+	return keys.JWKS()
+}
+
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// RevocationChecker reports whether a session has been ended, so access
+// tokens issued for it are no longer accepted.
+type RevocationChecker interface {
+	IsSessionRevoked(sessionID string) bool
+}
+
+var revocations RevocationChecker
+
+// UseRevocationChecker makes ParseToken reject tokens of revoked sessions.
+func UseRevocationChecker(c RevocationChecker) {
+	revocations = c
+}
+
+// Claims are what an access token says about its bearer. SessionID is empty
+// for tokens issued before sessions existed.
+type Claims struct {
+	UserID    int
+	SessionID string
 }
 
-func GenerateToken(sub int) (string, error) {
+// GenerateToken issues an access token for a session that expires after ttl.
+func GenerateToken(sub int, sessionID string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
 	claims := jwt.MapClaims{
 		"sub": sub,
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
+		"sid": sessionID,
+		"iss": issuer,
+		"aud": audience,
+		"jti": RandomID(),
+		"iat": now.Unix(),
+		"exp": expiresAt.Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(getJWTKey())
+	token := jwt.NewWithClaims(keys.active.method, claims)
+	token.Header["kid"] = keys.active.kid
+	tokenString, err := token.SignedString(keys.active.private)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 
-	return tokenString, nil
+	return tokenString, expiresAt, nil
 }
 
-func ParseToken(tokenString string) (int, error) {
+func ParseToken(tokenString string) (*Claims, error) {
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (
-		interface{},
-		error,
-	) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return getJWTKey(), nil
-	})
+	token, err := jwt.Parse(tokenString, keys.verificationKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audience),
+	)
 
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID := int(claims["sub"].(float64))
-		expiration := claims["exp"].(float64)
+		sub, okSub := claims["sub"].(float64)
+		expiration, okExp := claims["exp"].(float64)
+		if !okSub || !okExp {
+			return nil, errors.New("token has no numeric sub or exp")
+		}
+		userID := int(sub)
 
 		// Проверка срока действия токена
 		expirationTime := time.Unix(int64(expiration), 0)
 		if time.Now().After(expirationTime) {
-			return 0, errors.New("token has expired")
+			return nil, errors.New("token has expired")
+		}
+
+		sessionID, _ := claims["sid"].(string)
+		if sessionID != "" && revocations != nil && revocations.IsSessionRevoked(sessionID) {
+			return nil, ErrTokenRevoked
 		}
 
-		return userID, nil
+		return &Claims{UserID: userID, SessionID: sessionID}, nil
 	}
 
-	return 0, errors.New("invalid token")
+	return nil, errors.New("invalid token")
+}
+
+// RandomID returns 16 random bytes, hex encoded.
+func RandomID() string {
+	return RandomToken(16)
+}
+
+// RandomToken returns n random bytes, hex encoded.
+func RandomToken(n int) string {
+	return hex.EncodeToString(RandomBytes(n))
+}
+
+// RandomBytes returns n bytes from the system's secure random source.
+func RandomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
 }
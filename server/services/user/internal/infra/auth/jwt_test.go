@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestParseTokenClaims(t *testing.T) {
+	ks, err := GenerateKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	UseKeySet(ks, "bux-test", "bux-test")
+
+	token, _, err := GenerateToken(42, "s1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err := ParseToken(token); err != nil || claims.UserID != 42 || claims.SessionID != "s1" {
+		t.Fatalf("ParseToken = %+v, %v; want user 42 in session s1", claims, err)
+	}
+
+	sign := func(claims jwt.MapClaims) string {
+		claims["iss"], claims["aud"] = "bux-test", "bux-test"
+		token := jwt.NewWithClaims(ks.active.method, claims)
+		token.Header["kid"] = ks.active.kid
+		s, err := token.SignedString(ks.active.private)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+	for name, claims := range map[string]jwt.MapClaims{
+		"no sub":     {"exp": exp},
+		"string sub": {"sub": "42", "exp": exp},
+		"no exp":     {"sub": 42},
+		"expired":    {"sub": 42, "exp": time.Now().Add(-time.Hour).Unix()},
+	} {
+		if _, err := ParseToken(sign(claims)); err == nil {
+			t.Errorf("%s: token accepted", name)
+		}
+	}
+}
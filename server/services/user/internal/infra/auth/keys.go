@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingKey is one private key of the key set. Its algorithm follows from
+// the key type: RS256 for RSA, EdDSA for Ed25519.
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+}
+
+// KeySet holds the keys tokens are signed with. Only the active key signs;
+// the others still verify, so tokens signed before a rotation stay valid
+// until they expire.
+type KeySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+// LoadKeySet reads every <kid>.pem private key in dir, PKCS#8 or PKCS#1,
+// and signs with activeKID. With a single key activeKID may be empty.
+func LoadKeySet(dir, activeKID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no *.pem keys in %s", dir)
+	}
+
+	ks := &KeySet{keys: map[string]*signingKey{}}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := parsePrivateKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		ks.keys[kid] = key
+	}
+
+	if activeKID == "" && len(ks.keys) == 1 {
+		for kid := range ks.keys {
+			activeKID = kid
+		}
+	}
+	active, ok := ks.keys[activeKID]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found in %s", activeKID, dir)
+	}
+	ks.active = active
+	return ks, nil
+}
+
+// GenerateKeySet makes a key set with one new Ed25519 key. Tokens it signs
+// don't survive a restart; it is meant for local runs without configured
+// keys.
+func GenerateKeySet() (*KeySet, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	key := &signingKey{kid: RandomID(), method: jwt.SigningMethodEdDSA, private: private}
+	return &KeySet{active: key, keys: map[string]*signingKey{key.kid: key}}, nil
+}
+
+func parsePrivateKey(kid string, data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("not a PEM file")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return &signingKey{kid: kid, method: jwt.SigningMethodRS256, private: k}, nil
+	case ed25519.PrivateKey:
+		return &signingKey{kid: kid, method: jwt.SigningMethodEdDSA, private: k}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", parsed)
+	}
+}
+
+// verificationKey returns the public key for a token's kid and algorithm.
+func (ks *KeySet) verificationKey(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.private.Public(), nil
+}
+
+// JWK is a public key in JSON Web Key form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS publishes the public half of every key, ordered by kid.
+func (ks *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(ks.keys))}
+	for _, key := range ks.keys {
+		jwk := JWK{Kid: key.kid, Use: "sig", Alg: key.method.Alg()}
+		switch pub := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+// ActiveKID names the key new tokens are signed with.
+func (ks *KeySet) ActiveKID() string {
+	return ks.active.kid
+}
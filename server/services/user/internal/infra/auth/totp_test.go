@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of RFC 6238 Appendix B, "12345678901234567890",
+// in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTPRFCVectors(t *testing.T) {
+	// The RFC gives eight digits; six-digit codes are their last six
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		step, ok := ValidateTOTP(rfcSecret, tt.code, time.Unix(tt.unix, 0))
+		if !ok || step != tt.unix/totpPeriod {
+			t.Errorf("T=%d: ValidateTOTP(%s) = %d, %v; want step %d", tt.unix, tt.code, step, ok, tt.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTOTPWindow(t *testing.T) {
+	// 287082 is the code of step 1, the 30 seconds from T=30
+	const code = "287082"
+	tests := []struct {
+		unix int64
+		ok   bool
+	}{
+		{0, true},   // one step early
+		{30, true},  // its own step
+		{59, true},  // the end of its own step
+		{60, true},  // one step late
+		{89, true},  // the end of the step after
+		{90, false}, // two steps late
+	}
+	for _, tt := range tests {
+		step, ok := ValidateTOTP(rfcSecret, code, time.Unix(tt.unix, 0))
+		if ok != tt.ok || (ok && step != 1) {
+			t.Errorf("T=%d: ValidateTOTP = %d, %v; want %v for step 1", tt.unix, step, ok, tt.ok)
+		}
+	}
+
+	// Step 3's code is two steps ahead of T=30
+	if _, ok := ValidateTOTP(rfcSecret, totpCode(mustDecodeTOTP(t, rfcSecret), 3), time.Unix(30, 0)); ok {
+		t.Error("a code two steps ahead was accepted")
+	}
+}
+
+func TestValidateTOTPInput(t *testing.T) {
+	now := time.Unix(59, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		ok     bool
+	}{
+		{"lower-case secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "287082", true},
+		{"wrong code", rfcSecret, "287083", false},
+		{"eight digits", rfcSecret, "94287082", false},
+		{"too short", rfcSecret, "28708", false},
+		{"empty", rfcSecret, "", false},
+		{"invalid secret", "not base32!", "287082", false},
+	}
+	for _, tt := range tests {
+		if _, ok := ValidateTOTP(tt.secret, tt.code, now); ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+		}
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret := GenerateTOTPSecret()
+	key := mustDecodeTOTP(t, secret)
+	if len(key) != 20 {
+		t.Fatalf("secret of %d bytes, want 20", len(key))
+	}
+	now := time.Now()
+	if _, ok := ValidateTOTP(secret, totpCode(key, now.Unix()/totpPeriod), now); !ok {
+		t.Fatal("the current code of a new secret was rejected")
+	}
+}
+
+func mustDecodeTOTP(t *testing.T, secret string) []byte {
+	t.Helper()
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
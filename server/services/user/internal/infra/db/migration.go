@@ -7,7 +7,7 @@ import (
 )
 
 func Migrate(db *gorm.DB) error {
-	err := db.AutoMigrate(&model.User{})
+	err := db.AutoMigrate(&model.User{}, &model.Session{}, &model.RecoveryCode{}, &model.LoginChallenge{}, &model.AuthEvent{}, &model.LoginThrottle{})
 
 	if err != nil {
 		return err
@@ -0,0 +1,42 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer saves each email as an .eml file in a directory, named so the
+// files sort by time. It stands in for a mail server in tests and staging.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{dir: dir, from: from}
+}
+
+func (m *FileMailer) Send(ctx context.Context, to, subject, body string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return fmt.Errorf("create mail dir: %w", err)
+	}
+
+	now := time.Now()
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := filepath.Join(m.dir, fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix)))
+	if err := os.WriteFile(name, buildMessage(m.from, to, subject, body, now), 0o644); err != nil {
+		return fmt.Errorf("write email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package mail
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogMailer writes emails to the service log instead of sending them. It
+// never fails. Meant for local runs: links in the body are usable straight
+// from the log.
+type LogMailer struct {
+	log *slog.Logger
+}
+
+func NewLogMailer(log *slog.Logger) *LogMailer {
+	return &LogMailer{log: log}
+}
+
+func (m *LogMailer) Send(_ context.Context, to, subject, body string) error {
+	m.log.Info("email",
+		slog.String("to", to),
+		slog.String("subject", subject),
+		slog.String("body", body),
+	)
+	return nil
+}
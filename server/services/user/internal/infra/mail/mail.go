@@ -0,0 +1,29 @@
+// Package mail sends account emails. Every mailer implements service.Mailer.
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// headerValue drops line breaks so a value can't add headers of its own.
+var headerValue = strings.NewReplacer("\r", "", "\n", "")
+
+// buildMessage renders a plain-text UTF-8 email with its headers.
+func buildMessage(from, to, subject, body string, date time.Time) []byte {
+	from, to, subject = headerValue.Replace(from), headerValue.Replace(to), headerValue.Replace(subject)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(body)
+	return b.Bytes()
+}
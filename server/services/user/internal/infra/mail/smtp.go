@@ -0,0 +1,90 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+const smtpTimeout = 30 * time.Second
+
+// SMTPMailer sends through an SMTP server, upgrading to TLS with STARTTLS
+// when the server offers it. Credentials are only sent over TLS.
+type SMTPMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{host: host, port: port, username: username, password: password, from: from}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, to, subject, body string) error {
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("dial smtp: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return fmt.Errorf("smtp hello: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if m.username != "" {
+		// PlainAuth refuses to send credentials over an unencrypted
+		// connection to anything but localhost
+		if err := c.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	if err := c.Mail(envelopeAddress(m.from)); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	if err := c.Rcpt(to); err != nil {
+		return fmt.Errorf("smtp rcpt to: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(buildMessage(m.from, to, subject, body, time.Now())); err != nil {
+		return fmt.Errorf("smtp write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	return c.Quit()
+}
+
+// envelopeAddress takes the bare address out of a From header value such
+// as "Bux <no-reply@example.com>".
+func envelopeAddress(from string) string {
+	if addr, err := netmail.ParseAddress(from); err == nil {
+		return addr.Address
+	}
+	return from
+}
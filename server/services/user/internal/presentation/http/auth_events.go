@@ -0,0 +1,41 @@
+package http
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"user/internal/domain/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// throttled answers 429 with Retry-After if err is a sign-in refused for
+// too many failures, reporting whether it did.
+func throttled(ctx *gin.Context, err error) bool {
+	var te *service.ThrottleError
+	if !errors.As(err, &te) {
+		return false
+	}
+	ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(te.RetryAfter.Seconds()))))
+	ctx.JSON(http.StatusTooManyRequests, gin.H{"error": te.Error()})
+	return true
+}
+
+// AuthEvents lists the signed-in user's recent sign-in activity.
+func (h *UserHTTP) AuthEvents(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден в контексте"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(ctx.Query("limit"))
+	events, err := h.service.GetAuthEvents(userID.(int), limit)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, events)
+}
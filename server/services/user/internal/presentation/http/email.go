@@ -0,0 +1,91 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"user/internal/domain/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *UserHTTP) VerifyEmail(ctx *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.VerifyEmail(req.Token); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidLink) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// ResendVerification emails the signed-in user a new verification link.
+func (h *UserHTTP) ResendVerification(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден в контексте"})
+		return
+	}
+
+	if err := h.service.ResendVerification(userID.(int)); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrNoEmail):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrEmailAlreadyVerified):
+			status = http.StatusConflict
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusAccepted)
+}
+
+// ForgotPassword always answers 202, whether or not the address is known.
+func (h *UserHTTP) ForgotPassword(ctx *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.service.ForgotPassword(req.Email)
+	ctx.Status(http.StatusAccepted)
+}
+
+func (h *UserHTTP) ResetPassword(ctx *gin.Context) {
+	var req struct {
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.ResetPassword(req.Token, req.NewPassword); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidLink) || errors.Is(err, service.ErrPasswordTooShort) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -5,7 +5,6 @@ import (
 	"net/http"
 	"user/internal/domain/model"
 	"user/internal/domain/service"
-	"user/internal/infra/auth"
 	"user/internal/presentation/http/middleware"
 
 	"github.com/gin-gonic/gin"
@@ -15,15 +14,26 @@ type UserHTTP struct {
 	service *service.UserService
 }
 
-func New(r *gin.Engine, s *service.UserService) {
+// New mounts the routes. authLimit guards the endpoints that take
+// passwords, codes and emailed tokens, or create accounts.
+func New(r *gin.Engine, s *service.UserService, authLimit gin.HandlerFunc) {
 	h := &UserHTTP{
 		service: s,
 	}
 
+	r.GET("/.well-known/jwks.json", h.JWKS)
+
 	auth := r.Group("/auth")
 	{
-		auth.POST("/register", h.Register)
-		auth.POST("/login", h.Login)
+		auth.POST("/register", authLimit, h.Register)
+		auth.POST("/login", authLimit, h.Login)
+		auth.POST("/login/2fa", authLimit, h.CompleteLogin)
+		auth.POST("/refresh", h.Refresh)
+		auth.POST("/logout", middleware.AuthMiddleware(), h.Logout)
+		auth.GET("/revocations", h.Revocations)
+		auth.POST("/verify-email", authLimit, h.VerifyEmail)
+		auth.POST("/forgot-password", authLimit, h.ForgotPassword)
+		auth.POST("/reset-password", authLimit, h.ResetPassword)
 	}
 
 	users := r.Group("/users")
@@ -33,6 +43,17 @@ func New(r *gin.Engine, s *service.UserService) {
 		users.GET("/me", h.Me)
 		users.PUT("/profile", h.UpdateProfile)
 		users.PUT("/password", h.UpdatePassword)
+		users.GET("/auth-events", h.AuthEvents)
+		users.POST("/verify-email", h.ResendVerification)
+		users.GET("/sessions", h.Sessions)
+		users.DELETE("/sessions", h.RevokeOtherSessions)
+		users.DELETE("/sessions/:id", h.RevokeSession)
+		users.GET("/2fa", h.MFAStatus)
+		users.POST("/2fa/enroll", h.EnrollTOTP)
+		users.POST("/2fa/confirm", h.ConfirmTOTP)
+		users.POST("/2fa/reset", h.ResetTOTP)
+		users.POST("/2fa/disable", h.DisableTOTP)
+		users.POST("/2fa/recovery-codes", h.RegenerateRecoveryCodes)
 	}
 }
 
@@ -64,16 +85,13 @@ func (h *UserHTTP) Register(ctx *gin.Context) {
 		return
 	}
 
-	token, err := auth.GenerateToken(registeredUser.ID)
+	tokens, err := h.service.StartSession(registeredUser.ID, clientInfo(ctx))
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, gin.H{
-		"user":  registeredUser,
-		"token": token,
-	})
+	ctx.JSON(http.StatusCreated, tokenResponse(registeredUser, tokens))
 }
 
 func (h *UserHTTP) Login(ctx *gin.Context) {
@@ -87,16 +105,25 @@ func (h *UserHTTP) Login(ctx *gin.Context) {
 		return
 	}
 
-	user, token, err := h.service.Login(UserCredentials.Username, UserCredentials.Password)
+	user, tokens, challenge, err := h.service.Login(UserCredentials.Username, UserCredentials.Password, clientInfo(ctx))
+	if throttled(ctx, err) {
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"user":  user,
-		"token": token,
-	})
+	if challenge != nil {
+		ctx.JSON(http.StatusOK, gin.H{
+			"mfa_required":    true,
+			"challenge_token": challenge.Token,
+			"expires_at":      challenge.ExpiresAt,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tokenResponse(user, tokens))
 }
 
 func (h *UserHTTP) Me(ctx *gin.Context) {
@@ -171,7 +198,8 @@ func (h *UserHTTP) UpdatePassword(ctx *gin.Context) {
 		return
 	}
 
-	if err := h.service.UpdatePassword(userID.(int), req.CurrentPassword, req.NewPassword); err != nil {
+	sessionID := ctx.GetString("sessionID")
+	if err := h.service.UpdatePassword(userID.(int), sessionID, req.CurrentPassword, req.NewPassword); err != nil {
 		status := http.StatusBadRequest
 		switch {
 		case errors.Is(err, service.ErrUserNotFound):
@@ -0,0 +1,177 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"user/internal/domain/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mfaStatus maps 2FA errors to response codes.
+func mfaStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrPasswordMismatch), errors.Is(err, service.ErrInvalidOTP),
+		errors.Is(err, service.ErrInvalidChallenge):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrTOTPAlreadyEnabled), errors.Is(err, service.ErrTOTPNotEnabled),
+		errors.Is(err, service.ErrNoPendingTOTP):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CompleteLogin is the second login step for users with 2FA on.
+func (h *UserHTTP) CompleteLogin(ctx *gin.Context) {
+	var req struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+		Code           string `json:"code" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, tokens, err := h.service.CompleteLogin(req.ChallengeToken, req.Code, clientInfo(ctx))
+	if throttled(ctx, err) {
+		return
+	}
+	if err != nil {
+		ctx.JSON(mfaStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tokenResponse(user, tokens))
+}
+
+func (h *UserHTTP) MFAStatus(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден в контексте"})
+		return
+	}
+
+	status, err := h.service.GetMFAStatus(userID.(int))
+	if err != nil {
+		ctx.JSON(mfaStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, status)
+}
+
+// EnrollTOTP returns a secret and its otpauth:// URI for the client to show
+// as a QR code.
+func (h *UserHTTP) EnrollTOTP(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден в контексте"})
+		return
+	}
+
+	enrollment, err := h.service.EnrollTOTP(userID.(int))
+	if err != nil {
+		ctx.JSON(mfaStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollment)
+}
+
+func (h *UserHTTP) ConfirmTOTP(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден в контексте"})
+		return
+	}
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.service.ConfirmTOTP(userID.(int), ctx.GetString("sessionID"), req.Code)
+	if err != nil {
+		ctx.JSON(mfaStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func (h *UserHTTP) ResetTOTP(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден в контексте"})
+		return
+	}
+
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	enrollment, err := h.service.ResetTOTP(userID.(int), req.Password)
+	if err != nil {
+		ctx.JSON(mfaStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollment)
+}
+
+func (h *UserHTTP) DisableTOTP(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден в контексте"})
+		return
+	}
+
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.DisableTOTP(userID.(int), req.Password); err != nil {
+		ctx.JSON(mfaStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *UserHTTP) RegenerateRecoveryCodes(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден в контексте"})
+		return
+	}
+
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(userID.(int), req.Password)
+	if err != nil {
+		ctx.JSON(mfaStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
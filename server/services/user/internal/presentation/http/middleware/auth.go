@@ -1,9 +1,9 @@
 package middleware
 
 import (
-	"user/internal/infra/auth"
 	"net/http"
 	"strings"
+	"user/internal/infra/auth"
 
 	"github.com/gin-gonic/gin"
 )
@@ -26,14 +26,15 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		token := parts[1]
 
-		userID, err := auth.ParseToken(token)
+		claims, err := auth.ParseToken(token)
 		if err != nil {
 			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			ctx.Abort()
 			return
 		}
 
-		ctx.Set("userID", userID)
+		ctx.Set("userID", claims.UserID)
+		ctx.Set("sessionID", claims.SessionID)
 		ctx.Next()
 	}
 }
@@ -0,0 +1,157 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"user/internal/domain/model"
+	"user/internal/domain/service"
+	"user/internal/infra/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func clientInfo(ctx *gin.Context) service.ClientInfo {
+	return service.ClientInfo{
+		UserAgent: ctx.Request.UserAgent(),
+		IP:        ctx.ClientIP(),
+	}
+}
+
+// tokenResponse keeps the "user" and "token" fields login always returned
+// and adds the session's refresh token.
+func tokenResponse(user *model.User, tokens *service.TokenPair) gin.H {
+	return gin.H{
+		"user":          user,
+		"token":         tokens.AccessToken,
+		"expires_at":    tokens.ExpiresAt,
+		"refresh_token": tokens.RefreshToken,
+		"session_id":    tokens.SessionID,
+	}
+}
+
+func (h *UserHTTP) Refresh(ctx *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.service.Refresh(req.RefreshToken)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+			status = http.StatusUnauthorized
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tokens)
+}
+
+// Logout ends the session the access token belongs to.
+func (h *UserHTTP) Logout(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден в контексте"})
+		return
+	}
+	sessionID := ctx.GetString("sessionID")
+	if sessionID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "token has no session, sign in again"})
+		return
+	}
+
+	if err := h.service.RevokeSession(userID.(int), sessionID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// Revocations lists sessions revoked recently enough that their access
+// tokens may not have expired. The other services poll it to reject them.
+func (h *UserHTTP) Revocations(ctx *gin.Context) {
+	revoked, err := h.service.GetRecentRevocations()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
+
+// JWKS publishes the public keys access tokens are verified with.
+func (h *UserHTTP) JWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, auth.PublicKeys())
+}
+
+func (h *UserHTTP) Sessions(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден в контексте"})
+		return
+	}
+
+	sessions, err := h.service.GetSessions(userID.(int))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	current := ctx.GetString("sessionID")
+	type sessionResponse struct {
+		model.Session
+		Current bool `json:"current"`
+	}
+	resp := make([]sessionResponse, len(sessions))
+	for i, session := range sessions {
+		resp[i] = sessionResponse{Session: session, Current: session.ID == current}
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// RevokeOtherSessions signs out every device but the one making the request.
+func (h *UserHTTP) RevokeOtherSessions(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден в контексте"})
+		return
+	}
+
+	revoked, err := h.service.RevokeOtherSessions(userID.(int), ctx.GetString("sessionID"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
+
+func (h *UserHTTP) RevokeSession(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден в контексте"})
+		return
+	}
+
+	if err := h.service.RevokeSession(userID.(int), ctx.Param("id")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -13,6 +13,12 @@ type Config struct {
 	HTTPServer `yaml:"http_server"`
 	Postgres   `yaml:"postgres"`
 	RMQ        `yaml:"rmq"`
+	Session    `yaml:"session"`
+	JWT        `yaml:"jwt"`
+	MFA        `yaml:"mfa"`
+	Mail       `yaml:"mail"`
+	Lockout    `yaml:"lockout"`
+	RateLimit  `yaml:"rate_limit"`
 }
 
 type HTTPServer struct {
@@ -34,6 +40,72 @@ type RMQ struct {
 	URL string `yaml:"url"`
 }
 
+// Session sets how long access tokens live and how long a session may sit
+// unused before its refresh token expires.
+type Session struct {
+	AccessTTL  time.Duration `yaml:"access_ttl" env:"SESSION_ACCESS_TTL" env-default:"15m"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl" env:"SESSION_REFRESH_TTL" env-default:"720h"`
+}
+
+// JWT locates the token signing keys: <kid>.pem files in KeysDir, signing
+// with ActiveKID. Without KeysDir a key is generated at startup, which only
+// suits local runs since tokens then don't survive a restart.
+type JWT struct {
+	KeysDir   string `yaml:"keys_dir" env:"JWT_KEYS_DIR"`
+	ActiveKID string `yaml:"active_kid" env:"JWT_ACTIVE_KID"`
+	Issuer    string `yaml:"issuer" env:"JWT_ISSUER" env-default:"bux-user"`
+	Audience  string `yaml:"audience" env:"JWT_AUDIENCE" env-default:"bux"`
+}
+
+// MFA configures TOTP two-factor login: the issuer authenticator apps list
+// the account under, and how long the code step of a login may take.
+type MFA struct {
+	Issuer       string        `yaml:"issuer" env:"MFA_ISSUER" env-default:"Bux"`
+	ChallengeTTL time.Duration `yaml:"challenge_ttl" env:"MFA_CHALLENGE_TTL" env-default:"5m"`
+}
+
+// Mail selects how account emails go out: "log" writes them to the service
+// log, "file" saves .eml files in Dir, "smtp" sends through SMTPHost. Links
+// in them point at AppURL, the web app's address.
+type Mail struct {
+	Type            string        `yaml:"type" env:"MAIL_TYPE" env-default:"log"`
+	From            string        `yaml:"from" env:"MAIL_FROM" env-default:"Bux <no-reply@bux.local>"`
+	Dir             string        `yaml:"dir" env:"MAIL_DIR" env-default:"./data/mail"`
+	SMTPHost        string        `yaml:"smtp_host" env:"MAIL_SMTP_HOST"`
+	SMTPPort        int           `yaml:"smtp_port" env:"MAIL_SMTP_PORT" env-default:"587"`
+	SMTPUsername    string        `yaml:"smtp_username" env:"MAIL_SMTP_USERNAME"`
+	SMTPPassword    string        `yaml:"smtp_password" env:"MAIL_SMTP_PASSWORD"`
+	AppURL          string        `yaml:"app_url" env:"APP_URL" env-default:"http://localhost:3000"`
+	VerificationTTL time.Duration `yaml:"verification_ttl" env-default:"48h"`
+	ResetTTL        time.Duration `yaml:"reset_ttl" env-default:"1h"`
+}
+
+// Lockout sets how failed sign-ins slow down further attempts; see
+// service.LockoutConfig.
+type Lockout struct {
+	FreeAttempts    int           `yaml:"free_attempts" env-default:"3"`
+	BaseDelay       time.Duration `yaml:"base_delay" env-default:"1s"`
+	MaxDelay        time.Duration `yaml:"max_delay" env-default:"5m"`
+	LockoutAfter    int           `yaml:"lockout_after" env:"LOCKOUT_AFTER" env-default:"10"`
+	LockoutDuration time.Duration `yaml:"lockout_duration" env:"LOCKOUT_DURATION" env-default:"30m"`
+	IPLockoutAfter  int           `yaml:"ip_lockout_after" env-default:"50"`
+	Window          time.Duration `yaml:"window" env-default:"1h"`
+}
+
+// RateLimit caps requests per client: Limit per Window across the API, and
+// AuthLimit per AuthWindow on the sign-up and sign-in endpoints. Store is
+// "memory", counted per instance, or "postgres", shared by all of them.
+// Client addresses are taken from X-Forwarded-For only for requests from
+// TrustedProxies.
+type RateLimit struct {
+	Store          string        `yaml:"store" env:"RATE_LIMIT_STORE" env-default:"memory"`
+	Limit          int           `yaml:"limit" env:"RATE_LIMIT_LIMIT" env-default:"300"`
+	Window         time.Duration `yaml:"window" env:"RATE_LIMIT_WINDOW" env-default:"1m"`
+	AuthLimit      int           `yaml:"auth_limit" env:"RATE_LIMIT_AUTH_LIMIT" env-default:"20"`
+	AuthWindow     time.Duration `yaml:"auth_window" env:"RATE_LIMIT_AUTH_WINDOW" env-default:"1m"`
+	TrustedProxies []string      `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+}
+
 type DBConfig struct {
 	User     string
 	Password string
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// clock is a settable time source for a MemoryStore.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestStore(start time.Time) (*MemoryStore, *clock) {
+	c := &clock{t: start}
+	s := NewMemoryStore()
+	s.now = c.now
+	s.lastSweep = start
+	return s, c
+}
+
+func hit(t *testing.T, s *MemoryStore, key string, window time.Duration) int {
+	t.Helper()
+	n, err := s.Hit(context.Background(), key, window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestMemoryStoreWindowBoundary(t *testing.T) {
+	start := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	s, c := newTestStore(start)
+
+	if n := hit(t, s, "k", time.Minute); n != 1 {
+		t.Fatalf("first hit = %d, want 1", n)
+	}
+	c.t = start.Add(time.Minute - time.Nanosecond)
+	if n := hit(t, s, "k", time.Minute); n != 2 {
+		t.Fatalf("hit at the end of the window = %d, want 2", n)
+	}
+	// Windows are fixed, not sliding: the next one starts from zero
+	c.t = start.Add(time.Minute)
+	if n := hit(t, s, "k", time.Minute); n != 1 {
+		t.Fatalf("hit at the start of the next window = %d, want 1", n)
+	}
+	c.t = start.Add(3 * time.Minute)
+	if n := hit(t, s, "k", time.Minute); n != 1 {
+		t.Fatalf("hit after idle windows = %d, want 1", n)
+	}
+}
+
+func TestMemoryStoreKeysAreIsolated(t *testing.T) {
+	s, _ := newTestStore(time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC))
+
+	for i := 1; i <= 3; i++ {
+		if n := hit(t, s, "ip:10.0.0.1", time.Minute); n != i {
+			t.Fatalf("hit %d of the first key = %d", i, n)
+		}
+	}
+	if n := hit(t, s, "ip:10.0.0.2", time.Minute); n != 1 {
+		t.Fatalf("first hit of another key = %d, want 1", n)
+	}
+	if n := hit(t, s, "ip:10.0.0.1", time.Minute); n != 4 {
+		t.Fatalf("first key after another's hit = %d, want 4", n)
+	}
+}
+
+func TestMemoryStoreSweepsEndedWindows(t *testing.T) {
+	start := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	s, c := newTestStore(start)
+
+	hit(t, s, "short", time.Minute)
+	hit(t, s, "long", time.Hour)
+
+	c.t = start.Add(memorySweepInterval + time.Second)
+	hit(t, s, "other", time.Minute)
+	if _, ok := s.buckets["short"]; ok {
+		t.Error("ended window was kept")
+	}
+	if _, ok := s.buckets["long"]; !ok {
+		t.Error("open window was swept")
+	}
+	if n := hit(t, s, "long", time.Hour); n != 2 {
+		t.Errorf("hit in the kept window = %d, want 2", n)
+	}
+}